@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/jianyun8023/bookimporter/pkg/ui"
 	"github.com/jianyun8023/bookimporter/pkg/util"
@@ -13,23 +16,63 @@ import (
 
 // CheckConfig 检测命令配置
 type CheckConfig struct {
-	Path       string // 文件或目录路径
-	Recursive  bool   // 是否递归搜索
-	OnlyErrors bool   // 只显示有问题的文件
-	MoveTo     string // 移动损坏文件到指定目录
-	Delete     bool   // 删除损坏的文件
-	Force      bool   // 删除时不需要确认
-	DoTry      bool   // 试运行模式
-	Debug      bool   // 调试模式
+	Path       string   // 文件或目录路径
+	Recursive  bool     // 是否递归搜索
+	OnlyErrors bool     // 只显示有问题的文件
+	MoveTo     string   // 移动损坏文件到指定目录
+	Delete     bool     // 删除损坏的文件
+	Force      bool     // 删除时不需要确认
+	DoTry      bool     // 试运行模式
+	Debug      bool     // 调试模式
+	Jobs       int      // 并发检测的文件数
+	Format     string   // 输出格式：text、json、ndjson
+	Deep       bool     // 完整读取每个条目，校验 CRC32
+	Repair     bool     // 修复打包不规范或编码不一致的文件
+	NoCache    bool     // 忽略扫描缓存，重新检测所有文件
+	Types      []string // 要检测的格式：epub、mobi、pdf、fb2，见 util.BookFormatNames
+
+	formats []*util.BookFormat // 由 Types 解析
+	journal *util.Journal      // 操作日志，不修改文件或试运行时为 nil
 }
 
 var checkConfig = &CheckConfig{}
 
 var checkCmd = &cobra.Command{
 	Use:   "check",
-	Short: "检测 EPUB 文件完整性",
-	Long: `检测 EPUB 文件是否损坏，包括 ZIP 结构、必需文件和元数据验证。
-可以选择将损坏的文件移动到指定目录或删除。`,
+	Short: "检测 EPUB、MOBI、AZW3、PDF、FB2 文件完整性",
+	Long: `检测电子书文件是否损坏。EPUB 检查 ZIP 结构、必需文件和元数据，
+MOBI/AZW/AZW3 检查 PalmDB 记录表、MOBI 头部、EXTH 和书名，
+记录超出文件末尾时报告文件被截断。PDF 检查文件头、%%EOF、startxref、
+交叉引用表和 /Prev 链能否读取、线性化参数是否超出文件末尾，以及文档目录和页面树。
+FB2/FB2.ZIP 检查 XML 是否格式良好、title-info 中的书名和嵌入图片的 base64 数据。
+使用 --types 只检测部分格式。
+可以选择将损坏的文件移动到指定目录或删除。
+
+同时检查 OCF/OPF 结构规范：mimetype 必须是第一个条目、不压缩且内容正确，
+container.xml 指向的 OPF 必须存在，manifest 中的文件必须存在，
+spine 只能引用 manifest 中的条目，XHTML/HTML 内容文档的实际编码必须与
+XML 声明或 <meta charset> 一致（如声明为 UTF-8 实际为 GBK 时阅读器显示乱码）。
+打包不规范或编码声明错误但内容完好的文件会标记为"可修复"。
+
+使用 --repair 自动修复这类文件：重新打包 mimetype、去除条目名的 "./" 前缀、
+根据唯一的 OPF 重新生成 container.xml、将编码不一致的内容文档转换为 UTF-8
+并更新 XML 声明和 <meta charset>。修复前原文件备份为 <文件名>.bak，
+修复后重新检测，仍未通过时从备份恢复原文件。无法修复的文件继续按
+--move-to 或 --delete 处理。
+
+默认只读取每个条目开头的数据；使用 --deep 会完整读取每个条目，
+校验 CRC32 和解压后大小，能发现条目尾部的截断或位损坏，并列出损坏的条目名。
+MOBI 文件在 --deep 下会解压全部正文记录，检查长度是否与头部一致
+（加密和 HUFF/CDIC 压缩的正文跳过）；PDF 文件在 --deep 下会读取交叉引用表中的
+每个对象；FB2 文件在 --deep 下会检查图片数据是否有效、文档内链接指向的 id 是否存在。
+--repair 只适用于 EPUB。
+
+检测结果保存在扫描缓存中（Linux 上为 ~/.cache/bookimporter/scan.db），
+再次检测时大小和修改时间未变化的文件直接使用上次的结果，移动或重命名过的文件
+按内容摘要识别。使用 --no-cache 重新检测所有文件并更新缓存。
+
+使用 --format json 或 --format ndjson 输出机器可读的结果，
+每个文件一条记录，最后附带汇总信息。`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := validateCheckConfig(checkConfig); err != nil {
 			fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
@@ -60,6 +103,18 @@ func init() {
 		"试运行模式，不实际执行操作")
 	checkCmd.Flags().BoolVarP(&checkConfig.Debug, "debug", "d", false,
 		"调试模式")
+	checkCmd.Flags().IntVarP(&checkConfig.Jobs, "jobs", "j", defaultJobs,
+		"并发检测的文件数")
+	checkCmd.Flags().StringVar(&checkConfig.Format, "format", formatText,
+		"输出格式：text、json 或 ndjson")
+	checkCmd.Flags().BoolVar(&checkConfig.Deep, "deep", false,
+		"深度检测，完整读取每个条目并校验 CRC32 和大小（较慢）")
+	checkCmd.Flags().BoolVar(&checkConfig.Repair, "repair", false,
+		"修复打包不规范或编码不一致的文件（修复前备份为 .bak）")
+	checkCmd.Flags().BoolVar(&checkConfig.NoCache, "no-cache", false,
+		"忽略扫描缓存，重新检测所有文件")
+	checkCmd.Flags().StringSliceVar(&checkConfig.Types, "types", util.BookFormatNames(),
+		"要检测的格式，逗号分隔：epub、mobi、pdf、fb2（mobi 包括 .mobi、.azw、.azw3，fb2 包括 .fb2.zip）")
 
 	checkCmd.MarkFlagRequired("path")
 }
@@ -84,39 +139,69 @@ func validateCheckConfig(cfg *CheckConfig) error {
 		return fmt.Errorf("--force 只能与 --delete 一起使用")
 	}
 
+	if err := validateJobs(cfg.Jobs); err != nil {
+		return err
+	}
+
+	if err := validateCheckFormat(cfg.Format); err != nil {
+		return err
+	}
+
+	formats, err := util.ParseBookFormats(cfg.Types)
+	if err != nil {
+		return err
+	}
+	if len(formats) == 0 {
+		return fmt.Errorf("--types 不能为空")
+	}
+	cfg.formats = formats
+
+	// 机器可读输出时无法进行交互确认
+	if cfg.Format != formatText && cfg.Delete && !cfg.Force {
+		return fmt.Errorf("--format %s 与 --delete 一起使用时必须指定 --force", cfg.Format)
+	}
+
 	return nil
 }
 
 // runCheck 执行检测
 func runCheck(cfg *CheckConfig) error {
 	var files []string
+	text := cfg.Format == formatText
+	validator := newCheckValidator(cfg)
 
 	// 打印头部
-	fmt.Println(ui.RenderHeader("EPUB 文件检测", "检查文件完整性、ZIP 结构和元数据"))
-	fmt.Println()
+	if text {
+		fmt.Println(ui.RenderHeader("电子书文件检测", "检查文件完整性、文件结构和元数据"))
+		fmt.Println()
+	}
 
 	// 收集要检测的文件
 	if util.IsFile(cfg.Path) {
-		if !strings.HasSuffix(strings.ToLower(cfg.Path), ".epub") {
-			return fmt.Errorf("不是 EPUB 文件: %s", cfg.Path)
+		if f := util.FormatOf(cfg.Path); f == nil || !slices.Contains(cfg.formats, f) {
+			return fmt.Errorf("不是 %s 文件: %s", formatNames(cfg.formats), cfg.Path)
 		}
 		files = append(files, cfg.Path)
 	} else {
 		var err error
-		files, err = collectEpubFiles(cfg.Path, cfg.Recursive)
+		files, err = collectBookFiles(cfg.Path, cfg.Recursive, cfg.formats)
 		if err != nil {
 			return fmt.Errorf("收集文件失败: %w", err)
 		}
 	}
 
-	if len(files) == 0 {
-		fmt.Println(ui.RenderWarning("未找到 EPUB 文件"))
-		return nil
+	// 会修改文件时记录操作日志
+	if cfg.MoveTo != "" || cfg.Delete || cfg.Repair {
+		journal, err := openJournal("check", cfg.DoTry)
+		if err != nil {
+			return err
+		}
+		cfg.journal = journal
+		defer closeJournal(journal, journalOutput(cfg))
 	}
 
-	// 显示找到的文件数
-	fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个 EPUB 文件", len(files))))
-	fmt.Println()
+	cache := openScanCache(cfg.NoCache, journalOutput(cfg))
+	defer closeScanCache(cache, journalOutput(cfg))
 
 	// 统计信息
 	stats := &CheckStats{
@@ -126,37 +211,62 @@ func runCheck(cfg *CheckConfig) error {
 		Handled: 0,
 	}
 
+	// 机器可读输出
+	if !text {
+		reporter := newCheckReporter(cfg.Format, os.Stdout)
+		var reportErr error
+		util.RunOrdered(len(files), cfg.Jobs, func(i int) error {
+			return cache.Validate(files[i], validator)
+		}, func(i int, validateErr error) {
+			rec := checkSingleFile(files[i], validateErr, cfg, stats)
+			if cfg.OnlyErrors && rec.Passed {
+				return
+			}
+			if err := reporter.Record(rec); err != nil && reportErr == nil {
+				reportErr = err
+			}
+		})
+		if reportErr != nil {
+			return fmt.Errorf("输出结果失败: %w", reportErr)
+		}
+		return reporter.Finish(stats)
+	}
+
+	if len(files) == 0 {
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("未找到 %s 文件", formatNames(cfg.formats))))
+		return nil
+	}
+
+	// 显示找到的文件数
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个 %s 文件", len(files), formatNames(cfg.formats))))
+	fmt.Println()
+
 	// 创建增强的进度跟踪器
 	progress := ui.NewCompactProgressTracker(len(files))
 	progress.SetShowMessage(true)
 
-	// 检测每个文件
-	for i, file := range files {
-		progress.SetMessage(fmt.Sprintf("%s", filepath.Base(file)))
+	// 并发检测，按文件顺序输出结果
+	util.RunOrdered(len(files), cfg.Jobs, func(i int) error {
+		return cache.Validate(files[i], validator)
+	}, func(i int, validateErr error) {
+		file := files[i]
 
-		// 显示进度（只在批量模式下显示）
+		// 清除进度行，为文件详情腾出空间
 		if len(files) > 1 {
-			fmt.Printf("\r%s", progress.RenderCompact())
+			fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
 		}
 
-		err := checkSingleFile(file, cfg, stats)
+		checkSingleFile(file, validateErr, cfg, stats)
 
 		// 更新统计计数
-		if err == nil {
-			progress.IncrementSuccess()
-		} else {
-			if cfg.Debug {
-				fmt.Fprintf(os.Stderr, "\n处理文件 %s 时发生错误: %v\n", file, err)
-			}
-			progress.IncrementFailure()
-		}
+		progress.SetMessage(filepath.Base(file))
+		progress.IncrementSuccess()
 
-		// 在单个文件模式下不需要清除行
+		// 显示进度（只在批量模式下显示）
 		if len(files) > 1 && i < len(files)-1 {
-			// 清除进度行，为文件详情腾出空间
-			fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
+			fmt.Printf("\r%s", progress.RenderCompact())
 		}
-	}
+	})
 
 	// 清除最后的进度行
 	if len(files) > 1 {
@@ -173,24 +283,58 @@ func runCheck(cfg *CheckConfig) error {
 	return nil
 }
 
+// newCheckValidator 根据配置创建检测器，check 命令总是检查 EPUB 结构规范
+func newCheckValidator(cfg *CheckConfig) *util.BookValidator {
+	return util.NewBookValidator(util.ValidateOptions{
+		Deep:        cfg.Deep,
+		Conformance: true,
+	}, cfg.formats...)
+}
+
 // CheckStats 检测统计
+// 计数方法可以在多个 goroutine 中并发调用
 type CheckStats struct {
+	mu      sync.Mutex
 	Total   int // 总文件数
 	Passed  int // 通过数
 	Failed  int // 失败数
-	Handled int // 已处理数（移动或删除）
+	Handled int // 已处理数（修复、移动或删除）
+}
+
+// IncrementPassed 增加通过数
+func (s *CheckStats) IncrementPassed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Passed++
+}
+
+// IncrementFailed 增加失败数
+func (s *CheckStats) IncrementFailed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed++
+}
+
+// IncrementHandled 增加已处理数
+func (s *CheckStats) IncrementHandled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Handled++
 }
 
-// collectEpubFiles 收集 EPUB 文件
-func collectEpubFiles(dir string, recursive bool) ([]string, error) {
+// collectBookFiles 收集指定格式的电子书文件
+func collectBookFiles(dir string, recursive bool, formats []*util.BookFormat) ([]string, error) {
 	var files []string
+	match := func(name string) bool {
+		return slices.Contains(formats, util.FormatOf(name))
+	}
 
 	if recursive {
 		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".epub") {
+			if !info.IsDir() && match(path) {
 				files = append(files, path)
 			}
 			return nil
@@ -205,7 +349,7 @@ func collectEpubFiles(dir string, recursive bool) ([]string, error) {
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".epub") {
+		if !entry.IsDir() && match(entry.Name()) {
 			files = append(files, filepath.Join(dir, entry.Name()))
 		}
 	}
@@ -213,79 +357,129 @@ func collectEpubFiles(dir string, recursive bool) ([]string, error) {
 	return files, nil
 }
 
-// checkSingleFile 检测单个文件
-func checkSingleFile(file string, cfg *CheckConfig, stats *CheckStats) error {
-	err := util.ValidateEpubFile(file)
+// checkSingleFile 记录单个文件的检测结果，并按配置处理损坏文件
+// 文本格式下同时输出彩色结果
+func checkSingleFile(file string, err error, cfg *CheckConfig, stats *CheckStats) *checkRecord {
+	text := cfg.Format == formatText
+	rec := newCheckRecord(file, err)
 
 	if err == nil {
 		// 文件正常
-		stats.Passed++
-		if !cfg.OnlyErrors {
+		stats.IncrementPassed()
+		if text && !cfg.OnlyErrors {
 			fmt.Println(ui.FormatFilePath("检查", file))
 			fmt.Println(ui.RenderSuccess("通过"))
 			fmt.Println()
 		}
-		return nil
+		return rec
 	}
 
 	// 文件有问题
-	stats.Failed++
-	fmt.Println(ui.FormatFilePath("检查", file))
-	fmt.Println(ui.RenderError(fmt.Sprintf("失败: %v", err)))
+	stats.IncrementFailed()
+	if text {
+		fmt.Println(ui.FormatFilePath("检查", file))
+		fmt.Println(ui.RenderError(fmt.Sprintf("失败: %v", err)))
+		if rec.Fixable {
+			fmt.Println(ui.RenderInfo("该问题可能通过重新打包修复"))
+		}
+		if cfg.Debug {
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("错误类型: %s", rec.ErrorType)))
+		}
+	}
 
 	// 处理损坏的文件
+	rec.DryRun = cfg.DoTry && (cfg.Repair || cfg.MoveTo != "" || cfg.Delete)
+
+	// 优先尝试修复打包不规范的 EPUB 文件，修复失败时继续移动或删除
+	if cfg.Repair && util.FormatOf(file) == util.EpubFormat && util.GetErrorType(err).Fixable() {
+		if repairSingleFile(file, cfg, rec) {
+			stats.IncrementHandled()
+			if text {
+				fmt.Println()
+			}
+			return rec
+		}
+	}
+
 	if cfg.MoveTo != "" {
-		if err := handleMoveFile(file, cfg.MoveTo, cfg.DoTry); err != nil {
-			fmt.Println(ui.RenderError(fmt.Sprintf("移动失败: %v", err)))
+		newPath, err := handleMoveFile(file, cfg.MoveTo, cfg.DoTry)
+		if err != nil {
+			rec.Action = actionMoveFailed
+			rec.ActionError = err.Error()
+			if text {
+				fmt.Println(ui.RenderError(fmt.Sprintf("移动失败: %v", err)))
+			}
 		} else {
-			stats.Handled++
+			stats.IncrementHandled()
+			rec.Action = actionMoved
+			rec.ActionPath = newPath
+			recordJournal(cfg.journal, util.JournalEntry{Op: util.JournalMove, Path: file, NewPath: newPath}, journalOutput(cfg))
+			if text && cfg.DoTry {
+				fmt.Println(ui.RenderInfo(fmt.Sprintf("[试运行] 将移动到: %s", newPath)))
+			} else if text {
+				fmt.Println(ui.RenderInfo(fmt.Sprintf("已移动到: %s", newPath)))
+			}
 		}
 	} else if cfg.Delete {
-		if err := handleDeleteFile(file, cfg.Force, cfg.DoTry); err != nil {
-			fmt.Println(ui.RenderError(fmt.Sprintf("删除失败: %v", err)))
+		reason := err.Error()
+		trashed, err := handleDeleteFile(file, reason, cfg.Force, cfg.DoTry)
+		if err != nil {
+			rec.Action = actionDeleteFailed
+			rec.ActionError = err.Error()
+			if text {
+				fmt.Println(ui.RenderError(fmt.Sprintf("删除失败: %v", err)))
+			}
 		} else {
-			stats.Handled++
+			stats.IncrementHandled()
+			rec.Action = actionDeleted
+			rec.ActionPath = trashed
+			recordJournal(cfg.journal, util.JournalEntry{Op: util.JournalDelete, Path: file, NewPath: trashed}, journalOutput(cfg))
+			if text && cfg.DoTry {
+				fmt.Println(ui.RenderInfo("[试运行] 将移入回收站"))
+			} else if text {
+				fmt.Println(ui.RenderInfo(fmt.Sprintf("已移入回收站: %s", trashed)))
+			}
 		}
 	}
 
-	fmt.Println()
-	return nil
+	if text {
+		fmt.Println()
+	}
+	return rec
 }
 
-// handleMoveFile 处理移动文件
-func handleMoveFile(srcPath, dstDir string, doTry bool) error {
-	if doTry {
-		// 试运行模式，只显示将要执行的操作
-		fileName := filepath.Base(srcPath)
-		expectedPath := filepath.Join(dstDir, fileName)
-		fmt.Println(ui.RenderInfo(fmt.Sprintf("[试运行] 将移动到: %s", expectedPath)))
-		return nil
+// journalOutput 返回操作日志警告的输出位置，机器可读输出时为 stderr
+func journalOutput(cfg *CheckConfig) io.Writer {
+	if cfg.Format == formatText {
+		return os.Stdout
 	}
+	return os.Stderr
+}
 
-	newPath, err := util.MoveFileWithConflictHandling(srcPath, dstDir)
-	if err != nil {
-		return err
+// handleMoveFile 处理移动文件，返回移动后的路径
+// 试运行模式下只返回预期路径，不实际移动
+func handleMoveFile(srcPath, dstDir string, doTry bool) (string, error) {
+	if doTry {
+		fileName := filepath.Base(srcPath)
+		return filepath.Join(dstDir, fileName), nil
 	}
 
-	fmt.Println(ui.RenderInfo(fmt.Sprintf("已移动到: %s", newPath)))
-	return nil
+	return util.MoveFileWithConflictHandling(srcPath, dstDir)
 }
 
-// handleDeleteFile 处理删除文件
-func handleDeleteFile(filePath string, force, doTry bool) error {
+// handleDeleteFile 处理删除文件，将文件移入回收站并返回回收站中的路径
+// 试运行模式下不实际删除，返回空路径
+func handleDeleteFile(filePath, reason string, force, doTry bool) (string, error) {
 	if doTry {
-		// 试运行模式，只显示将要执行的操作
-		fmt.Println(ui.RenderInfo("[试运行] 将删除"))
-		return nil
+		return "", nil
 	}
 
 	needConfirm := !force
-	if err := util.SafeDeleteFile(filePath, needConfirm); err != nil {
-		return err
+	item, err := util.SafeDeleteFile(filePath, reason, needConfirm)
+	if err != nil {
+		return "", err
 	}
-
-	fmt.Println(ui.RenderInfo("已删除"))
-	return nil
+	return item.Path, nil
 }
 
 // printStats 打印统计信息
@@ -352,3 +546,12 @@ func printStats(stats *CheckStats) {
 		}
 	}
 }
+
+// formatNames 返回格式的显示名称，如 "EPUB/MOBI"
+func formatNames(formats []*util.BookFormat) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = strings.ToUpper(f.Name)
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+)
+
+// repairSingleFile 修复单个文件并记录结果，修复成功时返回 true
+func repairSingleFile(file string, cfg *CheckConfig, rec *checkRecord) bool {
+	text := cfg.Format == formatText
+
+	fixes, backup, err := handleRepairFile(file, newCheckValidator(cfg), cfg.DoTry)
+	if err != nil {
+		rec.RepairError = err.Error()
+		if text {
+			fmt.Println(ui.RenderError(fmt.Sprintf("修复失败: %v", err)))
+		}
+		return false
+	}
+
+	rec.Action = actionRepaired
+	rec.ActionPath = backup
+	rec.Repairs = fixes
+	if cfg.journal != nil {
+		entry := util.JournalEntry{Op: util.JournalRepair, Path: file, Backup: backup}
+		// 记录修复后的内容，撤销时文件已被再次修改则跳过
+		if sum, err := util.FileHash(file); err == nil {
+			entry.Hash = sum
+		}
+		recordJournal(cfg.journal, entry, journalOutput(cfg))
+	}
+	if text && cfg.DoTry {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("[试运行] 将修复: %s", strings.Join(fixes, "；"))))
+	} else if text {
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("已修复: %s", strings.Join(fixes, "；"))))
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("原文件已备份到: %s", backup)))
+	}
+	return true
+}
+
+// handleRepairFile 备份并修复文件，修复后重新检测，返回修复项和备份路径
+// 修复后仍未通过检测时从备份恢复原文件。
+// 试运行模式下在临时副本上修复，原文件保持不变
+func handleRepairFile(file string, validator util.FileValidator, doTry bool) ([]string, string, error) {
+	backup := backupPath(file)
+	target := file
+
+	if doTry {
+		tmp, err := os.CreateTemp("", "bookimporter-repair-*.epub")
+		if err != nil {
+			return nil, "", fmt.Errorf("无法创建临时文件: %w", err)
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		target = tmp.Name()
+		if err := util.CopyFile(file, target); err != nil {
+			return nil, "", err
+		}
+	} else if err := util.CopyFile(file, backup); err != nil {
+		os.Remove(backup)
+		return nil, "", fmt.Errorf("备份失败: %w", err)
+	}
+
+	// restore 丢弃修复结果，恢复原文件
+	restore := func() {
+		if !doTry {
+			os.Rename(backup, file)
+		}
+	}
+
+	fixes, err := util.RepairEpub(target)
+	if err != nil {
+		restore()
+		return nil, "", err
+	}
+	if len(fixes) == 0 {
+		restore()
+		return nil, "", fmt.Errorf("没有可自动修复的问题")
+	}
+
+	if err := validator.Validate(target); err != nil {
+		restore()
+		return nil, "", fmt.Errorf("修复后仍未通过检测: %w", err)
+	}
+	return fixes, backup, nil
+}
+
+// backupPath 返回不与现有文件冲突的备份路径，如 book.epub.bak、book.epub.bak.1
+func backupPath(file string) string {
+	path := file + ".bak"
+	for i := 1; util.Exists(path); i++ {
+		path = fmt.Sprintf("%s.bak.%d", file, i)
+	}
+	return path
+}
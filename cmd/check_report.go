@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jianyun8023/bookimporter/pkg/util"
+)
+
+// check 命令支持的输出格式
+const (
+	formatText   = "text"   // 彩色文本（默认）
+	formatJSON   = "json"   // 结束时输出一个完整的 JSON 文档
+	formatNDJSON = "ndjson" // 每个文件一行 JSON，最后一行为汇总
+)
+
+// 损坏文件的处理动作
+const (
+	actionRepaired     = "repaired"
+	actionMoved        = "moved"
+	actionDeleted      = "deleted"
+	actionMoveFailed   = "move_failed"
+	actionDeleteFailed = "delete_failed"
+)
+
+// checkRecord 单个文件的检测结果
+type checkRecord struct {
+	Type        string   `json:"type"` // 固定为 "file"，便于 NDJSON 区分记录类型
+	Path        string   `json:"path"`
+	Format      string   `json:"format,omitempty"` // 文件格式：epub、mobi
+	Passed      bool     `json:"passed"`
+	ErrorType   string   `json:"error_type,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Detail      string   `json:"detail,omitempty"`
+	Fixable     bool     `json:"fixable,omitempty"` // 打包不规范但内容完好，可能通过重新打包修复
+	Size        int64    `json:"size"`
+	Action      string   `json:"action,omitempty"`       // 处理动作：repaired/moved/deleted/move_failed/delete_failed
+	ActionPath  string   `json:"action_path,omitempty"`  // 移动后的路径，删除时为回收站中的路径，修复时为备份路径
+	Repairs     []string `json:"repairs,omitempty"`      // 已执行的修复项
+	RepairError string   `json:"repair_error,omitempty"` // 修复失败原因
+	ActionError string   `json:"action_error,omitempty"` // 处理失败原因
+	DryRun      bool     `json:"dry_run,omitempty"`      // 试运行模式下动作未实际执行
+}
+
+// checkSummary 检测汇总，由 CheckStats 生成
+type checkSummary struct {
+	Type    string `json:"type"` // 固定为 "summary"
+	Total   int    `json:"total"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Handled int    `json:"handled"`
+}
+
+// newCheckRecord 根据检测结果创建记录
+func newCheckRecord(file string, err error) *checkRecord {
+	rec := &checkRecord{
+		Type:   "file",
+		Path:   file,
+		Passed: err == nil,
+	}
+	if f := util.FormatOf(file); f != nil {
+		rec.Format = f.Name
+	}
+	if info, statErr := os.Stat(file); statErr == nil {
+		rec.Size = info.Size()
+	}
+	if err == nil {
+		return rec
+	}
+
+	var epubErr *util.EpubError
+	if errors.As(err, &epubErr) {
+		rec.ErrorType = epubErr.Type.String()
+		rec.Message = epubErr.Message
+		rec.Detail = epubErr.Detail
+		rec.Fixable = epubErr.Type.Fixable()
+	} else {
+		rec.ErrorType = util.GetErrorType(err).String()
+		rec.Message = err.Error()
+	}
+	return rec
+}
+
+// newCheckSummary 根据统计信息生成汇总
+func newCheckSummary(stats *CheckStats) *checkSummary {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return &checkSummary{
+		Type:    "summary",
+		Total:   stats.Total,
+		Passed:  stats.Passed,
+		Failed:  stats.Failed,
+		Handled: stats.Handled,
+	}
+}
+
+// checkReporter 输出机器可读的检测结果
+type checkReporter struct {
+	format  string
+	enc     *json.Encoder
+	records []*checkRecord
+}
+
+// newCheckReporter 创建 JSON/NDJSON 报告输出
+func newCheckReporter(format string, w io.Writer) *checkReporter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if format == formatJSON {
+		enc.SetIndent("", "  ")
+	}
+	return &checkReporter{format: format, enc: enc, records: []*checkRecord{}}
+}
+
+// Record 输出或缓存单个文件的结果
+func (r *checkReporter) Record(rec *checkRecord) error {
+	if r.format == formatNDJSON {
+		return r.enc.Encode(rec)
+	}
+	r.records = append(r.records, rec)
+	return nil
+}
+
+// Finish 输出汇总
+func (r *checkReporter) Finish(stats *CheckStats) error {
+	summary := newCheckSummary(stats)
+	if r.format == formatNDJSON {
+		return r.enc.Encode(summary)
+	}
+	return r.enc.Encode(struct {
+		Files   []*checkRecord `json:"files"`
+		Summary *checkSummary  `json:"summary"`
+	}{r.records, summary})
+}
+
+// validateCheckFormat 验证输出格式
+func validateCheckFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatNDJSON:
+		return nil
+	}
+	return fmt.Errorf("不支持的输出格式: %s（可选 text、json、ndjson）", format)
+}
@@ -1,15 +1,17 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/jianyun8023/bookimporter/pkg/ui"
 	"github.com/jianyun8023/bookimporter/pkg/util"
-	"github.com/kapmahc/epub"
 	"github.com/spf13/cobra"
 )
 
@@ -23,7 +25,30 @@ var clnameCmd = &cobra.Command{
 	Long: `清理 EPUB 书籍标题中的无用描述符和标记
 
 自动移除书籍标题中的各种括号标记，如：（）【】()[]
-使用 Calibre 的 ebook-meta 工具修改元数据。
+直接改写 EPUB 内的 OPF 元数据，无需安装 Calibre。
+
+MOBI/AZW/AZW3 文件会读取 EXTH 中的书名和作者并检测完整性，显示建议的修改，
+但暂不支持写入元数据，这些文件计为跳过。
+
+PDF 文件读取文档信息字典的 /Title（没有时使用 XMP 的 dc:title），清理后通过增量更新
+写回 /Title 和 XMP 的 dc:title：新内容追加在文件末尾，原有内容保持不变。
+PDF 只支持清理书名，加密的 PDF 不支持修改。
+
+FB2/FB2.ZIP 文件读取 title-info 中的 book-title、author 和 sequence，直接改写 XML，
+其余内容保持原样；原编码（如 windows-1251）无法表示新内容时改为 UTF-8。
+
+使用 --rules 指定 YAML 规则文件（不支持 TOML），扩展需要删除或保留的内容、括号对和长度阈值。
+
+使用 --fields 同时清理其他字段：
+  • series：将书名中的分卷片段转换为系列信息，如 "三体（第二部）" 变为
+    书名 "三体"、系列 "三体" 第 2 部（calibre:series 和 EPUB3 belongs-to-collection）
+  • creator：去除 [美] 等国籍标记，将 著/编/译/绘 转换为角色（opf:role），
+    拆分写在一起的多个作者，如 "[美] 某某 著 张三 译"
+  • publisher：去除 "出版社：" 等标签和多余的 "出版" 字样
+  • subject：拆分用分号、逗号、顿号连在一起的主题并去重
+
+检测结果和元数据保存在扫描缓存中，再次运行时未变化且无需修改的文件直接跳过，
+使用 --no-cache 重新读取所有文件。
 
 支持：
   • 单个文件或批量目录处理
@@ -42,12 +67,26 @@ var clnameCmd = &cobra.Command{
   # 预览模式（不实际修改）
   bookimporter clname -p /path/to/books/ -r -t
 
+  # 同时清理作者、出版社和主题
+  bookimporter clname -p /path/to/books/ -r --fields title,creator,publisher,subject
+
+  # 使用自定义清理规则
+  bookimporter clname -p /path/to/books/ -r --rules rules.yaml
+
   # 自动移动损坏文件
   bookimporter clname -p /path/to/books/ -r --move-corrupted-to /path/to/corrupted/`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Validate config.
 		ValidateConfig(c)
 
+		journal, err := openJournal("clname", c.DoTry)
+		if err != nil {
+			fmt.Println(ui.RenderError(err.Error()))
+			os.Exit(1)
+		}
+		c.journal = journal
+		c.cache = openScanCache(c.NoCache, os.Stdout)
+
 		// 打印头部
 		fmt.Println(ui.RenderHeader("清理书籍标题", "移除标题中的无用描述符和标记"))
 		fmt.Println()
@@ -61,77 +100,62 @@ var clnameCmd = &cobra.Command{
 		}
 
 		if util.IsDir(c.Path) {
-			// 根据 Recursive 参数决定是否递归搜索 EPUB 文件
-			var m []string
-			var err error
-
-			if c.Recursive {
-				// 递归搜索所有子目录
-				err = filepath.Walk(c.Path, func(path string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
-					if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".epub") {
-						m = append(m, path)
-					}
-					return nil
-				})
-			} else {
-				// 仅搜索当前目录
-				pattern := filepath.Join(c.Path, "*.epub")
-				m, err = filepath.Glob(pattern)
-			}
-
+			// 根据 Recursive 参数决定是否递归搜索电子书文件
+			m, err := collectBookFiles(c.Path, c.Recursive, util.BookFormats)
 			if err != nil {
 				fmt.Println(ui.RenderError(fmt.Sprintf("扫描目录失败: %v", err)))
+				journal.Close()
+				c.cache.Close()
 				return
 			}
 
 			stats.Total = len(m)
 
 			if stats.Total == 0 {
-				fmt.Println(ui.RenderWarning("未找到 EPUB 文件"))
+				fmt.Println(ui.RenderWarning("未找到 EPUB、MOBI、AZW3、PDF 或 FB2 文件"))
+				journal.Close()
+				c.cache.Close()
 				return
 			}
 
-			fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个 EPUB 文件", stats.Total)))
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个电子书文件", stats.Total)))
 			fmt.Println()
 
 			// 创建增强的进度跟踪器
 			progress := ui.NewCompactProgressTracker(stats.Total)
 			progress.SetShowMessage(true)
 
-			for i, val := range m {
-				epubpath := val
-				progress.SetMessage(filepath.Base(epubpath))
+			// 并发处理，按文件顺序输出结果
+			util.RunOrdered(len(m), c.Jobs, func(i int) *clnameResult {
+				res := &clnameResult{}
+				res.err = ParseEpub(m[i], c, stats, progress, &res.output)
+				return res
+			}, func(i int, res *clnameResult) {
+				epubpath := m[i]
 
-				// 显示进度
+				// 清除进度行，为文件详情腾出空间
 				if stats.Total > 1 {
-					fmt.Printf("\r%s", progress.RenderCompact())
+					fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
 				}
+				fmt.Print(res.output.String())
 
-				err := ParseEpub(epubpath, c, stats, progress)
-				if err != nil {
-					// 清除进度行
-					if stats.Total > 1 {
-						fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
-					}
+				if res.err != nil {
+					handleCorruptedEpub(epubpath, res.err, c)
 
 					// 记录错误并继续处理下一个文件
 					fmt.Println(ui.FormatFilePath("文件", epubpath))
-					fmt.Println(ui.RenderWarning(fmt.Sprintf("跳过: %v", err)))
+					fmt.Println(ui.RenderWarning(fmt.Sprintf("跳过: %v", res.err)))
 					fmt.Println()
-					stats.Failed++
-					if progress != nil {
-						progress.IncrementFailure()
-					}
+					stats.IncrementFailed()
+					progress.IncrementFailure()
 				}
 
-				// 清除进度行，为文件详情腾出空间
+				// 显示进度
+				progress.SetMessage(filepath.Base(epubpath))
 				if stats.Total > 1 && i < stats.Total-1 {
-					fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
+					fmt.Printf("\r%s", progress.RenderCompact())
 				}
-			}
+			})
 
 			// 清除最后的进度行并显示最终统计
 			if stats.Total > 1 {
@@ -143,17 +167,21 @@ var clnameCmd = &cobra.Command{
 		} else {
 			stats.Total = 1
 			epubpath := c.Path
-			err := ParseEpub(epubpath, c, stats, nil)
+			err := ParseEpub(epubpath, c, stats, nil, os.Stdout)
 			if err != nil {
+				handleCorruptedEpub(epubpath, err, c)
+
 				// 记录错误
 				fmt.Println(ui.FormatFilePath("文件", epubpath))
 				fmt.Println(ui.RenderWarning(fmt.Sprintf("处理失败: %v", err)))
-				stats.Failed++
+				stats.IncrementFailed()
 			}
 		}
 
 		// 打印统计信息
 		printClnameStats(stats)
+		closeScanCache(c.cache, os.Stdout)
+		closeJournal(journal, os.Stdout)
 
 		// 如果有失败且未设置忽略错误，设置退出码为 1（便于脚本检测）
 		if stats.Failed > 0 && !c.IgnoreErrors {
@@ -170,8 +198,8 @@ func ValidateConfig(c *ClnameConfig) {
 	}
 
 	// 验证文件格式
-	if util.IsFile(c.Path) && !strings.HasSuffix(c.Path, ".epub") {
-		fmt.Println(ui.RenderError("文件格式不正确，必须是 .epub 文件"))
+	if util.IsFile(c.Path) && util.FormatOf(c.Path) == nil {
+		fmt.Println(ui.RenderError("文件格式不正确，必须是 EPUB、MOBI、AZW3、PDF 或 FB2 文件"))
 		os.Exit(1)
 	}
 
@@ -188,6 +216,28 @@ func ValidateConfig(c *ClnameConfig) {
 		fmt.Println(ui.RenderInfo("提示: --force-delete 用于在删除损坏文件时跳过确认步骤"))
 	}
 
+	if err := validateJobs(c.Jobs); err != nil {
+		fmt.Println(ui.RenderError(err.Error()))
+		os.Exit(1)
+	}
+
+	if err := validateFields(c.Fields); err != nil {
+		fmt.Println(ui.RenderError(err.Error()))
+		os.Exit(1)
+	}
+
+	// 加载书名清理规则
+	if c.Rules != "" {
+		rules, err := util.LoadTitleRules(c.Rules)
+		if err == nil {
+			c.cleaner, err = rules.Compile()
+		}
+		if err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("加载规则失败: %v", err)))
+			os.Exit(1)
+		}
+	}
+
 	// 验证 move-corrupted-to 目标目录
 	if c.MoveCorruptedTo != "" {
 		// 确保目标目录不是源目录的子目录
@@ -203,13 +253,42 @@ func ValidateConfig(c *ClnameConfig) {
 }
 
 // ClnameStats 清理标题统计
+// 计数方法可以在多个 goroutine 中并发调用
 type ClnameStats struct {
+	mu      sync.Mutex
 	Total   int
 	Updated int
 	Skipped int
 	Failed  int
 }
 
+// IncrementUpdated 增加已更新数
+func (s *ClnameStats) IncrementUpdated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Updated++
+}
+
+// IncrementSkipped 增加跳过数
+func (s *ClnameStats) IncrementSkipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Skipped++
+}
+
+// IncrementFailed 增加失败数
+func (s *ClnameStats) IncrementFailed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed++
+}
+
+// clnameResult 单个文件的处理结果，output 缓存该文件的输出以便按顺序打印
+type clnameResult struct {
+	output bytes.Buffer
+	err    error
+}
+
 // printClnameStats 打印统计信息
 func printClnameStats(stats *ClnameStats) {
 	fmt.Println()
@@ -280,9 +359,9 @@ func printClnameStats(stats *ClnameStats) {
 func init() {
 	// 基础参数
 	clnameCmd.Flags().StringVarP(&c.Path, "path", "p", "./",
-		"指定要处理的 EPUB 文件或目录路径")
+		"指定要处理的电子书文件或目录路径")
 	clnameCmd.Flags().BoolVarP(&c.Recursive, "recursive", "r", false,
-		"递归搜索子目录中的所有 EPUB 文件")
+		"递归搜索子目录中的所有电子书文件")
 
 	// 运行模式
 	clnameCmd.Flags().BoolVarP(&c.DoTry, "dotry", "t", false,
@@ -292,64 +371,55 @@ func init() {
 
 	// 损坏文件处理（互斥选项）
 	clnameCmd.Flags().StringVar(&c.MoveCorruptedTo, "move-corrupted-to", "",
-		"将损坏的 EPUB 文件移动到指定目录（与 --delete-corrupted 互斥）")
+		"将损坏的文件移动到指定目录（与 --delete-corrupted 互斥）")
 	clnameCmd.Flags().BoolVar(&c.DeleteCorrupted, "delete-corrupted", false,
-		"直接删除损坏的 EPUB 文件（与 --move-corrupted-to 互斥）")
+		"直接删除损坏的文件（与 --move-corrupted-to 互斥）")
 	clnameCmd.Flags().BoolVar(&c.ForceDelete, "force-delete", false,
 		"删除损坏文件时不需要用户确认（需配合 --delete-corrupted 使用）")
 
+	// 清理规则
+	clnameCmd.Flags().StringVar(&c.Rules, "rules", "",
+		"书名清理规则文件（仅支持 YAML），默认追加到内置规则之后")
+	clnameCmd.Flags().StringSliceVar(&c.Fields, "fields", []string{fieldTitle},
+		"要清理的字段：title、series、creator、publisher、subject，多个用逗号分隔")
+
+	// 性能选项
+	clnameCmd.Flags().IntVarP(&c.Jobs, "jobs", "j", defaultJobs,
+		"并发处理的文件数")
+	clnameCmd.Flags().BoolVar(&c.NoCache, "no-cache", false,
+		"忽略扫描缓存，重新检测所有文件")
+
 	// 调试选项
 	clnameCmd.Flags().BoolVarP(&c.Debug, "debug", "d", false,
 		"启用调试模式，显示详细的执行信息")
 }
 
-func ParseEpub(file string, c *ClnameConfig, stats *ClnameStats, progress *ui.ProgressTracker) error {
-	// 预先检测 EPUB 文件完整性
-	if err := util.ValidateEpubFile(file); err != nil {
-		if c.Debug {
-			fmt.Println(ui.RenderError(fmt.Sprintf("EPUB 文件检测失败: %v", err)))
-		}
+// ParseEpub 清理单个电子书的标题，输出写入 out
+// 可以在多个 goroutine 中并发调用；损坏文件的移动或删除由调用方通过 handleCorruptedEpub 完成
+func ParseEpub(file string, c *ClnameConfig, stats *ClnameStats, progress *ui.ProgressTracker, out io.Writer) error {
+	format := util.FormatOf(file)
+	if format == nil {
+		return fmt.Errorf("不支持的文件格式: %s", filepath.Ext(file))
+	}
 
-		// 处理损坏的文件
-		if c.MoveCorruptedTo != "" {
-			if c.DoTry {
-				fmt.Println(ui.RenderInfo(fmt.Sprintf("[试运行] 将移动损坏文件到: %s", c.MoveCorruptedTo)))
-			} else {
-				newPath, moveErr := util.MoveFileWithConflictHandling(file, c.MoveCorruptedTo)
-				if moveErr != nil {
-					fmt.Println(ui.RenderError(fmt.Sprintf("移动损坏文件失败: %v", moveErr)))
-				} else {
-					fmt.Println(ui.RenderInfo(fmt.Sprintf("已移动损坏文件到: %s", newPath)))
-				}
-			}
-		} else if c.DeleteCorrupted {
-			if c.DoTry {
-				fmt.Println(ui.RenderInfo("[试运行] 将删除损坏文件"))
-			} else {
-				needConfirm := !c.ForceDelete
-				deleteErr := util.SafeDeleteFile(file, needConfirm)
-				if deleteErr != nil {
-					fmt.Println(ui.RenderError(fmt.Sprintf("删除损坏文件失败: %v", deleteErr)))
-				} else {
-					fmt.Println(ui.RenderInfo("已删除损坏文件"))
-				}
-			}
+	// 预先检测文件完整性
+	if err := c.cache.Validate(file, format.NewValidator(util.ValidateOptions{})); err != nil {
+		if c.Debug {
+			fmt.Fprintln(out, ui.RenderError(fmt.Sprintf("%s 文件检测失败: %v", strings.ToUpper(format.Name), err)))
 		}
-
-		return fmt.Errorf("EPUB 文件检测失败: %w", err)
+		return fmt.Errorf("%s 文件检测失败: %w", strings.ToUpper(format.Name), err)
 	}
 
-	book, err := epub.Open(file)
+	meta, err := c.cache.Metadata(file)
 	if err != nil {
 		return err
 	}
-	if book == nil || len(book.Opf.Metadata.Title) == 0 {
-		return fmt.Errorf("无法获得书籍标题")
+	update, changes, err := c.planMetadata(meta)
+	if err != nil {
+		return err
 	}
-	title := book.Opf.Metadata.Title[0]
-	newTitle := util.TryCleanTitle(title)
-	if title == newTitle {
-		stats.Skipped++
+	if update == nil {
+		stats.IncrementSkipped()
 		if progress != nil {
 			progress.IncrementSkipped()
 		}
@@ -357,47 +427,121 @@ func ParseEpub(file string, c *ClnameConfig, stats *ClnameStats, progress *ui.Pr
 	}
 
 	// 美化输出
-	fmt.Println(ui.FormatFilePath("路径", file))
-	fmt.Println(ui.FormatFileOperation("标题", title, newTitle))
+	fmt.Fprintln(out, ui.FormatFilePath("路径", file))
+	for _, change := range changes {
+		fmt.Fprintln(out, ui.FormatFileOperation(change.label, change.old, change.new))
+	}
 
-	if c.DoTry {
-		fmt.Println(ui.RenderInfo("[试运行] 将更新标题"))
-		fmt.Println()
-		stats.Skipped++
+	if format.WriteMetadata == nil {
+		fmt.Fprintln(out, ui.RenderWarning(fmt.Sprintf("%s 暂不支持写入元数据，已跳过", strings.ToUpper(format.Name))))
+		fmt.Fprintln(out)
+		stats.IncrementSkipped()
 		if progress != nil {
 			progress.IncrementSkipped()
 		}
 		return nil
 	}
 
-	// 执行单个shell命令时, 直接运行即可
-	command := fmt.Sprintf("ebook-meta \"%s\" -t \"%s\"", file, newTitle)
-	cmdx := exec.Command("bash", "-c", command)
+	if c.DoTry {
+		fmt.Fprintln(out, ui.RenderInfo("[试运行] 将更新元数据"))
+		fmt.Fprintln(out)
+		stats.IncrementSkipped()
+		if progress != nil {
+			progress.IncrementSkipped()
+		}
+		return nil
+	}
 
-	if _, err = cmdx.Output(); err != nil {
+	if err := format.WriteMetadata(file, update); err != nil {
 		if c.Debug {
-			fmt.Println(cmdx.Env)
-			fmt.Println(cmdx.Args)
+			fmt.Fprintln(out, ui.RenderError(fmt.Sprintf("写入元数据失败: %v", err)))
 		}
 		return err
 	}
 
-	fmt.Println(ui.RenderSuccess("已更新"))
-	fmt.Println()
-	stats.Updated++
+	recordJournal(c.journal, util.JournalEntry{
+		Op:   util.JournalMetadata,
+		Path: file,
+		Old:  previousMetadata(meta, update),
+		New:  update,
+	}, out)
+
+	fmt.Fprintln(out, ui.RenderSuccess("已更新"))
+	fmt.Fprintln(out)
+	stats.IncrementUpdated()
 	if progress != nil {
 		progress.IncrementSuccess()
 	}
 	return nil
 }
 
+// handleCorruptedEpub 按配置移动或删除检测失败的 EPUB 文件
+// 删除时可能需要用户确认，因此只在输出 goroutine 中调用
+func handleCorruptedEpub(file string, err error, c *ClnameConfig) {
+	var epubErr *util.EpubError
+	if !errors.As(err, &epubErr) {
+		return
+	}
+
+	if c.MoveCorruptedTo != "" {
+		if c.DoTry {
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("[试运行] 将移动损坏文件到: %s", c.MoveCorruptedTo)))
+		} else {
+			newPath, moveErr := util.MoveFileWithConflictHandling(file, c.MoveCorruptedTo)
+			if moveErr != nil {
+				fmt.Println(ui.RenderError(fmt.Sprintf("移动损坏文件失败: %v", moveErr)))
+			} else {
+				fmt.Println(ui.RenderInfo(fmt.Sprintf("已移动损坏文件到: %s", newPath)))
+				recordJournal(c.journal, util.JournalEntry{Op: util.JournalMove, Path: file, NewPath: newPath}, os.Stdout)
+			}
+		}
+	} else if c.DeleteCorrupted {
+		if c.DoTry {
+			fmt.Println(ui.RenderInfo("[试运行] 将删除损坏文件"))
+		} else {
+			needConfirm := !c.ForceDelete
+			item, deleteErr := util.SafeDeleteFile(file, epubErr.Error(), needConfirm)
+			if deleteErr != nil {
+				fmt.Println(ui.RenderError(fmt.Sprintf("删除损坏文件失败: %v", deleteErr)))
+			} else {
+				fmt.Println(ui.RenderInfo(fmt.Sprintf("已将损坏文件移入回收站: %s", item.Path)))
+				recordJournal(c.journal, util.JournalEntry{Op: util.JournalDelete, Path: file, NewPath: item.Path}, os.Stdout)
+			}
+		}
+	}
+}
+
 type ClnameConfig struct {
 	Path            string
 	Recursive       bool // 是否递归搜索子目录
 	DoTry           bool
 	Debug           bool
-	IgnoreErrors    bool   // 忽略错误，有失败也返回 0
-	MoveCorruptedTo string // 损坏文件移动目标目录
-	DeleteCorrupted bool   // 是否删除损坏文件
-	ForceDelete     bool   // 删除时不需要确认
+	IgnoreErrors    bool     // 忽略错误，有失败也返回 0
+	MoveCorruptedTo string   // 损坏文件移动目标目录
+	DeleteCorrupted bool     // 是否删除损坏文件
+	ForceDelete     bool     // 删除时不需要确认
+	Jobs            int      // 并发处理的文件数
+	Rules           string   // 书名清理规则文件（YAML）
+	Fields          []string // 要清理的字段：title、series、creator、publisher、subject
+	NoCache         bool     // 忽略扫描缓存，重新检测所有文件
+
+	cleaner *util.TitleCleaner // 由 Rules 编译，未指定时使用内置规则
+	journal *util.Journal      // 操作日志，试运行时为 nil
+	cache   *util.ScanCache    // 扫描缓存，无法打开时为 nil
+}
+
+// cleanTitle 按配置的规则清理书名
+func (c *ClnameConfig) cleanTitle(title string) string {
+	if c.cleaner == nil {
+		return util.TryCleanTitle(title)
+	}
+	return c.cleaner.Clean(title)
+}
+
+// extractSeries 按配置的括号规则从书名中提取系列信息
+func (c *ClnameConfig) extractSeries(title string) (string, *util.SeriesInfo) {
+	if c.cleaner == nil {
+		return util.ExtractSeries(title)
+	}
+	return c.cleaner.ExtractSeries(title)
 }
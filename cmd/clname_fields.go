@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jianyun8023/bookimporter/pkg/util"
+)
+
+// clname 可清理的元数据字段
+const (
+	fieldTitle     = "title"
+	fieldCreator   = "creator"
+	fieldPublisher = "publisher"
+	fieldSubject   = "subject"
+	fieldSeries    = "series"
+)
+
+// clnameFields 所有字段，按显示顺序排列
+var clnameFields = []string{fieldTitle, fieldSeries, fieldCreator, fieldPublisher, fieldSubject}
+
+// fieldChange 单个字段的修改，用于显示差异
+type fieldChange struct {
+	label string
+	old   string
+	new   string
+}
+
+// validateFields 验证 --fields 参数
+func validateFields(fields []string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("--fields 不能为空")
+	}
+	for _, f := range fields {
+		if !hasField(clnameFields, f) {
+			return fmt.Errorf("不支持的字段: %s（可选 %s）", f, strings.Join(clnameFields, "、"))
+		}
+	}
+	return nil
+}
+
+// hasField 判断字段列表中是否包含 field
+func hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// planMetadata 按配置的字段清理元数据，返回需要写入的修改和用于显示的差异
+// 没有任何字段变化时返回 nil
+func (c *ClnameConfig) planMetadata(meta *util.EpubMetadata) (*util.MetadataUpdate, []fieldChange, error) {
+	update := &util.MetadataUpdate{}
+	var changes []fieldChange
+
+	if hasField(c.Fields, fieldTitle) || hasField(c.Fields, fieldSeries) {
+		if meta.Title == "" {
+			return nil, nil, fmt.Errorf("无法获得书籍标题")
+		}
+		title := meta.Title
+
+		// 先提取系列，清理规则会删除分卷片段之后的文本
+		// 已有系列信息的书籍保持不变，避免覆盖手工整理的结果
+		if hasField(c.Fields, fieldSeries) && meta.Series == nil {
+			var series *util.SeriesInfo
+			if title, series = c.extractSeries(title); series != nil {
+				if hasField(c.Fields, fieldTitle) {
+					series.Name = c.cleanTitle(series.Name)
+				}
+				update.Series = series
+			}
+		}
+		if hasField(c.Fields, fieldTitle) {
+			title = c.cleanTitle(title)
+		}
+
+		if title != meta.Title {
+			update.Title = &title
+			changes = append(changes, fieldChange{"标题", meta.Title, title})
+		}
+		if update.Series != nil {
+			changes = append(changes, fieldChange{"系列", meta.Series.String(), update.Series.String()})
+		}
+	}
+
+	if hasField(c.Fields, fieldCreator) && len(meta.Creators) > 0 {
+		if creators := util.NormalizeCreators(meta.Creators); !util.CreatorsEqual(creators, meta.Creators) {
+			update.Creators = creators
+			changes = append(changes, fieldChange{"作者", util.FormatCreators(meta.Creators), util.FormatCreators(creators)})
+		}
+	}
+
+	if hasField(c.Fields, fieldPublisher) && meta.Publisher != "" {
+		if publisher := util.NormalizePublisher(meta.Publisher); publisher != meta.Publisher && publisher != "" {
+			update.Publisher = &publisher
+			changes = append(changes, fieldChange{"出版社", meta.Publisher, publisher})
+		}
+	}
+
+	if hasField(c.Fields, fieldSubject) && len(meta.Subjects) > 0 {
+		if subjects := util.NormalizeSubjects(meta.Subjects); strings.Join(subjects, "\x00") != strings.Join(meta.Subjects, "\x00") {
+			update.Subjects = subjects
+			changes = append(changes, fieldChange{"主题", strings.Join(meta.Subjects, "; "), strings.Join(subjects, "; ")})
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil, nil
+	}
+	return update, changes, nil
+}
+
+// previousMetadata 返回 update 所修改字段在修改前的值，用于写入操作日志
+func previousMetadata(meta *util.EpubMetadata, update *util.MetadataUpdate) *util.MetadataUpdate {
+	old := &util.MetadataUpdate{}
+	if update.Title != nil {
+		old.Title = &meta.Title
+	}
+	if update.Creators != nil {
+		old.Creators = meta.Creators
+	}
+	if update.Publisher != nil {
+		old.Publisher = &meta.Publisher
+	}
+	if update.Subjects != nil {
+		old.Subjects = meta.Subjects
+	}
+	if update.Series != nil {
+		// 原来没有系列信息时用空系列表示删除
+		old.Series = &util.SeriesInfo{}
+		if meta.Series != nil {
+			old.Series = meta.Series
+		}
+	}
+	return old
+}
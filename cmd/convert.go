@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// ConvertConfig 格式转换命令配置
+type ConvertConfig struct {
+	Paths     []string // 要转换的文件或目录
+	OutputDir string   // 输出目录，为空时与源文件相同
+	Recursive bool     // 递归搜索子目录
+	Encoding  string   // 源文件编码，为空时自动判断
+	Title     string   // 书名，为空时从文件名解析
+	Author    string   // 作者，为空时从文件名解析
+	Language  string   // 语言代码
+	Chapter   string   // 章标题正则
+	Volume    string   // 卷标题正则，为空时不分卷
+	Rules     string   // 书名清理规则文件
+	Overwrite bool     // 覆盖已存在的输出文件，原文件移入回收站
+	DoTry     bool     // 试运行模式
+
+	chapters *util.ChapterRules
+	cleaner  *util.TitleCleaner
+	journal  *util.Journal // 操作日志，试运行时为 nil
+}
+
+var convertConfig = &ConvertConfig{}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "转换电子书格式",
+	Long: `将其他格式的书籍转换为 EPUB
+
+子命令：
+  txt2epub <文件或目录>...  将 TXT 小说转换为 EPUB 3`,
+	Example: `  # 转换单个 TXT 文件
+  bookimporter convert txt2epub "《斗破苍穹》作者：天蚕土豆.txt"`,
+}
+
+var txt2epubCmd = &cobra.Command{
+	Use:   "txt2epub <文件或目录>...",
+	Short: "将 TXT 小说转换为 EPUB 3",
+	Long: `将 TXT 小说转换为 EPUB 3，同时生成 nav 和 NCX 目录
+
+编码：默认自动识别 UTF-8（含 BOM）、UTF-16（含 BOM）、GB18030（兼容 GBK、GB2312）和 Big5，
+识别错误时使用 --encoding 指定，如 gbk、big5、utf-8。
+
+章节：按行匹配标题，去除首尾空白后不超过 40 个字的行才视为标题
+  章标题（--chapter）默认匹配 第X章/回/节、Chapter N，以及序章、楔子、尾声、番外等
+  卷标题（--volume）默认匹配 第X卷/部/集、卷X，卷之后的章节在目录中位于该卷之下；
+                    指定 --volume "" 时不分卷
+第一个标题之前的内容作为 "前言"，没有正文的标题（如开头的目录）被丢弃；
+没有匹配到任何标题时按字数切分。
+
+书名和作者：从文件名解析，如 "《斗破苍穹》（校对版全本）作者：天蚕土豆.txt"
+  书名取书名号中的内容，没有书名号时取 "作者：" 或 " by " 之前的部分，
+  再按与 clname 相同的规则清理（可用 --rules 扩展）；
+  作者取 "作者："、" by " 之后的部分。可使用 --title 和 --author 指定。
+
+EPUB 保存在源文件所在目录（或 --output 指定的目录），文件名与源文件相同。
+输出文件已存在时跳过，指定 --overwrite 时原文件移入回收站。
+生成的文件记录在操作日志中，可使用 bookimporter undo 撤销（移入回收站）。`,
+	Example: `  # 转换目录中的所有 TXT 文件
+  bookimporter convert txt2epub ~/Downloads/novels -r -o ~/Books
+
+  # 指定编码和书名
+  bookimporter convert txt2epub novel.txt --encoding big5 --title 笑傲江湖 --author 金庸
+
+  # 使用自定义章节标题，不分卷
+  bookimporter convert txt2epub novel.txt --chapter '^第\d+话' --volume ''
+
+  # 试运行，只显示解析结果
+  bookimporter convert txt2epub ~/Downloads/novels --do-try`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		convertConfig.Paths = args
+		if err := validateConvertConfig(convertConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed, err := runTxt2Epub(convertConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "转换失败: %v\n", err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	txt2epubCmd.Flags().StringVarP(&convertConfig.OutputDir, "output", "o", "",
+		"输出目录，默认与源文件相同")
+	txt2epubCmd.Flags().BoolVarP(&convertConfig.Recursive, "recursive", "r", false,
+		"递归搜索子目录")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Encoding, "encoding", "",
+		"源文件编码，如 gbk、gb18030、big5、utf-8，默认自动识别")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Title, "title", "",
+		"书名，默认从文件名解析，只能用于单个文件")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Author, "author", "",
+		"作者，多个作者用顿号分隔，默认从文件名解析")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Language, "language", "zh",
+		"书籍语言代码")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Chapter, "chapter", util.DefaultChapterPattern,
+		"章标题正则")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Volume, "volume", util.DefaultVolumePattern,
+		"卷标题正则，为空时不分卷")
+	txt2epubCmd.Flags().StringVar(&convertConfig.Rules, "rules", "",
+		"书名清理规则文件（仅支持 YAML），与 clname --rules 相同")
+	txt2epubCmd.Flags().BoolVar(&convertConfig.Overwrite, "overwrite", false,
+		"覆盖已存在的 EPUB，原文件移入回收站")
+	txt2epubCmd.Flags().BoolVar(&convertConfig.DoTry, "do-try", false,
+		"试运行模式，只显示解析结果，不生成文件")
+
+	convertCmd.AddCommand(txt2epubCmd)
+}
+
+// validateConvertConfig 验证配置并编译章节和书名规则
+func validateConvertConfig(cfg *ConvertConfig) error {
+	for _, p := range cfg.Paths {
+		if !util.Exists(p) {
+			return fmt.Errorf("文件或目录不存在: %s", p)
+		}
+	}
+	if cfg.Title != "" && (len(cfg.Paths) > 1 || util.IsDir(cfg.Paths[0])) {
+		return fmt.Errorf("--title 只能用于单个文件")
+	}
+	if cfg.OutputDir != "" && util.Exists(cfg.OutputDir) && !util.IsDir(cfg.OutputDir) {
+		return fmt.Errorf("输出目录不是目录: %s", cfg.OutputDir)
+	}
+	if strings.TrimSpace(cfg.Chapter) == "" {
+		return fmt.Errorf("--chapter 不能为空")
+	}
+
+	var err error
+	if cfg.chapters, err = util.NewChapterRules(cfg.Chapter, cfg.Volume); err != nil {
+		return err
+	}
+	if cfg.Rules != "" {
+		rules, err := util.LoadTitleRules(cfg.Rules)
+		if err == nil {
+			cfg.cleaner, err = rules.Compile()
+		}
+		if err != nil {
+			return fmt.Errorf("加载规则失败: %w", err)
+		}
+	} else {
+		cfg.cleaner, _ = util.DefaultTitleRules().Compile()
+	}
+	return nil
+}
+
+// runTxt2Epub 转换所有 TXT 文件，返回失败的数量
+func runTxt2Epub(cfg *ConvertConfig) (int, error) {
+	fmt.Println(ui.RenderHeader("TXT 转 EPUB", "识别编码、切分章节并生成 EPUB 3"))
+	fmt.Println()
+
+	files, err := collectTxtFiles(cfg)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		fmt.Println(ui.RenderWarning("没有找到 TXT 文件"))
+		return 0, nil
+	}
+	if cfg.OutputDir != "" && !cfg.DoTry {
+		if err := util.EnsureDir(cfg.OutputDir); err != nil {
+			return 0, fmt.Errorf("无法创建输出目录: %w", err)
+		}
+	}
+
+	journal, err := openJournal("convert", cfg.DoTry)
+	if err != nil {
+		return 0, err
+	}
+	cfg.journal = journal
+	defer closeJournal(journal, os.Stdout)
+
+	converted, skipped, failed := 0, 0, 0
+	targets := make(map[string]bool)
+	for i, file := range files {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("[%d/%d] %s", i+1, len(files), file)))
+		target := txtEpubPath(cfg, file)
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			abs = target
+		}
+		if targets[abs] {
+			fmt.Println(ui.RenderSkip(fmt.Sprintf("跳过：与其他文件的输出路径相同 %s", target)))
+			skipped++
+			continue
+		}
+		targets[abs] = true
+		if util.Exists(target) && !cfg.Overwrite {
+			fmt.Println(ui.RenderSkip(fmt.Sprintf("跳过：%s 已存在，使用 --overwrite 覆盖", target)))
+			skipped++
+			continue
+		}
+
+		if err := convertTxtFile(cfg, file, target); err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("转换失败: %v", err)))
+			failed++
+			continue
+		}
+		converted++
+	}
+
+	printConvertStats(cfg, len(files), converted, skipped, failed)
+	return failed, nil
+}
+
+// collectTxtFiles 收集参数中的 TXT 文件，目录中只查找 .txt 文件
+func collectTxtFiles(cfg *ConvertConfig) ([]string, error) {
+	filter := &util.FileFilter{Formats: []string{"txt"}}
+	var files []string
+	for _, p := range cfg.Paths {
+		if !util.IsDir(p) {
+			files = append(files, p)
+			continue
+		}
+		found, err := util.FindFiles(p, filter, cfg.Recursive)
+		if err != nil {
+			return nil, fmt.Errorf("查找文件失败: %w", err)
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// txtEpubPath 返回 TXT 文件对应的 EPUB 路径
+func txtEpubPath(cfg *ConvertConfig, file string) string {
+	dir := filepath.Dir(file)
+	if cfg.OutputDir != "" {
+		dir = cfg.OutputDir
+	}
+	base := filepath.Base(file)
+	return filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base))+".epub")
+}
+
+// convertTxtFile 转换一个文件并记录操作日志
+func convertTxtFile(cfg *ConvertConfig, file, target string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	text, encodingName, err := util.DecodeText(data, cfg.Encoding)
+	if err != nil {
+		return err
+	}
+
+	title, authors := cfg.cleaner.ParseFileName(file)
+	if cfg.Title != "" {
+		title = cfg.Title
+	}
+	if cfg.Author != "" {
+		authors = nil
+		for _, c := range util.NormalizeCreators([]util.Creator{{Name: cfg.Author}}) {
+			authors = append(authors, c.Name)
+		}
+	}
+	book := &util.TxtBook{
+		Title:    title,
+		Authors:  authors,
+		Language: cfg.Language,
+		Chapters: cfg.chapters.Split(text),
+	}
+	printTxtBook(book, encodingName)
+
+	if cfg.DoTry {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("[试运行] 将生成 %s", target)))
+		return nil
+	}
+
+	// 新文件写入成功后才把已存在的文件移入回收站，写入失败时原文件保持不变
+	var trashed *util.TrashItem
+	err = book.ReplaceEpub(target, func() error {
+		if !util.Exists(target) {
+			return nil
+		}
+		item, err := util.SafeDeleteFile(target, "被 convert txt2epub 覆盖", false)
+		if err != nil {
+			return fmt.Errorf("无法移走已存在的文件: %w", err)
+		}
+		trashed = item
+		return nil
+	})
+	if err != nil {
+		if trashed != nil {
+			util.RestoreTrashedFile(trashed.Path)
+		}
+		return err
+	}
+	if trashed != nil {
+		recordJournal(cfg.journal, util.JournalEntry{Op: util.JournalDelete, Path: target, NewPath: trashed.Path}, os.Stdout)
+	}
+	recordJournal(cfg.journal, util.JournalEntry{Op: util.JournalCreate, Path: target}, os.Stdout)
+	fmt.Println(ui.FormatFileOperation("生成", file, target))
+	return nil
+}
+
+// printTxtBook 打印解析出的书名、作者、编码和章节数
+func printTxtBook(book *util.TxtBook, encodingName string) {
+	volumes := 0
+	for _, c := range book.Chapters {
+		if c.Volume {
+			volumes++
+		}
+	}
+	authors := strings.Join(book.Authors, "、")
+	if authors == "" {
+		authors = "（未知）"
+	}
+	structure := fmt.Sprintf("%d 章", len(book.Chapters)-volumes)
+	if volumes > 0 {
+		structure = fmt.Sprintf("%d 卷 %s", volumes, structure)
+	}
+	fmt.Printf("  书名: %s  作者: %s  编码: %s  章节: %s\n", book.Title, authors, encodingName, structure)
+}
+
+// printConvertStats 打印统计信息
+func printConvertStats(cfg *ConvertConfig, total, converted, skipped, failed int) {
+	fmt.Println()
+	fmt.Println(ui.RenderSeparator(60))
+	fmt.Println()
+
+	tableConfig := ui.NewTableConfig()
+	tableConfig.Headers = []string{"  项目  ", " 值 "}
+	tableConfig.BorderStyle = "rounded"
+	tableConfig.AlignRight = []int{1}
+
+	rows := [][]string{
+		{" 文件总数 ", fmt.Sprintf(" %d ", total)},
+	}
+	if cfg.DoTry {
+		rows = append(rows, []string{" 模式 ", " 预览模式 "})
+	}
+	rows = append(rows, []string{" 转换 ", fmt.Sprintf(" %d ", converted)})
+	if skipped > 0 {
+		rows = append(rows, []string{" 跳过 ", fmt.Sprintf(" %d ", skipped)})
+	}
+	if failed > 0 {
+		rows = append(rows, []string{" 失败 ", fmt.Sprintf(" %d ", failed)})
+	}
+
+	tableConfig.Rows = rows
+	fmt.Println(ui.NewTable(tableConfig).Render())
+	fmt.Println()
+
+	if cfg.DoTry {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将转换 %d 个文件", converted)))
+	} else if converted > 0 {
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功转换 %d 个文件", converted)))
+	}
+	if failed > 0 {
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("⚠️  %d 个文件转换失败", failed)))
+	}
+}
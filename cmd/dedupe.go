@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// DedupeConfig 查找重复书籍命令配置
+type DedupeConfig struct {
+	Dirs      []string // 要查找的目录，可以有多个
+	By        []string // 判断重复的依据
+	Formats   []string // 文件格式过滤
+	Filter    FilterFlags
+	Recursive bool   // 递归搜索子目录
+	MoveTo    string // 将多余的副本移动到该目录
+	Jobs      int    // 并发读取的文件数
+	DoTry     bool   // 试运行模式
+
+	keys    []util.DedupeKey // 由 By 解析
+	filter  *util.FileFilter // 由 Formats 和 Filter 解析
+	journal *util.Journal    // 操作日志，试运行时为 nil
+}
+
+// dedupeKeyNames 判断依据的显示名称
+var dedupeKeyNames = map[util.DedupeKey]string{
+	util.DedupeHash:  "内容相同",
+	util.DedupeTitle: "书名+作者",
+	util.DedupeISBN:  "ISBN/标识符",
+}
+
+var dedupeConfig = &DedupeConfig{}
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe <目录>...",
+	Short: "查找重复的书籍，保留最好的副本",
+	Long: `按内容、书名和作者、ISBN 查找重复的书籍
+
+判断依据（--by，可组合，默认全部）：
+  hash   文件内容的 SHA-256 相同
+  title  书名和作者相同：书名先经过与 clname 相同的清理，
+         再忽略大小写、全半角、空白和标点；作者与顺序无关
+  isbn   元数据中的 ISBN 相同，没有 ISBN 时比较 uuid 或 MOBI 的 ASIN；
+         calibre 编号等书库内的标识符不参与比较
+
+EPUB、MOBI/AZW3、PDF、FB2 按各自的格式检测完整性和读取元数据；
+其他格式只能按 hash 查找，保留的副本按大小和修改时间选择。
+
+任一依据相同的文件归为一组（传递关联）。每组中按以下顺序选出保留的副本：
+完整性检测通过 > 文件更大 > 修改时间更新。
+
+默认只报告重复组；指定 --move-to 时将其余副本移动到该目录，
+重名时添加 (1)、(2) 等序号后缀。移动操作记录在操作日志中，可使用 bookimporter undo 撤销。`,
+	Example: `  # 查找书库中的重复书籍
+  bookimporter dedupe ~/Books -r
+
+  # 只按内容查找完全相同的文件
+  bookimporter dedupe ~/Books -r --by hash
+
+  # 比较下载目录和书库，将多余的副本移走
+  bookimporter dedupe ~/Downloads ~/Books -r --move-to ~/duplicates`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dedupeConfig.Dirs = args
+		if err := validateDedupeConfig(dedupeConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed, err := runDedupe(dedupeConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "查找重复失败: %v\n", err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	dedupeCmd.Flags().StringSliceVar(&dedupeConfig.By, "by", []string{"hash", "title", "isbn"},
+		"判断重复的依据：hash、title、isbn，用逗号分隔")
+	dedupeCmd.Flags().StringArrayVarP(&dedupeConfig.Formats, "format", "f", []string{"epub"},
+		"要查找的文件扩展名或 glob 模式，可多次使用")
+	dedupeCmd.Flags().BoolVarP(&dedupeConfig.Recursive, "recursive", "r", false,
+		"递归搜索子目录")
+	dedupeCmd.Flags().StringVar(&dedupeConfig.MoveTo, "move-to", "",
+		"将多余的副本移动到该目录，不指定时只报告")
+	dedupeCmd.Flags().IntVarP(&dedupeConfig.Jobs, "jobs", "j", defaultJobs,
+		"并发读取的文件数")
+	dedupeCmd.Flags().BoolVar(&dedupeConfig.DoTry, "do-try", false,
+		"试运行模式，只显示将要移动的文件")
+
+	bindFilterFlags(dedupeCmd, &dedupeConfig.Filter)
+}
+
+// validateDedupeConfig 验证配置并解析判断依据
+func validateDedupeConfig(cfg *DedupeConfig) error {
+	for _, dir := range cfg.Dirs {
+		if !util.IsDir(dir) {
+			return fmt.Errorf("目录不存在: %s", dir)
+		}
+	}
+	if cfg.MoveTo != "" && util.Exists(cfg.MoveTo) && !util.IsDir(cfg.MoveTo) {
+		return fmt.Errorf("移动目标不是目录: %s", cfg.MoveTo)
+	}
+	if err := validateJobs(cfg.Jobs); err != nil {
+		return err
+	}
+
+	cfg.keys = nil
+	for _, by := range cfg.By {
+		key := util.DedupeKey(strings.ToLower(strings.TrimSpace(by)))
+		if _, ok := dedupeKeyNames[key]; !ok {
+			return fmt.Errorf("未知的判断依据 %q，可选: hash、title、isbn", by)
+		}
+		cfg.keys = append(cfg.keys, key)
+	}
+	if len(cfg.keys) == 0 {
+		return fmt.Errorf("--by 不能为空")
+	}
+
+	var err error
+	cfg.filter, err = cfg.Filter.build(cfg.Formats)
+	return err
+}
+
+// runDedupe 查找并处理重复书籍，返回移动失败的文件数
+func runDedupe(cfg *DedupeConfig) (int, error) {
+	fmt.Println(ui.RenderHeader("查找重复书籍", "按内容、书名和作者、ISBN 比较"))
+	fmt.Println()
+
+	files, err := collectDedupeFiles(cfg)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) < 2 {
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("找到 %d 个文件，无需比较", len(files))))
+		return 0, nil
+	}
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个文件，正在读取...", len(files))))
+
+	type result struct {
+		fp  *util.BookFingerprint
+		err error
+	}
+	var books []*util.BookFingerprint
+	util.RunOrdered(len(files), cfg.Jobs, func(i int) result {
+		fp, err := util.ReadBookFingerprint(files[i], cfg.keys)
+		return result{fp, err}
+	}, func(i int, r result) {
+		if r.err != nil {
+			fmt.Println(ui.RenderWarning(fmt.Sprintf("跳过 %s: %v", files[i], r.err)))
+			return
+		}
+		books = append(books, r.fp)
+	})
+
+	groups := util.GroupDuplicates(books, cfg.keys)
+	if len(groups) == 0 {
+		fmt.Println()
+		fmt.Println(ui.RenderSuccess("✨ 没有发现重复的书籍"))
+		return 0, nil
+	}
+
+	// 只检测重复组中的文件，用于选出保留的副本
+	var candidates []*util.BookFingerprint
+	for _, g := range groups {
+		candidates = append(candidates, g.Books...)
+	}
+	// 不支持的格式不做检测，按大小和修改时间选择
+	util.RunOrdered(len(candidates), cfg.Jobs, func(i int) error {
+		if util.FormatOf(candidates[i].Path) == nil {
+			return nil
+		}
+		return util.ValidateBookFile(candidates[i].Path)
+	}, func(i int, err error) {
+		candidates[i].Checked = util.FormatOf(candidates[i].Path) != nil
+		candidates[i].ValidErr = err
+	})
+	for i := range groups {
+		groups[i].SortByPreference()
+	}
+
+	journal, err := openJournal("dedupe", cfg.DoTry || cfg.MoveTo == "")
+	if err != nil {
+		return 0, err
+	}
+	cfg.journal = journal
+	defer closeJournal(journal, os.Stdout)
+
+	// 试运行时记录已分配的目标路径，同名文件与实际移动一样添加序号后缀
+	reserved := make(map[string]bool)
+	duplicates, moved, failed := 0, 0, 0
+	var wasted int64
+	for i, g := range groups {
+		fmt.Println()
+		printDuplicateGroup(i+1, g)
+		for _, b := range g.Books[1:] {
+			duplicates++
+			wasted += b.Size
+			if cfg.MoveTo == "" {
+				continue
+			}
+			if cfg.DoTry {
+				preview, err := util.AvailablePath(cfg.MoveTo, filepath.Base(b.Path), reserved)
+				if err != nil {
+					fmt.Println(ui.RenderError(fmt.Sprintf("移动失败 %s: %v", b.Path, err)))
+					failed++
+					continue
+				}
+				reserved[preview] = true
+				fmt.Println(ui.FormatRenamePreview(b.Path, preview))
+				moved++
+				continue
+			}
+			newPath, err := util.MoveFileAs(b.Path, cfg.MoveTo, filepath.Base(b.Path))
+			if err != nil {
+				fmt.Println(ui.RenderError(fmt.Sprintf("移动失败 %s: %v", b.Path, err)))
+				failed++
+				continue
+			}
+			fmt.Println(ui.FormatRenamePreview(b.Path, newPath))
+			recordJournal(cfg.journal, util.JournalEntry{Op: util.JournalMove, Path: b.Path, NewPath: newPath}, os.Stdout)
+			moved++
+		}
+	}
+
+	printDedupeStats(cfg, len(books), len(groups), duplicates, wasted, moved, failed)
+	return failed, nil
+}
+
+// collectDedupeFiles 收集所有目录中的文件，同一文件只保留一次
+func collectDedupeFiles(cfg *DedupeConfig) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, dir := range cfg.Dirs {
+		found, err := util.FindFiles(dir, cfg.filter, cfg.Recursive)
+		if err != nil {
+			return nil, fmt.Errorf("查找文件失败: %w", err)
+		}
+		for _, file := range found {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				abs = file
+			}
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// printDuplicateGroup 以表格打印一组重复的书籍，第一行为保留的副本
+func printDuplicateGroup(n int, g util.DuplicateGroup) {
+	names := make([]string, len(g.Keys))
+	for i, key := range g.Keys {
+		names[i] = dedupeKeyNames[key]
+	}
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("重复组 %d（%s）", n, strings.Join(names, "、"))))
+
+	tableConfig := ui.NewTableConfig()
+	tableConfig.Headers = []string{" 处理 ", " 文件 ", " 大小 ", " 修改时间 ", " 检测 "}
+	tableConfig.BorderStyle = "rounded"
+	tableConfig.AlignRight = []int{2}
+
+	var rows [][]string
+	for i, b := range g.Books {
+		action := " 重复 "
+		if i == 0 {
+			action = " 保留 "
+		}
+		status := " 通过 "
+		if !b.Checked {
+			status = " 未检测 "
+		} else if b.ValidErr != nil {
+			status = " 损坏 "
+		}
+		rows = append(rows, []string{
+			action,
+			fmt.Sprintf(" %s ", truncatePathLeft(b.Path, 60)),
+			fmt.Sprintf(" %s ", util.FormatSize(b.Size)),
+			fmt.Sprintf(" %s ", b.ModTime.Format("2006-01-02 15:04")),
+			status,
+		})
+	}
+	tableConfig.Rows = rows
+	fmt.Println(ui.NewTable(tableConfig).Render())
+}
+
+// printDedupeStats 打印统计信息
+func printDedupeStats(cfg *DedupeConfig, total, groups, duplicates int, wasted int64, moved, failed int) {
+	fmt.Println()
+	fmt.Println(ui.RenderSeparator(60))
+	fmt.Println()
+
+	tableConfig := ui.NewTableConfig()
+	tableConfig.Headers = []string{"  项目  ", " 值 "}
+	tableConfig.BorderStyle = "rounded"
+	tableConfig.AlignRight = []int{1}
+
+	rows := [][]string{
+		{" 文件总数 ", fmt.Sprintf(" %d ", total)},
+		{" 重复组 ", fmt.Sprintf(" %d ", groups)},
+		{" 多余副本 ", fmt.Sprintf(" %d ", duplicates)},
+		{" 占用空间 ", fmt.Sprintf(" %s ", util.FormatSize(wasted))},
+	}
+	if cfg.MoveTo != "" {
+		if cfg.DoTry {
+			rows = append(rows, []string{" 模式 ", " 预览模式 "})
+		}
+		rows = append(rows, []string{" 移动 ", fmt.Sprintf(" %d ", moved)})
+		if failed > 0 {
+			rows = append(rows, []string{" 失败 ", fmt.Sprintf(" %d ", failed)})
+		}
+	}
+
+	tableConfig.Rows = rows
+	fmt.Println(ui.NewTable(tableConfig).Render())
+	fmt.Println()
+
+	switch {
+	case cfg.MoveTo == "":
+		fmt.Println(ui.RenderInfo("使用 --move-to <目录> 移走多余的副本"))
+	case cfg.DoTry:
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将移动 %d 个副本到: %s", moved, cfg.MoveTo)))
+	case moved > 0:
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 已移动 %d 个副本到: %s", moved, cfg.MoveTo)))
+	}
+	if failed > 0 {
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("⚠️  %d 个文件移动失败", failed)))
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jianyun8023/bookimporter/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// FilterFlags rename 和 organize 共用的文件过滤参数
+type FilterFlags struct {
+	Include   []string // 文件名需匹配的正则
+	Exclude   []string // 排除文件名匹配的正则
+	MinSize   string   // 最小大小，如 100K
+	MaxSize   string   // 最大大小，如 50M
+	NewerThan string   // 修改时间晚于，如 2024-01-01 或 7d
+	OlderThan string   // 修改时间早于
+	Hidden    bool     // 包含隐藏文件和目录
+}
+
+// bindFilterFlags 为命令添加文件过滤参数
+func bindFilterFlags(cmd *cobra.Command, f *FilterFlags) {
+	cmd.Flags().StringArrayVar(&f.Include, "include", nil,
+		"只处理文件名匹配该正则的文件，可多次使用")
+	cmd.Flags().StringArrayVar(&f.Exclude, "exclude", nil,
+		"排除文件名匹配该正则的文件，可多次使用")
+	cmd.Flags().StringVar(&f.MinSize, "min-size", "",
+		"最小文件大小，如 100K、1.5M")
+	cmd.Flags().StringVar(&f.MaxSize, "max-size", "",
+		"最大文件大小，如 50M、1G")
+	cmd.Flags().StringVar(&f.NewerThan, "newer-than", "",
+		"只处理修改时间晚于该时间的文件，如 2024-01-01、7d（7 天内）")
+	cmd.Flags().StringVar(&f.OlderThan, "older-than", "",
+		"只处理修改时间早于该时间的文件，如 2024-01-01、30d（30 天前）")
+	cmd.Flags().BoolVar(&f.Hidden, "hidden", false,
+		"包含以 . 开头的隐藏文件和目录")
+}
+
+// build 解析过滤参数，formats 为 -f 指定的扩展名或 glob 模式
+func (f *FilterFlags) build(formats []string) (*util.FileFilter, error) {
+	filter := &util.FileFilter{Formats: formats, Hidden: f.Hidden}
+
+	for _, pattern := range f.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 --include 正则 %q: %w", pattern, err)
+		}
+		filter.Include = append(filter.Include, re)
+	}
+	for _, pattern := range f.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 --exclude 正则 %q: %w", pattern, err)
+		}
+		filter.Exclude = append(filter.Exclude, re)
+	}
+
+	var err error
+	if f.MinSize != "" {
+		if filter.MinSize, err = util.ParseSize(f.MinSize); err != nil {
+			return nil, fmt.Errorf("--min-size: %w", err)
+		}
+	}
+	if f.MaxSize != "" {
+		if filter.MaxSize, err = util.ParseSize(f.MaxSize); err != nil {
+			return nil, fmt.Errorf("--max-size: %w", err)
+		}
+	}
+
+	now := time.Now()
+	if f.NewerThan != "" {
+		if filter.After, err = util.ParseTimeFilter(f.NewerThan, now); err != nil {
+			return nil, fmt.Errorf("--newer-than: %w", err)
+		}
+	}
+	if f.OlderThan != "" {
+		if filter.Before, err = util.ParseTimeFilter(f.OlderThan, now); err != nil {
+			return nil, fmt.Errorf("--older-than: %w", err)
+		}
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
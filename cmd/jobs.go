@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// defaultJobs 默认并发数，与 CPU 核心数一致
+var defaultJobs = runtime.NumCPU()
+
+// validateJobs 验证 --jobs 参数
+func validateJobs(jobs int) error {
+	if jobs < 1 {
+		return fmt.Errorf("--jobs 必须大于 0，当前为 %d", jobs)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+)
+
+// openJournal 为会修改文件的命令创建操作日志，试运行时不记录，返回 nil
+func openJournal(command string, doTry bool) (*util.Journal, error) {
+	if doTry {
+		return nil, nil
+	}
+	dir, err := util.DefaultJournalDir()
+	if err != nil {
+		return nil, err
+	}
+	return util.CreateJournal(dir, command)
+}
+
+// recordJournal 写入一条操作记录，失败时只输出警告，不影响已完成的操作
+func recordJournal(journal *util.Journal, entry util.JournalEntry, out io.Writer) {
+	if err := journal.Record(entry); err != nil {
+		fmt.Fprintln(out, ui.RenderWarning(fmt.Sprintf("记录操作日志失败: %v", err)))
+	}
+}
+
+// closeJournal 关闭操作日志，有记录时提示撤销方法
+func closeJournal(journal *util.Journal, out io.Writer) {
+	n := journal.Len()
+	journal.Close()
+	if n > 0 {
+		fmt.Fprintln(out, ui.RenderInfo(fmt.Sprintf("操作日志: %s（%d 项，可使用 bookimporter undo 撤销）", journal.Path(), n)))
+	}
+}
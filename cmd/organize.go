@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// OrganizeConfig 整理命令配置
+type OrganizeConfig struct {
+	SourceDir  string   // 源目录
+	OutputDir  string   // 书库目录
+	Template   string   // 路径模板，优先于 Layout
+	Layout     string   // 预设布局
+	Formats    []string // 文件格式过滤
+	Filter     FilterFlags
+	Recursive  bool // 递归搜索子目录
+	StartIndex int  // {n} 的起始序号
+	DoTry      bool // 试运行模式
+
+	template *util.PathTemplate // 由 Template 或 Layout 解析
+	filter   *util.FileFilter   // 由 Formats 和 Filter 解析
+	journal  *util.Journal      // 操作日志，试运行时为 nil
+}
+
+// organizeLayouts 预设的目录布局
+var organizeLayouts = map[string]string{
+	"author":  "{author|未知作者}/{title}",
+	"series":  "{author|未知作者}/{series|}/{title}",
+	"calibre": "{author|Unknown}/{title}/{title} - {author|Unknown}",
+}
+
+var organizeConfig = &OrganizeConfig{}
+
+var organizeCmd = &cobra.Command{
+	Use:   "organize <源目录>",
+	Short: "按作者、系列等元数据将书籍整理到目录结构中",
+	Long: `按路径模板将书籍移动到书库的目录结构中
+
+模板以 "/" 分隔目录层级，每一级都可以使用 rename 的占位符：
+  {title} {author} {series} {index} {year} {isbn} {ext} {parent} {name} {n}
+并支持 {字段:0N} 补零和 {字段|默认值}。最后一级为文件名，没有 {ext} 时自动保留扩展名。
+目录一级的结果为空时省略该级，如 "{author}/{series|}/{title}" 中
+没有系列的书籍直接放在作者目录下。
+
+预设布局（--layout）：
+  author   {author|未知作者}/{title}
+  series   {author|未知作者}/{series|}/{title}
+  calibre  {author|Unknown}/{title}/{title} - {author|Unknown}
+
+文件过滤参数与 rename 相同：-f 为扩展名或 glob 模式，另有 --include、--exclude、
+--min-size、--max-size、--newer-than、--older-than 和 --hidden。
+
+目录按需创建；字段值中的 / \ : * ? " < > | 替换为 _。
+目标文件已存在时与 check --move-to 相同，自动添加 (1)、(2) 等序号后缀。
+已在目标位置的文件跳过；字段为空且没有默认值的文件跳过并给出提示。
+移动操作记录在操作日志中，可使用 bookimporter undo 撤销。`,
+	Example: `  # 按 作者/书名.epub 整理到书库
+  bookimporter organize ~/Downloads -o ~/Library -r
+
+  # 按 作者/系列/书名.epub 整理
+  bookimporter organize ~/Downloads -o ~/Library --layout series
+
+  # 自定义模板
+  bookimporter organize ~/Downloads -o ~/Library -t "{author}/{series|}/{index:02|} {title}"
+
+  # 预览整理结果
+  bookimporter organize ~/Downloads -o ~/Library -r --do-try`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		organizeConfig.SourceDir = args[0]
+		if err := validateOrganizeConfig(organizeConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed, err := runOrganize(organizeConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "整理失败: %v\n", err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	organizeCmd.Flags().StringVarP(&organizeConfig.OutputDir, "output", "o", "",
+		"书库目录（必需）")
+	organizeCmd.Flags().StringVarP(&organizeConfig.Template, "template", "t", "",
+		"路径模板，如 '{author}/{series|}/{title}'，指定后忽略 --layout")
+	organizeCmd.Flags().StringVar(&organizeConfig.Layout, "layout", "author",
+		"预设布局：author、series、calibre")
+	organizeCmd.Flags().StringArrayVarP(&organizeConfig.Formats, "format", "f", []string{"epub"},
+		"要整理的文件扩展名或 glob 模式，可多次使用")
+	organizeCmd.Flags().BoolVarP(&organizeConfig.Recursive, "recursive", "r", false,
+		"递归搜索子目录")
+	organizeCmd.Flags().IntVar(&organizeConfig.StartIndex, "start-num", 1,
+		"{n} 的起始序号")
+	organizeCmd.Flags().BoolVar(&organizeConfig.DoTry, "do-try", false,
+		"试运行模式，只显示整理结果，不移动文件")
+
+	bindFilterFlags(organizeCmd, &organizeConfig.Filter)
+
+	organizeCmd.MarkFlagRequired("output")
+}
+
+// validateOrganizeConfig 验证配置并解析模板
+func validateOrganizeConfig(cfg *OrganizeConfig) error {
+	if !util.IsDir(cfg.SourceDir) {
+		return fmt.Errorf("目录不存在: %s", cfg.SourceDir)
+	}
+	if cfg.OutputDir == "" {
+		return fmt.Errorf("必须指定 --output 参数")
+	}
+	if util.Exists(cfg.OutputDir) && !util.IsDir(cfg.OutputDir) {
+		return fmt.Errorf("书库路径不是目录: %s", cfg.OutputDir)
+	}
+
+	template := cfg.Template
+	if template == "" {
+		var ok bool
+		if template, ok = organizeLayouts[cfg.Layout]; !ok {
+			names := make([]string, 0, len(organizeLayouts))
+			for name := range organizeLayouts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("未知的布局 %q，可选: %s", cfg.Layout, strings.Join(names, "、"))
+		}
+	}
+
+	var err error
+	if cfg.filter, err = cfg.Filter.build(cfg.Formats); err != nil {
+		return err
+	}
+	cfg.template, err = util.ParsePathTemplate(template)
+	return err
+}
+
+// runOrganize 执行整理，返回失败的文件数
+func runOrganize(cfg *OrganizeConfig) (int, error) {
+	fmt.Println(ui.RenderHeader("整理书库", "按元数据将书籍移动到目录结构中"))
+	fmt.Println()
+
+	files, err := util.FindFiles(cfg.SourceDir, cfg.filter, cfg.Recursive)
+	if err != nil {
+		return 0, fmt.Errorf("查找文件失败: %w", err)
+	}
+	if len(files) == 0 {
+		fmt.Println(ui.RenderWarning("未找到匹配的文件"))
+		return 0, nil
+	}
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个文件，模板: %s", len(files), cfg.template)))
+	fmt.Println()
+
+	journal, err := openJournal("organize", cfg.DoTry)
+	if err != nil {
+		return 0, err
+	}
+	cfg.journal = journal
+	defer closeJournal(journal, os.Stdout)
+
+	// 试运行时记录已分配的目标路径，重名的书籍与实际移动一样添加序号后缀
+	reserved := make(map[string]bool)
+	moved, skipped, failed := 0, 0, 0
+	for i, file := range files {
+		target, err := organizeTarget(cfg, cfg.StartIndex+i, file)
+		if err != nil {
+			fmt.Println(ui.RenderWarning(fmt.Sprintf("跳过 %s: %s", file, err)))
+			skipped++
+			continue
+		}
+		if alreadyOrganized(file, target) {
+			skipped++
+			continue
+		}
+
+		if cfg.DoTry {
+			preview, err := util.AvailablePath(filepath.Dir(target), filepath.Base(target), reserved)
+			if err != nil {
+				fmt.Println(ui.RenderError(fmt.Sprintf("移动失败 %s: %v", file, err)))
+				failed++
+				continue
+			}
+			reserved[preview] = true
+			fmt.Println(ui.FormatRenamePreview(file, preview))
+			moved++
+			continue
+		}
+
+		newPath, err := util.MoveFileAs(file, filepath.Dir(target), filepath.Base(target))
+		if err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("移动失败 %s: %v", file, err)))
+			failed++
+			continue
+		}
+		fmt.Println(ui.FormatRenamePreview(file, newPath))
+		recordJournal(cfg.journal, util.JournalEntry{Op: util.JournalMove, Path: file, NewPath: newPath}, os.Stdout)
+		moved++
+	}
+
+	printOrganizeStats(cfg, len(files), moved, skipped, failed)
+	return failed, nil
+}
+
+// organizeTarget 返回文件在书库中的目标路径
+func organizeTarget(cfg *OrganizeConfig, index int, file string) (string, error) {
+	values, err := templateValues(file, index, cfg.template.NeedsMetadata())
+	if err != nil {
+		return "", err
+	}
+	rel, err := cfg.template.Execute(values)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cfg.OutputDir, rel), nil
+}
+
+// alreadyOrganized 判断文件是否已在目标位置
+// 之前因重名添加了 (1)、(2) 等后缀的文件也视为已整理，避免重复运行时再次改名
+func alreadyOrganized(file, target string) bool {
+	absFile, errA := filepath.Abs(file)
+	absTarget, errB := filepath.Abs(target)
+	if errA != nil || errB != nil || filepath.Dir(absFile) != filepath.Dir(absTarget) {
+		return false
+	}
+	if absFile == absTarget {
+		return true
+	}
+
+	ext := util.FileExt(absTarget)
+	stem := strings.TrimSuffix(filepath.Base(absTarget), ext)
+	name := filepath.Base(absFile)
+	if !strings.HasPrefix(name, stem+"(") || !strings.HasSuffix(name, ")"+ext) {
+		return false
+	}
+	n := strings.TrimSuffix(strings.TrimPrefix(name, stem+"("), ")"+ext)
+	_, err := strconv.Atoi(n)
+	return err == nil
+}
+
+// printOrganizeStats 打印统计信息
+func printOrganizeStats(cfg *OrganizeConfig, total, moved, skipped, failed int) {
+	fmt.Println()
+	fmt.Println(ui.RenderSeparator(60))
+	fmt.Println()
+
+	tableConfig := ui.NewTableConfig()
+	tableConfig.Headers = []string{"  项目  ", " 值 "}
+	tableConfig.BorderStyle = "rounded"
+	tableConfig.AlignRight = []int{1}
+
+	rows := [][]string{
+		{" 文件总数 ", fmt.Sprintf(" %d ", total)},
+		{" 书库目录 ", fmt.Sprintf(" %s ", cfg.OutputDir)},
+	}
+	if cfg.DoTry {
+		rows = append(rows, []string{" 模式 ", " 预览模式 "})
+	}
+	rows = append(rows, []string{" 移动 ", fmt.Sprintf(" %d ", moved)})
+	if skipped > 0 {
+		rows = append(rows, []string{" 跳过 ", fmt.Sprintf(" %d ", skipped)})
+	}
+	if failed > 0 {
+		rows = append(rows, []string{" 失败 ", fmt.Sprintf(" %d ", failed)})
+	}
+
+	tableConfig.Rows = rows
+	fmt.Println(ui.NewTable(tableConfig).Render())
+	fmt.Println()
+
+	if cfg.DoTry {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将移动 %d 个文件到: %s", moved, cfg.OutputDir)))
+	} else if moved > 0 {
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功整理 %d 个文件到: %s", moved, cfg.OutputDir)))
+	}
+	if failed > 0 {
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("⚠️  %d 个文件移动失败", failed)))
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
 
 	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
 	"github.com/spf13/cobra"
 )
 
+var renameFilterFlags = &FilterFlags{}
+
 var renameCmd = &cobra.Command{
 	Use:   "rename <目录路径>",
 	Short: "按自定义模板批量重命名或移动文件",
@@ -20,20 +23,44 @@ rename 命令可以根据指定的模板批量重命名文件，支持序列号
 常用于整理大量文件，使其具有统一的命名规则。
 
 核心功能：
-  • 支持自定义文件名模板（使用 @n 作为序号占位符）
-  • 支持多种文件格式过滤
+  • 支持自定义文件名模板（序号或书籍元数据占位符）
+  • 按扩展名、glob 模式、正则、大小和修改时间过滤文件
   • 支持递归搜索子目录
   • 支持移动文件到指定目录
   • 提供预览模式，查看重命名结果
   • 自动保留原始文件扩展名
 
-序号占位符：
-  @n  - 会被替换为实际的序列号
-  
+占位符：
+  @n、{n}    序列号
+  {title}    书名            {author}  作者（多个用 "、" 连接）
+  {series}   系列名          {index}   在系列中的序号
+  {year}     出版年份        {isbn}    ISBN
+  {ext}      扩展名（不含点）{parent}  所在目录名
+  {name}     原文件名（不含扩展名）
+
+  书名、作者等元数据字段从 EPUB、MOBI/AZW3、PDF 和 FB2/FB2.ZIP 文件中读取，
+  其他格式的文件没有这些字段；fb2.zip 的 {ext} 为 "fb2.zip"。
+  {字段:0N} 将数字补零到 N 位，如 {n:03} → 001；
+  {字段|默认值} 在字段为空时使用默认值，如 {author|未知作者}。
+  字段为空且没有默认值的文件会被跳过。
+
+文件过滤：
+  -f 为扩展名（txt、fb2.zip）或文件名 glob 模式（*.epub、vol-??.pdf），不区分大小写
+  --include/--exclude 按正则匹配文件名，--min-size/--max-size 按大小，
+  --newer-than/--older-than 按修改时间（2024-01-01 或 7d、12h 等时长）过滤；
+  默认跳过以 . 开头的隐藏文件和目录，使用 --hidden 包含
+
 重要说明：
-  • 模板中必须包含 @n 占位符
-  • 文件扩展名会自动添加，无需在模板中指定
+  • 模板中至少要有一个占位符
+  • 模板中没有 {ext} 时自动添加原扩展名
+  • 字段值中的 / \ : * ? " < > | 会替换为 _
+  • 执行前先生成完整的计划：目标与已有文件或其他文件的目标重名时按 --on-conflict 处理
+    （skip 跳过，suffix 添加 (1)、(2) 等序号后缀，fail 不执行任何重命名），不会覆盖文件
+  • a→b、b→a 这样的交换和环形重命名会经过临时文件名完成
   • 序列号默认从 1 开始，可通过 --start-num 自定义
+  • 序号按 --sort 指定的顺序分配：name（默认，按文件名逐字节）、natural（数字按数值比较，
+    ch2 在 ch10 之前，第二章在第十二章之前）、mtime、size、title（电子书书名）；
+    --reverse 倒序，--per-dir 使每个目录从起始序号重新编号
   • 使用 --do-try 可以先预览结果，确认无误后再执行`,
 	Example: `  # 基础用法：重命名当前目录下的 txt 文件
   bookimporter rename . -f txt -t "book-@n"
@@ -48,6 +75,21 @@ rename 命令可以根据指定的模板批量重命名文件，支持序列号
   # 重命名并移动到新目录（整理文件）
   bookimporter rename /source -f jpg -t "photo-@n" -o /photos
   
+  # 按元数据命名：作者 - 书名.epub
+  bookimporter rename /path/to/books -f epub -t "{author|未知作者} - {title}"
+
+  # 按系列整理，序号补零
+  bookimporter rename /path/to/books -f epub -t "{series} {index:02} - {title}"
+
+  # 只处理 1MB 以上、最近 7 天修改、文件名不含 sample 的 PDF
+  bookimporter rename . -f pdf -t "doc-@n" --min-size 1M --newer-than 7d --exclude sample
+
+  # 按章节号自然排序编号，第十二章排在第二章之后
+  bookimporter rename ./novel -f txt -t "chapter-{n:03}" --sort natural
+
+  # 递归时每个目录单独编号
+  bookimporter rename ./series -f epub -t "{parent}-@n" -r --sort natural --per-dir
+
   # 从指定序号开始
   bookimporter rename . -f txt -t "doc-@n" --start-num 100
   结果: 从 doc-100.txt 开始编号
@@ -80,10 +122,21 @@ rename 命令可以根据指定的模板批量重命名文件，支持序列号
 			OutputDir:  cmd.Flag("output").Value.String(),
 			Template:   cmd.Flag("template").Value.String(),
 			StartIndex: parseIntFlag(cmd, "start-num"),
+			OnConflict: util.ConflictPolicy(cmd.Flag("on-conflict").Value.String()),
+			Sort:       util.SortKey(cmd.Flag("sort").Value.String()),
+			Reverse:    cmd.Flag("reverse").Value.String() == "true",
+			PerDir:     cmd.Flag("per-dir").Value.String() == "true",
 		}
 
 		validateConfig(config)
 
+		filter, err := renameFilterFlags.build(config.Formats)
+		if err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("错误: %s", err)))
+			os.Exit(1)
+		}
+		config.filter = filter
+
 		// 打印头部
 		fmt.Println(ui.RenderHeader("批量重命名文件", "按模板批量重命名或移动文件"))
 		fmt.Println()
@@ -97,22 +150,49 @@ rename 命令可以根据指定的模板批量重命名文件，支持序列号
 			fmt.Printf("  - 输出目录: %s\n", config.OutputDir)
 			fmt.Printf("  - 模板: %s\n", config.Template)
 			fmt.Printf("  - 起始序号: %d\n", config.StartIndex)
+			fmt.Printf("  - 冲突处理: %s\n", config.OnConflict)
+			fmt.Printf("  - 排序: %s（倒序: %v，按目录编号: %v）\n", config.Sort, config.Reverse, config.PerDir)
 			fmt.Println()
 		}
+
+		journal, err := openJournal("rename", config.DoTry)
+		if err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("错误: %s", err)))
+			os.Exit(1)
+		}
+		config.journal = journal
 		rename(config)
+		closeJournal(journal, os.Stdout)
 	},
 }
 
 func validateConfig(config *RenameConfig) {
-	// 检查模板是否包含序号占位符
-	if !strings.Contains(config.Template, "@n") {
-		fmt.Println(ui.RenderError(fmt.Sprintf("错误: 模板 '%s' 中缺少序号占位符 @n", config.Template)))
+	// 解析模板，模板中至少要有一个占位符
+	template, err := util.ParseNameTemplate(config.Template)
+	if err != nil {
+		fmt.Println(ui.RenderError(fmt.Sprintf("错误: %s", err)))
+		fmt.Println()
+		fmt.Println(ui.RenderInfo("模板必须包含 @n 序号或 {字段} 占位符，例如:"))
+		fmt.Println("  ✓ 正确: -t \"book-@n\"              → book-1.epub, book-2.epub")
+		fmt.Println("  ✓ 正确: -t \"book-{n:03}\"          → book-001.epub, book-002.epub")
+		fmt.Println("  ✓ 正确: -t \"{author} - {title}\"   → 刘慈欣 - 三体.epub")
+		fmt.Println("  ✓ 正确: -t \"{author|未知作者} - {title}\"")
+		fmt.Println("  ✗ 错误: -t \"book\"                 (没有占位符)")
 		fmt.Println()
-		fmt.Println(ui.RenderInfo("模板必须包含 @n 作为序号占位符，例如:"))
-		fmt.Println("  ✓ 正确: -t \"book-@n\"    → book-1.epub, book-2.epub")
-		fmt.Println("  ✓ 正确: -t \"file_@n\"    → file_1.txt, file_2.txt")
-		fmt.Println("  ✓ 正确: -t \"doc-@n-new\" → doc-1-new.pdf, doc-2-new.pdf")
-		fmt.Println("  ✗ 错误: -t \"book\"       (没有 @n)")
+		fmt.Println(ui.RenderInfo("可用字段: title author series index year isbn ext parent name n"))
+		os.Exit(1)
+	}
+	config.template = template
+
+	switch config.OnConflict {
+	case util.ConflictSkip, util.ConflictSuffix, util.ConflictFail:
+	default:
+		fmt.Println(ui.RenderError(fmt.Sprintf("错误: 未知的冲突处理方式 %q，可选: skip、suffix、fail", config.OnConflict)))
+		os.Exit(1)
+	}
+
+	if !slices.Contains(util.SortKeys, config.Sort) {
+		fmt.Println(ui.RenderError(fmt.Sprintf("错误: 未知的排序方式 %q，可选: name、natural、mtime、size、title", config.Sort)))
 		os.Exit(1)
 	}
 
@@ -133,7 +213,7 @@ func validateConfig(config *RenameConfig) {
 
 func rename(config *RenameConfig) {
 
-	files, err := findFiles(config.SourceDir, config.Formats, config.Recursive)
+	files, err := util.FindFiles(config.SourceDir, config.filter, config.Recursive)
 	if err != nil {
 		fmt.Println(ui.RenderError(fmt.Sprintf("查找文件失败: %s", err)))
 		os.Exit(1)
@@ -147,10 +227,21 @@ func rename(config *RenameConfig) {
 	fmt.Println(ui.RenderInfo(fmt.Sprintf("找到 %d 个文件", len(files))))
 	fmt.Println()
 
-	var (
-		renamedFiles []string
-		movedFiles   []string
-	)
+	// 按指定方式排序后分配序号，保证 @n 的顺序确定
+	if err := util.SortFiles(files, config.Sort, config.Reverse, config.PerDir); err != nil {
+		fmt.Println(ui.RenderError(fmt.Sprintf("排序失败: %s", err)))
+		os.Exit(1)
+	}
+
+	// 先为所有文件生成完整的计划，检查冲突后再修改磁盘
+	plan, skipped, err := planRename(config, files)
+	if err != nil {
+		fmt.Println()
+		fmt.Println(ui.RenderError(fmt.Sprintf("错误: %s，未重命名任何文件", err)))
+		fmt.Println(ui.RenderInfo("可使用 --on-conflict skip 跳过冲突的文件，或 --on-conflict suffix 添加序号后缀"))
+		closeJournal(config.journal, os.Stdout)
+		os.Exit(1)
+	}
 
 	// 如果是试运行模式，使用表格显示预览
 	if config.DoTry {
@@ -164,24 +255,10 @@ func rename(config *RenameConfig) {
 		tableConfig.CompactMode = false
 
 		var rows [][]string
-		for i, file := range files {
-			newName := buildNewName(config.Template, config.StartIndex+i, file)
-			var outputPath string
-			if config.OutputDir != "" {
-				outputPath = filepath.Join(config.OutputDir, newName)
-			} else {
-				outputPath = filepath.Join(filepath.Dir(file), newName)
-			}
-
+		for i, move := range plan.Moves {
 			// 截断长文件名
-			oldName := file
-			if len(oldName) > 40 {
-				oldName = "..." + oldName[len(oldName)-37:]
-			}
-			newPath := outputPath
-			if len(newPath) > 40 {
-				newPath = "..." + newPath[len(newPath)-37:]
-			}
+			oldName := truncatePathLeft(move.From, 40)
+			newPath := truncatePathLeft(move.To, 40)
 
 			rows = append(rows, []string{
 				fmt.Sprintf(" %d ", i+1),
@@ -191,10 +268,10 @@ func rename(config *RenameConfig) {
 			})
 
 			// 限制预览显示的行数
-			if i >= 19 && len(files) > 20 {
+			if i >= 19 && len(plan.Moves) > 20 {
 				rows = append(rows, []string{
 					" ... ",
-					fmt.Sprintf(" ... 还有 %d 个文件 ... ", len(files)-20),
+					fmt.Sprintf(" ... 还有 %d 个文件 ... ", len(plan.Moves)-20),
 					"   ",
 					" ... ",
 				})
@@ -208,74 +285,44 @@ func rename(config *RenameConfig) {
 		fmt.Println()
 	}
 
-	// 创建进度跟踪器
-	var progress *ui.ProgressTracker
-	if !config.DoTry && len(files) > 1 {
-		progress = ui.NewCompactProgressTracker(len(files))
-		progress.SetShowMessage(true)
-	}
-
-	// 如果不是预览模式，执行重命名
+	// 如果不是预览模式，按计划执行重命名
 	if !config.DoTry {
-		for i, file := range files {
-			newName := buildNewName(config.Template, config.StartIndex+i, file)
-
-			if config.OutputDir != "" {
-				outputPath := filepath.Join(config.OutputDir, newName)
-
-				// 显示进度
-				if progress != nil {
-					progress.SetMessage(filepath.Base(file))
-					fmt.Printf("\r%s", progress.RenderCompact())
-				}
-
-				err = os.Rename(file, outputPath)
-				if err != nil {
-					if progress != nil {
-						fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
-					}
-					fmt.Println(ui.RenderError(fmt.Sprintf("重命名失败: %s", err)))
-					os.Exit(1)
-				}
-
-				if progress != nil {
-					fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
-					progress.IncrementSuccess()
-				}
-				fmt.Println(ui.FormatRenamePreview(file, outputPath))
-
-				movedFiles = append(movedFiles, file+" -> "+outputPath)
-			} else {
-				outputPath := filepath.Join(filepath.Dir(file), newName)
-
-				// 显示进度
-				if progress != nil {
-					progress.SetMessage(filepath.Base(file))
-					fmt.Printf("\r%s", progress.RenderCompact())
-				}
-
-				err = os.Rename(file, outputPath)
-				if err != nil {
-					if progress != nil {
-						fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
-					}
-					fmt.Println(ui.RenderError(fmt.Sprintf("重命名失败: %s", err)))
-					os.Exit(1)
-				}
-
-				if progress != nil {
-					fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
-					progress.IncrementSuccess()
-				}
-				fmt.Println(ui.FormatRenamePreview(file, outputPath))
-
-				renamedFiles = append(renamedFiles, file+" -> "+outputPath)
-			}
+		// 创建进度跟踪器
+		var progress *ui.ProgressTracker
+		if len(plan.Moves) > 1 {
+			progress = ui.NewCompactProgressTracker(len(plan.Moves))
+			progress.SetShowMessage(true)
 		}
 
-		// 清除进度行并显示最终统计
+		// 环形重命名经过临时文件名，每一步都写入操作日志，撤销时按相反顺序还原
+		err := plan.Execute(func(step util.RenameStep) {
+			recordJournal(config.journal, util.JournalEntry{Op: util.JournalMove, Path: step.From, NewPath: step.To}, os.Stdout)
+			if !step.Final {
+				return
+			}
+			move := plan.Moves[step.Move]
+			if progress != nil {
+				fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
+				progress.IncrementSuccess()
+			}
+			fmt.Println(ui.FormatRenamePreview(move.From, move.To))
+			if progress != nil {
+				progress.SetMessage(filepath.Base(move.To))
+				fmt.Printf("\r%s", progress.RenderCompact())
+			}
+		})
 		if progress != nil {
 			fmt.Print("\r" + strings.Repeat(" ", 120) + "\r")
+		}
+		if err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("重命名失败: %s", err)))
+			fmt.Println(ui.RenderInfo("已完成的重命名可使用 bookimporter undo 撤销"))
+			closeJournal(config.journal, os.Stdout)
+			os.Exit(1)
+		}
+
+		// 显示最终统计
+		if progress != nil {
 			fmt.Println(progress.RenderWithStats())
 			fmt.Println()
 		}
@@ -313,7 +360,13 @@ func rename(config *RenameConfig) {
 	} else {
 		rows = append(rows, []string{
 			" 已处理 ",
-			fmt.Sprintf(" %d ", len(files)),
+			fmt.Sprintf(" %d ", len(plan.Moves)),
+		})
+	}
+	if skipped > 0 {
+		rows = append(rows, []string{
+			" 跳过 ",
+			fmt.Sprintf(" %d ", skipped),
 		})
 	}
 
@@ -324,81 +377,142 @@ func rename(config *RenameConfig) {
 
 	if config.OutputDir != "" {
 		if config.DoTry {
-			fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将移动 %d 个文件到: %s", len(files), config.OutputDir)))
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将移动 %d 个文件到: %s", len(plan.Moves), config.OutputDir)))
 		} else {
-			fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功移动 %d 个文件到: %s", len(movedFiles), config.OutputDir)))
+			fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功移动 %d 个文件到: %s", len(plan.Moves), config.OutputDir)))
 		}
 	} else {
 		if config.DoTry {
-			fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将重命名 %d 个文件", len(files))))
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将重命名 %d 个文件", len(plan.Moves))))
 		} else {
-			fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功重命名 %d 个文件", len(renamedFiles))))
+			fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功重命名 %d 个文件", len(plan.Moves))))
 		}
 	}
 }
 
-func findFiles(dir string, formats []string, recursive bool) ([]string, error) {
-	var files []string
-
-	matches, err := filepath.Glob(filepath.Join(dir, "*"))
-	if err != nil {
-		return nil, err
-	}
-
-	for _, match := range matches {
-
-		included := false
-		for _, includedStr := range formats {
-			if strings.Contains(match, includedStr) {
-				included = true
-				break
-			}
-		}
-
-		info, err := os.Stat(match)
+// planRename 为所有文件生成重命名计划并显示冲突，返回计划和跳过的文件数
+// 无法生成文件名、与其他文件冲突而跳过、以及无需改名的文件都计入跳过
+func planRename(config *RenameConfig, files []string) (*util.RenamePlan, int, error) {
+	var moves []util.RenameMove
+	skipped := 0
+	numbers := sequenceNumbers(files, config.StartIndex, config.PerDir)
+	for i, file := range files {
+		outputPath, err := renameTarget(config, numbers[i], file)
 		if err != nil {
-			return nil, err
+			fmt.Println(ui.RenderWarning(fmt.Sprintf("跳过 %s: %s", file, err)))
+			skipped++
+			continue
 		}
+		moves = append(moves, util.RenameMove{From: file, To: outputPath})
+	}
 
-		if !info.IsDir() && included {
-			files = append(files, match)
-		} else if recursive {
-			subFiles, err := findFiles(match, formats, true)
-			if err != nil {
-				return nil, err
-			}
-			files = append(files, subFiles...)
+	plan, err := util.PlanRenames(moves, config.OnConflict)
+	if plan == nil {
+		return nil, skipped, err
+	}
+	for _, c := range plan.Conflicts {
+		switch {
+		case err != nil:
+			fmt.Println(ui.RenderError(fmt.Sprintf("冲突 %s → %s: %s", c.From, c.To, c.Reason)))
+		case c.Resolved != "":
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("%s → %s: %s，改为 %s", c.From, c.To, c.Reason, filepath.Base(c.Resolved))))
+		default:
+			fmt.Println(ui.RenderWarning(fmt.Sprintf("跳过 %s → %s: %s", c.From, c.To, c.Reason)))
+			skipped++
 		}
 	}
-
-	return files, nil
-
+	if len(plan.Conflicts) > 0 || skipped > 0 {
+		fmt.Println()
+	}
+	return plan, skipped + plan.Unchanged, err
 }
 
 func init() {
 	renameCmd.Flags().StringArrayP("format", "f", []string{"*"},
-		"指定要处理的文件格式（如 'txt', 'epub'），可多次使用以匹配多种格式")
+		"文件扩展名（如 'txt'、'epub'）或文件名 glob 模式（如 '*.epub'、'vol-??.pdf'），不区分大小写，可多次使用")
 	renameCmd.Flags().StringP("template", "t", "file-@n",
-		"文件名模板，@n 为序号占位符（如 'book-@n' → book-1.epub）")
+		"文件名模板，@n 为序号，{title}、{author} 等为元数据字段（如 '{author} - {title}'）")
 	renameCmd.Flags().BoolP("recursive", "r", false,
 		"递归搜索子目录中的所有匹配文件")
 	renameCmd.Flags().StringP("output", "o", "",
 		"输出目录路径，指定后会将文件移动到此目录（不指定则在原位置重命名）")
 	renameCmd.Flags().Int("start-num", 1,
 		"序列号起始值（默认为 1）")
+	renameCmd.Flags().String("sort", string(util.SortName),
+		"分配序号的顺序：name 按文件名、natural 自然排序（ch2 在 ch10 之前，支持中文数字）、mtime、size、title")
+	renameCmd.Flags().Bool("reverse", false,
+		"倒序排列")
+	renameCmd.Flags().Bool("per-dir", false,
+		"递归时每个目录的序号从 --start-num 重新开始")
+	renameCmd.Flags().String("on-conflict", string(util.ConflictSkip),
+		"目标文件名冲突时的处理方式：skip 跳过、suffix 添加序号后缀、fail 不执行任何重命名")
 	renameCmd.Flags().Bool("do-try", false,
 		"预览模式，仅显示将要执行的操作，不实际修改文件")
 	renameCmd.Flags().Bool("debug", false,
 		"启用调试模式，显示详细的配置信息")
+	bindFilterFlags(renameCmd, renameFilterFlags)
 	_ = rootCmd.MarkFlagRequired("format")
 	_ = rootCmd.MarkFlagRequired("template")
 }
 
-func buildNewName(template string, index int, file string) string {
-	ext := filepath.Ext(file)
-	newName := strings.Replace(template, "@n", strconv.Itoa(index), -1)
-	newName += ext
-	return newName
+// sequenceNumbers 返回每个文件的序号，perDir 为 true 时每个目录从 start 重新编号
+// files 需已按目录分组
+func sequenceNumbers(files []string, start int, perDir bool) []int {
+	numbers := make([]int, len(files))
+	n, dir := start, ""
+	for i, file := range files {
+		if perDir && filepath.Dir(file) != dir {
+			n, dir = start, filepath.Dir(file)
+		}
+		numbers[i] = n
+		n++
+	}
+	return numbers
+}
+
+// truncatePathLeft 路径超过 max 个字符时只保留末尾部分，不截断在字符中间
+func truncatePathLeft(path string, max int) string {
+	runes := []rune(path)
+	if len(runes) <= max {
+		return path
+	}
+	return "..." + string(runes[len(runes)-max+3:])
+}
+
+// renameTarget 返回文件重命名后的完整路径，指定了输出目录时位于输出目录中
+func renameTarget(config *RenameConfig, index int, file string) (string, error) {
+	newName, err := buildNewName(config, index, file)
+	if err != nil {
+		return "", err
+	}
+	if config.OutputDir != "" {
+		return filepath.Join(config.OutputDir, newName), nil
+	}
+	return filepath.Join(filepath.Dir(file), newName), nil
+}
+
+// buildNewName 按模板生成新文件名
+func buildNewName(config *RenameConfig, index int, file string) (string, error) {
+	values, err := templateValues(file, index, config.template.NeedsMetadata())
+	if err != nil {
+		return "", err
+	}
+	return config.template.Execute(values)
+}
+
+// templateValues 返回文件的模板字段，needMetadata 为 true 时读取电子书元数据
+func templateValues(file string, index int, needMetadata bool) (map[string]string, error) {
+	values := util.FileTemplateValues(file, index)
+	if needMetadata {
+		meta, err := util.ReadBookTemplateValues(file)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range meta {
+			values[k] = v
+		}
+	}
+	return values, nil
 }
 
 func parseIntFlag(cmd *cobra.Command, name string) int {
@@ -415,4 +529,12 @@ type RenameConfig struct {
 	OutputDir  string
 	Template   string
 	StartIndex int
+	OnConflict util.ConflictPolicy
+	Sort       util.SortKey
+	Reverse    bool
+	PerDir     bool
+
+	template *util.NameTemplate // 由 Template 解析
+	filter   *util.FileFilter   // 由 Formats 和过滤参数解析
+	journal  *util.Journal      // 操作日志，试运行时为 nil
 }
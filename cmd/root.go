@@ -14,13 +14,20 @@ var rootCmd = &cobra.Command{
 
 主要功能:
   • 清理书籍标题中的无用描述 (clname)
-  • 检测 EPUB 文件完整性 (check)
+  • 检测 EPUB、MOBI、AZW3、PDF、FB2 文件完整性 (check)
   • 批量重命名文件 (rename)
+  • 按作者、系列整理书库目录 (organize)
+  • 撤销以上命令对文件的修改 (undo)
+  • 管理删除的文件 (trash)
+  • 查找重复的书籍 (dedupe)
+  • 将 TXT 小说转换为 EPUB (convert txt2epub)
 
 使用示例:
-  bookimporter check -p /books/     检测目录中的所有 EPUB 文件
+  bookimporter check -p /books/     检测目录中的所有电子书文件
   bookimporter clname -p /books/    清理书籍标题
   bookimporter rename . -f txt -t "book-@n"  批量重命名
+  bookimporter organize ~/Downloads -o ~/Library  按作者整理到书库
+  bookimporter undo                 撤销最近一次操作
 
 项目地址: https://github.com/jianyun8023/bookimporter`,
 }
@@ -39,4 +46,9 @@ func init() {
 	rootCmd.AddCommand(renameCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(organizeCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(trashCmd)
+	rootCmd.AddCommand(dedupeCmd)
+	rootCmd.AddCommand(convertCmd)
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+)
+
+// openScanCache 打开默认的扫描缓存，noCache 为 true 时重新检测所有文件并更新缓存
+// 无法打开（如另一个 bookimporter 正在使用）时输出警告，返回 nil 表示不使用缓存
+func openScanCache(noCache bool, out io.Writer) *util.ScanCache {
+	path, err := util.DefaultScanCachePath()
+	if err == nil {
+		var cache *util.ScanCache
+		if cache, err = util.OpenScanCache(path); err == nil {
+			cache.SetRefresh(noCache)
+			return cache
+		}
+	}
+	fmt.Fprintln(out, ui.RenderWarning(fmt.Sprintf("不使用扫描缓存: %v", err)))
+	return nil
+}
+
+// closeScanCache 保存并关闭扫描缓存，有命中时提示跳过的文件数
+func closeScanCache(cache *util.ScanCache, out io.Writer) {
+	hits := cache.Hits()
+	if err := cache.Close(); err != nil {
+		fmt.Fprintln(out, ui.RenderWarning(err.Error()))
+	}
+	if hits > 0 {
+		fmt.Fprintln(out, ui.RenderInfo(fmt.Sprintf("%d 项结果来自扫描缓存，文件未变化（使用 --no-cache 重新检测）", hits)))
+	}
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// TrashConfig 回收站命令配置
+type TrashConfig struct {
+	OlderThan string // purge: 清理早于该时间删除的文件
+	All       bool   // purge: 清空回收站
+	DoTry     bool   // restore、purge 的试运行模式
+}
+
+var trashConfig = &TrashConfig{}
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "管理被删除的书籍：列出、恢复或清理回收站",
+	Long: `管理 check --delete 和 clname --delete-corrupted 删除的文件
+
+删除的文件不会被永久删除，而是移入回收站 ~/.bookimporter/trash，
+同时记录原路径、删除时间和原因（如检测到的错误）。回收站采用
+freedesktop.org Trash 规范的目录结构：files/ 存放文件，info/*.trashinfo 存放信息。
+
+子命令：
+  list                      列出回收站中的文件
+  restore <名称或原路径>... 将文件移回原路径
+  purge --older-than <时间> 永久删除较早删除的文件`,
+	Example: `  # 查看回收站
+  bookimporter trash list
+
+  # 恢复文件（使用 list 中的名称或原路径）
+  bookimporter trash restore 三体.epub
+  bookimporter trash restore /books/三体.epub
+
+  # 永久删除 30 天前删除的文件
+  bookimporter trash purge --older-than 30d`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出回收站中的文件",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runTrashList(); err != nil {
+			fmt.Fprintf(os.Stderr, "列出回收站失败: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <名称或原路径>...",
+	Short: "将回收站中的文件移回原路径",
+	Long: `将回收站中的文件移回原路径
+
+参数为 trash list 中显示的名称，或文件删除前的路径；同一路径被删除过多次时恢复最近的一个。
+原路径已存在文件时跳过，原目录不存在时自动创建。`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed, err := runTrashRestore(trashConfig, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "恢复失败: %v\n", err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "永久删除回收站中的文件",
+	Long: `永久删除回收站中较早删除的文件，此操作无法撤销
+
+--older-than 接受日期 2024-01-31，或时长 30m、12h、7d、2w（表示删除时间早于多久之前）；
+使用 --all 清空回收站。`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runTrashPurge(trashConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "清理失败: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	trashRestoreCmd.Flags().BoolVar(&trashConfig.DoTry, "do-try", false,
+		"试运行模式，只显示将要恢复的文件")
+	trashPurgeCmd.Flags().StringVar(&trashConfig.OlderThan, "older-than", "",
+		"永久删除早于该时间删除的文件，如 30d、2024-01-01")
+	trashPurgeCmd.Flags().BoolVar(&trashConfig.All, "all", false,
+		"清空回收站")
+	trashPurgeCmd.Flags().BoolVar(&trashConfig.DoTry, "do-try", false,
+		"试运行模式，只显示将要永久删除的文件")
+
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashPurgeCmd)
+}
+
+// runTrashList 列出回收站中的文件，最近删除的在前
+func runTrashList() error {
+	trash, err := util.OpenDefaultTrash()
+	if err != nil {
+		return err
+	}
+	items, err := trash.List()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println(ui.RenderInfo("回收站是空的"))
+		return nil
+	}
+
+	tableConfig := ui.NewTableConfig()
+	tableConfig.Headers = []string{" 名称 ", " 原路径 ", " 删除时间 ", " 大小 ", " 原因 "}
+	tableConfig.BorderStyle = "rounded"
+	tableConfig.AlignRight = []int{3}
+
+	var rows [][]string
+	var total int64
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		total += item.Size
+		rows = append(rows, []string{
+			fmt.Sprintf(" %s ", item.Name),
+			fmt.Sprintf(" %s ", truncatePathLeft(item.OriginalPath, 50)),
+			fmt.Sprintf(" %s ", item.DeletedAt.Format("2006-01-02 15:04")),
+			fmt.Sprintf(" %s ", util.FormatSize(item.Size)),
+			fmt.Sprintf(" %s ", truncatePathLeft(item.Reason, 40)),
+		})
+	}
+
+	tableConfig.Rows = rows
+	fmt.Println(ui.NewTable(tableConfig).Render())
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("共 %d 个文件，%s，回收站目录: %s", len(items), util.FormatSize(total), trash.Dir())))
+	return nil
+}
+
+// runTrashRestore 恢复文件，返回失败的数量
+func runTrashRestore(cfg *TrashConfig, args []string) (int, error) {
+	trash, err := util.OpenDefaultTrash()
+	if err != nil {
+		return 0, err
+	}
+
+	restored, failed := 0, 0
+	for _, arg := range args {
+		item, err := trash.Find(arg)
+		if err != nil {
+			fmt.Println(ui.RenderError(err.Error()))
+			failed++
+			continue
+		}
+		fmt.Println(ui.FormatFileOperation("恢复", item.Path, item.OriginalPath))
+		if cfg.DoTry {
+			fmt.Println(ui.RenderInfo("[试运行] 将恢复"))
+			continue
+		}
+		if _, err := trash.Restore(item.Name); err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("恢复失败: %v", err)))
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	fmt.Println()
+	if restored > 0 {
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功恢复 %d 个文件", restored)))
+	}
+	if failed > 0 {
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("⚠️  %d 个文件恢复失败", failed)))
+	}
+	return failed, nil
+}
+
+// runTrashPurge 永久删除回收站中的文件
+func runTrashPurge(cfg *TrashConfig) error {
+	if cfg.OlderThan == "" && !cfg.All {
+		return fmt.Errorf("必须指定 --older-than 或 --all")
+	}
+	if cfg.OlderThan != "" && cfg.All {
+		return fmt.Errorf("--older-than 和 --all 不能同时使用")
+	}
+
+	var before time.Time
+	if cfg.OlderThan != "" {
+		var err error
+		if before, err = util.ParseTimeFilter(cfg.OlderThan, time.Now()); err != nil {
+			return fmt.Errorf("--older-than: %w", err)
+		}
+	}
+
+	trash, err := util.OpenDefaultTrash()
+	if err != nil {
+		return err
+	}
+
+	var items []util.TrashItem
+	if cfg.DoTry {
+		all, err := trash.List()
+		if err != nil {
+			return err
+		}
+		for _, item := range all {
+			if before.IsZero() || item.DeletedAt.Before(before) {
+				items = append(items, item)
+			}
+		}
+	} else if items, err = trash.Purge(before); err != nil {
+		return err
+	}
+
+	var total int64
+	for _, item := range items {
+		total += item.Size
+		fmt.Println(ui.FormatFilePath("永久删除", item.OriginalPath))
+	}
+	if len(items) == 0 {
+		fmt.Println(ui.RenderInfo("没有需要清理的文件"))
+	} else if cfg.DoTry {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将永久删除 %d 个文件，释放 %s", len(items), util.FormatSize(total))))
+	} else {
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 已永久删除 %d 个文件，释放 %s", len(items), util.FormatSize(total))))
+	}
+	return nil
+}
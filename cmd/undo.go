@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jianyun8023/bookimporter/pkg/ui"
+	"github.com/jianyun8023/bookimporter/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// UndoConfig 撤销命令配置
+type UndoConfig struct {
+	List  bool // 列出尚未撤销的操作日志
+	DoTry bool // 试运行模式
+}
+
+var undoConfig = &UndoConfig{}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [操作日志]",
+	Short: "撤销 rename、organize、dedupe、clname、check、convert 对文件的修改",
+	Long: `按操作日志撤销 rename、organize、dedupe、clname、check 和 convert 对文件的修改
+
+rename、organize、dedupe、clname、check、convert 在实际修改文件时会把每项操作写入操作日志
+~/.bookimporter/journal/<时间>-<命令>.jsonl，记录原路径、新路径、
+修改前后的元数据和时间。undo 按相反顺序回放日志：
+
+  • 重命名和移动的文件移回原路径
+  • clname 修改的书名、作者、出版社、主题和系列恢复为原值
+  • check --repair 修复的文件从 .bak 备份恢复
+  • 删除的文件从回收站移回原路径（回收站已清理时无法恢复）
+  • convert 生成的文件移入回收站
+
+不指定日志时撤销最近一次操作。撤销前会检查文件的当前状态，
+文件已被再次移动或修改、原路径已被占用时跳过该项。
+全部撤销成功后日志标记为已撤销；失败的项保留在日志中，处理后可再次运行 undo。`,
+	Example: `  # 撤销最近一次操作
+  bookimporter undo
+
+  # 列出可撤销的操作日志
+  bookimporter undo --list
+
+  # 撤销指定的操作日志
+  bookimporter undo ~/.bookimporter/journal/20240101-120000.000000-rename.jsonl
+
+  # 预览将要撤销的操作
+  bookimporter undo --do-try`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runUndo(undoConfig, args); err != nil {
+			fmt.Fprintf(os.Stderr, "撤销失败: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	undoCmd.Flags().BoolVarP(&undoConfig.List, "list", "l", false,
+		"列出尚未撤销的操作日志")
+	undoCmd.Flags().BoolVar(&undoConfig.DoTry, "do-try", false,
+		"试运行模式，只显示将要撤销的操作")
+}
+
+// runUndo 执行撤销
+func runUndo(cfg *UndoConfig, args []string) error {
+	dir, err := util.DefaultJournalDir()
+	if err != nil {
+		return err
+	}
+	journals, err := util.ListJournals(dir)
+	if err != nil {
+		return err
+	}
+
+	if cfg.List {
+		return printJournals(journals)
+	}
+
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else if len(journals) > 0 {
+		path = journals[len(journals)-1]
+	} else {
+		return fmt.Errorf("没有可撤销的操作日志")
+	}
+
+	entries, err := util.ReadJournal(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(ui.RenderHeader("撤销操作", "按操作日志恢复文件"))
+	fmt.Println()
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("操作日志: %s（%d 项）", path, len(entries))))
+	fmt.Println()
+
+	// 按相反顺序撤销，失败的项按原顺序保留
+	var failed []util.JournalEntry
+	undone, skipped := 0, 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := &entries[i]
+		fmt.Println(formatJournalEntry(entry))
+
+		if entry.Op == util.JournalDelete && entry.NewPath == "" {
+			fmt.Println(ui.RenderWarning("已永久删除的文件无法恢复，跳过"))
+			fmt.Println()
+			skipped++
+			continue
+		}
+		if cfg.DoTry {
+			fmt.Println(ui.RenderInfo("[试运行] 将撤销"))
+			fmt.Println()
+			continue
+		}
+
+		if err := util.UndoJournalEntry(entry); err != nil {
+			fmt.Println(ui.RenderError(fmt.Sprintf("撤销失败: %v", err)))
+			failed = append([]util.JournalEntry{*entry}, failed...)
+		} else {
+			fmt.Println(ui.RenderSuccess("已撤销"))
+			undone++
+		}
+		fmt.Println()
+	}
+
+	if cfg.DoTry {
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("📝 [试运行] 将撤销 %d 项操作", len(entries)-skipped)))
+		return nil
+	}
+
+	if len(failed) > 0 {
+		if err := util.WriteJournal(path, failed); err != nil {
+			return err
+		}
+		fmt.Println(ui.RenderWarning(fmt.Sprintf("⚠️  %d 项撤销失败，已保留在操作日志中，处理后可再次运行 undo", len(failed))))
+	} else if err := util.MarkJournalUndone(path); err != nil {
+		return fmt.Errorf("无法标记操作日志: %w", err)
+	}
+	if undone > 0 {
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("✨ 成功撤销 %d 项操作", undone)))
+	}
+	return nil
+}
+
+// formatJournalEntry 格式化一条操作记录
+func formatJournalEntry(entry *util.JournalEntry) string {
+	switch entry.Op {
+	case util.JournalMove:
+		return ui.FormatFileOperation("移回", entry.NewPath, entry.Path)
+	case util.JournalMetadata:
+		s := ui.FormatFilePath("恢复元数据", entry.Path)
+		if entry.Old != nil && entry.Old.Title != nil && entry.New != nil && entry.New.Title != nil {
+			s += "\n" + ui.FormatFileOperation("标题", *entry.New.Title, *entry.Old.Title)
+		}
+		return s
+	case util.JournalRepair:
+		return ui.FormatFileOperation("从备份恢复", entry.Backup, entry.Path)
+	case util.JournalCreate:
+		return ui.FormatFilePath("移入回收站", entry.Path)
+	default:
+		if entry.NewPath != "" {
+			return ui.FormatFileOperation("从回收站恢复", entry.NewPath, entry.Path)
+		}
+		return ui.FormatFilePath("删除", entry.Path)
+	}
+}
+
+// printJournals 列出操作日志及其记录数，最近的在前
+func printJournals(journals []string) error {
+	if len(journals) == 0 {
+		fmt.Println(ui.RenderInfo("没有可撤销的操作日志"))
+		return nil
+	}
+
+	tableConfig := ui.NewTableConfig()
+	tableConfig.Headers = []string{" 操作日志 ", " 项数 "}
+	tableConfig.BorderStyle = "rounded"
+	tableConfig.AlignRight = []int{1}
+
+	var rows [][]string
+	for i := len(journals) - 1; i >= 0; i-- {
+		entries, err := util.ReadJournal(journals[i])
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf(" %s ", filepath.Base(journals[i])),
+			fmt.Sprintf(" %d ", len(entries)),
+		})
+	}
+
+	tableConfig.Rows = rows
+	fmt.Println(ui.NewTable(tableConfig).Render())
+	fmt.Println(ui.RenderInfo(fmt.Sprintf("日志目录: %s", filepath.Dir(journals[0]))))
+	return nil
+}
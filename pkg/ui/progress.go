@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
@@ -10,7 +11,9 @@ import (
 )
 
 // ProgressTracker 进度跟踪器
+// 所有方法都可以在多个 goroutine 中并发调用
 type ProgressTracker struct {
+	mu           sync.Mutex
 	total        int
 	current      int
 	startTime    time.Time
@@ -47,6 +50,8 @@ func NewCompactProgressTracker(total int) *ProgressTracker {
 
 // SetCompact 设置紧凑模式
 func (p *ProgressTracker) SetCompact(compact bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.compactMode = compact
 	if compact {
 		p.showTimeInfo = false
@@ -55,55 +60,74 @@ func (p *ProgressTracker) SetCompact(compact bool) {
 
 // SetShowTimeInfo 设置是否显示时间信息
 func (p *ProgressTracker) SetShowTimeInfo(show bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.showTimeInfo = show
 }
 
 // SetShowMessage 设置是否显示消息
 func (p *ProgressTracker) SetShowMessage(show bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.showMessage = show
 }
 
 // IncrementSuccess 增加成功计数
 func (p *ProgressTracker) IncrementSuccess() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.current++
 	p.successCount++
 }
 
 // IncrementFailure 增加失败计数
 func (p *ProgressTracker) IncrementFailure() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.current++
 	p.failureCount++
 }
 
 // IncrementSkipped 增加跳过计数
 func (p *ProgressTracker) IncrementSkipped() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.current++
 	p.skippedCount++
 }
 
 // GetStats 获取统计信息
 func (p *ProgressTracker) GetStats() (success, failure, skipped int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.successCount, p.failureCount, p.skippedCount
 }
 
 // Increment 增加进度
 func (p *ProgressTracker) Increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.current++
 }
 
 // SetMessage 设置当前消息
 func (p *ProgressTracker) SetMessage(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.lastMessage = msg
 }
 
 // Render 渲染进度条
 func (p *ProgressTracker) Render() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if p.total == 0 {
 		return ""
 	}
 
 	if p.compactMode {
-		return p.RenderCompact()
+		return p.renderCompact()
 	}
 
 	percentage := float64(p.current) / float64(p.total)
@@ -152,6 +176,8 @@ func (p *ProgressTracker) Render() string {
 
 // RenderSimple 渲染简单版本的进度（单行）
 func (p *ProgressTracker) RenderSimple() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.total == 0 {
 		return ""
 	}
@@ -164,6 +190,13 @@ func (p *ProgressTracker) RenderSimple() string {
 
 // RenderCompact 渲染紧凑版本（单行带统计）
 func (p *ProgressTracker) RenderCompact() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.renderCompact()
+}
+
+// renderCompact 渲染紧凑版本，调用方需持有锁
+func (p *ProgressTracker) renderCompact() string {
 	if p.total == 0 {
 		return ""
 	}
@@ -216,6 +249,8 @@ func (p *ProgressTracker) RenderCompact() string {
 
 // RenderWithStats 渲染带统计信息的进度
 func (p *ProgressTracker) RenderWithStats() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.total == 0 {
 		return ""
 	}
@@ -0,0 +1,223 @@
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// FileValidator 检测单个文件，返回 nil 表示文件正常，返回 EpubError 表示检测到问题
+// Profile 用于区分不同配置的检测结果，如扫描缓存中的记录
+type FileValidator interface {
+	Validate(filePath string) error
+	Profile() string
+}
+
+// BookFormat 一种电子书格式的读取、检测和写入方式
+type BookFormat struct {
+	Name       string   // 格式名称，如 "epub"、"mobi"
+	Extensions []string // 小写的扩展名，含 "."，可以是 ".fb2.zip" 这样的复合扩展名
+
+	// NewValidator 根据选项创建检测器
+	NewValidator func(opts ValidateOptions) FileValidator
+	// ReadMetadata 读取书名、作者等元数据
+	ReadMetadata func(filePath string) (*EpubMetadata, error)
+	// WriteMetadata 写入元数据，为 nil 表示该格式不支持写入
+	WriteMetadata func(filePath string, update *MetadataUpdate) error
+	// TemplateValues 读取文件名模板字段，为 nil 时由 ReadMetadata 的结果生成
+	TemplateValues func(filePath string) (map[string]string, error)
+}
+
+// EpubFormat EPUB 格式
+var EpubFormat = &BookFormat{
+	Name:       "epub",
+	Extensions: []string{".epub"},
+	NewValidator: func(opts ValidateOptions) FileValidator {
+		return NewEpubValidator(opts)
+	},
+	ReadMetadata:   ReadEpubMetadata,
+	WriteMetadata:  UpdateEpubMetadata,
+	TemplateValues: ReadEpubTemplateValues,
+}
+
+// MobiFormat MOBI 格式，包括 Kindle 的 AZW 和 AZW3（KF8）
+// 只读取元数据，不支持写入
+var MobiFormat = &BookFormat{
+	Name:       "mobi",
+	Extensions: []string{".mobi", ".azw", ".azw3"},
+	NewValidator: func(opts ValidateOptions) FileValidator {
+		return &MobiValidator{Deep: opts.Deep}
+	},
+	ReadMetadata: ReadMobiMetadata,
+}
+
+// PdfFormat PDF 格式，只支持修改书名（通过增量更新写入 /Info 和 XMP）
+var PdfFormat = &BookFormat{
+	Name:       "pdf",
+	Extensions: []string{".pdf"},
+	NewValidator: func(opts ValidateOptions) FileValidator {
+		return &PdfValidator{Deep: opts.Deep}
+	},
+	ReadMetadata:  ReadPdfMetadata,
+	WriteMetadata: UpdatePdfMetadata,
+}
+
+// Fb2Format FictionBook 2 格式，包括压缩的 .fb2.zip
+var Fb2Format = &BookFormat{
+	Name:       "fb2",
+	Extensions: []string{".fb2", fb2ZipExt},
+	NewValidator: func(opts ValidateOptions) FileValidator {
+		return &Fb2Validator{Deep: opts.Deep}
+	},
+	ReadMetadata:   ReadFb2Metadata,
+	WriteMetadata:  UpdateFb2Metadata,
+	TemplateValues: ReadFb2TemplateValues,
+}
+
+// BookFormats 支持的所有电子书格式
+var BookFormats = []*BookFormat{EpubFormat, MobiFormat, PdfFormat, Fb2Format}
+
+// FormatOf 根据扩展名返回文件的格式，不支持的格式返回 nil
+func FormatOf(filePath string) *BookFormat {
+	f, _ := formatAndExt(filePath)
+	return f
+}
+
+// FileExt 返回文件的扩展名（含 "."），".fb2.zip" 等电子书的复合扩展名作为整体返回，其他文件同 filepath.Ext
+func FileExt(filePath string) string {
+	if _, ext := formatAndExt(filePath); ext != "" {
+		return ext
+	}
+	return filepath.Ext(filePath)
+}
+
+// formatAndExt 返回文件的格式和原样大小写的扩展名，优先匹配较长的扩展名
+func formatAndExt(filePath string) (*BookFormat, string) {
+	base := filepath.Base(filePath)
+	lower := strings.ToLower(base)
+	var match *BookFormat
+	var ext string
+	for _, f := range BookFormats {
+		for _, e := range f.Extensions {
+			if len(e) > len(ext) && len(lower) > len(e) && strings.HasSuffix(lower, e) {
+				match, ext = f, base[len(base)-len(e):]
+			}
+		}
+	}
+	return match, ext
+}
+
+// HasExtension 判断扩展名（含 "."，不区分大小写）是否属于该格式
+func (f *BookFormat) HasExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range f.Extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatByName 根据名称返回格式，也接受扩展名如 "azw3"
+func FormatByName(name string) *BookFormat {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "."))
+	for _, f := range BookFormats {
+		if f.Name == name || f.HasExtension("."+name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// ParseBookFormats 解析格式名称列表，去除重复项
+func ParseBookFormats(names []string) ([]*BookFormat, error) {
+	var formats []*BookFormat
+	for _, name := range names {
+		f := FormatByName(name)
+		if f == nil {
+			return nil, fmt.Errorf("不支持的格式 %q，可选值: %s", name, strings.Join(BookFormatNames(), "、"))
+		}
+		if !containsFormat(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+	return formats, nil
+}
+
+// BookFormatNames 返回所有格式的名称
+func BookFormatNames() []string {
+	names := make([]string, len(BookFormats))
+	for i, f := range BookFormats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func containsFormat(formats []*BookFormat, f *BookFormat) bool {
+	for _, g := range formats {
+		if g == f {
+			return true
+		}
+	}
+	return false
+}
+
+// BookValidator 按扩展名把文件交给对应格式的检测器
+type BookValidator struct {
+	formats    []*BookFormat
+	validators map[*BookFormat]FileValidator
+}
+
+// NewBookValidator 为指定格式创建检测器，未指定格式时支持所有格式
+func NewBookValidator(opts ValidateOptions, formats ...*BookFormat) *BookValidator {
+	if len(formats) == 0 {
+		formats = BookFormats
+	}
+	v := &BookValidator{formats: formats, validators: make(map[*BookFormat]FileValidator)}
+	for _, f := range formats {
+		v.validators[f] = f.NewValidator(opts)
+	}
+	return v
+}
+
+// Validate 使用文件格式对应的检测器检测文件
+func (v *BookValidator) Validate(filePath string) error {
+	f := FormatOf(filePath)
+	validator, ok := v.validators[f]
+	if !ok {
+		return &EpubError{Type: ErrorTypeFormat, Message: "不支持的文件格式", Detail: FileExt(filePath)}
+	}
+	return validator.Validate(filePath)
+}
+
+// Profile 返回各格式检测器的标识，如 "epub[zip,required-files,metadata];mobi[mobi]"
+func (v *BookValidator) Profile() string {
+	parts := make([]string, len(v.formats))
+	for i, f := range v.formats {
+		parts[i] = f.Name + "[" + v.validators[f].Profile() + "]"
+	}
+	return strings.Join(parts, ";")
+}
+
+// Formats 返回检测器支持的格式
+func (v *BookValidator) Formats() []*BookFormat {
+	return v.formats
+}
+
+// ValidateBookFile 使用默认检测器检测 EPUB、MOBI、PDF 或 FB2 文件
+func ValidateBookFile(filePath string) error {
+	f := FormatOf(filePath)
+	if f == nil {
+		return &EpubError{Type: ErrorTypeFormat, Message: "不支持的文件格式", Detail: filepath.Ext(filePath)}
+	}
+	return f.NewValidator(ValidateOptions{}).Validate(filePath)
+}
+
+// ReadBookMetadata 读取 EPUB、MOBI、PDF 或 FB2 文件的元数据
+func ReadBookMetadata(filePath string) (*EpubMetadata, error) {
+	f := FormatOf(filePath)
+	if f == nil {
+		return nil, fmt.Errorf("不支持的文件格式: %s", filepath.Ext(filePath))
+	}
+	return f.ReadMetadata(filePath)
+}
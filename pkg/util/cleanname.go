@@ -3,7 +3,6 @@ package util
 import (
 	"regexp"
 	"strings"
-	"unicode/utf8"
 )
 
 var (
@@ -58,112 +57,9 @@ func (s *Stack) GetItems() []string {
 	return s.items
 }
 
+// TryCleanTitle 使用内置规则清理书名
 func TryCleanTitle(title string) string {
-
-	stack := &Stack{}
-
-	//fmt.Println(title)
-	reader := strings.NewReader(title)
-
-	word := ""
-	symbol := &Stack{}
-	symbolPair := map[string]string{
-		"【": "】",
-		"[": "]",
-		"（": "）",
-		"(": ")",
-		"】": "【",
-		"]": "[",
-		"）": "（",
-		")": "(",
-	}
-	for {
-		r, _, err := reader.ReadRune()
-		if err != nil {
-			break
-		}
-		char := string(r)
-		//《大江大河》作者阿耐合集（共12册）（含《大江大河》(全4册)《欢乐颂》（全3册）《都挺好》(全2册)《不得往生》《食荤者》《余生》，阿耐出品，必是精品！作品改编影视剧均引起热议！）
-		//fmt.Println("【needSymbol】 " + strings.Join(symbol.GetItems(), " | "))
-		switch char {
-		case "【", "[", "（", "(":
-			if symbol.IsEmpty() && utf8.RuneCountInString(word) > 0 {
-				stack.Push(word)
-				word = char
-			} else {
-				word += char
-			}
-			symbol.Push(char)
-		case "】", "]", "）", ")":
-			word += char
-			if symbol.Peek() == symbolPair[char] {
-				symbol.Pop()
-				stack.Push(word)
-				word = ""
-			}
-		default:
-			word += char
-		}
-
-		//fmt.Println(word, "----", strings.Join(stack.GetItems(), " | "))
-	}
-
-	if symbol.IsEmpty() || len(word) != 0 {
-		stack.Push(word)
-	}
-
-	outTitle := ""
-	for i, v := range stack.GetItems() {
-		if i == 0 {
-			outTitle = v
-		} else if i <= 2 && preserve(v) {
-			outTitle += v
-		}
-	}
-
-	// 去除首尾空格
-	outTitle = strings.ReplaceAll(outTitle, "\"", " ")
-	outTitle = strings.TrimSpace(outTitle)
-	if utf8.RuneCountInString(outTitle) == 0 {
-		return title
-	}
-	return outTitle
-}
-
-func preserve(content string) bool {
-
-	c := strings.TrimPrefix(content, "【")
-	c = strings.TrimPrefix(c, "[")
-	c = strings.TrimPrefix(c, "（")
-	c = strings.TrimPrefix(c, "(")
-
-	c = strings.TrimSuffix(c, "】")
-	c = strings.TrimSuffix(c, "]")
-	c = strings.TrimSuffix(c, "）")
-	c = strings.TrimSuffix(c, ")")
-
-	if utf8.RuneCountInString(c) <= 3 {
-		return true
-	}
-	// 定义需要保留的括号内容的正则表达式
-	preservePatterns := []string{
-		`.{2,6}篇`,
-		`[上中下+]`,
-		`[上中下、]+[册本卷部辑]`,
-		`套装.*?[册本卷部辑]`,
-		`[全共].*?[册本卷部辑]`,
-		`\d+[册本卷部辑]`,
-		`第.*?[版卷部辑]`,
-		`[\d一二三四五六七八九十百千]+[-~—～][\d一二三四五六七八九十百千]+`,
-		`\d{4}[-~—～]\d{4}`,
-	}
-	// 合并保留模式为一个正则表达式
-	preserveRegex := regexp.MustCompile(strings.Join(preservePatterns, "|"))
-	b := preserveRegex.MatchString(c) && utf8.RuneCountInString(c) < 20
-	if !b {
-		return strings.HasSuffix(c, "版") && utf8.RuneCountInString(c) < 10
-	}
-	return b
+	return defaultTitleCleaner.Clean(title)
 }
 
 func NewCleanTitle(title string) string {
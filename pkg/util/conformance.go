@@ -0,0 +1,144 @@
+package util
+
+import (
+	"archive/zip"
+	"fmt"
+	"strings"
+)
+
+// checkMimetype 检查 mimetype 条目：必须是第一个条目、不压缩，且内容恰好为 application/epub+zip
+func checkMimetype(a *EpubArchive) error {
+	if len(a.Reader.File) == 0 {
+		return &EpubError{
+			Type:    ErrorTypeMissing,
+			Message: "缺少必需文件",
+			Detail:  "mimetype",
+		}
+	}
+	first := a.Reader.File[0]
+	if first.Name != "mimetype" {
+		return &EpubError{
+			Type:    ErrorTypeMimetype,
+			Message: "mimetype 不是第一个条目",
+			Detail:  fmt.Sprintf("第一个条目为 %s", first.Name),
+		}
+	}
+	if first.Method != zip.Store {
+		return &EpubError{
+			Type:    ErrorTypeMimetype,
+			Message: "mimetype 被压缩",
+			Detail:  "mimetype 必须以不压缩（stored）方式存储",
+		}
+	}
+
+	data, err := a.ReadFile("mimetype")
+	if err != nil {
+		return &EpubError{
+			Type:    ErrorTypeCorrupted,
+			Message: "ZIP 文件损坏",
+			Detail:  fmt.Sprintf("文件条目数据损坏: %s", first.Name),
+		}
+	}
+	if string(data) != EpubMimetype {
+		return &EpubError{
+			Type:    ErrorTypeMimetype,
+			Message: "mimetype 内容错误",
+			Detail:  fmt.Sprintf("期望 %q，得到 %q", EpubMimetype, truncateDetail(string(data))),
+		}
+	}
+	return nil
+}
+
+// checkRootfile 检查 container.xml 指向的 OPF 文件是否存在
+func checkRootfile(a *EpubArchive) error {
+	if _, err := a.Container(); err != nil {
+		return &EpubError{
+			Type:    ErrorTypeFormat,
+			Message: "无法解析 container.xml",
+			Detail:  err.Error(),
+		}
+	}
+
+	opfPath, err := a.OpfPath()
+	if err != nil {
+		return &EpubError{
+			Type:    ErrorTypeRootfile,
+			Message: "rootfile 无效",
+			Detail:  err.Error(),
+		}
+	}
+	if a.File(opfPath) == nil {
+		return &EpubError{
+			Type:    ErrorTypeRootfile,
+			Message: "rootfile 不存在",
+			Detail:  fmt.Sprintf("container.xml 指向的 %s 不在归档中", opfPath),
+		}
+	}
+	return nil
+}
+
+// checkManifest 检查 manifest 中的每个条目都能在归档中找到
+// 远程资源（带协议的 URL）不检查
+func checkManifest(a *EpubArchive) error {
+	opf, err := a.Opf()
+	if err != nil {
+		return nil // 由 checkMetadata 报告
+	}
+
+	var missing []string
+	for _, item := range opf.Manifest {
+		if strings.Contains(item.Href, "://") {
+			continue
+		}
+		if a.File(a.ResolveHref(item.Href)) == nil {
+			missing = append(missing, fmt.Sprintf("%s（%s）", item.Href, item.ID))
+		}
+	}
+
+	if len(missing) > 0 {
+		return &EpubError{
+			Type:    ErrorTypeManifest,
+			Message: "manifest 引用的文件不存在",
+			Detail:  fmt.Sprintf("%d 个条目缺失: %s", len(missing), strings.Join(missing, "; ")),
+		}
+	}
+	return nil
+}
+
+// checkSpine 检查 spine 中的每个 idref 都引用了 manifest 中的条目
+func checkSpine(a *EpubArchive) error {
+	opf, err := a.Opf()
+	if err != nil {
+		return nil // 由 checkMetadata 报告
+	}
+
+	ids := make(map[string]bool, len(opf.Manifest))
+	for _, item := range opf.Manifest {
+		ids[item.ID] = true
+	}
+
+	var dangling []string
+	for _, ref := range opf.Spine.Items {
+		if !ids[ref.IDref] {
+			dangling = append(dangling, ref.IDref)
+		}
+	}
+
+	if len(dangling) > 0 {
+		return &EpubError{
+			Type:    ErrorTypeSpine,
+			Message: "spine 引用了不存在的 manifest 条目",
+			Detail:  fmt.Sprintf("%d 个 idref 无效: %s", len(dangling), strings.Join(dangling, ", ")),
+		}
+	}
+	return nil
+}
+
+// truncateDetail 截断过长的错误详情
+func truncateDetail(s string) string {
+	const maxLen = 64
+	if r := []rune(s); len(r) > maxLen {
+		return string(r[:maxLen]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// strictValidator 包含结构规范检查的检测器
+func strictValidator() *Validator {
+	return NewEpubValidator(ValidateOptions{Conformance: true})
+}
+
+func TestConformance(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(entries [][2]string) [][2]string
+		wantType ErrorType
+		wantOK   bool
+	}{
+		{
+			name:   "规范文件",
+			modify: func(e [][2]string) [][2]string { return e },
+			wantOK: true,
+		},
+		{
+			name: "mimetype 不是第一个条目",
+			modify: func(e [][2]string) [][2]string {
+				return [][2]string{e[1], e[0], e[2], e[3]}
+			},
+			wantType: ErrorTypeMimetype,
+		},
+		{
+			name: "mimetype 内容带换行",
+			modify: func(e [][2]string) [][2]string {
+				e[0][1] = EpubMimetype + "\n"
+				return e
+			},
+			wantType: ErrorTypeMimetype,
+		},
+		{
+			name: "rootfile 不存在",
+			modify: func(e [][2]string) [][2]string {
+				e[1][1] = strings.Replace(e[1][1], "OEBPS/content.opf", "OPS/package.opf", 1)
+				return e
+			},
+			wantType: ErrorTypeRootfile,
+		},
+		{
+			name: "manifest 文件缺失",
+			modify: func(e [][2]string) [][2]string {
+				return e[:3]
+			},
+			wantType: ErrorTypeManifest,
+		},
+		{
+			name: "spine 引用不存在的 id",
+			modify: func(e [][2]string) [][2]string {
+				e[2][1] = strings.Replace(e[2][1], `<itemref idref="chapter1"/>`,
+					`<itemref idref="chapter1"/><itemref idref="chapter2"/>`, 1)
+				return e
+			},
+			wantType: ErrorTypeSpine,
+		},
+		{
+			name: "内容文档声明 UTF-8 实际为 GBK",
+			modify: func(e [][2]string) [][2]string {
+				e[3][1] = mustEncodeGBK(`<?xml version="1.0" encoding="utf-8"?>` + "\n<html><body><p>这是一段简体中文正文。</p></body></html>")
+				return e
+			},
+			wantType: ErrorTypeEncoding,
+		},
+		{
+			name: "manifest 路径含转义和片段",
+			modify: func(e [][2]string) [][2]string {
+				e[2][1] = strings.Replace(e[2][1], `href="chapter1.xhtml"`, `href="chapter%201.xhtml#top"`, 1)
+				e[3][0] = "OEBPS/chapter 1.xhtml"
+				return e
+			},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "book.epub")
+			writeTestEpub(t, path, tt.modify(testEpubEntries("三体")))
+
+			err := strictValidator().Validate(path)
+			if tt.wantOK {
+				if err != nil {
+					t.Errorf("期望检测通过，得到 %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("期望返回错误，但得到 nil")
+			}
+			if got := GetErrorType(err); got != tt.wantType {
+				t.Errorf("错误类型 = %v，期望 %v（%v）", got, tt.wantType, err)
+			}
+
+			// 默认检测器不检查结构规范
+			if tt.wantType != ErrorTypeRootfile {
+				if err := DefaultValidator().Validate(path); err != nil {
+					t.Errorf("默认检测器期望通过，得到 %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestConformance_CompressedMimetype(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("无法创建测试文件: %v", err)
+	}
+	w := zip.NewWriter(f)
+	for _, e := range testEpubEntries("三体") {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: e[0], Method: zip.Deflate})
+		if err != nil {
+			t.Fatalf("无法创建 ZIP 条目: %v", err)
+		}
+		fw.Write([]byte(e[1]))
+	}
+	w.Close()
+	f.Close()
+
+	err = strictValidator().Validate(path)
+	if GetErrorType(err) != ErrorTypeMimetype {
+		t.Errorf("期望 ErrorTypeMimetype，得到 %v", err)
+	}
+}
+
+func TestErrorType_Fixable(t *testing.T) {
+	fixable := map[ErrorType]bool{
+		ErrorTypeCorrupted: false,
+		ErrorTypeMissing:   true,
+		ErrorTypeFormat:    false,
+		ErrorTypeMetadata:  false,
+		ErrorTypeMimetype:  true,
+		ErrorTypeRootfile:  true,
+		ErrorTypeManifest:  false,
+		ErrorTypeSpine:     false,
+		ErrorTypeEncoding:  true,
+	}
+	for typ, want := range fixable {
+		if got := typ.Fixable(); got != want {
+			t.Errorf("%v.Fixable() = %v，期望 %v", typ, got, want)
+		}
+	}
+}
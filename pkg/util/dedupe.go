@@ -0,0 +1,232 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DedupeKey 判断重复的依据
+type DedupeKey string
+
+const (
+	DedupeHash  DedupeKey = "hash"  // 文件内容的 SHA-256 相同
+	DedupeTitle DedupeKey = "title" // 清理后的书名和作者相同
+	DedupeISBN  DedupeKey = "isbn"  // 元数据中的 ISBN 或其他标识符相同
+)
+
+// DedupeKeys 所有可用的依据
+var DedupeKeys = []DedupeKey{DedupeHash, DedupeTitle, DedupeISBN}
+
+// BookFingerprint 用于查找重复书籍的文件特征
+type BookFingerprint struct {
+	Path       string
+	Size       int64
+	ModTime    time.Time
+	Hash       string // SHA-256 十六进制，未按内容比较时为空
+	TitleKey   string // 规范化后的 "书名\x00作者"，没有书名时为空
+	Identifier string // "isbn:..."、"uuid:..." 或 "asin:..."，优先使用 ISBN
+	MetaErr    error  // 读取元数据失败的原因，此时只能按内容比较
+
+	Checked  bool  // 是否已做完整性检测
+	ValidErr error // 完整性检测的错误，nil 表示通过
+}
+
+// ReadBookFingerprint 读取 keys 所需的文件特征
+// 元数据读取失败不返回错误，记录在 MetaErr 中，损坏的文件仍可按内容比较
+func ReadBookFingerprint(path string, keys []DedupeKey) (*BookFingerprint, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	fp := &BookFingerprint{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+
+	if slices.Contains(keys, DedupeHash) {
+		sum, err := hashFile(path, sha256.New())
+		if err != nil {
+			return nil, fmt.Errorf("无法读取文件: %w", err)
+		}
+		fp.Hash = hex.EncodeToString(sum)
+	}
+
+	if slices.Contains(keys, DedupeTitle) || slices.Contains(keys, DedupeISBN) {
+		meta, err := ReadBookMetadata(path)
+		if err != nil {
+			fp.MetaErr = err
+			return fp, nil
+		}
+		if slices.Contains(keys, DedupeTitle) {
+			fp.TitleKey = titleAuthorKey(meta)
+		}
+		if slices.Contains(keys, DedupeISBN) {
+			fp.Identifier = identifierKey(meta)
+		}
+	}
+	return fp, nil
+}
+
+// titleAuthorKey 由清理后的书名和作者生成比较用的键，忽略大小写、全半角、空白和标点
+// 作者只取角色为空或 aut 的责任者，与顺序无关
+func titleAuthorKey(meta *EpubMetadata) string {
+	title := normalizeDedupeText(TryCleanTitle(meta.Title))
+	if title == "" {
+		return ""
+	}
+	var authors []string
+	for _, c := range NormalizeCreators(meta.Creators) {
+		if c.Role == "" || c.Role == "aut" {
+			if name := normalizeDedupeText(c.Name); name != "" {
+				authors = append(authors, name)
+			}
+		}
+	}
+	sort.Strings(authors)
+	return title + "\x00" + strings.Join(authors, "&")
+}
+
+// identifierKey 返回 ISBN，没有 ISBN 时返回第一个 UUID（包括 urn:uuid:）或 MOBI 的 ASIN
+// 其他标识符不参与比较：没有 scheme 的值和 calibre 等书库内的编号在不同书库之间会重复
+func identifierKey(meta *EpubMetadata) string {
+	if meta.ISBN != "" {
+		return "isbn:" + meta.ISBN
+	}
+	for _, id := range meta.Identifiers {
+		id = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(id)), "urn:")
+		for _, scheme := range []string{"uuid:", "asin:"} {
+			if v, ok := strings.CutPrefix(id, scheme); ok && strings.TrimSpace(v) != "" {
+				return scheme + strings.TrimSpace(v)
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeDedupeText 转为小写，全角字母数字转为半角，去除空白、标点和符号
+func normalizeDedupeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// DuplicateGroup 一组重复的书籍
+type DuplicateGroup struct {
+	Books []*BookFingerprint
+	Keys  []DedupeKey // 组内文件由哪些依据关联，按 DedupeKeys 的顺序
+}
+
+// GroupDuplicates 按 keys 将书籍分组，任一依据相同的文件归为一组（传递关联），只返回多于一个文件的组
+// 组内按路径排序，各组按第一个文件的路径排序；调用 SortByPreference 后 Books[0] 才是建议保留的副本
+func GroupDuplicates(books []*BookFingerprint, keys []DedupeKey) []DuplicateGroup {
+	parent := make([]int, len(books))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// 记录每次关联使用的依据，分组完成后归到所在组
+	type link struct {
+		i   int
+		key DedupeKey
+	}
+	var links []link
+	for _, key := range keys {
+		first := make(map[string]int)
+		for i, b := range books {
+			value := b.dedupeValue(key)
+			if value == "" {
+				continue
+			}
+			if j, ok := first[value]; ok {
+				parent[find(i)] = find(j)
+				links = append(links, link{i, key})
+			} else {
+				first[value] = i
+			}
+		}
+	}
+
+	members := make(map[int][]*BookFingerprint)
+	for i, b := range books {
+		root := find(i)
+		members[root] = append(members[root], b)
+	}
+	groupKeys := make(map[int]map[DedupeKey]bool)
+	for _, l := range links {
+		root := find(l.i)
+		if groupKeys[root] == nil {
+			groupKeys[root] = make(map[DedupeKey]bool)
+		}
+		groupKeys[root][l.key] = true
+	}
+
+	var groups []DuplicateGroup
+	for root, list := range members {
+		if len(list) < 2 {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+		g := DuplicateGroup{Books: list}
+		for _, key := range DedupeKeys {
+			if groupKeys[root][key] {
+				g.Keys = append(g.Keys, key)
+			}
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Books[0].Path < groups[j].Books[0].Path })
+	return groups
+}
+
+// dedupeValue 返回按 key 比较的值，为空表示不参与比较
+func (b *BookFingerprint) dedupeValue(key DedupeKey) string {
+	switch key {
+	case DedupeHash:
+		return b.Hash
+	case DedupeTitle:
+		return b.TitleKey
+	case DedupeISBN:
+		return b.Identifier
+	}
+	return ""
+}
+
+// SortByPreference 将组内文件按保留的优先级排序，Books[0] 为建议保留的副本
+func (g *DuplicateGroup) SortByPreference() {
+	sort.SliceStable(g.Books, func(i, j int) bool { return BetterCopy(g.Books[i], g.Books[j]) })
+}
+
+// BetterCopy 判断 a 是否比 b 更适合保留：完整性检测通过的优先，其次文件更大，再次修改时间更新，最后按路径
+func BetterCopy(a, b *BookFingerprint) bool {
+	aValid := a.Checked && a.ValidErr == nil
+	bValid := b.Checked && b.ValidErr == nil
+	if aValid != bValid {
+		return aValid
+	}
+	if a.Size != b.Size {
+		return a.Size > b.Size
+	}
+	if !a.ModTime.Equal(b.ModTime) {
+		return a.ModTime.After(b.ModTime)
+	}
+	return a.Path < b.Path
+}
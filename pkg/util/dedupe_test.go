@@ -0,0 +1,197 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDedupeEpub 创建指定书名、作者和标识符的测试 EPUB，body 用于区分文件内容
+func writeDedupeEpub(t *testing.T, path, title, author, identifier, body string) {
+	t.Helper()
+	entries := testEpubEntries(title)
+	opf := strings.Replace(entries[2][1], "测试作者", author, 1)
+	entries[2][1] = strings.Replace(opf, "urn:uuid:12345", identifier, 1)
+	entries[3][1] = "<html><body><p>" + body + "</p></body></html>"
+	writeTestEpub(t, path, entries)
+}
+
+func readFingerprints(t *testing.T, keys []DedupeKey, paths ...string) []*BookFingerprint {
+	t.Helper()
+	var books []*BookFingerprint
+	for _, path := range paths {
+		fp, err := ReadBookFingerprint(path, keys)
+		if err != nil {
+			t.Fatalf("ReadBookFingerprint(%s) 失败: %v", path, err)
+		}
+		books = append(books, fp)
+	}
+	return books
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.epub")
+	b := filepath.Join(dir, "b.epub")
+	c := filepath.Join(dir, "c.epub")
+	d := filepath.Join(dir, "d.epub")
+	e := filepath.Join(dir, "e.epub")
+	writeDedupeEpub(t, a, "三体", "刘慈欣", "urn:uuid:aaa", "1")
+	// 书名清理后相同，作者前后有空白
+	writeDedupeEpub(t, b, "三体【雨果奖获奖作品，亚洲首位获奖者，中国科幻基石丛书，刘慈欣代表作！】", " 刘慈欣 ", "urn:uuid:bbb", "2")
+	// 只有 ISBN 与 d 相同
+	writeDedupeEpub(t, c, "球状闪电", "刘慈欣", "urn:isbn:978-7-5366-9293-0", "3")
+	writeDedupeEpub(t, d, "Ball Lightning", "Cixin Liu", "isbn:9787536692930", "4")
+	// 同名不同作者，不是重复
+	writeDedupeEpub(t, e, "三体", "某某", "urn:uuid:eee", "5")
+	// 内容完全相同的副本
+	f := filepath.Join(dir, "f.epub")
+	data, _ := os.ReadFile(e)
+	os.WriteFile(f, data, 0644)
+
+	books := readFingerprints(t, DedupeKeys, a, b, c, d, e, f)
+	groups := GroupDuplicates(books, DedupeKeys)
+	if len(groups) != 3 {
+		t.Fatalf("得到 %d 组，期望 3", len(groups))
+	}
+
+	want := []struct {
+		paths []string
+		keys  []DedupeKey
+	}{
+		{[]string{a, b}, []DedupeKey{DedupeTitle}},
+		{[]string{c, d}, []DedupeKey{DedupeISBN}},
+		{[]string{e, f}, []DedupeKey{DedupeHash, DedupeTitle, DedupeISBN}},
+	}
+	for i, w := range want {
+		g := groups[i]
+		var paths []string
+		for _, b := range g.Books {
+			paths = append(paths, b.Path)
+		}
+		if strings.Join(paths, ",") != strings.Join(w.paths, ",") {
+			t.Errorf("第 %d 组 = %v，期望 %v", i, paths, w.paths)
+		}
+		if len(g.Keys) != len(w.keys) {
+			t.Errorf("第 %d 组的依据 = %v，期望 %v", i, g.Keys, w.keys)
+			continue
+		}
+		for j := range w.keys {
+			if g.Keys[j] != w.keys[j] {
+				t.Errorf("第 %d 组的依据 = %v，期望 %v", i, g.Keys, w.keys)
+			}
+		}
+	}
+
+	// 只按内容比较
+	groups = GroupDuplicates(readFingerprints(t, []DedupeKey{DedupeHash}, a, b, c, d, e, f), []DedupeKey{DedupeHash})
+	if len(groups) != 1 || len(groups[0].Books) != 2 {
+		t.Errorf("按内容比较得到 %+v", groups)
+	}
+}
+
+func TestGroupDuplicates_Transitive(t *testing.T) {
+	// a 与 b 书名相同，b 与 c 内容相同，三者归为一组
+	books := []*BookFingerprint{
+		{Path: "a", TitleKey: "三体\x00刘慈欣", Hash: "1"},
+		{Path: "b", TitleKey: "三体\x00刘慈欣", Hash: "2"},
+		{Path: "c", Hash: "2"},
+		{Path: "d", Hash: "3"},
+	}
+	groups := GroupDuplicates(books, DedupeKeys)
+	if len(groups) != 1 || len(groups[0].Books) != 3 {
+		t.Fatalf("得到 %+v，期望一组三个文件", groups)
+	}
+}
+
+func TestDuplicateGroup_SortByPreference(t *testing.T) {
+	now := time.Now()
+	g := DuplicateGroup{Books: []*BookFingerprint{
+		{Path: "big-broken", Size: 900, Checked: true, ValidErr: errors.New("损坏")},
+		{Path: "small", Size: 100, ModTime: now, Checked: true},
+		{Path: "large-old", Size: 500, ModTime: now.Add(-time.Hour), Checked: true},
+		{Path: "large-new", Size: 500, ModTime: now, Checked: true},
+	}}
+	g.SortByPreference()
+
+	var got []string
+	for _, b := range g.Books {
+		got = append(got, b.Path)
+	}
+	want := "large-new,large-old,small,big-broken"
+	if strings.Join(got, ",") != want {
+		t.Errorf("排序结果 = %v，期望 %s", got, want)
+	}
+}
+
+func TestReadBookFingerprint_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.epub")
+	os.WriteFile(path, []byte("not a zip"), 0644)
+
+	fp, err := ReadBookFingerprint(path, DedupeKeys)
+	if err != nil {
+		t.Fatalf("损坏的文件不应返回错误: %v", err)
+	}
+	if fp.Hash == "" || fp.MetaErr == nil || fp.TitleKey != "" {
+		t.Errorf("损坏的文件应只有内容摘要: %+v", fp)
+	}
+}
+
+func TestReadBookFingerprint_OtherFormats(t *testing.T) {
+	dir := t.TempDir()
+	epubPath := filepath.Join(dir, "a.epub")
+	writeDedupeEpub(t, epubPath, "三体", "刘慈欣", "urn:uuid:aaa", "1")
+	fb2Path := filepath.Join(dir, "b.fb2")
+	writeTestFb2(t, fb2Path, []byte(testFb2(`      <author><first-name>慈欣</first-name><last-name>刘</last-name></author>
+      <book-title>三体</book-title>`, "", "")))
+
+	books := readFingerprints(t, []DedupeKey{DedupeTitle}, epubPath, fb2Path)
+	if books[1].MetaErr != nil {
+		t.Fatalf("FB2 元数据读取失败: %v", books[1].MetaErr)
+	}
+	if groups := GroupDuplicates(books, []DedupeKey{DedupeTitle}); len(groups) != 1 {
+		t.Errorf("EPUB 和 FB2 书名作者相同，期望 1 组，得到 %d", len(groups))
+	}
+	if err := ValidateBookFile(fb2Path); err != nil {
+		t.Errorf("FB2 应通过检测: %v", err)
+	}
+}
+
+func TestReadBookFingerprint_Identifiers(t *testing.T) {
+	dir := t.TempDir()
+	// Calibre 先写书库内的编号，再写 uuid
+	write := func(name, title, uuid string) string {
+		path := filepath.Join(dir, name)
+		entries := testEpubEntries(title)
+		entries[2][1] = strings.Replace(entries[2][1], `<dc:identifier id="BookId">urn:uuid:12345</dc:identifier>`,
+			`<dc:identifier opf:scheme="calibre" id="BookId">123</dc:identifier>
+    <dc:identifier opf:scheme="uuid">`+uuid+`</dc:identifier>`, 1)
+		entries[3][1] = "<html><body><p>" + name + "</p></body></html>"
+		writeTestEpub(t, path, entries)
+		return path
+	}
+	// 不同书库中的两本书 calibre 编号相同，不是重复
+	a := write("a.epub", "三体", "0d3d5f6a-aaaa")
+	b := write("b.epub", "球状闪电", "9f1e2c3b-bbbb")
+	c := write("c.epub", "Ball Lightning", "9F1E2C3B-BBBB")
+	// 没有 scheme 的标识符不参与比较
+	d := filepath.Join(dir, "d.epub")
+	writeDedupeEpub(t, d, "超新星纪元", "刘慈欣", "123", "4")
+
+	books := readFingerprints(t, []DedupeKey{DedupeISBN}, a, b, c, d)
+	if books[0].Identifier != "uuid:0d3d5f6a-aaaa" || books[3].Identifier != "" {
+		t.Errorf("标识符 = %q、%q", books[0].Identifier, books[3].Identifier)
+	}
+	groups := GroupDuplicates(books, []DedupeKey{DedupeISBN})
+	if len(groups) != 1 || len(groups[0].Books) != 2 {
+		t.Fatalf("只有 uuid 相同的 b、c 应为一组，得到 %d 组", len(groups))
+	}
+	for _, book := range groups[0].Books {
+		if book.Path != b && book.Path != c {
+			t.Errorf("意外的重复: %s", book.Path)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"archive/zip"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -24,8 +26,49 @@ const (
 	ErrorTypeMissing                    // 结构缺失
 	ErrorTypeFormat                     // 格式错误
 	ErrorTypeMetadata                   // 元数据缺失
+	ErrorTypeMimetype                   // mimetype 不是第一个条目、被压缩或内容错误
+	ErrorTypeRootfile                   // container.xml 指向的 OPF 不存在
+	ErrorTypeManifest                   // manifest 中的条目在归档中不存在
+	ErrorTypeSpine                      // spine 引用了 manifest 中不存在的 id
+	ErrorTypeEncoding                   // 内容文档的实际编码与声明不一致
 )
 
+// String 返回错误类型的英文标识，用于机器可读的输出
+func (t ErrorType) String() string {
+	switch t {
+	case ErrorTypeCorrupted:
+		return "corrupted"
+	case ErrorTypeMissing:
+		return "missing"
+	case ErrorTypeFormat:
+		return "format"
+	case ErrorTypeMetadata:
+		return "metadata"
+	case ErrorTypeMimetype:
+		return "mimetype"
+	case ErrorTypeRootfile:
+		return "rootfile"
+	case ErrorTypeManifest:
+		return "manifest"
+	case ErrorTypeSpine:
+		return "spine"
+	case ErrorTypeEncoding:
+		return "encoding"
+	}
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
+
+// Fixable 判断该类错误是否可能通过重新打包修复
+// 书籍内容完好、只是打包结构不规范或编码声明错误的文件返回 true；
+// 数据损坏、内容缺失或元数据无法解析的文件返回 false
+func (t ErrorType) Fixable() bool {
+	switch t {
+	case ErrorTypeMissing, ErrorTypeMimetype, ErrorTypeRootfile, ErrorTypeEncoding:
+		return true
+	}
+	return false
+}
+
 // Error 实现 error 接口
 func (e *EpubError) Error() string {
 	if e.Detail != "" {
@@ -37,47 +80,14 @@ func (e *EpubError) Error() string {
 // ValidateEpubFile 检测 EPUB 文件完整性
 // 返回 nil 表示文件正常，返回 EpubError 表示检测到问题
 func ValidateEpubFile(filePath string) error {
-	// 1. 检查文件是否存在
-	if !Exists(filePath) {
-		return &EpubError{
-			Type:    ErrorTypeCorrupted,
-			Message: "文件不存在",
-			Detail:  filePath,
-		}
-	}
-
-	// 2. 检查 ZIP 文件完整性
-	if err := checkZipIntegrity(filePath); err != nil {
-		return err
-	}
-
-	// 3. 检查必需文件存在性
-	if err := checkRequiredFiles(filePath); err != nil {
-		return err
-	}
-
-	// 4. 检查元数据可解析性
-	if err := checkMetadata(filePath); err != nil {
-		return err
-	}
-
-	return nil
+	return DefaultValidator().Validate(filePath)
 }
 
 // checkZipIntegrity 检查 ZIP 文件完整性
-func checkZipIntegrity(filePath string) error {
-	r, err := zip.OpenReader(filePath)
-	if err != nil {
-		return &EpubError{
-			Type:    ErrorTypeCorrupted,
-			Message: "ZIP 文件损坏",
-			Detail:  "无法打开或读取文件",
-		}
-	}
-	defer r.Close()
-
+func checkZipIntegrity(a *EpubArchive) error {
 	// 尝试读取所有文件条目以确保 ZIP 结构完整
-	for _, f := range r.File {
+	buf := make([]byte, 1024)
+	for _, f := range a.Reader.File {
 		rc, err := f.Open()
 		if err != nil {
 			return &EpubError{
@@ -87,7 +97,6 @@ func checkZipIntegrity(filePath string) error {
 			}
 		}
 		// 读取一小部分数据以验证可读性
-		buf := make([]byte, 1024)
 		_, err = rc.Read(buf)
 		if err != nil && err != io.EOF {
 			rc.Close()
@@ -103,35 +112,64 @@ func checkZipIntegrity(filePath string) error {
 	return nil
 }
 
-// checkRequiredFiles 检查 EPUB 必需文件
-func checkRequiredFiles(filePath string) error {
-	r, err := zip.OpenReader(filePath)
-	if err != nil {
+// checkZipIntegrityDeep 完整读取每个条目，校验 CRC32 和解压后大小
+// Go 的 zip 读取器只在读到条目末尾时校验 CRC32，因此必须读完整个条目
+func checkZipIntegrityDeep(a *EpubArchive) error {
+	var corrupted []string
+	for _, f := range a.Reader.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if reason := verifyZipEntry(f); reason != "" {
+			corrupted = append(corrupted, fmt.Sprintf("%s（%s）", f.Name, reason))
+		}
+	}
+
+	if len(corrupted) > 0 {
 		return &EpubError{
 			Type:    ErrorTypeCorrupted,
-			Message: "无法打开文件",
-			Detail:  err.Error(),
+			Message: "ZIP 文件损坏",
+			Detail:  fmt.Sprintf("%d 个条目校验失败: %s", len(corrupted), strings.Join(corrupted, "; ")),
 		}
 	}
-	defer r.Close()
+	return nil
+}
 
-	// EPUB 必需文件列表
-	requiredFiles := map[string]bool{
-		"mimetype":               false,
-		"META-INF/container.xml": false,
+// verifyZipEntry 完整读取单个条目，返回损坏原因，正常时返回空字符串
+func verifyZipEntry(f *zip.File) string {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Sprintf("无法打开: %v", err)
 	}
+	defer rc.Close()
+
+	n, err := io.Copy(io.Discard, rc)
+	switch {
+	case errors.Is(err, zip.ErrChecksum):
+		return "CRC32 校验失败"
+	case errors.Is(err, zip.ErrFormat):
+		return fmt.Sprintf("解压后大小不符，期望 %d 字节", f.UncompressedSize64)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "数据截断"
+	case err != nil:
+		return fmt.Sprintf("数据损坏: %v", err)
+	case uint64(n) != f.UncompressedSize64:
+		return fmt.Sprintf("解压后大小为 %d 字节，期望 %d 字节", n, f.UncompressedSize64)
+	}
+	return ""
+}
 
-	// 检查文件是否存在
-	for _, f := range r.File {
-		name := strings.TrimPrefix(f.Name, "./")
-		if _, required := requiredFiles[name]; required {
-			requiredFiles[name] = true
-		}
+// checkRequiredFiles 检查 EPUB 必需文件
+func checkRequiredFiles(a *EpubArchive) error {
+	// EPUB 必需文件列表
+	requiredFiles := []string{
+		"mimetype",
+		"META-INF/container.xml",
 	}
 
-	// 验证所有必需文件都存在
-	for file, found := range requiredFiles {
-		if !found {
+	// 验证所有必需文件都存在（兼容 "./" 前缀）
+	for _, file := range requiredFiles {
+		if a.File(file) == nil {
 			return &EpubError{
 				Type:    ErrorTypeMissing,
 				Message: "缺少必需文件",
@@ -144,8 +182,8 @@ func checkRequiredFiles(filePath string) error {
 }
 
 // checkMetadata 检查元数据可解析性
-func checkMetadata(filePath string) error {
-	book, err := epub.Open(filePath)
+func checkMetadata(a *EpubArchive) error {
+	opf, err := a.Opf()
 	if err != nil {
 		return &EpubError{
 			Type:    ErrorTypeFormat,
@@ -154,16 +192,27 @@ func checkMetadata(filePath string) error {
 		}
 	}
 
-	// 检查基本元数据
-	if book == nil {
-		return &EpubError{
-			Type:    ErrorTypeFormat,
-			Message: "EPUB 解析失败",
-			Detail:  "无法读取书籍结构",
+	// 目录文件（NCX）无法解析时同样视为格式错误
+	for _, item := range opf.Manifest {
+		if item.ID != opf.Spine.Toc || opf.Spine.Toc == "" {
+			continue
+		}
+		data, err := a.ReadFile(a.ResolveHref(item.Href))
+		if err != nil {
+			break
+		}
+		var ncx epub.Ncx
+		if err := xml.Unmarshal(data, &ncx); err != nil {
+			return &EpubError{
+				Type:    ErrorTypeFormat,
+				Message: "无法解析 EPUB 元数据",
+				Detail:  fmt.Sprintf("目录文件解析失败: %v", err),
+			}
 		}
+		break
 	}
 
-	if len(book.Opf.Metadata.Title) == 0 {
+	if len(opf.Metadata.Title) == 0 {
 		return &EpubError{
 			Type:    ErrorTypeMetadata,
 			Message: "缺少书籍标题",
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -171,3 +172,102 @@ func TestEpubError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorType_String(t *testing.T) {
+	tests := []struct {
+		errType  ErrorType
+		expected string
+	}{
+		{ErrorTypeCorrupted, "corrupted"},
+		{ErrorTypeMissing, "missing"},
+		{ErrorTypeFormat, "format"},
+		{ErrorTypeMetadata, "metadata"},
+		{ErrorTypeMimetype, "mimetype"},
+		{ErrorTypeRootfile, "rootfile"},
+		{ErrorTypeManifest, "manifest"},
+		{ErrorTypeSpine, "spine"},
+		{ErrorTypeEncoding, "encoding"},
+		{ErrorType(99), "unknown(99)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.errType.String(); got != tt.expected {
+			t.Errorf("ErrorType(%d).String() = %q，期望 %q", int(tt.errType), got, tt.expected)
+		}
+	}
+}
+
+func TestValidateEpubFile_DeepDetectsCorruptedEntry(t *testing.T) {
+	tmpDir := t.TempDir()
+	epubFile := filepath.Join(tmpDir, "bitrot.epub")
+
+	// 添加一个不压缩的大条目，损坏位置位于开头 1KB 之后
+	entries := testEpubEntries("三体")
+	entries = append(entries, [2]string{"OEBPS/images/cover.bin", strings.Repeat("a", 8192)})
+	f, err := os.Create(epubFile)
+	if err != nil {
+		t.Fatalf("无法创建测试文件: %v", err)
+	}
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		method := zip.Deflate
+		if e[0] == "mimetype" || strings.HasSuffix(e[0], ".bin") {
+			method = zip.Store
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: e[0], Method: method})
+		if err != nil {
+			t.Fatalf("无法创建 ZIP 条目: %v", err)
+		}
+		fw.Write([]byte(e[1]))
+	}
+	w.Close()
+	f.Close()
+
+	r, err := zip.OpenReader(epubFile)
+	if err != nil {
+		t.Fatalf("无法打开测试文件: %v", err)
+	}
+	offset, err := r.File[len(r.File)-1].DataOffset()
+	r.Close()
+	if err != nil {
+		t.Fatalf("无法获取条目偏移: %v", err)
+	}
+
+	data, err := os.ReadFile(epubFile)
+	if err != nil {
+		t.Fatalf("无法读取测试文件: %v", err)
+	}
+	data[offset+5000] = 'b'
+	if err := os.WriteFile(epubFile, data, 0644); err != nil {
+		t.Fatalf("无法写入测试文件: %v", err)
+	}
+
+	// 默认检测只读取开头，无法发现
+	if err := ValidateEpubFile(epubFile); err != nil {
+		t.Fatalf("默认检测期望通过，得到 %v", err)
+	}
+
+	err = NewEpubValidator(ValidateOptions{Deep: true}).Validate(epubFile)
+	if err == nil {
+		t.Fatal("深度检测期望返回错误，但得到 nil")
+	}
+	epubErr := err.(*EpubError)
+	if epubErr.Type != ErrorTypeCorrupted {
+		t.Errorf("期望错误类型为 ErrorTypeCorrupted，得到 %v", epubErr.Type)
+	}
+	if !strings.Contains(epubErr.Detail, "OEBPS/images/cover.bin") || !strings.Contains(epubErr.Detail, "CRC32") {
+		t.Errorf("错误详情应包含损坏的条目名和原因，得到 %q", epubErr.Detail)
+	}
+	if strings.Contains(epubErr.Detail, "chapter1.xhtml") {
+		t.Errorf("错误详情不应包含正常条目，得到 %q", epubErr.Detail)
+	}
+}
+
+func TestValidateEpubFile_DeepPassesValidEpub(t *testing.T) {
+	epubFile := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, epubFile, testEpubEntries("三体"))
+
+	if err := NewEpubValidator(ValidateOptions{Deep: true}).Validate(epubFile); err != nil {
+		t.Errorf("深度检测期望通过，得到 %v", err)
+	}
+}
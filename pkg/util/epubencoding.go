@@ -0,0 +1,287 @@
+package util
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kapmahc/epub"
+)
+
+// contentMediaTypes 需要检查编码的内容文档类型
+var contentMediaTypes = map[string]bool{
+	"application/xhtml+xml": true,
+	"text/html":             true,
+}
+
+// reMetaCharset <meta charset="gbk"> 或 <meta http-equiv="Content-Type" content="text/html; charset=gbk"> 中的编码，
+// 第一组为编码之前的部分
+var reMetaCharset = regexp.MustCompile(`(?i)(<meta\s[^>]*?charset\s*=\s*["']?)([a-z0-9._:-]+)`)
+
+// charsetPrescanSize 查找 <meta charset> 的范围，与 HTML 规范的预扫描类似
+const charsetPrescanSize = 4096
+
+// EncodingMismatch 内容文档的实际编码与声明不一致
+type EncodingMismatch struct {
+	Path     string
+	Declared string // XML 声明或 <meta charset> 中的编码，未声明时为空（按 UTF-8 处理）
+	Actual   string // 实际编码，如 EncodingGB18030、EncodingUTF8；不是有效的 UTF-8 又无法判断时为空
+}
+
+// String 返回如 "text/ch1.xhtml（声明 UTF-8，实际 GB18030）" 的说明
+func (m *EncodingMismatch) String() string {
+	declared := m.Declared
+	if declared == "" {
+		declared = EncodingUTF8
+	}
+	if m.Actual == "" {
+		return fmt.Sprintf("%s（声明 %s，包含无效的 UTF-8 字节）", m.Path, declared)
+	}
+	return fmt.Sprintf("%s（声明 %s，实际 %s）", m.Path, declared, m.Actual)
+}
+
+// declaredEncoding 返回内容文档声明的编码：优先使用 XML 声明，其次为开头部分的 <meta charset>，都没有时为空
+func declaredEncoding(data []byte) string {
+	if m := xmlEncodingRe.FindSubmatch(data); m != nil {
+		return strings.TrimSpace(string(m[1]))
+	}
+	head := data
+	if len(head) > charsetPrescanSize {
+		head = head[:charsetPrescanSize]
+	}
+	if m := reMetaCharset.FindSubmatch(head); m != nil {
+		return string(m[2])
+	}
+	return ""
+}
+
+// isUTF8Label 判断编码名称是否表示 UTF-8
+func isUTF8Label(label string) bool {
+	return strings.EqualFold(label, "utf-8") || strings.EqualFold(label, "utf8")
+}
+
+// DetectEncodingMismatch 判断内容文档的实际编码是否与声明一致，一致时返回 nil
+// 声明为 UTF-8（或未声明）但不是有效的 UTF-8 时，按 chineseEncoding 判断实际编码；
+// 无效字节少于有效的多字节字符时视为损坏的 UTF-8，不像中文的（如 Latin-1）无法判断，Actual 均为空。
+// 声明为其他编码但内容是包含非 ASCII 字符的有效 UTF-8 时，Actual 为 UTF-8。
+// 带 UTF-16 BOM 的文档不检查
+func DetectEncodingMismatch(path string, data []byte) *EncodingMismatch {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		return nil
+	}
+	declared := declaredEncoding(data)
+	valid := utf8.Valid(data)
+
+	if declared == "" || isUTF8Label(declared) {
+		if valid {
+			return nil
+		}
+		m := &EncodingMismatch{Path: path, Declared: declared}
+		if !mostlyUTF8(data) {
+			m.Actual = chineseEncoding(data)
+		}
+		return m
+	}
+
+	if valid && !isASCII(data) {
+		return &EncodingMismatch{Path: path, Declared: declared, Actual: EncodingUTF8}
+	}
+	return nil
+}
+
+// minHanRatio 按 GB18030 或 Big5 解码后，常用汉字至少占非 ASCII 字符的 1/minHanRatio 才认为是中文
+const minHanRatio = 10
+
+// chineseEncoding 按 DetectTextEncoding 选出 GB18030 或 Big5，解码结果中常用汉字太少时返回空
+// DetectTextEncoding 对非 UTF-8 的文本只会回答这两种编码，Latin-1 等西文编码也会被误认为 GB18030
+func chineseEncoding(data []byte) string {
+	name := DetectTextEncoding(data)
+	enc, ok := textEncodings[name]
+	if !ok {
+		return ""
+	}
+	sample := data
+	if len(sample) > encodingSampleSize {
+		sample = sample[:encodingSampleSize]
+	}
+	decoded, err := enc.NewDecoder().Bytes(sample)
+	if err != nil {
+		return ""
+	}
+	han, nonASCII := 0, 0
+	for _, r := range string(decoded) {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		nonASCII++
+		if commonHan[r] {
+			han++
+		}
+	}
+	if han == 0 || han*minHanRatio < nonASCII {
+		return ""
+	}
+	return name
+}
+
+// mostlyUTF8 判断无效的字节是否少于有效的多字节字符，即文本本身是 UTF-8，只是部分字节损坏
+func mostlyUTF8(data []byte) bool {
+	multibyte, invalid := 0, 0
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case size > 1:
+			multibyte++
+		}
+		data = data[size:]
+	}
+	return multibyte > invalid
+}
+
+// isASCII 判断数据是否只包含 ASCII 字符
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// TranscodeToUTF8 将内容文档从 m.Actual 转换为 UTF-8，并将 XML 声明和 <meta charset> 中的编码改为 utf-8
+func TranscodeToUTF8(data []byte, m *EncodingMismatch) ([]byte, error) {
+	switch m.Actual {
+	case "":
+		return nil, fmt.Errorf("无法判断 %s 的实际编码", m.Path)
+	case EncodingUTF8:
+	default:
+		enc, ok := textEncodings[m.Actual]
+		if !ok {
+			return nil, fmt.Errorf("不支持的编码 %s", m.Actual)
+		}
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("按 %s 解码 %s 失败: %w", m.Actual, m.Path, err)
+		}
+		data = decoded
+	}
+
+	if loc := xmlEncodingRe.FindSubmatchIndex(data); loc != nil {
+		data = bytes.Join([][]byte{data[:loc[2]], []byte("utf-8"), data[loc[3]:]}, nil)
+	}
+	return reMetaCharset.ReplaceAll(data, []byte("${1}utf-8")), nil
+}
+
+// checkContentEncoding 检查 manifest 中的 XHTML/HTML 内容文档的实际编码与声明是否一致
+// 常见于旧工具生成的 EPUB：声明为 UTF-8，内容却是 GBK 编码，阅读器中显示为乱码
+func checkContentEncoding(a *EpubArchive) error {
+	opf, err := a.Opf()
+	if err != nil {
+		return nil // 由 checkMetadata 报告
+	}
+
+	var mismatches []string
+	seen := make(map[string]bool)
+	for _, item := range opf.Manifest {
+		if !contentMediaTypes[item.MediaType] || strings.Contains(item.Href, "://") {
+			continue
+		}
+		name := a.ResolveHref(item.Href)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		data, err := a.ReadFile(name)
+		if err != nil {
+			continue // 由 zip、manifest 阶段报告
+		}
+		if m := DetectEncodingMismatch(name, data); m != nil {
+			mismatches = append(mismatches, m.String())
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return &EpubError{
+			Type:    ErrorTypeEncoding,
+			Message: "内容文档的编码与声明不一致",
+			Detail:  fmt.Sprintf("%d 个文件: %s", len(mismatches), limitedList(mismatches)),
+		}
+	}
+	return nil
+}
+
+// repairContentEncoding 将编码与声明不一致的内容文档转换为 UTF-8，返回修复说明
+// 任一文档无法判断实际编码时返回错误，不做任何修改
+func repairContentEncoding(entries []*zipEntry) ([]string, error) {
+	opfPath, err := readRootfilePath(entries)
+	if err != nil {
+		return nil, nil
+	}
+	opfEntry := findEntry(entries, opfPath)
+	if opfEntry == nil {
+		return nil, nil
+	}
+	data, err := opfEntry.Read()
+	if err != nil {
+		return nil, nil
+	}
+	var opf epub.Opf
+	if err := xml.Unmarshal(data, &opf); err != nil {
+		return nil, nil
+	}
+
+	type change struct {
+		entry *zipEntry
+		data  []byte
+	}
+	var changes []change
+	counts := make(map[string]int)
+	var order []string
+	seen := make(map[string]bool)
+	for _, item := range opf.Manifest {
+		if !contentMediaTypes[item.MediaType] || strings.Contains(item.Href, "://") {
+			continue
+		}
+		name := resolveOpfHref(opfPath, item.Href)
+		entry := findEntry(entries, name)
+		if entry == nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		data, err := entry.Read()
+		if err != nil {
+			return nil, fmt.Errorf("无法读取 %s: %w", name, err)
+		}
+		m := DetectEncodingMismatch(name, data)
+		if m == nil {
+			continue
+		}
+		fixed, err := TranscodeToUTF8(data, m)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change{entry, fixed})
+		if counts[m.Actual] == 0 {
+			order = append(order, m.Actual)
+		}
+		counts[m.Actual]++
+	}
+
+	for _, c := range changes {
+		c.entry.SetData(c.data)
+	}
+	var fixes []string
+	for _, actual := range order {
+		if actual == EncodingUTF8 {
+			fixes = append(fixes, fmt.Sprintf("将 %d 个内容文档的编码声明改为 UTF-8", counts[actual]))
+		} else {
+			fixes = append(fixes, fmt.Sprintf("将 %d 个内容文档从 %s 转换为 UTF-8", counts[actual], actual))
+		}
+	}
+	return fixes, nil
+}
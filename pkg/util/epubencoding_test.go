@@ -0,0 +1,98 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// mustEncodeGBK 将 UTF-8 文本转换为 GBK
+func mustEncodeGBK(s string) string {
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(s)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
+
+const testXhtml = `<?xml version="1.0" encoding="utf-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <title>第一章</title>
+</head>
+<body><p>这是一个关于我们的故事，他们来到这里。</p></body>
+</html>`
+
+// testLatin1Xhtml 声明 UTF-8 但按 Latin-1 编码的西文内容文档
+const testLatin1Xhtml = "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n" +
+	"<html xmlns=\"http://www.w3.org/1999/xhtml\"><body><p>Caf\xe9 r\xe9sum\xe9 na\xefve fa\xe7ade \xe0 la cr\xe8me br\xfbl\xe9e.</p></body></html>"
+
+func TestDetectEncodingMismatch(t *testing.T) {
+	big5, _ := traditionalchinese.Big5.NewEncoder().String("<html><body><p>這是一個關於我們的故事，他們來到這裡。</p></body></html>")
+	damaged := strings.Repeat("正文", 20) + "\xff"
+
+	tests := []struct {
+		name     string
+		data     string
+		declared string
+		actual   string
+		ok       bool
+	}{
+		{"UTF-8", testXhtml, "", "", true},
+		{"未声明的 UTF-8", "<html><body>正文</body></html>", "", "", true},
+		{"声明 UTF-8 实际 GBK", mustEncodeGBK(testXhtml), "utf-8", EncodingGB18030, false},
+		{"未声明实际 Big5", big5, "", EncodingBig5, false},
+		{"meta 声明 GBK 实际 UTF-8", `<html><head><meta charset="gbk"/></head><body>正文</body></html>`, "gbk", EncodingUTF8, false},
+		{"声明 GBK 实际 GBK", mustEncodeGBK(`<?xml version="1.0" encoding="gbk"?><html><body>正文</body></html>`), "", "", true},
+		{"声明 GBK 只有 ASCII", `<?xml version="1.0" encoding="gbk"?><html/>`, "", "", true},
+		{"损坏的 UTF-8", damaged, "", "", false},
+		{"声明 UTF-8 实际 Latin-1", testLatin1Xhtml, "utf-8", "", false},
+		{"UTF-16", "\xff\xfe<\x00h\x00", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := DetectEncodingMismatch("ch.xhtml", []byte(tt.data))
+			if tt.ok {
+				if m != nil {
+					t.Errorf("期望一致，得到 %s", m)
+				}
+				return
+			}
+			if m == nil {
+				t.Fatal("期望检测到不一致")
+			}
+			if m.Declared != tt.declared || m.Actual != tt.actual {
+				t.Errorf("声明 %q 实际 %q，期望 %q、%q", m.Declared, m.Actual, tt.declared, tt.actual)
+			}
+		})
+	}
+}
+
+func TestTranscodeToUTF8(t *testing.T) {
+	data := []byte(mustEncodeGBK(strings.ReplaceAll(testXhtml, "utf-8", "UTF-8")))
+	m := DetectEncodingMismatch("ch.xhtml", data)
+	if m == nil {
+		t.Fatal("期望检测到不一致")
+	}
+	fixed, err := TranscodeToUTF8(data, m)
+	if err != nil {
+		t.Fatalf("TranscodeToUTF8 失败: %v", err)
+	}
+	if string(fixed) != testXhtml {
+		t.Errorf("转换结果 = %s", fixed)
+	}
+
+	// 声明 GBK 的 UTF-8 文档只修改声明
+	data = []byte(strings.ReplaceAll(testXhtml, "utf-8", "gb2312"))
+	fixed, err = TranscodeToUTF8(data, DetectEncodingMismatch("ch.xhtml", data))
+	if err != nil || string(fixed) != testXhtml {
+		t.Errorf("修改声明 = %s, %v", fixed, err)
+	}
+
+	if _, err := TranscodeToUTF8(data, &EncodingMismatch{Path: "ch.xhtml"}); err == nil {
+		t.Error("无法判断实际编码时期望返回错误")
+	}
+}
@@ -0,0 +1,245 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kapmahc/epub"
+)
+
+// EpubMimetype 是 EPUB mimetype 文件要求的内容
+const EpubMimetype = "application/epub+zip"
+
+// zipEntry 表示重新打包时的一个 ZIP 条目
+// data 为 nil 时直接拷贝原始条目的压缩数据，避免重复压缩
+type zipEntry struct {
+	Name string
+	file *zip.File
+	data []byte
+}
+
+// Read 读取条目的完整内容
+func (e *zipEntry) Read() ([]byte, error) {
+	if e.data != nil || e.file == nil {
+		return e.data, nil
+	}
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// SetData 替换条目内容
+func (e *zipEntry) SetData(data []byte) {
+	if data == nil {
+		data = []byte{}
+	}
+	e.data = data
+}
+
+// findEntry 按名称查找条目，兼容 "./" 前缀
+func findEntry(entries []*zipEntry, name string) *zipEntry {
+	for _, e := range entries {
+		if strings.TrimPrefix(e.Name, "./") == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// rewriteEpub 读取 EPUB 条目，交由 modify 调整后重新打包，并原子替换原文件
+func rewriteEpub(filePath string, modify func(entries []*zipEntry) ([]*zipEntry, error)) error {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("无法打开 EPUB 文件: %w", err)
+	}
+
+	entries := make([]*zipEntry, 0, len(r.File))
+	for _, f := range r.File {
+		entries = append(entries, &zipEntry{Name: f.Name, file: f})
+	}
+
+	entries, err = modify(entries)
+	if err != nil {
+		r.Close()
+		return err
+	}
+
+	tmpPath, err := writeEpubTemp(filePath, entries)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("替换原文件失败: %w", err)
+	}
+	return nil
+}
+
+// writeEpubTemp 将条目写入与 filePath 同目录的临时文件，返回临时文件路径
+func writeEpubTemp(filePath string, entries []*zipEntry) (string, error) {
+	return writeTempFile(filePath, func(w io.Writer) error {
+		return writeEpubArchive(w, entries)
+	})
+}
+
+// writeTempFile 在 filePath 同目录创建临时文件并交由 write 写入，保持原文件权限，返回临时文件路径
+func writeTempFile(filePath string, write func(w io.Writer) error) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("无法创建临时文件: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	fail := func(err error) (string, error) {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	if err := write(tmp); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(fmt.Errorf("写入临时文件失败: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	// 保持原文件权限
+	if info, err := os.Stat(filePath); err == nil {
+		os.Chmod(tmpPath, info.Mode().Perm())
+	}
+	return tmpPath, nil
+}
+
+// writeEpubArchive 按 OCF 规范写出 EPUB：mimetype 为第一个条目且不压缩
+func writeEpubArchive(w io.Writer, entries []*zipEntry) error {
+	zw := zip.NewWriter(w)
+
+	mt, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   "mimetype",
+		Method: zip.Store,
+	})
+	if err != nil {
+		return fmt.Errorf("写入 mimetype 失败: %w", err)
+	}
+	if _, err := mt.Write([]byte(EpubMimetype)); err != nil {
+		return fmt.Errorf("写入 mimetype 失败: %w", err)
+	}
+
+	for _, e := range entries {
+		if strings.TrimPrefix(e.Name, "./") == "mimetype" {
+			continue
+		}
+		if err := writeZipEntry(zw, e); err != nil {
+			return fmt.Errorf("写入条目 %s 失败: %w", e.Name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// writeZipEntry 写出单个条目，未修改的条目直接拷贝原始压缩数据
+func writeZipEntry(zw *zip.Writer, e *zipEntry) error {
+	if e.data == nil && e.file != nil {
+		if e.Name == e.file.Name {
+			return zw.Copy(e.file)
+		}
+		fh := e.file.FileHeader
+		fh.Name = e.Name
+		w, err := zw.CreateRaw(&fh)
+		if err != nil {
+			return err
+		}
+		raw, err := e.file.OpenRaw()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, raw)
+		return err
+	}
+
+	fh := &zip.FileHeader{Name: e.Name, Method: zip.Deflate}
+	if e.file != nil {
+		fh.Modified = e.file.Modified
+		fh.SetMode(e.file.Mode())
+	}
+	if strings.HasSuffix(e.Name, "/") {
+		fh.Method = zip.Store
+	}
+	w, err := zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(e.data)
+	return err
+}
+
+// readRootfilePath 从 META-INF/container.xml 中读取 OPF 路径
+func readRootfilePath(entries []*zipEntry) (string, error) {
+	entry := findEntry(entries, "META-INF/container.xml")
+	if entry == nil {
+		return "", fmt.Errorf("缺少 META-INF/container.xml")
+	}
+	data, err := entry.Read()
+	if err != nil {
+		return "", fmt.Errorf("无法读取 container.xml: %w", err)
+	}
+
+	var container epub.Container
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("无法解析 container.xml: %w", err)
+	}
+	if container.Rootfile.Path == "" {
+		return "", fmt.Errorf("container.xml 未指定 OPF 路径")
+	}
+	return container.Rootfile.Path, nil
+}
+
+// rewriteEpubOpf 读取 EPUB 中的 OPF，交由 edit 修改后重新打包
+func rewriteEpubOpf(filePath string, edit func(e *opfEditor) error) error {
+	return rewriteEpub(filePath, func(entries []*zipEntry) ([]*zipEntry, error) {
+		opfPath, err := readRootfilePath(entries)
+		if err != nil {
+			return nil, err
+		}
+		entry := findEntry(entries, opfPath)
+		if entry == nil {
+			return nil, fmt.Errorf("OPF 文件不存在: %s", opfPath)
+		}
+		data, err := entry.Read()
+		if err != nil {
+			return nil, fmt.Errorf("无法读取 OPF 文件: %w", err)
+		}
+
+		editor := newOpfEditor(data)
+		if err := edit(editor); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(editor.Bytes(), data) {
+			entry.SetData(editor.Bytes())
+		}
+		return entries, nil
+	})
+}
+
+// WriteEpubTitle 修改 EPUB 的书名（OPF 中第一个 dc:title）
+// 直接改写 OPF 并重新打包，不依赖 Calibre 的 ebook-meta
+func WriteEpubTitle(filePath, title string) error {
+	return rewriteEpubOpf(filePath, func(e *opfEditor) error {
+		return e.SetText("title", title)
+	})
+}
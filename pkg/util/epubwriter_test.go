@@ -0,0 +1,160 @@
+package util
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kapmahc/epub"
+)
+
+const testContainerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+const testOpfTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="BookId">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:title>{{TITLE}}</dc:title>
+    <dc:creator opf:role="aut">测试作者</dc:creator>
+    <dc:language>zh</dc:language>
+    <dc:identifier id="BookId">urn:uuid:12345</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="chapter1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="chapter1"/>
+  </spine>
+</package>`
+
+// writeTestEpub 创建测试用 EPUB，entries 中的条目按顺序写入
+func writeTestEpub(t *testing.T, path string, entries [][2]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("无法创建测试文件: %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		method := zip.Deflate
+		if e[0] == "mimetype" {
+			method = zip.Store
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: e[0], Method: method})
+		if err != nil {
+			t.Fatalf("无法创建 ZIP 条目: %v", err)
+		}
+		if _, err := fw.Write([]byte(e[1])); err != nil {
+			t.Fatalf("无法写入 ZIP 条目: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("无法写入 ZIP: %v", err)
+	}
+}
+
+// testEpubEntries 返回一本标题为 title 的最小 EPUB 的条目
+func testEpubEntries(title string) [][2]string {
+	return [][2]string{
+		{"mimetype", EpubMimetype},
+		{"META-INF/container.xml", testContainerXML},
+		{"OEBPS/content.opf", strings.Replace(testOpfTemplate, "{{TITLE}}", title, 1)},
+		{"OEBPS/chapter1.xhtml", "<html><body><p>正文</p></body></html>"},
+	}
+}
+
+func TestWriteEpubTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{"中文标题", "三体"},
+		{"包含引号和特殊字符", `他说 "你好" & $HOME 'ok' <b>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "book.epub")
+			writeTestEpub(t, path, testEpubEntries("三体（刘慈欣科幻作品）"))
+
+			if err := WriteEpubTitle(path, tt.title); err != nil {
+				t.Fatalf("WriteEpubTitle 失败: %v", err)
+			}
+
+			book, err := epub.Open(path)
+			if err != nil {
+				t.Fatalf("无法打开修改后的 EPUB: %v", err)
+			}
+			defer book.Close()
+
+			if got := book.Opf.Metadata.Title[0]; got != tt.title {
+				t.Errorf("标题 = %q，期望 %q", got, tt.title)
+			}
+			if len(book.Opf.Metadata.Creator) != 1 || book.Opf.Metadata.Creator[0].Data != "测试作者" {
+				t.Errorf("作者信息被意外修改: %+v", book.Opf.Metadata.Creator)
+			}
+		})
+	}
+}
+
+func TestWriteEpubTitle_MimetypeFirstAndStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	// mimetype 不在第一位且被压缩
+	entries := testEpubEntries("旧标题")
+	entries[0], entries[1] = entries[1], entries[0]
+	writeTestEpub(t, path, entries)
+
+	if err := WriteEpubTitle(path, "新标题"); err != nil {
+		t.Fatalf("WriteEpubTitle 失败: %v", err)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("无法打开修改后的 EPUB: %v", err)
+	}
+	defer r.Close()
+
+	if len(r.File) != 4 {
+		t.Fatalf("条目数量 = %d，期望 4", len(r.File))
+	}
+	first := r.File[0]
+	if first.Name != "mimetype" {
+		t.Errorf("第一个条目 = %q，期望 mimetype", first.Name)
+	}
+	if first.Method != zip.Store {
+		t.Errorf("mimetype 压缩方式 = %d，期望不压缩", first.Method)
+	}
+
+	if err := ValidateEpubFile(path); err != nil {
+		t.Errorf("修改后的 EPUB 未通过检测: %v", err)
+	}
+}
+
+func TestWriteEpubTitle_InsertWhenMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("")
+	entries[2][1] = strings.Replace(entries[2][1], "    <dc:title></dc:title>\n", "", 1)
+	writeTestEpub(t, path, entries)
+
+	if err := WriteEpubTitle(path, "补充的标题"); err != nil {
+		t.Fatalf("WriteEpubTitle 失败: %v", err)
+	}
+
+	book, err := epub.Open(path)
+	if err != nil {
+		t.Fatalf("无法打开修改后的 EPUB: %v", err)
+	}
+	defer book.Close()
+
+	if len(book.Opf.Metadata.Title) != 1 || book.Opf.Metadata.Title[0] != "补充的标题" {
+		t.Errorf("标题 = %v，期望 [补充的标题]", book.Opf.Metadata.Title)
+	}
+}
@@ -0,0 +1,811 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// FB2（FictionBook 2）格式
+// 参考 http://www.fictionbook.org/index.php/Eng:XML_Schema_Fictionbook_2.1
+
+// fb2ZipExt 压缩的 FB2 文件扩展名
+const fb2ZipExt = ".fb2.zip"
+
+var (
+	// xmlEncodingRe XML 声明中的 encoding 属性
+	xmlEncodingRe = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([^"']*)["']`)
+
+	utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// Fb2Book 解析后的 FB2 文件，XML 已转换为 UTF-8
+type Fb2Book struct {
+	Encoding string // XML 声明中的编码，如 "windows-1251"，为空表示 UTF-8
+	Entry    string // .fb2.zip 中 FB2 文件的名称，.fb2 文件为空
+
+	data []byte            // UTF-8 编码的 XML，不含 BOM
+	enc  encoding.Encoding // 原文件的编码，UTF-8 时为 nil
+	bom  bool              // 原文件以 UTF-8 BOM 开头
+	scan *fb2Scan
+}
+
+// fb2Element title-info 或 publish-info 的直接子元素
+type fb2Element struct {
+	opfElement
+	Fields map[string]string // 子元素的文本，如 author 的 first-name、last-name
+}
+
+// fb2Section description 下的 title-info 或 publish-info
+type fb2Section struct {
+	Found    bool
+	Start    int    // 开始标签起始偏移
+	CloseAt  int    // 结束标签起始偏移
+	Indent   string // 子元素缩进
+	Elements []fb2Element
+}
+
+// fb2Binary 嵌入的二进制数据，Start 和 End 为 base64 内容的偏移
+type fb2Binary struct {
+	ID          string
+	ContentType string
+	Start, End  int
+}
+
+// fb2Scan 扫描结果
+type fb2Scan struct {
+	titleInfo   fb2Section
+	publishInfo fb2Section
+	descCloseAt int // </description> 的起始偏移，没有时为 -1
+	customInfo  int // 第一个 custom-info 的起始偏移，没有时为 -1
+	binaries    []fb2Binary
+	ids         map[string]bool // 所有元素的 id 属性
+	refs        []string        // href 中以 "#" 开头引用的 id
+}
+
+// OpenFb2 读取并解析 .fb2 文件或 .fb2.zip 中的 FB2 文件
+func OpenFb2(filePath string) (*Fb2Book, error) {
+	raw, entry, err := readFb2File(filePath)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ParseFb2(raw)
+	if err != nil {
+		return nil, err
+	}
+	b.Entry = entry
+	return b, nil
+}
+
+// isFb2Zip 判断是否为 .fb2.zip 文件
+func isFb2Zip(filePath string) bool {
+	return strings.HasSuffix(strings.ToLower(filePath), fb2ZipExt)
+}
+
+// readFb2File 读取 .fb2 文件，或 .fb2.zip 中第一个 .fb2 文件的内容和条目名称
+func readFb2File(filePath string) ([]byte, string, error) {
+	if !isFb2Zip(filePath) {
+		data, err := os.ReadFile(filePath)
+		return data, "", err
+	}
+
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, "", &EpubError{Type: ErrorTypeCorrupted, Message: "无法打开 ZIP 文件", Detail: err.Error()}
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".fb2") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, "", &EpubError{Type: ErrorTypeCorrupted, Message: "ZIP 中的 FB2 文件损坏", Detail: fmt.Sprintf("%s: %v", f.Name, err)}
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, "", &EpubError{Type: ErrorTypeCorrupted, Message: "ZIP 中的 FB2 文件损坏", Detail: fmt.Sprintf("%s: %v", f.Name, err)}
+		}
+		return data, f.Name, nil
+	}
+	return nil, "", &EpubError{Type: ErrorTypeFormat, Message: "ZIP 中没有 FB2 文件"}
+}
+
+// ParseFb2 解析 FB2 内容，按 BOM 或 XML 声明中的编码转换为 UTF-8 后检查 XML 是否格式良好
+func ParseFb2(raw []byte) (*Fb2Book, error) {
+	b := &Fb2Book{}
+	switch {
+	case bytes.HasPrefix(raw, utf8BOM):
+		b.bom = true
+		raw = raw[len(utf8BOM):]
+	case bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		b.enc = xunicode.UTF16(xunicode.BigEndian, xunicode.ExpectBOM)
+		b.Encoding = "UTF-16"
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}):
+		b.enc = xunicode.UTF16(xunicode.LittleEndian, xunicode.ExpectBOM)
+		b.Encoding = "UTF-16"
+	default:
+		if m := xmlEncodingRe.FindSubmatch(raw); m != nil {
+			label := strings.TrimSpace(string(m[1]))
+			if label != "" && !strings.EqualFold(label, "utf-8") && !strings.EqualFold(label, "utf8") {
+				enc, err := htmlindex.Get(label)
+				if err != nil {
+					return nil, &EpubError{Type: ErrorTypeFormat, Message: "不支持的编码", Detail: label}
+				}
+				b.enc = enc
+				b.Encoding = label
+			}
+		}
+	}
+
+	b.data = raw
+	if b.enc != nil {
+		data, err := b.enc.NewDecoder().Bytes(raw)
+		if err != nil {
+			return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法转换编码", Detail: fmt.Sprintf("%s: %v", b.Encoding, err)}
+		}
+		b.data = data
+	}
+
+	scan, err := scanFb2(b.data)
+	if err != nil {
+		return nil, err
+	}
+	b.scan = scan
+	return b, nil
+}
+
+// encode 将修改后的 XML 转换回原编码
+// 原编码无法表示新内容（如俄文编码中写入汉字）时改用 UTF-8，并修改 XML 声明中的编码
+func (b *Fb2Book) encode(data []byte) []byte {
+	if b.enc != nil {
+		if out, err := b.enc.NewEncoder().Bytes(data); err == nil {
+			return out
+		}
+		if loc := xmlEncodingRe.FindSubmatchIndex(data); loc != nil {
+			var buf bytes.Buffer
+			buf.Write(data[:loc[2]])
+			buf.WriteString("utf-8")
+			buf.Write(data[loc[3]:])
+			data = buf.Bytes()
+		}
+		return data
+	}
+	if b.bom {
+		return append(append([]byte(nil), utf8BOM...), data...)
+	}
+	return data
+}
+
+// scanFb2 检查 XML 是否格式良好，并记录 title-info、publish-info 和 binary 的位置
+func scanFb2(data []byte) (*fb2Scan, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	// 内容已转换为 UTF-8，忽略声明中的编码
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	s := &fb2Scan{descCloseAt: -1, customInfo: -1, ids: make(map[string]bool)}
+	var stack []string
+	var section *fb2Section
+	var current *fb2Element
+	var binary *fb2Binary
+	var text, field strings.Builder
+	root := false
+
+	for {
+		offset := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fb2SyntaxError(err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			depth := len(stack)
+			if depth == 1 {
+				root = true
+			}
+			if depth == 1 && t.Name.Local != "FictionBook" {
+				return nil, &EpubError{Type: ErrorTypeFormat, Message: "不是 FB2 文件", Detail: "根元素为 " + t.Name.Local}
+			}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Local == "id":
+					s.ids[a.Value] = true
+				case a.Name.Local == "href" && strings.HasPrefix(a.Value, "#"):
+					s.refs = append(s.refs, a.Value[1:])
+				}
+			}
+
+			switch {
+			case depth == 2 && t.Name.Local == "binary":
+				binary = &fb2Binary{Start: int(d.InputOffset())}
+				for _, a := range t.Attr {
+					switch a.Name.Local {
+					case "id":
+						binary.ID = a.Value
+					case "content-type":
+						binary.ContentType = a.Value
+					}
+				}
+			case depth == 3 && stack[1] == "description":
+				switch t.Name.Local {
+				case "title-info":
+					section = &s.titleInfo
+				case "publish-info":
+					section = &s.publishInfo
+				case "custom-info":
+					if s.customInfo < 0 {
+						s.customInfo = offset
+					}
+				}
+				// 只使用第一个 title-info 和 publish-info
+				if section != nil && section.Found {
+					section = nil
+				}
+				if section != nil {
+					section.Found = true
+					section.Start = offset
+				}
+			case depth == 4 && section != nil:
+				current = &fb2Element{opfElement: opfElement{
+					Local: t.Name.Local,
+					Attr:  append([]xml.Attr(nil), t.Attr...),
+					Start: offset,
+				}}
+				text.Reset()
+				if section.Indent == "" {
+					section.Indent = leadingIndent(data, offset)
+				}
+			case depth == 5 && current != nil:
+				field.Reset()
+			}
+
+		case xml.EndElement:
+			depth := len(stack)
+			switch {
+			case depth == 2 && binary != nil:
+				binary.End = offset
+				s.binaries = append(s.binaries, *binary)
+				binary = nil
+			case depth == 2 && t.Name.Local == "description":
+				s.descCloseAt = offset
+			case depth == 3 && section != nil:
+				section.CloseAt = offset
+				if len(section.Elements) == 0 {
+					section.Indent = leadingIndent(data, section.Start) + "  "
+				}
+				section = nil
+			case depth == 4 && current != nil:
+				current.End = int(d.InputOffset())
+				current.Text = text.String()
+				section.Elements = append(section.Elements, *current)
+				current = nil
+			case depth == 5 && current != nil:
+				if current.Fields == nil {
+					current.Fields = make(map[string]string)
+				}
+				if _, ok := current.Fields[t.Name.Local]; !ok {
+					current.Fields[t.Name.Local] = field.String()
+				}
+			}
+			stack = stack[:depth-1]
+
+		case xml.CharData:
+			switch {
+			case current != nil && len(stack) == 4:
+				text.Write(t)
+			case current != nil && len(stack) == 5:
+				field.Write(t)
+			}
+		}
+	}
+
+	if !root {
+		return nil, &EpubError{Type: ErrorTypeFormat, Message: "不是 FB2 文件", Detail: "没有根元素"}
+	}
+	return s, nil
+}
+
+// fb2SyntaxError 将 XML 解析错误转换为 EpubError
+func fb2SyntaxError(err error) error {
+	var syntax *xml.SyntaxError
+	if !errors.As(err, &syntax) {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "XML 格式错误", Detail: err.Error()}
+	}
+	if strings.Contains(syntax.Msg, "unexpected EOF") {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "文件被截断", Detail: fmt.Sprintf("第 %d 行: XML 未结束", syntax.Line)}
+	}
+	return &EpubError{Type: ErrorTypeCorrupted, Message: "XML 格式错误", Detail: fmt.Sprintf("第 %d 行: %s", syntax.Line, syntax.Msg)}
+}
+
+// find 返回指定名称的子元素
+func (sec *fb2Section) find(local string) []fb2Element {
+	var found []fb2Element
+	for _, el := range sec.Elements {
+		if el.Local == local {
+			found = append(found, el)
+		}
+	}
+	return found
+}
+
+// anchor 返回 before 中第一个存在的子元素的起始偏移，都不存在时返回结束标签的偏移
+func (sec *fb2Section) anchor(before ...string) int {
+	for _, el := range sec.Elements {
+		for _, local := range before {
+			if el.Local == local {
+				return el.Start
+			}
+		}
+	}
+	return sec.CloseAt
+}
+
+// Metadata 返回 title-info 中的书名、作者、译者、体裁和系列，以及 publish-info 中的出版社和 ISBN
+func (b *Fb2Book) Metadata() *EpubMetadata {
+	m := &EpubMetadata{}
+	for _, el := range b.scan.titleInfo.Elements {
+		switch el.Local {
+		case "book-title":
+			// 书名保留原文，由清理规则决定如何处理空白
+			if m.Title == "" {
+				m.Title = el.Text
+			}
+		case "author", "translator":
+			if name := fb2PersonName(el.Fields); name != "" {
+				m.Creators = append(m.Creators, Creator{Name: name, Role: fb2Role(el.Local)})
+			}
+		case "genre":
+			if genre := strings.TrimSpace(el.Text); genre != "" {
+				m.Subjects = append(m.Subjects, genre)
+			}
+		case "sequence":
+			if name := strings.TrimSpace(el.Attribute("name")); m.Series == nil && name != "" {
+				m.Series = &SeriesInfo{Name: name}
+				m.Series.Index, _ = strconv.ParseFloat(strings.TrimSpace(el.Attribute("number")), 64)
+			}
+		}
+	}
+	for _, el := range b.scan.publishInfo.Elements {
+		text := strings.TrimSpace(el.Text)
+		switch el.Local {
+		case "publisher":
+			if m.Publisher == "" {
+				m.Publisher = text
+			}
+		case "isbn":
+			if text != "" {
+				m.Identifiers = append(m.Identifiers, text)
+			}
+			if m.ISBN == "" {
+				m.ISBN = parseISBN(text, "isbn")
+			}
+		}
+	}
+	return m
+}
+
+// Year 返回出版年份，优先使用 publish-info 的 year，其次是 title-info 的 date
+func (b *Fb2Book) Year() string {
+	for _, el := range b.scan.publishInfo.find("year") {
+		if year := reYear.FindString(el.Text); year != "" {
+			return year
+		}
+	}
+	for _, el := range b.scan.titleInfo.find("date") {
+		if year := reYear.FindString(el.Attribute("value")); year != "" {
+			return year
+		}
+		if year := reYear.FindString(el.Text); year != "" {
+			return year
+		}
+	}
+	return ""
+}
+
+// fb2Role 返回 author 或 translator 对应的角色代码
+func fb2Role(local string) string {
+	if local == "translator" {
+		return "trl"
+	}
+	return "aut"
+}
+
+// fb2PersonName 由 first-name、middle-name、last-name 组成姓名，没有时使用 nickname
+// 中日韩姓名按姓在前且不加空格，如 "刘" + "慈欣" 为 "刘慈欣"
+func fb2PersonName(fields map[string]string) string {
+	first := strings.TrimSpace(fields["first-name"])
+	middle := strings.TrimSpace(fields["middle-name"])
+	last := strings.TrimSpace(fields["last-name"])
+	if first == "" && middle == "" && last == "" {
+		return strings.TrimSpace(fields["nickname"])
+	}
+	if containsCJK(first + middle + last) {
+		return last + first + middle
+	}
+	var parts []string
+	for _, p := range []string{first, middle, last} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// containsCJK 判断文本是否包含汉字、假名或谚文
+func containsCJK(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderFb2Person 将姓名写为 author 或 translator 元素
+// 含空格的姓名拆分为名、中间名和姓，其他（如中文姓名）写为 nickname
+func renderFb2Person(local, name string) string {
+	var b strings.Builder
+	field := func(tag, text string) {
+		b.WriteString(renderElement(&opfElement{Local: tag, Text: text}))
+	}
+	b.WriteString("<" + local + ">")
+	parts := strings.Fields(name)
+	if len(parts) > 1 && !containsCJK(name) {
+		field("first-name", parts[0])
+		if len(parts) > 2 {
+			field("middle-name", strings.Join(parts[1:len(parts)-1], " "))
+		}
+		field("last-name", parts[len(parts)-1])
+	} else {
+		field("nickname", strings.TrimSpace(name))
+	}
+	b.WriteString("</" + local + ">")
+	return b.String()
+}
+
+// ReadFb2Metadata 读取 FB2 或 FB2.ZIP 的元数据
+func ReadFb2Metadata(filePath string) (*EpubMetadata, error) {
+	b, err := OpenFb2(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 FB2 元数据: %w", err)
+	}
+	return b.Metadata(), nil
+}
+
+// ReadFb2TemplateValues 读取 FB2 元数据，返回书名、作者、系列、年份和 ISBN 等模板字段
+func ReadFb2TemplateValues(filePath string) (map[string]string, error) {
+	b, err := OpenFb2(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 FB2 元数据: %w", err)
+	}
+	values := metadataTemplateValues(b.Metadata())
+	if year := b.Year(); year != "" {
+		values[FieldYear] = year
+	}
+	return values, nil
+}
+
+// UpdateFb2Metadata 按 update 修改 FB2 的 title-info 和 publish-info，并原子替换原文件
+// 只改写涉及的元素，其余内容（包括正文和图片）保持原样；.fb2.zip 中的其他条目原样拷贝
+func UpdateFb2Metadata(filePath string, update *MetadataUpdate) error {
+	raw, entry, err := readFb2File(filePath)
+	if err != nil {
+		return err
+	}
+	b, err := ParseFb2(raw)
+	if err != nil {
+		return err
+	}
+
+	e := newOpfEditor(b.data)
+	if err := updateFb2(e, update); err != nil {
+		return err
+	}
+	if bytes.Equal(e.Bytes(), b.data) {
+		return nil
+	}
+	if _, err := scanFb2(e.Bytes()); err != nil {
+		return fmt.Errorf("修改后的 FB2 无效: %w", err)
+	}
+	data := b.encode(e.Bytes())
+
+	var tmpPath string
+	if entry == "" {
+		tmpPath, err = writeTempFile(filePath, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	} else {
+		tmpPath, err = writeFb2ZipTemp(filePath, entry, data)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("替换原文件失败: %w", err)
+	}
+	return nil
+}
+
+// writeFb2ZipTemp 将 .fb2.zip 中的 entry 替换为 data，其他条目原样拷贝，返回临时文件路径
+func writeFb2ZipTemp(filePath, entry string, data []byte) (string, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", fmt.Errorf("无法打开 ZIP 文件: %w", err)
+	}
+	defer r.Close()
+
+	return writeTempFile(filePath, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, f := range r.File {
+			e := &zipEntry{Name: f.Name, file: f}
+			if f.Name == entry {
+				e.SetData(data)
+			}
+			if err := writeZipEntry(zw, e); err != nil {
+				return fmt.Errorf("写入条目 %s 失败: %w", f.Name, err)
+			}
+		}
+		return zw.Close()
+	})
+}
+
+// title-info 子元素的顺序，新增元素插入到后面第一个已有元素之前
+var (
+	fb2AfterGenre      = []string{"author", "book-title", "annotation", "keywords", "date", "coverpage", "lang", "src-lang", "translator", "sequence"}
+	fb2AfterAuthor     = fb2AfterGenre[1:]
+	fb2AfterTitle      = fb2AfterGenre[2:]
+	fb2AfterTranslator = []string{"sequence"}
+	fb2AfterPublisher  = []string{"city", "year", "isbn", "sequence"}
+)
+
+// updateFb2 按 update 修改 XML，每项修改后重新扫描以获得新的偏移
+func updateFb2(e *opfEditor, update *MetadataUpdate) error {
+	edit := func(fn func(s *fb2Scan)) error {
+		s, err := scanFb2(e.Bytes())
+		if err != nil {
+			return err
+		}
+		if !s.titleInfo.Found {
+			return errors.New("FB2 中缺少 title-info")
+		}
+		fn(s)
+		return nil
+	}
+
+	if update.Title != nil {
+		err := edit(func(s *fb2Scan) {
+			old := s.titleInfo.find("book-title")
+			if len(old) > 1 {
+				old = old[:1]
+			}
+			rendered := renderElement(&opfElement{Local: "book-title", Text: *update.Title})
+			replaceFb2Elements(e, &s.titleInfo, old, []string{rendered}, fb2AfterTitle...)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if update.Creators != nil {
+		for _, local := range []string{"author", "translator"} {
+			before := fb2AfterAuthor
+			if local == "translator" {
+				before = fb2AfterTranslator
+			}
+			err := edit(func(s *fb2Scan) {
+				old := s.titleInfo.find(local)
+				var rendered []string
+				for _, c := range update.Creators {
+					// FB2 只区分作者和译者，其他角色写为作者
+					if (c.Role == "trl") != (local == "translator") {
+						continue
+					}
+					rendered = append(rendered, renderFb2Creator(e.Bytes(), old, local, c.Name))
+				}
+				replaceFb2Elements(e, &s.titleInfo, old, rendered, before...)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if update.Subjects != nil {
+		err := edit(func(s *fb2Scan) {
+			var rendered []string
+			for _, subject := range update.Subjects {
+				rendered = append(rendered, renderElement(&opfElement{Local: "genre", Text: subject}))
+			}
+			replaceFb2Elements(e, &s.titleInfo, s.titleInfo.find("genre"), rendered, fb2AfterGenre...)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if update.Series != nil {
+		err := edit(func(s *fb2Scan) {
+			// 只修改第一个 sequence，其余的（如出版社的丛书）保持不变
+			old := s.titleInfo.find("sequence")
+			if len(old) > 1 {
+				old = old[:1]
+			}
+			var rendered []string
+			if update.Series.Name != "" {
+				el := &opfElement{Local: "sequence", Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: update.Series.Name}}}
+				if update.Series.Index != 0 {
+					el.Attr = append(el.Attr, xml.Attr{Name: xml.Name{Local: "number"}, Value: formatSeriesIndex(update.Series.Index)})
+				}
+				rendered = []string{renderElement(el)}
+			}
+			replaceFb2Elements(e, &s.titleInfo, old, rendered)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if update.Publisher != nil {
+		err := edit(func(s *fb2Scan) {
+			rendered := renderElement(&opfElement{Local: "publisher", Text: *update.Publisher})
+			if s.publishInfo.Found {
+				old := s.publishInfo.find("publisher")
+				if len(old) > 1 {
+					old = old[:1]
+				}
+				var list []string
+				if *update.Publisher != "" {
+					list = []string{rendered}
+				}
+				replaceFb2Elements(e, &s.publishInfo, old, list, fb2AfterPublisher...)
+				return
+			}
+			if *update.Publisher == "" || s.descCloseAt < 0 {
+				return
+			}
+			// 没有 publish-info 时新增，位于 custom-info 之前
+			indent := leadingIndent(e.Bytes(), s.titleInfo.Start)
+			section := "<publish-info>\n" + s.titleInfo.Indent + rendered + "\n" + indent + "</publish-info>"
+			at := s.descCloseAt
+			if s.customInfo >= 0 {
+				at = s.customInfo
+			}
+			e.insert(&metadataScan{indent: indent, closeAt: at}, section)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderFb2Creator 渲染 author 或 translator 元素，old 中有同名元素时保留其原文
+func renderFb2Creator(data []byte, old []fb2Element, local, name string) string {
+	for _, el := range old {
+		if fb2PersonName(el.Fields) == name {
+			return string(data[el.Start:el.End])
+		}
+	}
+	return renderFb2Person(local, name)
+}
+
+// replaceFb2Elements 删除 old 中的元素，并在第一个元素的位置写入 rendered
+// old 为空时插入到 before 中第一个存在的元素之前，都不存在时插入到节的末尾
+func replaceFb2Elements(e *opfEditor, sec *fb2Section, old []fb2Element, rendered []string, before ...string) {
+	spans := make([]opfElement, len(old))
+	for i, el := range old {
+		spans[i] = el.opfElement
+	}
+	e.replaceElements(&metadataScan{indent: sec.Indent, closeAt: sec.anchor(before...)}, spans, rendered)
+}
+
+// Fb2Validator FB2 和 FB2.ZIP 文件检测器
+// 检查 XML 是否格式良好、根元素、书名和嵌入的 base64 数据；Deep 时还检查图片数据和文档内链接
+type Fb2Validator struct {
+	Deep bool
+}
+
+// Profile 返回检测器的配置标识
+func (v *Fb2Validator) Profile() string {
+	if v.Deep {
+		return "fb2-deep"
+	}
+	return "fb2"
+}
+
+// Validate 检测 FB2 文件，返回 nil 表示文件正常，返回 EpubError 表示检测到问题
+func (v *Fb2Validator) Validate(filePath string) error {
+	b, err := OpenFb2(filePath)
+	if err != nil {
+		return err
+	}
+	if !b.scan.titleInfo.Found {
+		return &EpubError{Type: ErrorTypeMetadata, Message: "缺少书籍信息", Detail: "description 中未找到 title-info"}
+	}
+	if strings.TrimSpace(b.Metadata().Title) == "" {
+		return &EpubError{Type: ErrorTypeMetadata, Message: "缺少书籍标题", Detail: "title-info 中未找到 book-title"}
+	}
+	return b.checkBinaries(v.Deep)
+}
+
+// checkBinaries 解码所有 binary 元素；deep 时检查图片数据的类型和以 "#" 开头的链接
+func (b *Fb2Book) checkBinaries(deep bool) error {
+	var bad []string
+	for _, bin := range b.scan.binaries {
+		data, err := decodeFb2Base64(b.data[bin.Start:bin.End])
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", bin.ID, err))
+			continue
+		}
+		if deep && strings.HasPrefix(strings.ToLower(bin.ContentType), "image/") &&
+			!strings.HasPrefix(http.DetectContentType(data), "image/") {
+			bad = append(bad, fmt.Sprintf("%s: 不是有效的图片数据", bin.ID))
+		}
+	}
+	if len(bad) > 0 {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "嵌入的二进制数据损坏", Detail: limitedList(bad)}
+	}
+
+	if deep {
+		var missing []string
+		seen := make(map[string]bool)
+		for _, id := range b.scan.refs {
+			if !b.scan.ids[id] && !seen[id] {
+				seen[id] = true
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			return &EpubError{Type: ErrorTypeCorrupted, Message: "链接指向不存在的 id", Detail: limitedList(missing)}
+		}
+	}
+	return nil
+}
+
+// limitedList 用逗号连接前 10 项，其余只显示数量
+func limitedList(items []string) string {
+	const limit = 10
+	if len(items) <= limit {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s 等 %d 个", strings.Join(items[:limit], ", "), len(items))
+}
+
+// decodeFb2Base64 解码 binary 中的 base64 内容，忽略空白，允许省略末尾的 "="
+func decodeFb2Base64(text []byte) ([]byte, error) {
+	clean := bytes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+	if len(clean) == 0 {
+		return nil, errors.New("内容为空")
+	}
+	if s := string(clean); strings.HasSuffix(s, "=") || len(s)%4 == 0 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(string(clean))
+}
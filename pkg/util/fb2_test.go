@@ -0,0 +1,345 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// testPng 最小的 PNG 文件头，足以被识别为图片
+var testPng = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+// testFb2 生成 FB2 文档，titleInfo 为 title-info 的内容，body 和 binaries 追加在 description 之后
+func testFb2(titleInfo, publishInfo, body string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+` + titleInfo + `
+    </title-info>
+    <document-info>
+      <id>doc-1</id>
+    </document-info>
+`)
+	if publishInfo != "" {
+		b.WriteString("    <publish-info>\n" + publishInfo + "\n    </publish-info>\n")
+	}
+	b.WriteString("  </description>\n")
+	if body == "" {
+		body = `<body><section><p>正文</p></section></body>`
+	}
+	b.WriteString("  " + body + "\n</FictionBook>\n")
+	return b.String()
+}
+
+const testFb2TitleInfo = `      <genre>sf</genre>
+      <author><first-name>慈欣</first-name><last-name>刘</last-name></author>
+      <author><first-name>Isaac</first-name><last-name>Asimov</last-name></author>
+      <book-title>三体（典藏版）</book-title>
+      <date value="2008-01-01">2008</date>
+      <lang>zh</lang>
+      <translator><nickname>张三</nickname></translator>
+      <sequence name="地球往事" number="1"/>`
+
+func writeTestFb2(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestFb2Zip 将 FB2 内容写入 .fb2.zip，附带一个其他条目
+func writeTestFb2Zip(t *testing.T, path string, data []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string][]byte{"book.fb2": data, "readme.txt": []byte("说明")} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFb2(t, path, buf.Bytes())
+}
+
+func TestReadFb2Metadata(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.fb2")
+	writeTestFb2(t, path, []byte(testFb2(testFb2TitleInfo, `      <publisher>重庆出版社</publisher>
+      <year>2010</year>
+      <isbn>978-7-5366-9293-0</isbn>`, "")))
+
+	meta, err := ReadBookMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadBookMetadata 失败: %v", err)
+	}
+	if meta.Title != "三体（典藏版）" {
+		t.Errorf("书名 = %q", meta.Title)
+	}
+	want := []Creator{{Name: "刘慈欣", Role: "aut"}, {Name: "Isaac Asimov", Role: "aut"}, {Name: "张三", Role: "trl"}}
+	if len(meta.Creators) != len(want) {
+		t.Fatalf("责任者 = %+v", meta.Creators)
+	}
+	for i, c := range want {
+		if meta.Creators[i].Name != c.Name || meta.Creators[i].Role != c.Role {
+			t.Errorf("责任者 %d = %+v，期望 %+v", i, meta.Creators[i], c)
+		}
+	}
+	if meta.Series.String() != "地球往事 #1" {
+		t.Errorf("系列 = %v", meta.Series)
+	}
+	if meta.Publisher != "重庆出版社" || meta.ISBN != "9787536692930" {
+		t.Errorf("出版社 = %q，ISBN = %q", meta.Publisher, meta.ISBN)
+	}
+	if len(meta.Subjects) != 1 || meta.Subjects[0] != "sf" {
+		t.Errorf("主题 = %v", meta.Subjects)
+	}
+
+	values, err := ReadBookTemplateValues(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[FieldYear] != "2010" || values[FieldAuthor] != "刘慈欣、Isaac Asimov" || values[FieldIndex] != "1" {
+		t.Errorf("模板字段 = %v", values)
+	}
+
+	// windows-1251 编码和 .fb2.zip
+	cp1251, err := charmap.Windows1251.NewEncoder().String(strings.Replace(
+		testFb2(`<author><nickname>Автор</nickname></author><book-title>Пикник на обочине</book-title>`, "",
+			`<body><section><p>Текст</p></section></body>`),
+		`encoding="utf-8"`, `encoding="windows-1251"`, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipped := filepath.Join(dir, "book.fb2.zip")
+	writeTestFb2Zip(t, zipped, []byte(cp1251))
+	if meta, err = ReadBookMetadata(zipped); err != nil || meta.Title != "Пикник на обочине" {
+		t.Errorf("书名 = %+v, %v", meta, err)
+	}
+}
+
+func TestFb2Validator(t *testing.T) {
+	dir := t.TempDir()
+	image := `<body><section><image l:href="#cover.png"/><p><a l:href="#n1">1</a></p></section></body>
+  <body name="notes"><section id="n1"><p>注释</p></section></body>
+  <binary id="cover.png" content-type="image/png">` + base64.StdEncoding.EncodeToString(testPng) + `</binary>`
+	good := testFb2(testFb2TitleInfo, "", image)
+
+	tests := []struct {
+		name     string
+		data     string
+		zip      bool
+		deep     bool
+		wantType ErrorType
+		wantErr  bool
+	}{
+		{name: "正常文件", data: good},
+		{name: "正常文件深度检测", data: good, deep: true},
+		{name: "正常的 fb2.zip", data: good, zip: true, deep: true},
+		{name: "被截断", data: good[:len(good)/2], wantErr: true, wantType: ErrorTypeCorrupted},
+		{name: "标签不匹配", data: strings.Replace(good, "</section>", "</p>", 1), wantErr: true, wantType: ErrorTypeCorrupted},
+		{name: "不是 FB2", data: `<?xml version="1.0"?><html><body/></html>`, wantErr: true, wantType: ErrorTypeFormat},
+		{name: "不支持的编码", data: strings.Replace(good, "utf-8", "x-unknown", 1), wantErr: true, wantType: ErrorTypeFormat},
+		{
+			name:     "缺少书名",
+			data:     testFb2(`<author><nickname>刘慈欣</nickname></author>`, "", ""),
+			wantErr:  true,
+			wantType: ErrorTypeMetadata,
+		},
+		{
+			name:     "base64 损坏",
+			data:     strings.Replace(good, `content-type="image/png">`, `content-type="image/png">A`, 1),
+			wantErr:  true,
+			wantType: ErrorTypeCorrupted,
+		},
+		{
+			name:     "图片数据无效",
+			data:     strings.Replace(good, base64.StdEncoding.EncodeToString(testPng), base64.StdEncoding.EncodeToString([]byte("not an image")), 1),
+			deep:     true,
+			wantErr:  true,
+			wantType: ErrorTypeCorrupted,
+		},
+		{
+			name:     "链接指向不存在的 id",
+			data:     strings.Replace(good, `#n1`, `#n2`, 1),
+			deep:     true,
+			wantErr:  true,
+			wantType: ErrorTypeCorrupted,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "book"+string(rune('a'+i))+".fb2")
+			if tt.zip {
+				path += ".zip"
+				writeTestFb2Zip(t, path, []byte(tt.data))
+			} else {
+				writeTestFb2(t, path, []byte(tt.data))
+			}
+
+			err := NewBookValidator(ValidateOptions{Deep: tt.deep}).Validate(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v，期望出错 %v", err, tt.wantErr)
+			}
+			var epubErr *EpubError
+			if tt.wantErr && (!errors.As(err, &epubErr) || epubErr.Type != tt.wantType) {
+				t.Errorf("错误 = %v，期望类型 %v", err, tt.wantType)
+			}
+		})
+	}
+
+	// 浅检测不检查图片内容和链接
+	path := filepath.Join(dir, "links.fb2")
+	writeTestFb2(t, path, []byte(strings.Replace(good, `#n1`, `#n2`, 1)))
+	if err := (&Fb2Validator{}).Validate(path); err != nil {
+		t.Errorf("浅检测不应检查链接: %v", err)
+	}
+
+	// ZIP 中没有 FB2 文件
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("readme.txt")
+	zw.Close()
+	path = filepath.Join(dir, "empty.fb2.zip")
+	writeTestFb2(t, path, buf.Bytes())
+	if err := ValidateBookFile(path); GetErrorType(err) != ErrorTypeFormat {
+		t.Errorf("ZIP 中没有 FB2 文件应返回 ErrorTypeFormat，得到 %v", err)
+	}
+}
+
+func TestUpdateFb2Metadata(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.fb2")
+	body := `<body><section><p>正文 &amp; 注释</p></section></body>
+  <binary id="cover.png" content-type="image/png">` + base64.StdEncoding.EncodeToString(testPng) + `</binary>`
+	writeTestFb2(t, path, []byte(testFb2(testFb2TitleInfo, "", body)))
+
+	title := "三体"
+	publisher := "重庆出版社"
+	err := UpdateFb2Metadata(path, &MetadataUpdate{
+		Title:     &title,
+		Creators:  []Creator{{Name: "刘慈欣", Role: "aut"}, {Name: "Ken Liu", Role: "trl"}},
+		Publisher: &publisher,
+		Subjects:  []string{"sf", "sf_social"},
+		Series:    &SeriesInfo{Name: "三体", Index: 1},
+	})
+	if err != nil {
+		t.Fatalf("UpdateFb2Metadata 失败: %v", err)
+	}
+
+	meta, err := ReadFb2Metadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "三体" || meta.Publisher != "重庆出版社" || meta.Series.String() != "三体 #1" {
+		t.Errorf("元数据 = %+v，系列 %v", meta, meta.Series)
+	}
+	if len(meta.Creators) != 2 || meta.Creators[0].Name != "刘慈欣" || meta.Creators[1].Name != "Ken Liu" || meta.Creators[1].Role != "trl" {
+		t.Errorf("责任者 = %+v", meta.Creators)
+	}
+	if len(meta.Subjects) != 2 {
+		t.Errorf("主题 = %v", meta.Subjects)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{
+		// 同名作者保留原来的写法
+		"<author><first-name>慈欣</first-name><last-name>刘</last-name></author>",
+		"<translator><first-name>Ken</first-name><last-name>Liu</last-name></translator>",
+		`<sequence name="三体" number="1"/>`,
+		"    <publish-info>\n      <publisher>重庆出版社</publisher>\n    </publish-info>\n  </description>",
+		"<p>正文 &amp; 注释</p>",
+		base64.StdEncoding.EncodeToString(testPng),
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("修改后的文件缺少 %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "Asimov") || strings.Contains(string(data), "张三") {
+		t.Errorf("未删除旧的责任者:\n%s", data)
+	}
+	if err := (&Fb2Validator{Deep: true}).Validate(path); err != nil {
+		t.Errorf("修改后检测失败: %v", err)
+	}
+}
+
+func TestUpdateFb2Metadata_Encoding(t *testing.T) {
+	dir := t.TempDir()
+	doc := strings.Replace(testFb2(`      <author><nickname>Автор</nickname></author>
+      <book-title>Пикник (сборник)</book-title>`, "", ""), `encoding="utf-8"`, `encoding="windows-1251"`, 1)
+	doc = strings.Replace(doc, "正文", "Текст", 1)
+	cp1251, err := charmap.Windows1251.NewEncoder().String(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 原编码能表示的书名保持原编码
+	path := filepath.Join(dir, "book.fb2.zip")
+	writeTestFb2Zip(t, path, []byte(cp1251))
+	title := "Пикник"
+	if err := UpdateFb2Metadata(path, &MetadataUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateFb2Metadata 失败: %v", err)
+	}
+	b, err := OpenFb2(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Encoding != "windows-1251" || b.Metadata().Title != "Пикник" || b.Entry != "book.fb2" {
+		t.Errorf("编码 = %q，书名 = %q，条目 = %q", b.Encoding, b.Metadata().Title, b.Entry)
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != 2 {
+		t.Errorf("ZIP 条目数 = %d，期望保留其他条目", len(r.File))
+	}
+	r.Close()
+
+	// 原编码无法表示的书名改用 UTF-8
+	title = "路边野餐"
+	if err := UpdateFb2Metadata(path, &MetadataUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateFb2Metadata 失败: %v", err)
+	}
+	if b, err = OpenFb2(path); err != nil {
+		t.Fatal(err)
+	}
+	if b.Encoding != "" || b.Metadata().Title != "路边野餐" || !strings.Contains(string(b.data), "Текст") {
+		t.Errorf("编码 = %q，书名 = %q", b.Encoding, b.Metadata().Title)
+	}
+}
+
+func TestFileExt(t *testing.T) {
+	tests := map[string]string{
+		"a/三体.fb2.zip": ".fb2.zip",
+		"三体.FB2.ZIP":   ".FB2.ZIP",
+		"三体.fb2":       ".fb2",
+		"archive.zip":  ".zip",
+		"三体.epub":      ".epub",
+		"notes.tar.gz": ".gz",
+		"fb2.zip":      ".zip",
+		"no-extension": "",
+	}
+	for path, want := range tests {
+		if got := FileExt(path); got != want {
+			t.Errorf("FileExt(%q) = %q，期望 %q", path, got, want)
+		}
+	}
+
+	values := FileTemplateValues("/books/三体.fb2.zip", 1)
+	if values[FieldExt] != "fb2.zip" || values[FieldName] != "三体" {
+		t.Errorf("模板字段 = %v", values)
+	}
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileFilter 查找文件时的过滤条件，零值匹配所有非隐藏文件
+type FileFilter struct {
+	// Formats 扩展名（"epub"、".fb2.zip"）或 glob 模式（"*.epub"、"vol-??.pdf"），匹配文件名且不区分大小写，
+	// 满足其中之一即可；为空时匹配所有文件
+	Formats []string
+	Include []*regexp.Regexp // 文件名需匹配其中之一，为空时不限制
+	Exclude []*regexp.Regexp // 文件名匹配其中之一时排除
+	MinSize int64            // 最小字节数，0 表示不限制
+	MaxSize int64            // 最大字节数，0 表示不限制
+	After   time.Time        // 修改时间晚于该时间，零值表示不限制
+	Before  time.Time        // 修改时间早于该时间，零值表示不限制
+	Hidden  bool             // 包含以 "." 开头的隐藏文件和目录
+}
+
+// MatchName 判断文件名是否满足格式和正则条件
+func (f *FileFilter) MatchName(name string) bool {
+	if !f.matchFormat(name) {
+		return false
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+// Match 判断文件是否满足所有条件，目录总是返回 false
+func (f *FileFilter) Match(name string, info fs.FileInfo) bool {
+	if info.IsDir() || (!f.Hidden && isHiddenName(name)) || !f.MatchName(name) {
+		return false
+	}
+	if f.MinSize > 0 && info.Size() < f.MinSize {
+		return false
+	}
+	if f.MaxSize > 0 && info.Size() > f.MaxSize {
+		return false
+	}
+	if !f.After.IsZero() && !info.ModTime().After(f.After) {
+		return false
+	}
+	if !f.Before.IsZero() && !info.ModTime().Before(f.Before) {
+		return false
+	}
+	return true
+}
+
+// matchFormat 按扩展名或 glob 模式匹配文件名
+func (f *FileFilter) matchFormat(name string) bool {
+	if len(f.Formats) == 0 {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, format := range f.Formats {
+		format = strings.ToLower(format)
+		if strings.ContainsAny(format, "*?[") {
+			if ok, _ := filepath.Match(format, lower); ok {
+				return true
+			}
+			continue
+		}
+		if ext := strings.TrimPrefix(format, "."); ext != "" && strings.HasSuffix(lower, "."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 检查 Formats 中的 glob 模式是否合法
+func (f *FileFilter) Validate() error {
+	for _, format := range f.Formats {
+		if _, err := filepath.Match(strings.ToLower(format), ""); err != nil {
+			return fmt.Errorf("无效的文件格式模式 %q: %w", format, err)
+		}
+	}
+	if f.MinSize > 0 && f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return fmt.Errorf("最小大小 %d 大于最大大小 %d", f.MinSize, f.MaxSize)
+	}
+	return nil
+}
+
+// FindFiles 返回 dir 中满足 filter 的文件，按路径排序；recursive 为 true 时搜索子目录
+// 不包含隐藏文件时也不进入隐藏目录；符号链接按其指向的文件判断
+func FindFiles(dir string, filter *FileFilter, recursive bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() {
+			if recursive && (filter.Hidden || !isHiddenName(name)) {
+				sub, err := FindFiles(path, filter, true)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, sub...)
+			}
+			continue
+		}
+		if filter.Match(name, info) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// isHiddenName 判断是否为隐藏文件名
+func isHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeUnits 大小单位，按 1024 进制
+var sizeUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+}
+
+var reSize = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([A-Za-z]*)$`)
+
+// ParseSize 解析 "500"、"300K"、"1.5MB"、"2GiB" 等大小，单位按 1024 进制，不区分大小写
+func ParseSize(s string) (int64, error) {
+	m := reSize.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("无效的大小 %q", s)
+	}
+	unit, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("无效的大小单位 %q，可用 B、K、M、G", m[2])
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的大小 %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// FormatSize 将字节数格式化为 "512 B"、"1.5 KB"、"3.2 MB" 等，按 1024 进制
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+var reAge = regexp.MustCompile(`^(\d+)\s*([mhdw])$`)
+
+// ageUnits 相对时间的单位
+var ageUnits = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseTimeFilter 解析修改时间条件：日期 "2024-01-31"、"2024-01-31 08:00"、RFC 3339，
+// 或相对于 now 的时长 "30m"、"12h"、"7d"、"2w"（表示 now 之前这么久的时刻）；日期按本地时区解析
+func ParseTimeFilter(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if m := reAge.FindStringSubmatch(strings.ToLower(s)); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return now.Add(-time.Duration(n) * ageUnits[m[2]]), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的时间 %q，可使用 2024-01-31、2024-01-31 08:00 或 7d、12h 等时长", s)
+}
@@ -0,0 +1,134 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFileFilter_MatchName(t *testing.T) {
+	tests := []struct {
+		formats []string
+		name    string
+		want    bool
+	}{
+		{[]string{"txt"}, "notes.TXT", true},
+		{[]string{"txt"}, "mytxtnotes.pdf", false},
+		{[]string{".epub"}, "book.epub", true},
+		{[]string{"fb2.zip"}, "book.fb2.zip", true},
+		{[]string{"zip"}, "book.fb2.zip", true},
+		{[]string{"*"}, "anything", true},
+		{[]string{"*.epub"}, "三体.EPUB", true},
+		{[]string{"vol-??.pdf"}, "vol-01.pdf", true},
+		{[]string{"vol-??.pdf"}, "vol-1.pdf", false},
+		{[]string{"epub", "pdf"}, "a.pdf", true},
+		{nil, "a.mobi", true},
+	}
+	for _, tt := range tests {
+		f := &FileFilter{Formats: tt.formats}
+		if got := f.MatchName(tt.name); got != tt.want {
+			t.Errorf("Formats %v 匹配 %q = %v, 期望 %v", tt.formats, tt.name, got, tt.want)
+		}
+	}
+
+	f := &FileFilter{
+		Include: []*regexp.Regexp{regexp.MustCompile(`^三体`)},
+		Exclude: []*regexp.Regexp{regexp.MustCompile(`(?i)sample`)},
+	}
+	if !f.MatchName("三体.epub") || f.MatchName("三体-Sample.epub") || f.MatchName("球状闪电.epub") {
+		t.Error("正则过滤不正确")
+	}
+
+	if err := (&FileFilter{Formats: []string{"[a-"}}).Validate(); err == nil {
+		t.Error("无效的 glob 模式期望返回错误")
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for name, size := range map[string]int{
+		"small.epub":         10,
+		"big.epub":           2048,
+		"sub/nested.epub":    2048,
+		"epub/readme.md":     10,
+		".hidden.epub":       2048,
+		".cache/cached.epub": 2048,
+		"old.epub":           2048,
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Chtimes(filepath.Join(dir, "old.epub"), old, old)
+
+	find := func(filter *FileFilter, recursive bool) []string {
+		files, err := FindFiles(dir, filter, recursive)
+		if err != nil {
+			t.Fatalf("FindFiles 失败: %v", err)
+		}
+		var rel []string
+		for _, f := range files {
+			r, _ := filepath.Rel(dir, f)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		return rel
+	}
+	check := func(got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("期望 %v，得到 %v", want, got)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("期望 %v，得到 %v", want, got)
+				return
+			}
+		}
+	}
+
+	epub := []string{"epub"}
+	check(find(&FileFilter{Formats: epub}, false), "big.epub", "old.epub", "small.epub")
+	check(find(&FileFilter{Formats: epub}, true), "big.epub", "old.epub", "small.epub", "sub/nested.epub")
+	check(find(&FileFilter{Formats: epub, Hidden: true}, true),
+		".cache/cached.epub", ".hidden.epub", "big.epub", "old.epub", "small.epub", "sub/nested.epub")
+	check(find(&FileFilter{Formats: epub, MinSize: 1024, After: time.Now().Add(-time.Hour)}, false), "big.epub")
+	check(find(&FileFilter{Formats: epub, MaxSize: 100}, true), "small.epub")
+	check(find(&FileFilter{Formats: epub, Before: time.Now().Add(-24 * time.Hour)}, true), "old.epub")
+}
+
+func TestParseSizeAndTime(t *testing.T) {
+	sizes := map[string]int64{"500": 500, "300K": 300 << 10, "1.5mb": 3 << 19, "2GiB": 2 << 30, "10 B": 10}
+	for s, want := range sizes {
+		if got, err := ParseSize(s); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d (%v), 期望 %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "abc", "10X", "-1K"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) 期望返回错误", s)
+		}
+	}
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	times := map[string]time.Time{
+		"7d":               now.Add(-7 * 24 * time.Hour),
+		"12h":              now.Add(-12 * time.Hour),
+		"2w":               now.Add(-14 * 24 * time.Hour),
+		"2024-01-31":       time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local),
+		"2024-01-31 08:30": time.Date(2024, 1, 31, 8, 30, 0, 0, time.Local),
+	}
+	for s, want := range times {
+		if got, err := ParseTimeFilter(s, now); err != nil || !got.Equal(want) {
+			t.Errorf("ParseTimeFilter(%q) = %v (%v), 期望 %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseTimeFilter("yesterday", now); err == nil {
+		t.Error("无效的时间期望返回错误")
+	}
+}
@@ -47,46 +47,55 @@ func EnsureDir(dir string) error {
 
 // MoveFileWithConflictHandling 移动文件并处理重名冲突
 // 如果目标文件已存在，会自动添加序号后缀，如 file(1).epub, file(2).epub
+// 目标目录在其他文件系统上时复制后删除源文件，见 MoveFile
 func MoveFileWithConflictHandling(srcPath, dstDir string) (string, error) {
+	return MoveFileAs(srcPath, dstDir, filepath.Base(srcPath))
+}
+
+// MoveFileAs 将文件移动到 dstDir 并命名为 fileName，重名冲突的处理与 MoveFileWithConflictHandling 相同
+func MoveFileAs(srcPath, dstDir, fileName string) (string, error) {
 	// 确保目标目录存在
 	if err := EnsureDir(dstDir); err != nil {
 		return "", fmt.Errorf("无法创建目标目录: %w", err)
 	}
 
-	fileName := filepath.Base(srcPath)
-	dstPath := filepath.Join(dstDir, fileName)
+	dstPath, err := AvailablePath(dstDir, fileName, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := MoveFile(srcPath, dstPath); err != nil {
+		return "", fmt.Errorf("移动文件失败: %w", err)
+	}
+	return dstPath, nil
+}
 
-	// 如果目标文件不存在，直接移动
-	if !Exists(dstPath) {
-		if err := os.Rename(srcPath, dstPath); err != nil {
-			return "", fmt.Errorf("移动文件失败: %w", err)
-		}
+// AvailablePath 返回 MoveFileAs 使用的目标路径：dstDir/fileName 已存在或已在 reserved 中时
+// 添加 (1)、(2) 等序号后缀。试运行时用 reserved 记录已分配的路径，预览结果与实际移动一致
+func AvailablePath(dstDir, fileName string, reserved map[string]bool) (string, error) {
+	dstPath := filepath.Join(dstDir, fileName)
+	if !Exists(dstPath) && !reserved[dstPath] {
 		return dstPath, nil
 	}
 
 	// 处理文件名冲突，添加序号
-	ext := filepath.Ext(fileName)
+	ext := FileExt(fileName)
 	nameWithoutExt := strings.TrimSuffix(fileName, ext)
 
 	for i := 1; i < 10000; i++ {
-		newFileName := fmt.Sprintf("%s(%d)%s", nameWithoutExt, i, ext)
-		newDstPath := filepath.Join(dstDir, newFileName)
-		if !Exists(newDstPath) {
-			if err := os.Rename(srcPath, newDstPath); err != nil {
-				return "", fmt.Errorf("移动文件失败: %w", err)
-			}
+		newDstPath := filepath.Join(dstDir, fmt.Sprintf("%s(%d)%s", nameWithoutExt, i, ext))
+		if !Exists(newDstPath) && !reserved[newDstPath] {
 			return newDstPath, nil
 		}
 	}
-
 	return "", fmt.Errorf("无法找到可用的文件名（尝试了 10000 次）")
 }
 
-// SafeDeleteFile 安全删除文件
-// needConfirm 为 true 时会要求用户确认
-func SafeDeleteFile(filePath string, needConfirm bool) error {
+// SafeDeleteFile 安全删除文件：移入回收站 ~/.bookimporter/trash 而不是永久删除
+// reason 记录删除原因（如检测到的错误）；needConfirm 为 true 时会要求用户确认
+// 可使用 bookimporter trash restore 恢复，返回回收站中的文件信息
+func SafeDeleteFile(filePath, reason string, needConfirm bool) (*TrashItem, error) {
 	if !Exists(filePath) {
-		return fmt.Errorf("文件不存在: %s", filePath)
+		return nil, fmt.Errorf("文件不存在: %s", filePath)
 	}
 
 	if needConfirm {
@@ -94,7 +103,7 @@ func SafeDeleteFile(filePath string, needConfirm bool) error {
 		fmt.Println()
 		warningMsg := ui.RenderMessageBox(
 			"删除确认",
-			fmt.Sprintf("即将删除文件:\n%s\n\n此操作无法撤销！", ui.RenderPath(filePath)),
+			fmt.Sprintf("即将删除文件:\n%s\n\n文件将移入回收站，可使用 bookimporter trash restore 恢复", ui.RenderPath(filePath)),
 			"warning",
 		)
 		fmt.Println(warningMsg)
@@ -104,20 +113,24 @@ func SafeDeleteFile(filePath string, needConfirm bool) error {
 		reader := bufio.NewReader(os.Stdin)
 		input, err := reader.ReadString('\n')
 		if err != nil {
-			return fmt.Errorf("读取用户输入失败: %w", err)
+			return nil, fmt.Errorf("读取用户输入失败: %w", err)
 		}
 		input = strings.TrimSpace(strings.ToLower(input))
 		if input != "y" && input != "yes" {
 			fmt.Println(ui.RenderInfo("已取消删除操作"))
-			return fmt.Errorf("用户取消删除操作")
+			return nil, fmt.Errorf("用户取消删除操作")
 		}
 	}
 
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("删除文件失败: %w", err)
+	trash, err := OpenDefaultTrash()
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	item, err := trash.Put(filePath, reason)
+	if err != nil {
+		return nil, fmt.Errorf("删除文件失败: %w", err)
+	}
+	return item, nil
 }
 
 // CopyFile 复制文件
@@ -0,0 +1,333 @@
+package util
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalOp 操作日志中的操作类型
+type JournalOp string
+
+const (
+	JournalMove     JournalOp = "move"     // 重命名或移动文件，Path → NewPath
+	JournalDelete   JournalOp = "delete"   // 删除文件，移入回收站中的 NewPath；NewPath 为空时无法撤销
+	JournalMetadata JournalOp = "metadata" // 修改元数据，Old 为修改前的值
+	JournalRepair   JournalOp = "repair"   // 重新打包，原文件备份在 Backup
+	JournalCreate   JournalOp = "create"   // 新建文件 Path，撤销时移入回收站
+)
+
+// journalExt 操作日志的扩展名，撤销后改为 journalUndoneExt
+const (
+	journalExt       = ".jsonl"
+	journalUndoneExt = ".undone"
+)
+
+// JournalEntry 操作日志中的一条记录
+type JournalEntry struct {
+	Time    time.Time       `json:"time"`
+	Op      JournalOp       `json:"op"`
+	Path    string          `json:"path"`
+	NewPath string          `json:"new_path,omitempty"` // move 的目标路径，delete 时为回收站中的路径
+	Backup  string          `json:"backup,omitempty"`
+	Old     *MetadataUpdate `json:"old,omitempty"`  // 修改前的元数据，只包含修改过的字段
+	New     *MetadataUpdate `json:"new,omitempty"`  // 修改后的元数据
+	Hash    string          `json:"hash,omitempty"` // 操作后 Path 的 SHA-256，撤销时用于确认文件未被再次修改
+}
+
+// Journal 记录一次命令执行中的文件操作，每条记录一行 JSON
+// 可以在多个 goroutine 中并发调用 Record；nil 表示不记录，各方法均为空操作
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	entries int
+}
+
+// DefaultJournalDir 返回默认的操作日志目录 ~/.bookimporter/journal
+func DefaultJournalDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("无法确定用户目录: %w", err)
+	}
+	return filepath.Join(home, ".bookimporter", "journal"), nil
+}
+
+// CreateJournal 在 dir 中创建操作日志，文件名为 "时间-命令.jsonl"
+func CreateJournal(dir, command string) (*Journal, error) {
+	if err := EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("无法创建日志目录: %w", err)
+	}
+
+	// 精确到微秒，保证按文件名排序即为创建顺序
+	name := time.Now().Format("20060102-150405.000000") + "-" + command
+	path := filepath.Join(dir, name+journalExt)
+	for i := 1; ; i++ {
+		// 已撤销的同名日志也视为冲突，避免标记撤销时覆盖
+		err := os.ErrExist
+		if !Exists(strings.TrimSuffix(path, journalExt) + journalUndoneExt) {
+			var f *os.File
+			if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err == nil {
+				return &Journal{path: path, file: f}, nil
+			}
+		}
+		if !os.IsExist(err) || i >= 100 {
+			return nil, fmt.Errorf("无法创建操作日志: %w", err)
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, journalExt))
+	}
+}
+
+// Path 返回日志文件路径
+func (j *Journal) Path() string {
+	if j == nil {
+		return ""
+	}
+	return j.path
+}
+
+// Len 返回已记录的操作数
+func (j *Journal) Len() int {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.entries
+}
+
+// Record 追加一条记录并立即同步到磁盘，路径转换为绝对路径，Time 为空时使用当前时间
+func (j *Journal) Record(entry JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	// 使用绝对路径，撤销时不依赖当前目录
+	for _, p := range []*string{&entry.Path, &entry.NewPath, &entry.Backup} {
+		if *p != "" {
+			if abs, err := filepath.Abs(*p); err == nil {
+				*p = abs
+			}
+		}
+	}
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入操作日志失败: %w", err)
+	}
+	j.entries++
+	return j.file.Sync()
+}
+
+// Close 关闭日志，没有任何记录时删除日志文件
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.file.Close()
+	if j.entries == 0 {
+		os.Remove(j.path)
+	}
+	return err
+}
+
+// ReadJournal 读取日志中的所有记录，按执行顺序返回
+func ReadJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开操作日志: %w", err)
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("操作日志第 %d 行无效: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取操作日志失败: %w", err)
+	}
+	return entries, nil
+}
+
+// WriteJournal 用 entries 覆盖日志文件，用于保留撤销失败的记录
+func WriteJournal(path string, entries []JournalEntry) error {
+	var buf strings.Builder
+	for i := range entries {
+		line, err := json.Marshal(&entries[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0644); err != nil {
+		return fmt.Errorf("写入操作日志失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// MarkJournalUndone 将日志标记为已撤销，ListJournals 不再返回该日志
+func MarkJournalUndone(path string) error {
+	return os.Rename(path, strings.TrimSuffix(path, journalExt)+journalUndoneExt)
+}
+
+// ListJournals 返回 dir 中尚未撤销的操作日志，按创建时间从旧到新排序
+func ListJournals(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+journalExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// modifiedField 比较 update 中的每个字段与当前元数据，返回第一个不一致的字段名，全部一致时返回空
+// 责任者只比较姓名且不计顺序，FB2 等格式写入时会按角色重新排列、合并角色
+func modifiedField(meta *EpubMetadata, update *MetadataUpdate) string {
+	if update.Title != nil && meta.Title != *update.Title {
+		return "书名"
+	}
+	if update.Creators != nil && !sameCreatorNames(meta.Creators, update.Creators) {
+		return "作者"
+	}
+	if update.Publisher != nil && meta.Publisher != *update.Publisher {
+		return "出版社"
+	}
+	if update.Subjects != nil && !slices.Equal(meta.Subjects, update.Subjects) {
+		return "主题"
+	}
+	if update.Series != nil {
+		current := SeriesInfo{}
+		if meta.Series != nil {
+			current = *meta.Series
+		}
+		if current.Name != update.Series.Name || (update.Series.Name != "" && current.Index != update.Series.Index) {
+			return "系列"
+		}
+	}
+	return ""
+}
+
+// sameCreatorNames 判断两组责任者的姓名是否相同，不计顺序
+func sameCreatorNames(a, b []Creator) bool {
+	names := func(creators []Creator) []string {
+		result := make([]string, len(creators))
+		for i, c := range creators {
+			result[i] = c.Name
+		}
+		sort.Strings(result)
+		return result
+	}
+	return slices.Equal(names(a), names(b))
+}
+
+// FileHash 返回文件内容的 SHA-256 十六进制字符串，用于记录 JournalEntry.Hash
+func FileHash(path string) (string, error) {
+	sum, err := hashFile(path, sha256.New())
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// UndoJournalEntry 撤销一条记录
+// 撤销前检查文件的当前状态，与记录不一致（如文件已被再次移动或修改）时返回错误，不做任何改动
+func UndoJournalEntry(entry *JournalEntry) error {
+	switch entry.Op {
+	case JournalMove:
+		if !Exists(entry.NewPath) {
+			return fmt.Errorf("文件不存在: %s", entry.NewPath)
+		}
+		if Exists(entry.Path) {
+			return fmt.Errorf("原路径已存在文件: %s", entry.Path)
+		}
+		if err := EnsureDir(filepath.Dir(entry.Path)); err != nil {
+			return err
+		}
+		return MoveFile(entry.NewPath, entry.Path)
+
+	case JournalMetadata:
+		if entry.Old == nil {
+			return errors.New("记录中缺少修改前的元数据")
+		}
+		format := FormatOf(entry.Path)
+		if format == nil || format.WriteMetadata == nil {
+			return fmt.Errorf("不支持恢复该格式的元数据: %s", entry.Path)
+		}
+		meta, err := format.ReadMetadata(entry.Path)
+		if err != nil {
+			return err
+		}
+		if entry.New != nil {
+			if field := modifiedField(meta, entry.New); field != "" {
+				return fmt.Errorf("%s已被再次修改", field)
+			}
+		}
+		return format.WriteMetadata(entry.Path, entry.Old)
+
+	case JournalRepair:
+		if !Exists(entry.Backup) {
+			return fmt.Errorf("备份文件不存在: %s", entry.Backup)
+		}
+		if entry.Hash != "" {
+			sum, err := FileHash(entry.Path)
+			if err != nil {
+				return fmt.Errorf("无法读取文件: %w", err)
+			}
+			if sum != entry.Hash {
+				return fmt.Errorf("文件已被再次修改: %s", entry.Path)
+			}
+		}
+		return os.Rename(entry.Backup, entry.Path)
+
+	case JournalCreate:
+		if !Exists(entry.Path) {
+			return fmt.Errorf("文件不存在: %s", entry.Path)
+		}
+		_, err := SafeDeleteFile(entry.Path, "撤销创建", false)
+		return err
+
+	case JournalDelete:
+		if entry.NewPath == "" {
+			return errors.New("已永久删除的文件无法恢复")
+		}
+		if !Exists(entry.NewPath) {
+			return fmt.Errorf("回收站中的文件不存在，可能已被清理: %s", entry.NewPath)
+		}
+		_, err := RestoreTrashedFile(entry.NewPath)
+		return err
+
+	default:
+		return fmt.Errorf("未知的操作类型: %s", entry.Op)
+	}
+}
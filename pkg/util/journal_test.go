@@ -0,0 +1,280 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournal_RecordAndRead(t *testing.T) {
+	dir := t.TempDir()
+	j, err := CreateJournal(dir, "rename")
+	if err != nil {
+		t.Fatalf("CreateJournal 失败: %v", err)
+	}
+
+	title := "三体"
+	entries := []JournalEntry{
+		{Op: JournalMove, Path: "a.epub", NewPath: "b.epub"},
+		{Op: JournalMetadata, Path: "b.epub", Old: &MetadataUpdate{Title: &title}},
+	}
+	for _, e := range entries {
+		if err := j.Record(e); err != nil {
+			t.Fatalf("Record 失败: %v", err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close 失败: %v", err)
+	}
+
+	got, err := ReadJournal(j.Path())
+	if err != nil {
+		t.Fatalf("ReadJournal 失败: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("期望 2 条记录，得到 %d", len(got))
+	}
+	if !filepath.IsAbs(got[0].Path) || !filepath.IsAbs(got[0].NewPath) {
+		t.Errorf("期望记录绝对路径，得到 %q → %q", got[0].Path, got[0].NewPath)
+	}
+	if got[0].Time.IsZero() {
+		t.Error("期望记录时间")
+	}
+	if got[1].Old == nil || got[1].Old.Title == nil || *got[1].Old.Title != title || got[1].Old.Creators != nil {
+		t.Errorf("元数据记录不正确: %+v", got[1].Old)
+	}
+
+	list, err := ListJournals(dir)
+	if err != nil || len(list) != 1 || list[0] != j.Path() {
+		t.Errorf("ListJournals 期望 [%s]，得到 %v (%v)", j.Path(), list, err)
+	}
+	if err := MarkJournalUndone(j.Path()); err != nil {
+		t.Fatalf("MarkJournalUndone 失败: %v", err)
+	}
+	if list, _ := ListJournals(dir); len(list) != 0 {
+		t.Errorf("已撤销的日志不应列出，得到 %v", list)
+	}
+}
+
+func TestJournal_EmptyRemovedAndNil(t *testing.T) {
+	dir := t.TempDir()
+	j, err := CreateJournal(dir, "check")
+	if err != nil {
+		t.Fatalf("CreateJournal 失败: %v", err)
+	}
+	j.Close()
+	if Exists(j.Path()) {
+		t.Error("没有记录的日志应被删除")
+	}
+
+	var none *Journal
+	if err := none.Record(JournalEntry{Op: JournalDelete, Path: "a.epub"}); err != nil || none.Len() != 0 {
+		t.Errorf("nil 日志应为空操作，得到 %v", err)
+	}
+}
+
+func TestUndoJournalEntry_Move(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "sub", "a.epub")
+	dst := filepath.Join(dir, "b.epub")
+	if err := os.WriteFile(dst, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &JournalEntry{Op: JournalMove, Path: src, NewPath: dst}
+	if err := UndoJournalEntry(entry); err != nil {
+		t.Fatalf("UndoJournalEntry 失败: %v", err)
+	}
+	if !Exists(src) || Exists(dst) {
+		t.Error("文件应移回原路径")
+	}
+
+	// 原路径被占用时不做任何改动
+	os.WriteFile(dst, []byte("y"), 0644)
+	if err := UndoJournalEntry(entry); err == nil {
+		t.Error("原路径已存在时期望返回错误")
+	}
+	if !Exists(dst) {
+		t.Error("撤销失败时不应移动文件")
+	}
+}
+
+func TestUndoJournalEntry_Create(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "new.epub")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &JournalEntry{Op: JournalCreate, Path: path}
+	if err := UndoJournalEntry(entry); err != nil {
+		t.Fatalf("UndoJournalEntry 失败: %v", err)
+	}
+	if Exists(path) {
+		t.Error("新建的文件应移入回收站")
+	}
+	if err := UndoJournalEntry(entry); err == nil {
+		t.Error("文件不存在时期望返回错误")
+	}
+}
+
+func TestUndoJournalEntry_Metadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = testOpf3
+	writeTestEpub(t, path, entries)
+
+	meta, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	title := "三体：黑暗森林"
+	update := &MetadataUpdate{
+		Title:    &title,
+		Creators: NormalizeCreators(meta.Creators),
+		Series:   &SeriesInfo{Name: "三体", Index: 2},
+	}
+	if err := UpdateEpubMetadata(path, update); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	entry := &JournalEntry{
+		Op:   JournalMetadata,
+		Path: path,
+		Old:  &MetadataUpdate{Title: &meta.Title, Creators: meta.Creators, Series: &SeriesInfo{}},
+		New:  update,
+	}
+	if err := UndoJournalEntry(entry); err != nil {
+		t.Fatalf("UndoJournalEntry 失败: %v", err)
+	}
+
+	restored, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	if restored.Title != meta.Title || !CreatorsEqual(restored.Creators, meta.Creators) || restored.Series != nil {
+		t.Errorf("元数据未恢复: %+v\n%s", restored, readTestOpf(t, path))
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("恢复后期望检测通过，得到 %v", err)
+	}
+
+	// 书名已被再次修改时拒绝撤销
+	if err := UndoJournalEntry(entry); err == nil {
+		t.Error("书名与记录不一致时期望返回错误")
+	}
+}
+
+func TestUndoJournalEntry_Repair(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.epub")
+	backup := path + ".bak"
+	os.WriteFile(backup, []byte("original"), 0644)
+	os.WriteFile(path, []byte("repaired"), 0644)
+
+	sum, err := FileHash(path)
+	if err != nil {
+		t.Fatalf("FileHash 失败: %v", err)
+	}
+	entry := &JournalEntry{Op: JournalRepair, Path: path, Backup: backup, Hash: sum}
+
+	// 修复后的文件已被再次修改时拒绝恢复
+	os.WriteFile(path, []byte("changed"), 0644)
+	if err := UndoJournalEntry(entry); err == nil {
+		t.Error("文件已被再次修改时期望返回错误")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "changed" || !Exists(backup) {
+		t.Error("撤销失败时不应改动文件")
+	}
+
+	os.WriteFile(path, []byte("repaired"), 0644)
+	if err := UndoJournalEntry(entry); err != nil {
+		t.Fatalf("UndoJournalEntry 失败: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" || Exists(backup) {
+		t.Errorf("期望从备份恢复，得到 %q", data)
+	}
+}
+
+func TestUndoJournalEntry_MetadataKeepsCreatorID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = testOpf3
+	writeTestEpub(t, path, entries)
+
+	meta, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	update := &MetadataUpdate{Creators: NormalizeCreators(meta.Creators)}
+	if err := UpdateEpubMetadata(path, update); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	// 经过操作日志的 JSON 往返后仍保留原 id
+	j, err := CreateJournal(t.TempDir(), "clname")
+	if err != nil {
+		t.Fatalf("CreateJournal 失败: %v", err)
+	}
+	j.Record(JournalEntry{Op: JournalMetadata, Path: path, Old: &MetadataUpdate{Creators: meta.Creators}, New: update})
+	j.Close()
+	got, err := ReadJournal(j.Path())
+	if err != nil || len(got) != 1 {
+		t.Fatalf("ReadJournal 失败: %v", err)
+	}
+	if err := UndoJournalEntry(&got[0]); err != nil {
+		t.Fatalf("UndoJournalEntry 失败: %v", err)
+	}
+
+	opf := readTestOpf(t, path)
+	if !strings.Contains(opf, `<dc:creator id="c1">[美] 某某 著 张三 译</dc:creator>`) {
+		t.Errorf("撤销后应保留原 id:\n%s", opf)
+	}
+	if !strings.Contains(opf, `<meta refines="#c1" property="display-seq">1</meta>`) {
+		t.Errorf("其他 meta refines 应保持有效:\n%s", opf)
+	}
+}
+
+func TestUndoJournalEntry_MetadataModifiedAgain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, path, testEpubEntries("三体"))
+
+	title := "三体：黑暗森林"
+	update := &MetadataUpdate{Title: &title, Subjects: []string{"科幻"}, Series: &SeriesInfo{Name: "三体", Index: 2}}
+	if err := UpdateEpubMetadata(path, update); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+	old := "三体"
+	entry := &JournalEntry{
+		Op:   JournalMetadata,
+		Path: path,
+		Old:  &MetadataUpdate{Title: &old, Subjects: []string{}, Series: &SeriesInfo{}},
+		New:  update,
+	}
+
+	// 书名未变，但系列序号已被再次修改
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Series: &SeriesInfo{Name: "三体", Index: 3}}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+	if err := UndoJournalEntry(entry); err == nil {
+		t.Error("系列与记录不一致时期望返回错误")
+	}
+	if meta, _ := ReadEpubMetadata(path); meta.Title != title {
+		t.Errorf("撤销失败时不应改动书名，得到 %q", meta.Title)
+	}
+
+	// 主题已被再次修改
+	UpdateEpubMetadata(path, &MetadataUpdate{Series: update.Series, Subjects: []string{"小说"}})
+	if err := UndoJournalEntry(entry); err == nil {
+		t.Error("主题与记录不一致时期望返回错误")
+	}
+
+	UpdateEpubMetadata(path, &MetadataUpdate{Subjects: update.Subjects})
+	if err := UndoJournalEntry(entry); err != nil {
+		t.Fatalf("UndoJournalEntry 失败: %v", err)
+	}
+	if meta, _ := ReadEpubMetadata(path); meta.Title != old || meta.Series != nil {
+		t.Errorf("元数据未恢复: %+v", meta)
+	}
+}
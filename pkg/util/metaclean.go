@@ -0,0 +1,167 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// creatorRoles 中文责任方式到 MARC 角色代码的映射，按长度优先匹配
+var creatorRoles = []struct {
+	suffix string
+	role   string
+}{
+	{"编译", "trl"},
+	{"翻译", "trl"},
+	{"编著", "aut"},
+	{"主编", "edt"},
+	{"插图", "ill"},
+	{"绘图", "ill"},
+	{"著", "aut"},
+	{"撰", "aut"},
+	{"编", "edt"},
+	{"译", "trl"},
+	{"绘", "ill"},
+}
+
+var (
+	// reNationality 名字前的国籍或朝代，如 [美]、（英）、〔清〕
+	reNationality = regexp.MustCompile(`^[\[［【(（〔]\s*\p{Han}{1,4}\s*[\]］】)）〕]\s*`)
+	// reCreatorSeparator 分隔不同责任者的符号，顿号表示共享同一责任方式，单独处理
+	reCreatorSeparator = regexp.MustCompile(`[;；/，]`)
+	// reSubjectSeparator 分隔多个主题的符号，"/" 常用于分类层级，不作为分隔符
+	reSubjectSeparator = regexp.MustCompile(`[;；,，、|]`)
+	// rePublisherLabel 出版社前的标签
+	rePublisherLabel = regexp.MustCompile(`^(出版社|出版者|出版|Publisher)\s*[:：]\s*`)
+	// rePublisherSuffix 出版社名称后多余的"出版"、"出品"
+	rePublisherSuffix = regexp.MustCompile(`(社|公司|集团|书局|书店|书社)\s*(出版|出品|发行)$`)
+)
+
+// normalizeSpaces 将全角空格和连续空白合并为一个空格
+func normalizeSpaces(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "　", " ")), " ")
+}
+
+// splitCreatorRole 拆分名字末尾的责任方式，如 "阿耐著" 返回 "阿耐"、"aut"
+// 不带空格时名字至少保留两个字，避免误拆
+func splitCreatorRole(token string) (string, string) {
+	for _, r := range creatorRoles {
+		if strings.TrimPrefix(token, "等") == r.suffix {
+			return "", r.role
+		}
+	}
+	for _, r := range creatorRoles {
+		name := strings.TrimSuffix(token, r.suffix)
+		if name != token && utf8.RuneCountInString(strings.TrimSuffix(name, "等")) >= 2 {
+			return name, r.role
+		}
+	}
+	return token, ""
+}
+
+// NormalizeCreators 清理责任者：去除名字前的国籍标记，将 "著"、"编"、"译" 等责任方式
+// 转换为角色代码，并拆分写在同一字段中的多个责任者，如 "[美] 某某 著 张三 译"
+// 未识别出责任方式时保留原有角色
+func NormalizeCreators(creators []Creator) []Creator {
+	var result []Creator
+	for _, c := range creators {
+		parsed := parseCreator(c.Name)
+		for i := range parsed {
+			if parsed[i].Role == "" {
+				parsed[i].Role = c.Role
+			}
+		}
+		if len(parsed) == 0 {
+			result = append(result, c)
+			continue
+		}
+
+		// 第一个责任者沿用原元素的属性、id 和排序名，排序名与未清理的名字相同时一并丢弃
+		parsed[0].id = c.id
+		parsed[0].attrs = c.attrs
+		if c.FileAs != c.Name || parsed[0].Name == c.Name {
+			parsed[0].FileAs = c.FileAs
+		}
+		result = append(result, parsed...)
+	}
+	return result
+}
+
+// parseCreator 解析单个 dc:creator 文本
+func parseCreator(raw string) []Creator {
+	var result []Creator
+	for _, segment := range reCreatorSeparator.Split(normalizeSpaces(raw), -1) {
+		var names []string
+		flush := func(role string) {
+			for _, name := range splitCreatorNames(strings.Join(names, " ")) {
+				result = append(result, Creator{Name: name, Role: role})
+			}
+			names = names[:0]
+		}
+
+		for _, token := range strings.Fields(segment) {
+			name, role := splitCreatorRole(token)
+			if name != "" {
+				names = append(names, name)
+			}
+			if role != "" {
+				flush(role)
+			}
+		}
+		flush("")
+	}
+	return result
+}
+
+// splitCreatorNames 去除国籍标记和 "等" 字，按顿号拆分共享责任方式的多个名字
+func splitCreatorNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, "、") {
+		name = strings.TrimSpace(reNationality.ReplaceAllString(strings.TrimSpace(name), ""))
+		if n := strings.TrimSpace(strings.TrimSuffix(name, "等")); utf8.RuneCountInString(n) >= 2 {
+			name = n
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// NormalizePublisher 清理出版社：合并空白，去除 "出版社：" 等标签和名称后多余的 "出版"
+func NormalizePublisher(publisher string) string {
+	p := normalizeSpaces(publisher)
+	p = rePublisherLabel.ReplaceAllString(p, "")
+	p = rePublisherSuffix.ReplaceAllString(p, "$1")
+	return strings.TrimSpace(p)
+}
+
+// NormalizeSubjects 清理主题：拆分写在同一字段中的多个主题，去除空白和重复项
+func NormalizeSubjects(subjects []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, subject := range subjects {
+		for _, s := range reSubjectSeparator.Split(subject, -1) {
+			s = normalizeSpaces(s)
+			if s == "" || seen[s] {
+				continue
+			}
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// CreatorsEqual 判断两组责任者的名字、角色和排序名是否一致
+func CreatorsEqual(a, b []Creator) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Role != b[i].Role || a[i].FileAs != b[i].FileAs {
+			return false
+		}
+	}
+	return true
+}
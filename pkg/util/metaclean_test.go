@@ -0,0 +1,67 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeCreators(t *testing.T) {
+	tests := []struct {
+		input    Creator
+		expected []Creator
+	}{
+		{Creator{Name: "阿耐 著"}, []Creator{{Name: "阿耐", Role: "aut"}}},
+		{Creator{Name: "阿耐著"}, []Creator{{Name: "阿耐", Role: "aut"}}},
+		{Creator{Name: "[美] 某某 著"}, []Creator{{Name: "某某", Role: "aut"}}},
+		{Creator{Name: "（英）某某"}, []Creator{{Name: "某某"}}},
+		{Creator{Name: "[美] 某某 译"}, []Creator{{Name: "某某", Role: "trl"}}},
+		{Creator{Name: "[美] 某某 著 张三 译"}, []Creator{{Name: "某某", Role: "aut"}, {Name: "张三", Role: "trl"}}},
+		{Creator{Name: "张三、李四 译"}, []Creator{{Name: "张三", Role: "trl"}, {Name: "李四", Role: "trl"}}},
+		{Creator{Name: "王五 主编；赵六 编"}, []Creator{{Name: "王五", Role: "edt"}, {Name: "赵六", Role: "edt"}}},
+		{Creator{Name: "阿耐 等著"}, []Creator{{Name: "阿耐", Role: "aut"}}},
+		// 未识别出责任方式时保留原有角色和排序名
+		{Creator{Name: "刘慈欣", Role: "aut", FileAs: "Liu, Cixin"}, []Creator{{Name: "刘慈欣", Role: "aut", FileAs: "Liu, Cixin"}}},
+		{Creator{Name: "J. K. Rowling", Role: "aut"}, []Creator{{Name: "J. K. Rowling", Role: "aut"}}},
+		// 名字过短时不拆分
+		{Creator{Name: "李编"}, []Creator{{Name: "李编"}}},
+		// 排序名与未清理的名字相同时丢弃
+		{Creator{Name: "阿耐 著", FileAs: "阿耐 著"}, []Creator{{Name: "阿耐", Role: "aut"}}},
+	}
+
+	for _, tt := range tests {
+		got := NormalizeCreators([]Creator{tt.input})
+		for i := range got {
+			got[i].id, got[i].attrs = "", nil
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("NormalizeCreators(%q) = %v，期望 %v", tt.input.Name, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizePublisher(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"人民文学出版社", "人民文学出版社"},
+		{"  人民文学出版社  ", "人民文学出版社"},
+		{"出版社：人民文学出版社", "人民文学出版社"},
+		{"人民文学出版社出版", "人民文学出版社"},
+		{"中信出版集团 出品", "中信出版集团"},
+		{"Penguin  Books", "Penguin Books"},
+	}
+	for _, tt := range tests {
+		if got := NormalizePublisher(tt.input); got != tt.expected {
+			t.Errorf("NormalizePublisher(%q) = %q，期望 %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizeSubjects(t *testing.T) {
+	got := NormalizeSubjects([]string{"小说;科幻", " 科幻 ", "中国、当代", "FICTION / Science Fiction"})
+	expected := []string{"小说", "科幻", "中国", "当代", "FICTION / Science Fiction"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("NormalizeSubjects = %q，期望 %q", got, expected)
+	}
+}
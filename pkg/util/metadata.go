@@ -0,0 +1,519 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Creator 作者、译者等责任者
+// Role 为 MARC 角色代码，如 aut（作者）、trl（译者）、edt（编者）、ill（绘者），空表示未指定
+type Creator struct {
+	Name   string
+	Role   string
+	FileAs string
+
+	id    string     // EPUB3 中用于 meta refines 的 id
+	attrs []xml.Attr // 原始属性，重写时保留
+}
+
+// String 返回便于显示的文本，如 "阿耐 (aut)"
+func (c Creator) String() string {
+	if c.Role == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Role)
+}
+
+// creatorAttr 责任者原始属性的 JSON 形式
+type creatorAttr struct {
+	Space string `json:"space,omitempty"`
+	Local string `json:"local"`
+	Value string `json:"value"`
+}
+
+// creatorJSON 责任者的 JSON 形式，包含 id 和原始属性，
+// 撤销时按原 id 重写，避免其他 meta refines 指向不存在的 id
+type creatorJSON struct {
+	Name   string        `json:"name"`
+	Role   string        `json:"role,omitempty"`
+	FileAs string        `json:"file_as,omitempty"`
+	ID     string        `json:"id,omitempty"`
+	Attrs  []creatorAttr `json:"attrs,omitempty"`
+}
+
+// MarshalJSON 实现 json.Marshaler，保留 id 和原始属性
+func (c Creator) MarshalJSON() ([]byte, error) {
+	v := creatorJSON{Name: c.Name, Role: c.Role, FileAs: c.FileAs, ID: c.id}
+	for _, a := range c.attrs {
+		v.Attrs = append(v.Attrs, creatorAttr{Space: a.Name.Space, Local: a.Name.Local, Value: a.Value})
+	}
+	return json.Marshal(&v)
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (c *Creator) UnmarshalJSON(data []byte) error {
+	var v creatorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = Creator{Name: v.Name, Role: v.Role, FileAs: v.FileAs, id: v.ID}
+	for _, a := range v.Attrs {
+		c.attrs = append(c.attrs, xml.Attr{Name: xml.Name{Space: a.Space, Local: a.Local}, Value: a.Value})
+	}
+	return nil
+}
+
+// FormatCreators 将责任者列表格式化为一行文本
+func FormatCreators(creators []Creator) string {
+	parts := make([]string, len(creators))
+	for i, c := range creators {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// EpubMetadata 可清理的 EPUB 元数据字段
+type EpubMetadata struct {
+	Title     string
+	Creators  []Creator
+	Publisher string
+	Subjects  []string
+	Series    *SeriesInfo
+
+	Identifiers []string // dc:identifier，有 scheme 时加上前缀，如 "calibre:123"、"uuid:..."；只读
+	ISBN        string   // 从 dc:identifier 中识别出的 ISBN，不含连字符
+}
+
+// MetadataUpdate 元数据修改，nil 字段保持不变
+// Series 的 Name 为空时删除系列信息
+type MetadataUpdate struct {
+	Title     *string     `json:"title"`
+	Creators  []Creator   `json:"creators"`
+	Publisher *string     `json:"publisher"`
+	Subjects  []string    `json:"subjects"`
+	Series    *SeriesInfo `json:"series"`
+}
+
+// ReadEpubMetadata 读取 EPUB 中 OPF 的书名、责任者、出版社和主题
+func ReadEpubMetadata(filePath string) (*EpubMetadata, error) {
+	s, err := scanEpubMetadata(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return s.metadata(), nil
+}
+
+// scanEpubMetadata 读取并扫描 EPUB 中 OPF 的 metadata 元素
+func scanEpubMetadata(filePath string) (*metadataScan, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开 EPUB 文件: %w", err)
+	}
+	defer r.Close()
+
+	entries := make([]*zipEntry, 0, len(r.File))
+	for _, f := range r.File {
+		entries = append(entries, &zipEntry{Name: f.Name, file: f})
+	}
+	opfPath, err := readRootfilePath(entries)
+	if err != nil {
+		return nil, err
+	}
+	entry := findEntry(entries, opfPath)
+	if entry == nil {
+		return nil, fmt.Errorf("OPF 文件不存在: %s", opfPath)
+	}
+	data, err := entry.Read()
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 OPF 文件: %w", err)
+	}
+
+	return newOpfEditor(data).scan()
+}
+
+// metadata 从扫描结果中提取元数据
+func (s *metadataScan) metadata() *EpubMetadata {
+	m := &EpubMetadata{}
+	for _, el := range s.elements {
+		text := strings.TrimSpace(el.Text)
+		switch el.Local {
+		case "title":
+			// 书名保留原文，由清理规则决定如何处理空白
+			if m.Title == "" {
+				m.Title = el.Text
+			}
+		case "creator":
+			m.Creators = append(m.Creators, s.creator(&el))
+		case "publisher":
+			if m.Publisher == "" {
+				m.Publisher = text
+			}
+		case "subject":
+			m.Subjects = append(m.Subjects, text)
+		case "identifier":
+			m.Identifiers = append(m.Identifiers, qualifyIdentifier(el.Attribute("scheme"), text))
+			if m.ISBN == "" {
+				m.ISBN = parseISBN(text, el.Attribute("scheme"))
+			}
+		}
+	}
+	m.Series = s.series()
+	return m
+}
+
+// year 返回 dc:date 中的年份，优先使用出版日期
+func (s *metadataScan) year() string {
+	var year string
+	for _, el := range s.elements {
+		if el.Local != "date" {
+			continue
+		}
+		if y := reYear.FindString(el.Text); y != "" && (year == "" || el.Attribute("event") == "publication") {
+			year = y
+		}
+	}
+	return year
+}
+
+// qualifyIdentifier 为标识符加上 scheme 前缀，值本身已带前缀（如 "urn:uuid:..."）时保持不变
+func qualifyIdentifier(scheme, value string) string {
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+	lower := strings.ToLower(value)
+	if scheme == "" || strings.HasPrefix(lower, scheme+":") || strings.HasPrefix(lower, "urn:"+scheme+":") {
+		return value
+	}
+	return scheme + ":" + value
+}
+
+// series 读取系列信息，优先使用 calibre:series，其次是 EPUB3 的 belongs-to-collection
+func (s *metadataScan) series() *SeriesInfo {
+	var series *SeriesInfo
+	for _, el := range s.elements {
+		if el.Local != "meta" {
+			continue
+		}
+		switch el.Attribute("name") {
+		case "calibre:series":
+			if series == nil {
+				series = &SeriesInfo{}
+			}
+			series.Name = el.Attribute("content")
+		case "calibre:series_index":
+			if series == nil {
+				series = &SeriesInfo{}
+			}
+			series.Index, _ = strconv.ParseFloat(el.Attribute("content"), 64)
+		}
+	}
+	if series != nil && series.Name != "" {
+		return series
+	}
+
+	for _, el := range s.elements {
+		if el.Local != "meta" || el.Attribute("property") != "belongs-to-collection" {
+			continue
+		}
+		series = &SeriesInfo{Name: strings.TrimSpace(el.Text)}
+		if id := el.Attribute("id"); id != "" {
+			for _, meta := range s.refines(id) {
+				if meta.Attribute("property") == "group-position" {
+					series.Index, _ = strconv.ParseFloat(strings.TrimSpace(meta.Text), 64)
+				}
+			}
+		}
+		return series
+	}
+	return nil
+}
+
+// creator 解析 dc:creator 元素，EPUB3 的角色和排序名来自 meta refines
+func (s *metadataScan) creator(el *opfElement) Creator {
+	c := Creator{
+		Name:   strings.TrimSpace(el.Text),
+		Role:   el.Attribute("role"),
+		FileAs: el.Attribute("file-as"),
+		id:     el.Attribute("id"),
+		attrs:  el.Attr,
+	}
+	if c.id == "" {
+		return c
+	}
+	for _, meta := range s.refines(c.id) {
+		switch meta.Attribute("property") {
+		case "role":
+			c.Role = strings.TrimSpace(meta.Text)
+		case "file-as":
+			c.FileAs = strings.TrimSpace(meta.Text)
+		}
+	}
+	return c
+}
+
+// refines 返回修饰指定 id 的 meta 元素
+func (s *metadataScan) refines(id string) []opfElement {
+	var metas []opfElement
+	for _, el := range s.elements {
+		if el.Local == "meta" && el.Attribute("refines") == "#"+id {
+			metas = append(metas, el)
+		}
+	}
+	return metas
+}
+
+// UpdateEpubMetadata 按 update 修改 EPUB 元数据并重新打包
+func UpdateEpubMetadata(filePath string, update *MetadataUpdate) error {
+	return rewriteEpubOpf(filePath, func(e *opfEditor) error {
+		return e.apply(update)
+	})
+}
+
+// apply 在 OPF 上执行元数据修改
+func (e *opfEditor) apply(update *MetadataUpdate) error {
+	if update.Title != nil {
+		if err := e.SetText("title", *update.Title); err != nil {
+			return err
+		}
+	}
+	if update.Publisher != nil {
+		if err := e.SetText("publisher", *update.Publisher); err != nil {
+			return err
+		}
+	}
+	if update.Subjects != nil {
+		if err := e.SetSubjects(update.Subjects); err != nil {
+			return err
+		}
+	}
+	if update.Creators != nil {
+		if err := e.SetCreators(update.Creators); err != nil {
+			return err
+		}
+	}
+	if update.Series != nil {
+		if err := e.SetSeries(update.Series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSeries 写入系列信息，替换已有的 calibre:series、calibre:series_index
+// EPUB3 同时写入 belongs-to-collection 及其 collection-type、group-position；Name 为空时只删除
+func (e *opfEditor) SetSeries(series *SeriesInfo) error {
+	s, err := e.scan()
+	if err != nil {
+		return err
+	}
+
+	var old []opfElement
+	for _, el := range s.elements {
+		if el.Local != "meta" {
+			continue
+		}
+		switch {
+		case el.Attribute("name") == "calibre:series", el.Attribute("name") == "calibre:series_index":
+			old = append(old, el)
+		case el.Attribute("property") == "belongs-to-collection":
+			old = append(old, el)
+			if id := el.Attribute("id"); id != "" {
+				old = append(old, s.refines(id)...)
+			}
+		}
+	}
+
+	nameMeta := func(name, content string) string {
+		return renderElement(&opfElement{Local: "meta", Attr: []xml.Attr{
+			{Name: xml.Name{Local: "name"}, Value: name},
+			{Name: xml.Name{Local: "content"}, Value: content},
+		}})
+	}
+	refineMeta := func(id, property, text string) string {
+		return renderElement(&opfElement{Local: "meta", Text: text, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "refines"}, Value: "#" + id},
+			{Name: xml.Name{Local: "property"}, Value: property},
+		}})
+	}
+
+	if series.Name == "" {
+		e.replaceElements(s, old, nil)
+		return nil
+	}
+
+	rendered := []string{nameMeta("calibre:series", series.Name)}
+	if series.Index != 0 {
+		rendered = append(rendered, nameMeta("calibre:series_index", formatSeriesIndex(series.Index)))
+	}
+	if s.epub3() {
+		id := newIDAllocator(e.data).next("collection")
+		rendered = append(rendered, renderElement(&opfElement{Local: "meta", Text: series.Name, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "property"}, Value: "belongs-to-collection"},
+			{Name: xml.Name{Local: "id"}, Value: id},
+		}}))
+		rendered = append(rendered, refineMeta(id, "collection-type", "series"))
+		if series.Index != 0 {
+			rendered = append(rendered, refineMeta(id, "group-position", formatSeriesIndex(series.Index)))
+		}
+	}
+	e.replaceElements(s, old, rendered)
+	return nil
+}
+
+// SetSubjects 用 subjects 替换所有 dc:subject
+func (e *opfEditor) SetSubjects(subjects []string) error {
+	s, err := e.scan()
+	if err != nil {
+		return err
+	}
+
+	var old []opfElement
+	for _, el := range s.elements {
+		if el.Local == "subject" {
+			old = append(old, el)
+		}
+	}
+	rendered := make([]string, len(subjects))
+	for i, subject := range subjects {
+		rendered[i] = renderElement(&opfElement{Prefix: s.dcPrefix, Local: "subject", Text: subject})
+	}
+	e.replaceElements(s, old, rendered)
+	return nil
+}
+
+// SetCreators 用 creators 替换所有 dc:creator
+// EPUB2 使用 opf:role 和 opf:file-as 属性；EPUB3 使用 meta refines，原有的角色 meta 会被替换
+func (e *opfEditor) SetCreators(creators []Creator) error {
+	s, err := e.scan()
+	if err != nil {
+		return err
+	}
+	epub3 := s.epub3()
+
+	// EPUB2 需要声明 OPF 命名空间前缀，声明后偏移变化，需要重新扫描
+	if !epub3 && s.opfPrefix == "" && needsOpfAttrs(creators) {
+		e.declareOpfPrefix(s)
+		if s, err = e.scan(); err != nil {
+			return err
+		}
+	}
+
+	var old []opfElement
+	for _, el := range s.elements {
+		if el.Local != "creator" {
+			continue
+		}
+		old = append(old, el)
+		if id := el.Attribute("id"); epub3 && id != "" {
+			for _, meta := range s.refines(id) {
+				if p := meta.Attribute("property"); p == "role" || p == "file-as" {
+					old = append(old, meta)
+				}
+			}
+		}
+	}
+
+	ids := newIDAllocator(e.data)
+	var rendered []string
+	for _, c := range creators {
+		if epub3 {
+			rendered = append(rendered, renderCreatorEpub3(s, c, ids)...)
+		} else {
+			rendered = append(rendered, renderCreatorEpub2(s, c))
+		}
+	}
+	e.replaceElements(s, old, rendered)
+	return nil
+}
+
+// needsOpfAttrs 判断是否需要写出 opf: 前缀的属性
+func needsOpfAttrs(creators []Creator) bool {
+	for _, c := range creators {
+		if c.Role != "" || c.FileAs != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCreatorEpub2 渲染 EPUB2 的 dc:creator，角色和排序名写入 opf: 属性
+func renderCreatorEpub2(s *metadataScan, c Creator) string {
+	el := &opfElement{Prefix: s.dcPrefix, Local: "creator", Text: c.Name}
+	for _, a := range c.attrs {
+		if a.Name.Local != "role" && a.Name.Local != "file-as" {
+			el.Attr = append(el.Attr, a)
+		}
+	}
+	if c.Role != "" {
+		el.Attr = append(el.Attr, xml.Attr{Name: xml.Name{Space: s.opfPrefix, Local: "role"}, Value: c.Role})
+	}
+	if c.FileAs != "" {
+		el.Attr = append(el.Attr, xml.Attr{Name: xml.Name{Space: s.opfPrefix, Local: "file-as"}, Value: c.FileAs})
+	}
+	return renderElement(el)
+}
+
+// renderCreatorEpub3 渲染 EPUB3 的 dc:creator 及其角色、排序名 meta
+func renderCreatorEpub3(s *metadataScan, c Creator, ids *idAllocator) []string {
+	el := &opfElement{Prefix: s.dcPrefix, Local: "creator", Text: c.Name}
+	id := c.id
+	for _, a := range c.attrs {
+		// EPUB3 中 opf:role 已废弃，统一改为 meta refines
+		if a.Name.Local == "role" || a.Name.Local == "file-as" {
+			continue
+		}
+		el.Attr = append(el.Attr, a)
+	}
+	if id == "" && (c.Role != "" || c.FileAs != "") {
+		id = ids.next("creator")
+		el.Attr = append(el.Attr, xml.Attr{Name: xml.Name{Local: "id"}, Value: id})
+	}
+
+	rendered := []string{renderElement(el)}
+	if c.Role != "" {
+		rendered = append(rendered, renderElement(&opfElement{
+			Local: "meta",
+			Attr: []xml.Attr{
+				{Name: xml.Name{Local: "refines"}, Value: "#" + id},
+				{Name: xml.Name{Local: "property"}, Value: "role"},
+				{Name: xml.Name{Local: "scheme"}, Value: "marc:relators"},
+			},
+			Text: c.Role,
+		}))
+	}
+	if c.FileAs != "" {
+		rendered = append(rendered, renderElement(&opfElement{
+			Local: "meta",
+			Attr: []xml.Attr{
+				{Name: xml.Name{Local: "refines"}, Value: "#" + id},
+				{Name: xml.Name{Local: "property"}, Value: "file-as"},
+			},
+			Text: c.FileAs,
+		}))
+	}
+	return rendered
+}
+
+// idAllocator 生成 OPF 中未使用的 id
+type idAllocator struct {
+	data []byte
+	used map[string]bool
+}
+
+func newIDAllocator(data []byte) *idAllocator {
+	return &idAllocator{data: data, used: make(map[string]bool)}
+}
+
+// next 返回形如 prefix1、prefix2 的未使用 id
+func (a *idAllocator) next(prefix string) string {
+	for i := 1; ; i++ {
+		id := fmt.Sprintf("%s%d", prefix, i)
+		if a.used[id] || strings.Contains(string(a.data), `id="`+id+`"`) {
+			continue
+		}
+		a.used[id] = true
+		return id
+	}
+}
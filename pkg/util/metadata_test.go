@@ -0,0 +1,170 @@
+package util
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOpf3 = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="BookId">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>三体</dc:title>
+    <dc:creator id="c1">[美] 某某 著 张三 译</dc:creator>
+    <meta refines="#c1" property="role" scheme="marc:relators">aut</meta>
+    <meta refines="#c1" property="display-seq">1</meta>
+    <dc:subject>小说;科幻</dc:subject>
+    <dc:identifier id="BookId">urn:uuid:12345</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="chapter1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="chapter1"/>
+  </spine>
+</package>`
+
+// readTestOpf 读取测试 EPUB 中的 OPF 内容
+func readTestOpf(t *testing.T, path string) string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("无法打开 EPUB: %v", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == "OEBPS/content.opf" {
+			rc, _ := f.Open()
+			defer rc.Close()
+			data, _ := io.ReadAll(rc)
+			return string(data)
+		}
+	}
+	t.Fatal("未找到 OPF")
+	return ""
+}
+
+func TestUpdateEpubMetadata_Epub2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = strings.Replace(entries[2][1], `<dc:creator opf:role="aut">测试作者</dc:creator>`,
+		`<dc:creator>[美] 某某 著 张三 译</dc:creator>
+    <dc:publisher>出版社：译林出版社</dc:publisher>
+    <dc:subject>小说;科幻</dc:subject>
+    <dc:subject>科幻</dc:subject>`, 1)
+	writeTestEpub(t, path, entries)
+
+	meta, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	if len(meta.Creators) != 1 || meta.Publisher != "出版社：译林出版社" || len(meta.Subjects) != 2 {
+		t.Fatalf("读取的元数据不正确: %+v", meta)
+	}
+
+	publisher := NormalizePublisher(meta.Publisher)
+	err = UpdateEpubMetadata(path, &MetadataUpdate{
+		Creators:  NormalizeCreators(meta.Creators),
+		Publisher: &publisher,
+		Subjects:  NormalizeSubjects(meta.Subjects),
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	opf := readTestOpf(t, path)
+	for _, want := range []string{
+		`<dc:creator opf:role="aut">某某</dc:creator>`,
+		`<dc:creator opf:role="trl">张三</dc:creator>`,
+		`<dc:publisher>译林出版社</dc:publisher>`,
+		"<dc:subject>小说</dc:subject>\n    <dc:subject>科幻</dc:subject>\n",
+		`<dc:title>三体</dc:title>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("OPF 中缺少 %q:\n%s", want, opf)
+		}
+	}
+	if strings.Count(opf, "<dc:subject>") != 2 {
+		t.Errorf("dc:subject 数量不正确:\n%s", opf)
+	}
+
+	// 再次读取，结果与写入一致
+	meta, err = ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	if FormatCreators(meta.Creators) != "某某 (aut); 张三 (trl)" {
+		t.Errorf("写入后的责任者 = %s", FormatCreators(meta.Creators))
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("写入后期望检测通过，得到 %v", err)
+	}
+}
+
+func TestUpdateEpubMetadata_Epub2DeclaresOpfPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = strings.Replace(entries[2][1], ` xmlns:opf="http://www.idpf.org/2007/opf"`, "", 1)
+	entries[2][1] = strings.Replace(entries[2][1], `<dc:creator opf:role="aut">测试作者</dc:creator>`,
+		`<dc:creator>阿耐 著</dc:creator>`, 1)
+	writeTestEpub(t, path, entries)
+
+	meta, _ := ReadEpubMetadata(path)
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Creators: NormalizeCreators(meta.Creators)}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	opf := readTestOpf(t, path)
+	if !strings.Contains(opf, `xmlns:opf="http://www.idpf.org/2007/opf"`) {
+		t.Errorf("未声明 opf 命名空间:\n%s", opf)
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("写入后期望检测通过，得到 %v", err)
+	}
+}
+
+func TestUpdateEpubMetadata_Epub3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = testOpf3
+	writeTestEpub(t, path, entries)
+
+	meta, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	if meta.Creators[0].Role != "aut" {
+		t.Fatalf("EPUB3 角色应从 meta refines 读取，得到 %q", meta.Creators[0].Role)
+	}
+
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Creators: NormalizeCreators(meta.Creators)}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	opf := readTestOpf(t, path)
+	for _, want := range []string{
+		`<dc:creator id="c1">某某</dc:creator>`,
+		`<meta refines="#c1" property="role" scheme="marc:relators">aut</meta>`,
+		`<dc:creator id="creator1">张三</dc:creator>`,
+		`<meta refines="#creator1" property="role" scheme="marc:relators">trl</meta>`,
+		// 与角色无关的 meta 保留
+		`<meta refines="#c1" property="display-seq">1</meta>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("OPF 中缺少 %q:\n%s", want, opf)
+		}
+	}
+	if strings.Count(opf, `property="role"`) != 2 {
+		t.Errorf("角色 meta 数量不正确:\n%s", opf)
+	}
+	if strings.Contains(opf, "opf:role") {
+		t.Errorf("EPUB3 不应写入 opf:role:\n%s", opf)
+	}
+
+	meta, _ = ReadEpubMetadata(path)
+	if FormatCreators(meta.Creators) != "某某 (aut); 张三 (trl)" {
+		t.Errorf("写入后的责任者 = %s", FormatCreators(meta.Creators))
+	}
+}
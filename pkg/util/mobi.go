@@ -0,0 +1,485 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PalmDB 和 MOBI 格式常量
+// 参考 https://wiki.mobileread.com/wiki/PDB 和 https://wiki.mobileread.com/wiki/MOBI
+const (
+	palmDBHeaderSize  = 78
+	palmDBRecordEntry = 8
+	palmDocHeaderSize = 16
+
+	mobiCompressionNone    = 1
+	mobiCompressionPalmDoc = 2
+	mobiCompressionHuff    = 17480
+
+	mobiEncodingCP1252 = 1252
+	mobiEncodingUTF8   = 65001
+
+	// mobiExthFlag MOBI 头部 EXTH 标志中表示存在 EXTH 的位
+	mobiExthFlag = 0x40
+)
+
+// EXTH 记录类型
+const (
+	exthAuthor       = 100
+	exthPublisher    = 101
+	exthISBN         = 104
+	exthSubject      = 105
+	exthASIN         = 113
+	exthUpdatedTitle = 503
+)
+
+// MobiRecord PalmDB 中的一条记录
+type MobiRecord struct {
+	Offset int64
+	Size   int64
+}
+
+// ExthRecord EXTH 头部中的一条元数据
+type ExthRecord struct {
+	Type uint32
+	Data []byte
+}
+
+// MobiBook 解析后的 PalmDB/MOBI 文件（.mobi、.azw、.azw3）
+// 只读取头部和记录表，正文记录在需要时通过 Record 读取
+type MobiBook struct {
+	Name    string // PalmDB 名称
+	Type    string // PalmDB 类型和创建者，如 "BOOKMOBI"、"TEXtREAd"
+	Records []MobiRecord
+
+	Compression uint16 // 1 不压缩，2 PalmDOC，17480 HUFF/CDIC
+	TextLength  uint32 // 解压后的正文长度
+	TextRecords uint16 // 正文记录数，从第 1 条记录开始
+	RecordSize  uint16 // 每条正文记录解压后的最大长度，通常为 4096
+	Encryption  uint16 // 0 表示未加密
+
+	HasMobiHeader bool
+	MobiType      uint32
+	Encoding      uint32 // 1252 或 65001
+	Version       uint32 // 文件版本，KF8（AZW3）为 8
+	FullName      string
+	ExtraFlags    uint16 // 正文记录末尾附加数据的标志
+	Exth          []ExthRecord
+
+	r io.ReaderAt
+}
+
+// OpenMobi 打开并解析 MOBI 文件，调用方需关闭返回的文件
+func OpenMobi(filePath string) (*MobiBook, *os.File, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法打开文件", Detail: err.Error()}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法打开文件", Detail: err.Error()}
+	}
+	book, err := ParseMobi(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return book, f, nil
+}
+
+// ParseMobi 解析 PalmDB 头部、记录表、PalmDOC 头部、MOBI 头部和 EXTH
+// 记录超出文件末尾（文件被截断）、头部越界等问题返回 EpubError
+func ParseMobi(r io.ReaderAt, size int64) (*MobiBook, error) {
+	header := make([]byte, palmDBHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "PalmDB 头部不完整", Detail: fmt.Sprintf("文件只有 %d 字节", size)}
+	}
+
+	book := &MobiBook{
+		Name: strings.TrimRight(string(bytes.TrimRight(header[:32], "\x00")), " "),
+		Type: string(header[60:68]),
+		r:    r,
+	}
+	if book.Type != "BOOKMOBI" && book.Type != "TEXtREAd" {
+		return nil, &EpubError{Type: ErrorTypeFormat, Message: "不是 MOBI 文件", Detail: fmt.Sprintf("PalmDB 类型为 %q", book.Type)}
+	}
+
+	count := int(binary.BigEndian.Uint16(header[76:]))
+	if count == 0 {
+		return nil, &EpubError{Type: ErrorTypeFormat, Message: "PalmDB 中没有记录"}
+	}
+	table := make([]byte, count*palmDBRecordEntry)
+	if _, err := r.ReadAt(table, palmDBHeaderSize); err != nil {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "文件被截断", Detail: "记录表不完整"}
+	}
+	tableEnd := int64(palmDBHeaderSize + len(table))
+	for i := 0; i < count; i++ {
+		offset := int64(binary.BigEndian.Uint32(table[i*palmDBRecordEntry:]))
+		if offset >= size {
+			return nil, &EpubError{
+				Type:    ErrorTypeCorrupted,
+				Message: "文件被截断",
+				Detail:  fmt.Sprintf("记录 %d/%d 的偏移 %d 超出文件末尾（%d 字节）", i, count, offset, size),
+			}
+		}
+		if offset < tableEnd || (i > 0 && offset < book.Records[i-1].Offset) {
+			return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "记录表损坏", Detail: fmt.Sprintf("记录 %d 的偏移 %d 无效", i, offset)}
+		}
+		book.Records = append(book.Records, MobiRecord{Offset: offset})
+	}
+	for i := range book.Records {
+		end := size
+		if i+1 < count {
+			end = book.Records[i+1].Offset
+		}
+		book.Records[i].Size = end - book.Records[i].Offset
+	}
+
+	rec0, err := book.Record(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := book.parseRecord0(rec0); err != nil {
+		return nil, err
+	}
+	if int(book.TextRecords) >= count {
+		return nil, &EpubError{
+			Type:    ErrorTypeCorrupted,
+			Message: "文件被截断",
+			Detail:  fmt.Sprintf("头部声明 %d 条正文记录，文件中只有 %d 条记录", book.TextRecords, count-1),
+		}
+	}
+	return book, nil
+}
+
+// parseRecord0 解析第 0 条记录中的 PalmDOC 头部、MOBI 头部、EXTH 和完整书名
+func (m *MobiBook) parseRecord0(rec []byte) error {
+	if len(rec) < palmDocHeaderSize {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "PalmDOC 头部不完整"}
+	}
+	be := binary.BigEndian
+	m.Compression = be.Uint16(rec[0:])
+	m.TextLength = be.Uint32(rec[4:])
+	m.TextRecords = be.Uint16(rec[8:])
+	m.RecordSize = be.Uint16(rec[10:])
+	m.Encryption = be.Uint16(rec[12:])
+	switch m.Compression {
+	case mobiCompressionNone, mobiCompressionPalmDoc, mobiCompressionHuff:
+	default:
+		return &EpubError{Type: ErrorTypeFormat, Message: "未知的压缩方式", Detail: fmt.Sprintf("%d", m.Compression)}
+	}
+
+	if len(rec) < palmDocHeaderSize+8 || string(rec[16:20]) != "MOBI" {
+		if m.Type == "BOOKMOBI" {
+			return &EpubError{Type: ErrorTypeFormat, Message: "缺少 MOBI 头部"}
+		}
+		m.Encoding = mobiEncodingCP1252
+		return nil
+	}
+	m.HasMobiHeader = true
+
+	headerLen := int(be.Uint32(rec[20:]))
+	mobiEnd := palmDocHeaderSize + headerLen
+	if headerLen < 0x74 || mobiEnd > len(rec) {
+		return &EpubError{Type: ErrorTypeFormat, Message: "MOBI 头部损坏", Detail: fmt.Sprintf("头部长度 %d 超出记录范围", headerLen)}
+	}
+	m.MobiType = be.Uint32(rec[24:])
+	m.Encoding = be.Uint32(rec[28:])
+	m.Version = be.Uint32(rec[36:])
+	if m.Encoding != mobiEncodingCP1252 && m.Encoding != mobiEncodingUTF8 {
+		return &EpubError{Type: ErrorTypeFormat, Message: "不支持的文本编码", Detail: fmt.Sprintf("%d", m.Encoding)}
+	}
+	if headerLen >= 0xE4 {
+		m.ExtraFlags = be.Uint16(rec[0xF2:])
+	}
+
+	nameOffset, nameLen := int(be.Uint32(rec[0x54:])), int(be.Uint32(rec[0x58:]))
+	if nameLen > 0 {
+		if nameOffset < 0 || nameOffset+nameLen > len(rec) {
+			return &EpubError{Type: ErrorTypeFormat, Message: "MOBI 头部损坏", Detail: "书名超出记录范围"}
+		}
+		m.FullName = m.decode(rec[nameOffset : nameOffset+nameLen])
+	}
+
+	if be.Uint32(rec[0x80:])&mobiExthFlag != 0 {
+		exth, err := parseExth(rec[mobiEnd:])
+		if err != nil {
+			return err
+		}
+		m.Exth = exth
+	}
+	return nil
+}
+
+// parseExth 解析 EXTH 头部
+func parseExth(data []byte) ([]ExthRecord, error) {
+	corrupt := func(detail string) error {
+		return &EpubError{Type: ErrorTypeFormat, Message: "EXTH 头部损坏", Detail: detail}
+	}
+	if len(data) < 12 || string(data[:4]) != "EXTH" {
+		return nil, corrupt("缺少 EXTH 标识")
+	}
+	be := binary.BigEndian
+	length, count := int(be.Uint32(data[4:])), int(be.Uint32(data[8:]))
+	if length < 12 || length > len(data) {
+		return nil, corrupt(fmt.Sprintf("长度 %d 超出记录范围", length))
+	}
+
+	var records []ExthRecord
+	pos := 12
+	for i := 0; i < count; i++ {
+		if pos+8 > length {
+			return nil, corrupt(fmt.Sprintf("第 %d 条记录不完整", i))
+		}
+		typ, size := be.Uint32(data[pos:]), int(be.Uint32(data[pos+4:]))
+		if size < 8 || pos+size > length {
+			return nil, corrupt(fmt.Sprintf("第 %d 条记录长度 %d 无效", i, size))
+		}
+		records = append(records, ExthRecord{Type: typ, Data: data[pos+8 : pos+size]})
+		pos += size
+	}
+	return records, nil
+}
+
+// Record 读取第 i 条记录的原始数据
+func (m *MobiBook) Record(i int) ([]byte, error) {
+	if i < 0 || i >= len(m.Records) {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "记录不存在", Detail: fmt.Sprintf("%d", i)}
+	}
+	rec := m.Records[i]
+	data := make([]byte, rec.Size)
+	if _, err := m.r.ReadAt(data, rec.Offset); err != nil {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法读取记录", Detail: fmt.Sprintf("记录 %d: %v", i, err)}
+	}
+	return data, nil
+}
+
+// Metadata 返回书名、作者、出版社、主题和 ISBN
+// 书名依次取 EXTH 503、MOBI 头部中的完整书名和 PalmDB 名称
+func (m *MobiBook) Metadata() *EpubMetadata {
+	meta := &EpubMetadata{}
+	for _, rec := range m.Exth {
+		value := strings.TrimSpace(m.decode(rec.Data))
+		if value == "" {
+			continue
+		}
+		switch rec.Type {
+		case exthUpdatedTitle:
+			if meta.Title == "" {
+				meta.Title = value
+			}
+		case exthAuthor:
+			meta.Creators = append(meta.Creators, Creator{Name: value, Role: "aut"})
+		case exthPublisher:
+			if meta.Publisher == "" {
+				meta.Publisher = value
+			}
+		case exthSubject:
+			meta.Subjects = append(meta.Subjects, value)
+		case exthISBN:
+			meta.Identifiers = append(meta.Identifiers, value)
+			if meta.ISBN == "" {
+				meta.ISBN = parseISBN(value, "isbn")
+			}
+		case exthASIN:
+			meta.Identifiers = append(meta.Identifiers, "asin:"+value)
+		}
+	}
+	if meta.Title == "" {
+		meta.Title = m.FullName
+	}
+	if meta.Title == "" {
+		meta.Title = m.Name
+	}
+	return meta
+}
+
+// hasExth 判断是否有非空的指定类型 EXTH 记录
+func (m *MobiBook) hasExth(typ uint32) bool {
+	for _, rec := range m.Exth {
+		if rec.Type == typ && strings.TrimSpace(m.decode(rec.Data)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// decode 按 MOBI 头部声明的编码解码文本
+func (m *MobiBook) decode(b []byte) string {
+	b = bytes.TrimRight(b, "\x00")
+	if m.Encoding == mobiEncodingUTF8 && utf8.Valid(b) {
+		return string(b)
+	}
+	return decodeCP1252(b)
+}
+
+// cp1252High Windows-1252 中 0x80-0x9F 对应的字符，其余字节与 Latin-1 相同
+var cp1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeCP1252 将 Windows-1252 编码的文本转换为 UTF-8
+func decodeCP1252(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if c >= 0x80 && c < 0xA0 {
+			sb.WriteRune(cp1252High[c-0x80])
+		} else {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// ReadMobiMetadata 读取 MOBI/AZW3 文件的元数据
+func ReadMobiMetadata(filePath string) (*EpubMetadata, error) {
+	book, f, err := OpenMobi(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 MOBI 元数据: %w", err)
+	}
+	defer f.Close()
+	return book.Metadata(), nil
+}
+
+// MobiValidator MOBI/AZW3 检测器
+// 默认检查头部结构、记录表（记录超出文件末尾即文件被截断）和书名；
+// Deep 为 true 时解压所有正文记录，检查解压后的长度是否与头部一致
+type MobiValidator struct {
+	Deep bool
+}
+
+// Profile 返回检测配置的标识
+func (v *MobiValidator) Profile() string {
+	if v.Deep {
+		return "mobi-deep"
+	}
+	return "mobi"
+}
+
+// Validate 检测 MOBI 文件，返回 nil 表示文件正常，返回 EpubError 表示检测到问题
+func (v *MobiValidator) Validate(filePath string) error {
+	book, f, err := OpenMobi(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// PalmDB 名称只有 31 字节且常被截断，有 MOBI 头部时不作为书名
+	title := book.Metadata().Title
+	if book.HasMobiHeader && book.FullName == "" && !book.hasExth(exthUpdatedTitle) {
+		title = ""
+	}
+	if strings.TrimSpace(title) == "" {
+		return &EpubError{Type: ErrorTypeMetadata, Message: "缺少书籍标题", Detail: "MOBI 头部和 EXTH 中未找到标题信息"}
+	}
+	if v.Deep {
+		return book.checkText()
+	}
+	return nil
+}
+
+// checkText 解压所有正文记录，检查记录是否完整
+// 加密（DRM）和 HUFF/CDIC 压缩的正文无法解压，跳过
+func (m *MobiBook) checkText() error {
+	if m.Encryption != 0 || m.Compression == mobiCompressionHuff {
+		return nil
+	}
+	var total int64
+	for i := 1; i <= int(m.TextRecords); i++ {
+		data, err := m.Record(i)
+		if err != nil {
+			return err
+		}
+		data, err = m.stripTrailingEntries(data)
+		if err != nil {
+			return &EpubError{Type: ErrorTypeCorrupted, Message: "正文记录损坏", Detail: fmt.Sprintf("记录 %d: %v", i, err)}
+		}
+		if m.Compression == mobiCompressionPalmDoc {
+			if data, err = palmDocDecompress(data); err != nil {
+				return &EpubError{Type: ErrorTypeCorrupted, Message: "正文记录损坏", Detail: fmt.Sprintf("记录 %d: %v", i, err)}
+			}
+		}
+		total += int64(len(data))
+	}
+	if total < int64(m.TextLength) {
+		return &EpubError{
+			Type:    ErrorTypeCorrupted,
+			Message: "正文不完整",
+			Detail:  fmt.Sprintf("解压后 %d 字节，头部声明 %d 字节", total, m.TextLength),
+		}
+	}
+	return nil
+}
+
+// stripTrailingEntries 去除正文记录末尾由 ExtraFlags 声明的附加数据
+func (m *MobiBook) stripTrailingEntries(data []byte) ([]byte, error) {
+	size := len(data)
+	for flags := m.ExtraFlags >> 1; flags != 0; flags >>= 1 {
+		if flags&1 == 0 {
+			continue
+		}
+		// 附加数据的长度以反向变长整数存放在末尾，包含自身
+		n, shift := 0, 0
+		for i := size - 1; i >= 0 && i >= size-4; i-- {
+			n |= int(data[i]&0x7F) << shift
+			shift += 7
+			if data[i]&0x80 != 0 {
+				break
+			}
+		}
+		if n <= 0 || n > size {
+			return nil, fmt.Errorf("附加数据长度 %d 无效", n)
+		}
+		size -= n
+	}
+	if m.ExtraFlags&1 != 0 && size > 0 {
+		n := int(data[size-1]&0x3) + 1
+		if n > size {
+			return nil, fmt.Errorf("多字节附加数据长度 %d 无效", n)
+		}
+		size -= n
+	}
+	return data[:size], nil
+}
+
+// palmDocDecompress 解压 PalmDOC（LZ77 变体）压缩的数据
+func palmDocDecompress(data []byte) ([]byte, error) {
+	out := make([]byte, 0, 4096)
+	for i := 0; i < len(data); {
+		c := data[i]
+		i++
+		switch {
+		case c >= 1 && c <= 8:
+			if i+int(c) > len(data) {
+				return nil, fmt.Errorf("字面量超出数据末尾")
+			}
+			out = append(out, data[i:i+int(c)]...)
+			i += int(c)
+		case c < 0x80:
+			out = append(out, c)
+		case c >= 0xC0:
+			out = append(out, ' ', c^0x80)
+		default:
+			if i >= len(data) {
+				return nil, fmt.Errorf("回溯引用不完整")
+			}
+			pair := int(c)<<8 | int(data[i])
+			i++
+			dist, n := (pair>>3)&0x7FF, pair&7+3
+			if dist == 0 || dist > len(out) {
+				return nil, fmt.Errorf("回溯距离 %d 无效", dist)
+			}
+			for j := 0; j < n; j++ {
+				out = append(out, out[len(out)-dist])
+			}
+		}
+	}
+	return out, nil
+}
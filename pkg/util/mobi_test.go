@@ -0,0 +1,271 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testMobi 构造测试 MOBI 文件的参数
+type testMobi struct {
+	title   string   // EXTH 503
+	name    string   // MOBI 头部中的完整书名
+	authors []string // EXTH 100
+	isbn    string   // EXTH 104
+	text    []byte   // 正文，不压缩，放在一条记录中
+	// textLength 头部声明的正文长度，为 0 时使用 len(text)
+	textLength int
+}
+
+// build 生成 PalmDB 头部、记录 0（PalmDOC + MOBI + EXTH + 书名）、一条正文记录和结束记录
+func (b testMobi) build() []byte {
+	be := binary.BigEndian
+
+	var exth bytes.Buffer
+	var exthRecs [][]byte
+	addExth := func(typ uint32, value string) {
+		rec := make([]byte, 8+len(value))
+		be.PutUint32(rec, typ)
+		be.PutUint32(rec[4:], uint32(len(rec)))
+		copy(rec[8:], value)
+		exthRecs = append(exthRecs, rec)
+	}
+	for _, a := range b.authors {
+		addExth(exthAuthor, a)
+	}
+	if b.isbn != "" {
+		addExth(exthISBN, b.isbn)
+	}
+	if b.title != "" {
+		addExth(exthUpdatedTitle, b.title)
+	}
+	exthLen := 12
+	for _, r := range exthRecs {
+		exthLen += len(r)
+	}
+	exth.WriteString("EXTH")
+	binary.Write(&exth, be, uint32(exthLen))
+	binary.Write(&exth, be, uint32(len(exthRecs)))
+	for _, r := range exthRecs {
+		exth.Write(r)
+	}
+
+	const mobiHeaderLen = 0xE8
+	rec0 := make([]byte, palmDocHeaderSize+mobiHeaderLen)
+	textLength := b.textLength
+	if textLength == 0 {
+		textLength = len(b.text)
+	}
+	be.PutUint16(rec0[0:], mobiCompressionNone)
+	be.PutUint32(rec0[4:], uint32(textLength))
+	be.PutUint16(rec0[8:], 1)
+	be.PutUint16(rec0[10:], 4096)
+	copy(rec0[16:], "MOBI")
+	be.PutUint32(rec0[20:], mobiHeaderLen)
+	be.PutUint32(rec0[24:], 2)
+	be.PutUint32(rec0[28:], mobiEncodingUTF8)
+	be.PutUint32(rec0[36:], 6)
+	be.PutUint32(rec0[0x80:], mobiExthFlag)
+	nameOffset := len(rec0) + exth.Len()
+	be.PutUint32(rec0[0x54:], uint32(nameOffset))
+	be.PutUint32(rec0[0x58:], uint32(len(b.name)))
+	rec0 = append(rec0, exth.Bytes()...)
+	rec0 = append(rec0, b.name...)
+	rec0 = append(rec0, 0, 0)
+
+	records := [][]byte{rec0, b.text, {0xE9, 0x8E, 0x0D, 0x0A}}
+	header := make([]byte, palmDBHeaderSize)
+	copy(header, "test_book")
+	copy(header[60:], "BOOKMOBI")
+	be.PutUint16(header[76:], uint16(len(records)))
+
+	var out bytes.Buffer
+	out.Write(header)
+	offset := palmDBHeaderSize + len(records)*palmDBRecordEntry + 2
+	for i, r := range records {
+		entry := make([]byte, palmDBRecordEntry)
+		be.PutUint32(entry, uint32(offset))
+		be.PutUint32(entry[4:], uint32(i*2))
+		out.Write(entry)
+		offset += len(r)
+	}
+	out.Write([]byte{0, 0})
+	for _, r := range records {
+		out.Write(r)
+	}
+	return out.Bytes()
+}
+
+func writeTestMobi(t *testing.T, path string, b testMobi) {
+	t.Helper()
+	if err := os.WriteFile(path, b.build(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadMobiMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.azw3")
+	writeTestMobi(t, path, testMobi{
+		title:   "三体（典藏版）",
+		name:    "三体",
+		authors: []string{"刘慈欣"},
+		isbn:    "978-7-5366-9293-0",
+		text:    []byte("正文"),
+	})
+
+	meta, err := ReadMobiMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMobiMetadata 失败: %v", err)
+	}
+	if meta.Title != "三体（典藏版）" {
+		t.Errorf("书名 = %q，应优先使用 EXTH 503", meta.Title)
+	}
+	if len(meta.Creators) != 1 || meta.Creators[0].Name != "刘慈欣" || meta.Creators[0].Role != "aut" {
+		t.Errorf("作者 = %+v", meta.Creators)
+	}
+	if meta.ISBN != "9787536692930" {
+		t.Errorf("ISBN = %q", meta.ISBN)
+	}
+
+	// 没有 EXTH 503 时使用完整书名
+	writeTestMobi(t, path, testMobi{name: "球状闪电", text: []byte("正文")})
+	if meta, err = ReadBookMetadata(path); err != nil || meta.Title != "球状闪电" {
+		t.Errorf("书名 = %+v, %v，期望完整书名", meta, err)
+	}
+}
+
+func TestMobiValidator(t *testing.T) {
+	dir := t.TempDir()
+	data := testMobi{title: "三体", authors: []string{"刘慈欣"}, text: []byte("Hello, world")}.build()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		deep     bool
+		wantType ErrorType
+		wantErr  bool
+	}{
+		{name: "正常文件", data: data},
+		{name: "正常文件深度检测", data: data, deep: true},
+		{name: "截断到最后一条记录之前", data: data[:len(data)-20], wantErr: true, wantType: ErrorTypeCorrupted},
+		{name: "只有 PalmDB 头部", data: data[:40], wantErr: true, wantType: ErrorTypeCorrupted},
+		{name: "不是 MOBI", data: append([]byte("PK\x03\x04"), make([]byte, 100)...), wantErr: true, wantType: ErrorTypeFormat},
+		{
+			name:     "缺少书名",
+			data:     testMobi{text: []byte("正文")}.build(),
+			wantErr:  true,
+			wantType: ErrorTypeMetadata,
+		},
+		{
+			name:     "正文比头部声明的短",
+			data:     testMobi{title: "三体", text: []byte("正文"), textLength: 4096}.build(),
+			deep:     true,
+			wantErr:  true,
+			wantType: ErrorTypeCorrupted,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "book"+string(rune('a'+i))+".mobi")
+			os.WriteFile(path, tt.data, 0644)
+
+			err := (&MobiValidator{Deep: tt.deep}).Validate(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v，期望出错 %v", err, tt.wantErr)
+			}
+			var epubErr *EpubError
+			if tt.wantErr && (!errors.As(err, &epubErr) || epubErr.Type != tt.wantType) {
+				t.Errorf("错误 = %v，期望类型 %v", err, tt.wantType)
+			}
+		})
+	}
+
+	// 浅检测不解压正文
+	path := filepath.Join(dir, "short.mobi")
+	os.WriteFile(path, testMobi{title: "三体", text: []byte("正文"), textLength: 4096}.build(), 0644)
+	if err := (&MobiValidator{}).Validate(path); err != nil {
+		t.Errorf("浅检测不应检查正文长度: %v", err)
+	}
+}
+
+func TestPalmDocDecompress(t *testing.T) {
+	// 字面量 "abc"、空格 + 'd'、回溯距离 3 长度 3、1 个原样字节
+	data := []byte{3, 'a', 'b', 'c', 'd' ^ 0x80, 0x80, 3<<3 | 0, 'x'}
+	got, err := palmDocDecompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abc dc dx" {
+		t.Errorf("解压结果 = %q", got)
+	}
+
+	if _, err := palmDocDecompress([]byte{0x80, 0xFF}); err == nil {
+		t.Error("回溯距离超出已解压数据时应返回错误")
+	}
+}
+
+func TestStripTrailingEntries(t *testing.T) {
+	m := &MobiBook{ExtraFlags: 0x3}
+	// 正文 "text"、多字节附加数据 1 字节（长度字节 0x00）、附加数据 2 字节（含长度 0x82）
+	data := []byte{'t', 'e', 'x', 't', 0x00, 0xAA, 0x82}
+	got, err := m.stripTrailingEntries(data)
+	if err != nil || string(got) != "text" {
+		t.Errorf("stripTrailingEntries = %q, %v", got, err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]*BookFormat{
+		"a.epub":    EpubFormat,
+		"a.EPUB":    EpubFormat,
+		"a.mobi":    MobiFormat,
+		"a.azw":     MobiFormat,
+		"a.AZW3":    MobiFormat,
+		"a.pdf":     PdfFormat,
+		"a.fb2":     Fb2Format,
+		"a.FB2.zip": Fb2Format,
+		"a.zip":     nil,
+		"a.djvu":    nil,
+		"epub":      nil,
+		"a.azw3x":   nil,
+	}
+	for path, want := range tests {
+		if got := FormatOf(path); got != want {
+			t.Errorf("FormatOf(%q) = %v，期望 %v", path, got, want)
+		}
+	}
+
+	formats, err := ParseBookFormats([]string{"EPUB", "azw3", "mobi"})
+	if err != nil || len(formats) != 2 || formats[0] != EpubFormat || formats[1] != MobiFormat {
+		t.Errorf("ParseBookFormats = %v, %v", formats, err)
+	}
+	if _, err := ParseBookFormats([]string{"djvu"}); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
+
+func TestBookValidator(t *testing.T) {
+	dir := t.TempDir()
+	epub := filepath.Join(dir, "book.epub")
+	writeTestEpub(t, epub, testEpubEntries("三体"))
+	mobi := filepath.Join(dir, "book.mobi")
+	writeTestMobi(t, mobi, testMobi{title: "三体", text: []byte("正文")})
+
+	v := NewBookValidator(ValidateOptions{})
+	for _, path := range []string{epub, mobi} {
+		if err := v.Validate(path); err != nil {
+			t.Errorf("Validate(%s) 失败: %v", path, err)
+		}
+	}
+	if v.Profile() != "epub[zip,required-files,metadata];mobi[mobi];pdf[pdf];fb2[fb2]" {
+		t.Errorf("Profile() = %q", v.Profile())
+	}
+
+	epubOnly := NewBookValidator(ValidateOptions{}, EpubFormat)
+	if err := epubOnly.Validate(mobi); GetErrorType(err) != ErrorTypeFormat {
+		t.Errorf("未启用的格式应返回 ErrorTypeFormat，得到 %v", err)
+	}
+}
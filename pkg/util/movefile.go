@@ -0,0 +1,136 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+// renameFile 即 os.Rename，测试中替换以模拟跨设备移动
+var renameFile = os.Rename
+
+// errNotSameDevice Windows 上跨卷移动时的错误码 ERROR_NOT_SAME_DEVICE
+const errNotSameDevice = syscall.Errno(17)
+
+// MoveFile 将 src 移动到 dst，调用方需确保 dst 不存在
+// 同一文件系统内直接重命名；跨文件系统（如移动到 NAS 挂载目录）时 os.Rename 返回 EXDEV，
+// 改为复制到目标目录下的临时文件、同步到磁盘、校验大小和 SHA-256 后重命名为 dst，
+// 保留权限和修改时间，最后删除源文件。校验失败时删除临时文件，源文件保持不变
+func MoveFile(src, dst string) error {
+	err := renameFile(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+	return copyMove(src, dst)
+}
+
+// isCrossDevice 判断重命名失败是否因为源和目标不在同一文件系统
+func isCrossDevice(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.EXDEV || (runtime.GOOS == "windows" && errno == errNotSameDevice)
+}
+
+// copyMove 通过复制、校验、删除完成跨文件系统移动
+func copyMove(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("无法打开源文件: %w", err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("跨文件系统只能移动普通文件: %s", src)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("无法创建临时文件: %w", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	srcHash := sha256.New()
+	written, err := io.Copy(tmp, io.TeeReader(in, srcHash))
+	if err != nil {
+		return fmt.Errorf("复制文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("同步文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	// 重新读取写入的内容，与复制时的源文件比较
+	if written != info.Size() {
+		return fmt.Errorf("校验失败: 源文件 %d 字节，复制了 %d 字节", info.Size(), written)
+	}
+	dstHash, err := hashFile(tmpPath, sha256.New())
+	if err != nil {
+		return fmt.Errorf("校验失败: %w", err)
+	}
+	if !bytes.Equal(srcHash.Sum(nil), dstHash) {
+		return fmt.Errorf("校验失败: 复制后的内容与源文件不一致")
+	}
+
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("无法设置权限: %w", err)
+	}
+	if err := os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("无法设置修改时间: %w", err)
+	}
+	if Exists(dst) {
+		return fmt.Errorf("目标文件已存在: %s", dst)
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return fmt.Errorf("移动文件失败: %w", err)
+	}
+	committed = true
+	syncDir(filepath.Dir(dst))
+
+	in.Close()
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("已复制到 %s，但无法删除源文件: %w", dst, err)
+	}
+	return nil
+}
+
+// hashFile 计算文件内容的摘要
+func hashFile(path string, h hash.Hash) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// syncDir 将目录项的修改同步到磁盘，不支持时忽略
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// simulateCrossDevice 让 MoveFile 中的重命名返回 EXDEV，模拟移动到其他文件系统
+func simulateCrossDevice(t *testing.T) {
+	t.Helper()
+	renameFile = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { renameFile = os.Rename })
+}
+
+func TestMoveFile_CrossDevice(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.epub")
+	content := bytes.Repeat([]byte("三体"), 100000)
+	if err := os.WriteFile(src, content, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 5, 1, 8, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	simulateCrossDevice(t)
+	dst := filepath.Join(dir, "nas", "dst.epub")
+	os.Mkdir(filepath.Dir(dst), 0755)
+	if err := MoveFile(src, dst); err != nil {
+		t.Fatalf("MoveFile 失败: %v", err)
+	}
+
+	if Exists(src) {
+		t.Error("源文件应被删除")
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("目标文件内容不一致 (%v)", err)
+	}
+	info, _ := os.Stat(dst)
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("修改时间期望 %v，得到 %v", mtime, info.ModTime())
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("权限期望 0640，得到 %o", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dst)); len(entries) != 1 {
+		t.Errorf("不应留下临时文件，得到 %d 个文件", len(entries))
+	}
+
+	// 跨设备移动到 MoveFileAs 的冲突后缀路径
+	os.WriteFile(src, []byte("x"), 0644)
+	got, err := MoveFileAs(src, filepath.Dir(dst), "dst.epub")
+	if err != nil || got != filepath.Join(filepath.Dir(dst), "dst(1).epub") || Exists(src) {
+		t.Errorf("MoveFileAs 期望移动到 dst(1).epub，得到 %s (%v)", got, err)
+	}
+}
+
+func TestMoveFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	// 非跨设备的错误直接返回
+	if err := MoveFile(filepath.Join(dir, "missing"), filepath.Join(dir, "dst")); err == nil {
+		t.Error("源文件不存在时期望返回错误")
+	}
+
+	// 复制完成后目标已被占用时不覆盖，源文件保留
+	simulateCrossDevice(t)
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	os.WriteFile(src, []byte("src"), 0644)
+	os.WriteFile(dst, []byte("dst"), 0644)
+	if err := MoveFile(src, dst); err == nil {
+		t.Error("目标已存在时期望返回错误")
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "dst" || !Exists(src) {
+		t.Error("失败时不应修改源文件和目标文件")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("不应留下临时文件，得到 %d 个文件", len(entries))
+	}
+}
+
+func TestAvailablePath(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "三体.epub"), []byte("x"), 0644)
+
+	reserved := make(map[string]bool)
+	for _, want := range []string{"三体(1).epub", "三体(2).epub"} {
+		got, err := AvailablePath(dir, "三体.epub", reserved)
+		if err != nil || got != filepath.Join(dir, want) {
+			t.Errorf("期望 %s，得到 %s (%v)", want, got, err)
+		}
+		reserved[got] = true
+	}
+	if got, _ := AvailablePath(dir, "球状闪电.epub", reserved); got != filepath.Join(dir, "球状闪电.epub") {
+		t.Errorf("没有冲突时期望原名，得到 %s", got)
+	}
+}
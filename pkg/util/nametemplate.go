@@ -0,0 +1,402 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 文件名模板字段
+const (
+	FieldTitle  = "title"  // 书名
+	FieldAuthor = "author" // 作者，多个用 "、" 连接
+	FieldSeries = "series" // 系列名
+	FieldIndex  = "index"  // 在系列中的序号
+	FieldYear   = "year"   // 出版年份
+	FieldISBN   = "isbn"   // ISBN，不含连字符
+	FieldExt    = "ext"    // 扩展名，不含点
+	FieldParent = "parent" // 所在目录名
+	FieldName   = "name"   // 原文件名，不含扩展名
+	FieldNumber = "n"      // 序号，@n 等同于 {n}
+)
+
+// metadataFields 需要读取书籍元数据的字段
+var metadataFields = map[string]bool{
+	FieldTitle:  true,
+	FieldAuthor: true,
+	FieldSeries: true,
+	FieldIndex:  true,
+	FieldYear:   true,
+	FieldISBN:   true,
+}
+
+// fileFields 由文件属性和序号得到的字段
+var fileFields = map[string]bool{
+	FieldExt:    true,
+	FieldParent: true,
+	FieldName:   true,
+	FieldNumber: true,
+}
+
+// errEmptyName 填充后的名称为空
+var errEmptyName = errors.New("生成的文件名为空")
+
+// maxNameBytes 文件名的最大字节数，大多数文件系统限制为 255
+const maxNameBytes = 255
+
+var (
+	// rePlaceholder {字段[:0宽度][|默认值]}
+	rePlaceholder = regexp.MustCompile(`\{([a-z]+)(?::0(\d+))?(?:\|([^{}]*))?\}`)
+	// reUnsafeName 文件名中不允许或不便使用的字符
+	reUnsafeName = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]`)
+	// reYear 日期中的年份
+	reYear = regexp.MustCompile(`\d{4}`)
+	// reISBN ISBN-10 或 ISBN-13，允许连字符和空格
+	reISBN = regexp.MustCompile(`(?:97[89][-\s]?)?(?:\d[-\s]?){9}[\dXx]`)
+)
+
+// NameTemplate 编译后的文件名模板，如 "{author|未知作者} - {title}"
+// 占位符格式为 {字段[:0宽度][|默认值]}：:0N 将数字补零到 N 位，字段为空时使用默认值。
+// 模板中没有 {ext} 时自动追加原扩展名
+type NameTemplate struct {
+	raw    string
+	parts  []templatePart
+	fields map[string]bool
+}
+
+// templatePart 模板片段，field 为空时为普通文本
+type templatePart struct {
+	literal     string
+	field       string
+	width       int
+	fallback    string
+	hasFallback bool
+}
+
+// ParseNameTemplate 解析文件名模板，模板中至少要有一个占位符
+func ParseNameTemplate(template string) (*NameTemplate, error) {
+	t, err := parseNameTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.fields) == 0 {
+		return nil, fmt.Errorf("模板 '%s' 中没有占位符", template)
+	}
+	return t, nil
+}
+
+// parseNameTemplate 解析模板，允许没有占位符
+func parseNameTemplate(template string) (*NameTemplate, error) {
+	t := &NameTemplate{raw: template, fields: make(map[string]bool)}
+	s := strings.ReplaceAll(template, "@n", "{n}")
+
+	last := 0
+	for _, m := range rePlaceholder.FindAllStringSubmatchIndex(s, -1) {
+		if err := t.addLiteral(s[last:m[0]]); err != nil {
+			return nil, err
+		}
+		last = m[1]
+
+		part := templatePart{field: s[m[2]:m[3]]}
+		if !metadataFields[part.field] && !fileFields[part.field] {
+			return nil, fmt.Errorf("模板中的字段 {%s} 无效", part.field)
+		}
+		if m[4] >= 0 {
+			part.width, _ = strconv.Atoi(s[m[4]:m[5]])
+		}
+		if m[6] >= 0 {
+			part.fallback = s[m[6]:m[7]]
+			part.hasFallback = true
+		}
+		t.parts = append(t.parts, part)
+		t.fields[part.field] = true
+	}
+	if err := t.addLiteral(s[last:]); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// addLiteral 添加普通文本，未匹配的花括号视为模板错误
+func (t *NameTemplate) addLiteral(s string) error {
+	if strings.ContainsAny(s, "{}") {
+		return fmt.Errorf("模板中的占位符 %q 无效", s)
+	}
+	if s != "" {
+		t.parts = append(t.parts, templatePart{literal: s})
+	}
+	return nil
+}
+
+// String 返回原始模板
+func (t *NameTemplate) String() string {
+	return t.raw
+}
+
+// HasField 判断模板是否使用了字段
+func (t *NameTemplate) HasField(field string) bool {
+	return t.fields[field]
+}
+
+// NeedsMetadata 判断模板是否需要读取书籍元数据
+func (t *NameTemplate) NeedsMetadata() bool {
+	for field := range t.fields {
+		if metadataFields[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute 用 values 填充模板，返回新文件名
+// 字段值中的路径分隔符等字符替换为 "_"；字段为空且没有默认值时返回错误
+func (t *NameTemplate) Execute(values map[string]string) (string, error) {
+	return t.execute(values, true)
+}
+
+// execute 填充模板，withExt 为 false 时不追加扩展名，用于目录名
+func (t *NameTemplate) execute(values map[string]string, withExt bool) (string, error) {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.field == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		v := sanitizeNamePart(values[p.field])
+		if v == "" {
+			if !p.hasFallback {
+				return "", fmt.Errorf("字段 {%s} 为空，可使用 {%s|默认值} 指定默认值", p.field, p.field)
+			}
+			v = p.fallback
+		} else if p.width > 0 {
+			v = padNumber(v, p.width)
+		}
+		b.WriteString(v)
+	}
+
+	name := strings.TrimSpace(b.String())
+	ext := ""
+	if withExt && !t.fields[FieldExt] && values[FieldExt] != "" {
+		ext = "." + values[FieldExt]
+	}
+	name = truncateName(name, maxNameBytes-len(ext))
+	if name == "" {
+		return "", errEmptyName
+	}
+	return name + ext, nil
+}
+
+// PathTemplate 带目录层级的路径模板，如 "{author}/{series|}/{title}"
+// 以 "/" 分隔，每一级都按 NameTemplate 填充；目录一级的结果为空时省略该级，最后一级为文件名
+type PathTemplate struct {
+	raw  string
+	dirs []*NameTemplate
+	file *NameTemplate
+}
+
+// ParsePathTemplate 解析路径模板，模板中至少要有一个占位符
+func ParsePathTemplate(template string) (*PathTemplate, error) {
+	t := &PathTemplate{raw: template}
+	segments := strings.Split(template, "/")
+	hasField := false
+	for i, segment := range segments {
+		if strings.TrimSpace(segment) == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("模板 '%s' 中的第 %d 级路径无效", template, i+1)
+		}
+		nt, err := parseNameTemplate(segment)
+		if err != nil {
+			return nil, err
+		}
+		hasField = hasField || len(nt.fields) > 0
+		if i == len(segments)-1 {
+			t.file = nt
+		} else {
+			t.dirs = append(t.dirs, nt)
+		}
+	}
+	if !hasField {
+		return nil, fmt.Errorf("模板 '%s' 中没有占位符", template)
+	}
+	return t, nil
+}
+
+// String 返回原始模板
+func (t *PathTemplate) String() string {
+	return t.raw
+}
+
+// NeedsMetadata 判断模板是否需要读取书籍元数据
+func (t *PathTemplate) NeedsMetadata() bool {
+	for _, nt := range t.dirs {
+		if nt.NeedsMetadata() {
+			return true
+		}
+	}
+	return t.file.NeedsMetadata()
+}
+
+// Execute 用 values 填充模板，返回使用系统路径分隔符的相对路径
+func (t *PathTemplate) Execute(values map[string]string) (string, error) {
+	var parts []string
+	for _, nt := range t.dirs {
+		dir, err := nt.execute(values, false)
+		if errors.Is(err, errEmptyName) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := checkPathComponent(dir); err != nil {
+			return "", err
+		}
+		parts = append(parts, dir)
+	}
+
+	name, err := t.file.Execute(values)
+	if err != nil {
+		return "", err
+	}
+	if err := checkPathComponent(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(append(parts, name)...), nil
+}
+
+// checkPathComponent 拒绝只由点组成的路径片段，避免生成 "." 或 ".." 跳出目标目录
+func checkPathComponent(s string) error {
+	if strings.Trim(s, ".") == "" {
+		return fmt.Errorf("路径片段 %q 无效", s)
+	}
+	return nil
+}
+
+// sanitizeNamePart 去除字段值中不能用于文件名的字符
+func sanitizeNamePart(s string) string {
+	s = reUnsafeName.ReplaceAllString(s, "_")
+	return strings.TrimSpace(normalizeSpaces(s))
+}
+
+// padNumber 将数字的整数部分补零到 width 位，非数字原样返回
+func padNumber(s string, width int) string {
+	intPart, frac, _ := strings.Cut(s, ".")
+	if _, err := strconv.Atoi(intPart); err != nil {
+		return s
+	}
+	for len(intPart) < width {
+		intPart = "0" + intPart
+	}
+	if frac != "" {
+		return intPart + "." + frac
+	}
+	return intPart
+}
+
+// truncateName 按字节截断文件名，不截断在字符中间
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	name = name[:max]
+	for !utf8.ValidString(name) {
+		name = name[:len(name)-1]
+	}
+	return strings.TrimSpace(name)
+}
+
+// FileTemplateValues 返回由文件属性和序号得到的模板字段
+func FileTemplateValues(filePath string, n int) map[string]string {
+	ext := FileExt(filePath)
+	return map[string]string{
+		FieldExt:    strings.TrimPrefix(ext, "."),
+		FieldParent: filepath.Base(filepath.Dir(filePath)),
+		FieldName:   strings.TrimSuffix(filepath.Base(filePath), ext),
+		FieldNumber: strconv.Itoa(n),
+	}
+}
+
+// ReadEpubTemplateValues 读取 EPUB 元数据，返回书名、作者、系列、年份和 ISBN 等模板字段
+// 作者只取角色为空或 aut 的责任者，并去除国籍标记和 "著" 等责任方式
+func ReadEpubTemplateValues(filePath string) (map[string]string, error) {
+	s, err := scanEpubMetadata(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 EPUB 元数据: %w", err)
+	}
+	values := metadataTemplateValues(s.metadata())
+	if year := s.year(); year != "" {
+		values[FieldYear] = year
+	}
+	return values, nil
+}
+
+// ReadBookTemplateValues 按文件格式读取元数据模板字段，不支持的格式返回 nil
+func ReadBookTemplateValues(filePath string) (map[string]string, error) {
+	f := FormatOf(filePath)
+	if f == nil {
+		return nil, nil
+	}
+	if f.TemplateValues != nil {
+		return f.TemplateValues(filePath)
+	}
+	meta, err := f.ReadMetadata(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return metadataTemplateValues(meta), nil
+}
+
+// metadataTemplateValues 由元数据生成书名、作者、系列和 ISBN 模板字段
+func metadataTemplateValues(meta *EpubMetadata) map[string]string {
+	values := map[string]string{FieldAuthor: templateAuthors(meta.Creators)}
+	if title := strings.TrimSpace(meta.Title); title != "" {
+		values[FieldTitle] = title
+	}
+	if meta.Series != nil && meta.Series.Name != "" {
+		values[FieldSeries] = strings.TrimSpace(meta.Series.Name)
+		if meta.Series.Index != 0 {
+			values[FieldIndex] = formatSeriesIndex(meta.Series.Index)
+		}
+	}
+	if meta.ISBN != "" {
+		values[FieldISBN] = meta.ISBN
+	}
+	return values
+}
+
+// templateAuthors 返回角色为空或 aut 的责任者，去除国籍标记和 "著" 等责任方式后用顿号连接
+func templateAuthors(creators []Creator) string {
+	var authors []string
+	for _, c := range NormalizeCreators(creators) {
+		if c.Role == "" || c.Role == "aut" {
+			authors = append(authors, c.Name)
+		}
+	}
+	return strings.Join(authors, "、")
+}
+
+// parseISBN 从 dc:identifier 中提取 ISBN，如 "urn:isbn:978-7-5366-9293-0"
+// 没有标明 ISBN 的标识符只接受 13 位且以 978/979 开头的值，避免误认 UUID 等
+func parseISBN(data, scheme string) string {
+	s := strings.TrimSpace(data)
+	lower := strings.ToLower(s)
+	marked := strings.EqualFold(scheme, "isbn") || strings.Contains(lower, "isbn")
+	for _, prefix := range []string{"urn:isbn:", "isbn:", "isbn"} {
+		lower = strings.TrimPrefix(lower, prefix)
+	}
+	if strings.Contains(lower, "uuid") {
+		return ""
+	}
+
+	m := reISBN.FindString(lower)
+	if m == "" {
+		return ""
+	}
+	isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(m))
+	if !marked && (len(isbn) != 13 || !(strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979"))) {
+		return ""
+	}
+	return isbn
+}
@@ -0,0 +1,239 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNameTemplate_Invalid(t *testing.T) {
+	for _, template := range []string{
+		"book",         // 没有占位符
+		"{unknown}",    // 未知字段
+		"{title",       // 未闭合
+		"{n:3}",        // 宽度必须以 0 开头
+		"{title}}-{n}", // 多余的右括号
+		"{author|{x}}", // 默认值中不能有花括号
+	} {
+		if _, err := ParseNameTemplate(template); err == nil {
+			t.Errorf("ParseNameTemplate(%q) 期望返回错误", template)
+		}
+	}
+}
+
+func TestNameTemplate_Execute(t *testing.T) {
+	values := map[string]string{
+		FieldTitle:  "三体：黑暗森林",
+		FieldAuthor: "刘慈欣",
+		FieldSeries: "三体",
+		FieldIndex:  "2",
+		FieldExt:    "epub",
+		FieldNumber: "7",
+		FieldParent: "科幻",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"book-@n", "book-7.epub"},
+		{"book-{n:03}", "book-007.epub"},
+		{"{author} - {title}", "刘慈欣 - 三体：黑暗森林.epub"},
+		{"{series} {index:02}", "三体 02.epub"},
+		{"{isbn|无ISBN}-{year|}", "无ISBN-.epub"},
+		{"{title}.{ext}", "三体：黑暗森林.epub"},
+		{"{parent}-@n", "科幻-7.epub"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseNameTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("ParseNameTemplate(%q) 失败: %v", tt.template, err)
+		}
+		got, err := tmpl.Execute(values)
+		if err != nil {
+			t.Errorf("Execute(%q) 失败: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Execute(%q) = %q, 期望 %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestNameTemplate_ExecuteEdgeCases(t *testing.T) {
+	tmpl, _ := ParseNameTemplate("{author} - {title}")
+
+	// 字段为空且没有默认值
+	if _, err := tmpl.Execute(map[string]string{FieldTitle: "三体"}); err == nil {
+		t.Error("字段为空时期望返回错误")
+	}
+
+	// 路径分隔符等字符被替换
+	got, err := tmpl.Execute(map[string]string{FieldTitle: "A/B: C?", FieldAuthor: "甲\\乙", FieldExt: "epub"})
+	if err != nil || got != "甲_乙 - A_B_ C_.epub" {
+		t.Errorf("期望替换不安全字符，得到 %q (%v)", got, err)
+	}
+
+	// 过长的文件名按字节截断，保留扩展名且不截断在字符中间
+	got, err = tmpl.Execute(map[string]string{FieldTitle: strings.Repeat("书", 200), FieldAuthor: "某", FieldExt: "epub"})
+	if err != nil || len(got) > maxNameBytes || !strings.HasSuffix(got, "书.epub") {
+		t.Errorf("期望截断到 %d 字节以内，得到 %d 字节 %q (%v)", maxNameBytes, len(got), got, err)
+	}
+
+	// 小数序号只补齐整数部分
+	idx, _ := ParseNameTemplate("{index:03}")
+	if got, _ := idx.Execute(map[string]string{FieldIndex: "2.5"}); got != "002.5" {
+		t.Errorf("期望 002.5，得到 %q", got)
+	}
+}
+
+func TestParseISBN(t *testing.T) {
+	tests := []struct {
+		data, scheme, want string
+	}{
+		{"9787536692930", "ISBN", "9787536692930"},
+		{"urn:isbn:978-7-5366-9293-0", "", "9787536692930"},
+		{"ISBN 7-5366-9293-5", "", "7536692935"},
+		{"753669293X", "isbn", "753669293X"},
+		{"9787536692930", "", "9787536692930"},
+		{"urn:uuid:12345678-1234-1234-1234-123456789012", "", ""},
+		{"1234567890", "", ""}, // 未标明 ISBN 的 10 位数字不采用
+		{"calibre:123", "calibre", ""},
+	}
+	for _, tt := range tests {
+		if got := parseISBN(tt.data, tt.scheme); got != tt.want {
+			t.Errorf("parseISBN(%q, %q) = %q, 期望 %q", tt.data, tt.scheme, got, tt.want)
+		}
+	}
+}
+
+func TestReadEpubTemplateValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = strings.Replace(entries[2][1], `<dc:creator opf:role="aut">测试作者</dc:creator>`,
+		`<dc:creator opf:role="aut">[美] 某某 著</dc:creator>
+    <dc:creator opf:role="trl">张三</dc:creator>
+    <dc:date opf:event="modification">2020-01-01</dc:date>
+    <dc:date opf:event="publication">2008-05</dc:date>
+    <dc:identifier opf:scheme="ISBN">978-7-5366-9293-0</dc:identifier>
+    <meta name="calibre:series" content="地球往事"/>
+    <meta name="calibre:series_index" content="2.0"/>`, 1)
+	writeTestEpub(t, path, entries)
+
+	values, err := ReadEpubTemplateValues(path)
+	if err != nil {
+		t.Fatalf("ReadEpubTemplateValues 失败: %v", err)
+	}
+	want := map[string]string{
+		FieldTitle:  "三体",
+		FieldAuthor: "某某",
+		FieldSeries: "地球往事",
+		FieldIndex:  "2",
+		FieldYear:   "2008",
+		FieldISBN:   "9787536692930",
+	}
+	for k, v := range want {
+		if values[k] != v {
+			t.Errorf("{%s} = %q, 期望 %q", k, values[k], v)
+		}
+	}
+
+	// EPUB3 中 clname 写入的 belongs-to-collection 和 meta refines 角色
+	epub3 := testEpubEntries("三体")
+	epub3[2][1] = testOpf3
+	writeTestEpub(t, path, epub3)
+	title := "三体：黑暗森林"
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Title: &title, Series: &SeriesInfo{Name: "三体", Index: 2}}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+	if err := rewriteEpubOpf(path, func(e *opfEditor) error {
+		// 只保留 belongs-to-collection
+		e.data = []byte(strings.NewReplacer(`<meta name="calibre:series" content="三体"/>`, "",
+			`<meta name="calibre:series_index" content="2"/>`, "").Replace(string(e.data)))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if opf := readTestOpf(t, path); strings.Contains(opf, "calibre:series") || !strings.Contains(opf, "belongs-to-collection") {
+		t.Fatalf("期望只有 belongs-to-collection:\n%s", opf)
+	}
+	values, err = ReadEpubTemplateValues(path)
+	if err != nil {
+		t.Fatalf("ReadEpubTemplateValues 失败: %v", err)
+	}
+	if values[FieldSeries] != "三体" || values[FieldIndex] != "2" || values[FieldAuthor] != "某某" {
+		t.Errorf("EPUB3 字段不正确: %v\n%s", values, readTestOpf(t, path))
+	}
+
+	file := FileTemplateValues(filepath.Join("books", "科幻", "三体.epub"), 3)
+	if file[FieldExt] != "epub" || file[FieldParent] != "科幻" || file[FieldName] != "三体" || file[FieldNumber] != "3" {
+		t.Errorf("文件字段不正确: %v", file)
+	}
+}
+
+func TestPathTemplate_Execute(t *testing.T) {
+	tmpl, err := ParsePathTemplate("{author}/{series|}/{index:02|}{title}")
+	if err != nil {
+		t.Fatalf("ParsePathTemplate 失败: %v", err)
+	}
+	if !tmpl.NeedsMetadata() {
+		t.Error("期望需要读取元数据")
+	}
+
+	values := map[string]string{FieldTitle: "三体", FieldAuthor: "刘慈欣", FieldExt: "epub"}
+	got, err := tmpl.Execute(values)
+	if want := filepath.Join("刘慈欣", "三体.epub"); err != nil || got != want {
+		t.Errorf("没有系列时期望省略该级目录 %q，得到 %q (%v)", want, got, err)
+	}
+
+	values[FieldSeries] = "地球往事"
+	values[FieldIndex] = "2"
+	got, err = tmpl.Execute(values)
+	if want := filepath.Join("刘慈欣", "地球往事", "02三体.epub"); err != nil || got != want {
+		t.Errorf("期望 %q，得到 %q (%v)", want, got, err)
+	}
+
+	// 字段值中的路径分隔符不能产生新的目录层级，只由点组成的片段被拒绝
+	values[FieldAuthor] = "../x"
+	if got, _ := tmpl.Execute(values); strings.Count(got, string(filepath.Separator)) != 2 {
+		t.Errorf("字段值不应产生新的目录层级，得到 %q", got)
+	}
+	values[FieldAuthor] = ".."
+	if _, err := tmpl.Execute(values); err == nil {
+		t.Error("目录为 .. 时期望返回错误")
+	}
+}
+
+func TestParsePathTemplate_Invalid(t *testing.T) {
+	for _, template := range []string{
+		"books/library", // 没有占位符
+		"{author}//{title}",
+		"../{title}",
+		"{author}/./{title}",
+		"{author}/{unknown}",
+	} {
+		if _, err := ParsePathTemplate(template); err == nil {
+			t.Errorf("ParsePathTemplate(%q) 期望返回错误", template)
+		}
+	}
+}
+
+func TestMoveFileAs_Conflict(t *testing.T) {
+	dir := t.TempDir()
+	dstDir := filepath.Join(dir, "作者", "系列")
+	for i := 0; i < 3; i++ {
+		src := filepath.Join(dir, "src.epub")
+		if err := os.WriteFile(src, []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := MoveFileAs(src, dstDir, "三体.epub")
+		if err != nil {
+			t.Fatalf("MoveFileAs 失败: %v", err)
+		}
+		want := []string{"三体.epub", "三体(1).epub", "三体(2).epub"}[i]
+		if got != filepath.Join(dstDir, want) || Exists(src) {
+			t.Errorf("第 %d 次移动期望得到 %s，得到 %s", i+1, want, got)
+		}
+	}
+}
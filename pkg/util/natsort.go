@@ -0,0 +1,187 @@
+package util
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortKey 文件排序方式
+type SortKey string
+
+const (
+	SortName    SortKey = "name"    // 按路径逐字节排序
+	SortNatural SortKey = "natural" // 数字按数值比较，支持中文数字，如 ch2 < ch10、第二章 < 第十二章
+	SortMtime   SortKey = "mtime"   // 按修改时间，从旧到新
+	SortSize    SortKey = "size"    // 按文件大小，从小到大
+	SortTitle   SortKey = "title"   // 按电子书书名自然排序，其他文件或无法读取时使用文件名
+)
+
+// SortKeys 所有可用的排序方式
+var SortKeys = []SortKey{SortName, SortNatural, SortMtime, SortSize, SortTitle}
+
+// chineseNumeralRunes 可以组成中文数字的字符
+const chineseNumeralRunes = "零〇一二两三四五六七八九十百千万"
+
+// natChunk 自然排序时字符串切分出的一段，数字段的 text 为去掉前导零的十进制数
+type natChunk struct {
+	text  string
+	isNum bool
+}
+
+// natChunks 将字符串切分为数字段和文本段，连续的阿拉伯数字（含全角）或中文数字为一个数字段
+func natChunks(s string) []natChunk {
+	var chunks []natChunk
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i
+		switch {
+		case isDigitRune(runes[i]):
+			for j < len(runes) && isDigitRune(runes[j]) {
+				j++
+			}
+			digits := strings.TrimLeft(toHalfWidthDigits(string(runes[i:j])), "0")
+			if digits == "" {
+				digits = "0"
+			}
+			chunks = append(chunks, natChunk{text: digits, isNum: true})
+		case strings.ContainsRune(chineseNumeralRunes, runes[i]):
+			for j < len(runes) && strings.ContainsRune(chineseNumeralRunes, runes[j]) {
+				j++
+			}
+			if n, ok := ParseChineseNumeral(string(runes[i:j])); ok {
+				chunks = append(chunks, natChunk{text: strconv.Itoa(n), isNum: true})
+			} else {
+				chunks = append(chunks, natChunk{text: string(runes[i:j])})
+			}
+		default:
+			for j < len(runes) && !isDigitRune(runes[j]) && !strings.ContainsRune(chineseNumeralRunes, runes[j]) {
+				j++
+			}
+			chunks = append(chunks, natChunk{text: strings.ToLower(string(runes[i:j]))})
+		}
+		i = j
+	}
+	return chunks
+}
+
+func isDigitRune(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= '０' && r <= '９')
+}
+
+// NaturalCompare 按自然顺序比较两个字符串，返回 -1、0 或 1
+// 数字段按数值比较（"ch2" < "ch10"，"第二章" < "第十二章"），文本段不区分大小写；
+// 按这些规则相等时逐字节比较，保证结果确定
+func NaturalCompare(a, b string) int {
+	ca, cb := natChunks(a), natChunks(b)
+	for i := 0; i < len(ca) && i < len(cb); i++ {
+		x, y := ca[i], cb[i]
+		if x.isNum && y.isNum {
+			if len(x.text) != len(y.text) {
+				return cmp.Compare(len(x.text), len(y.text))
+			}
+		}
+		if c := strings.Compare(x.text, y.text); c != 0 {
+			return c
+		}
+	}
+	if len(ca) != len(cb) {
+		return cmp.Compare(len(ca), len(cb))
+	}
+	return strings.Compare(a, b)
+}
+
+// NaturalLess 按自然顺序判断 a 是否在 b 之前
+func NaturalLess(a, b string) bool {
+	return NaturalCompare(a, b) < 0
+}
+
+// sortItem 排序时每个文件的信息
+type sortItem struct {
+	path  string
+	dir   string
+	base  string
+	mtime time.Time
+	size  int64
+	title string
+}
+
+// SortFiles 按 key 对文件原地排序，reverse 为 true 时倒序
+// perDir 为 true 时先按目录分组，目录按自然顺序排列、不受 reverse 影响，组内按 key 排序；
+// name 和 natural 本身也先比较目录再比较文件名，同一目录的文件总是相邻。
+// 排序依据相同的文件按路径自然顺序排列，结果确定
+func SortFiles(files []string, key SortKey, reverse, perDir bool) error {
+	items := make([]sortItem, len(files))
+	for i, path := range files {
+		items[i] = sortItem{path: path, dir: filepath.Dir(path), base: filepath.Base(path)}
+		switch key {
+		case SortMtime, SortSize:
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			items[i].mtime, items[i].size = info.ModTime(), info.Size()
+		case SortTitle:
+			items[i].title = sortTitle(path)
+		}
+	}
+
+	byPath := func(a, b *sortItem) int {
+		if key == SortName {
+			if c := strings.Compare(a.dir, b.dir); c != 0 {
+				return c
+			}
+			return strings.Compare(a.base, b.base)
+		}
+		if c := NaturalCompare(a.dir, b.dir); c != 0 {
+			return c
+		}
+		return NaturalCompare(a.base, b.base)
+	}
+	byKey := func(a, b *sortItem) int {
+		switch key {
+		case SortMtime:
+			return a.mtime.Compare(b.mtime)
+		case SortSize:
+			return cmp.Compare(a.size, b.size)
+		case SortTitle:
+			return NaturalCompare(a.title, b.title)
+		}
+		return byPath(a, b)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := &items[i], &items[j]
+		if perDir {
+			if c := NaturalCompare(a.dir, b.dir); c != 0 {
+				return c < 0
+			}
+		}
+		c := byKey(a, b)
+		if c == 0 {
+			c = byPath(a, b)
+		}
+		if reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	for i := range items {
+		files[i] = items[i].path
+	}
+	return nil
+}
+
+// sortTitle 返回按书名排序时使用的字符串
+func sortTitle(path string) string {
+	if values, err := ReadBookTemplateValues(path); err == nil && values[FieldTitle] != "" {
+		return values[FieldTitle]
+	}
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, FileExt(name))
+}
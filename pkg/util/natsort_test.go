@@ -0,0 +1,80 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNaturalLess(t *testing.T) {
+	want := []string{
+		"ch1.txt", "ch2.txt", "Ch3.txt", "ch10.txt", "ch010b.txt", "ch11.txt",
+		"卷２.txt", "卷10.txt",
+		"第二章.txt", "第十二章.txt", "第一百零五章.txt",
+	}
+	got := make([]string, len(want))
+	for i, s := range want {
+		got[len(want)-1-i] = s
+	}
+	sort.Slice(got, func(i, j int) bool { return NaturalLess(got[i], got[j]) })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("自然排序结果\n得到 %v\n期望 %v", got, want)
+	}
+
+	// 数值相同时结果仍然确定
+	if NaturalCompare("a01", "a1") == 0 || NaturalCompare("a01", "a1") != -NaturalCompare("a1", "a01") {
+		t.Error("数值相同的字符串应按字节排序")
+	}
+}
+
+func TestSortFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	files := []string{"vol10.txt", "vol2.txt", "b/x1.txt", "a/x2.txt", "a/x10.txt"}
+	for i, name := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, make([]byte, 10-i), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	sorted := func(key SortKey, reverse, perDir bool) []string {
+		paths := make([]string, len(files))
+		for i, name := range files {
+			paths[i] = filepath.Join(dir, name)
+		}
+		if err := SortFiles(paths, key, reverse, perDir); err != nil {
+			t.Fatalf("SortFiles 失败: %v", err)
+		}
+		for i, p := range paths {
+			paths[i], _ = filepath.Rel(dir, p)
+			paths[i] = filepath.ToSlash(paths[i])
+		}
+		return paths
+	}
+
+	tests := []struct {
+		key             SortKey
+		reverse, perDir bool
+		want            []string
+	}{
+		{SortName, false, false, []string{"vol10.txt", "vol2.txt", "a/x10.txt", "a/x2.txt", "b/x1.txt"}},
+		{SortNatural, false, false, []string{"vol2.txt", "vol10.txt", "a/x2.txt", "a/x10.txt", "b/x1.txt"}},
+		{SortNatural, true, false, []string{"b/x1.txt", "a/x10.txt", "a/x2.txt", "vol10.txt", "vol2.txt"}},
+		{SortMtime, false, false, []string{"vol10.txt", "vol2.txt", "b/x1.txt", "a/x2.txt", "a/x10.txt"}},
+		{SortSize, false, false, []string{"a/x10.txt", "a/x2.txt", "b/x1.txt", "vol2.txt", "vol10.txt"}},
+		{SortSize, true, true, []string{"vol10.txt", "vol2.txt", "a/x2.txt", "a/x10.txt", "b/x1.txt"}},
+		{SortTitle, false, false, []string{"vol2.txt", "vol10.txt", "b/x1.txt", "a/x2.txt", "a/x10.txt"}},
+	}
+	for _, tt := range tests {
+		if got := sorted(tt.key, tt.reverse, tt.perDir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SortFiles(%s, reverse=%v, perDir=%v)\n得到 %v\n期望 %v", tt.key, tt.reverse, tt.perDir, got, tt.want)
+		}
+	}
+}
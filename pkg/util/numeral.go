@@ -0,0 +1,84 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// chineseDigits 中文数字
+var chineseDigits = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// chineseUnits 中文数位，万单独处理
+var chineseUnits = map[rune]int{'十': 10, '百': 100, '千': 1000}
+
+// ParseChineseNumeral 解析中文数字或阿拉伯数字（含全角），如 "十二"、"一百零五"、"二〇二四"、"12"
+// 无法解析时返回 false
+func ParseChineseNumeral(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(toHalfWidthDigits(s)); err == nil {
+		if n < 0 {
+			return 0, false
+		}
+		return n, true
+	}
+
+	// 没有数位时按位读，如 "二〇二四"
+	hasUnit := strings.ContainsAny(s, "十百千万")
+	if !hasUnit {
+		n := 0
+		for _, r := range s {
+			d, ok := chineseDigits[r]
+			if !ok {
+				return 0, false
+			}
+			n = n*10 + d
+		}
+		return n, true
+	}
+
+	total, section, digit := 0, 0, -1
+	for _, r := range s {
+		if d, ok := chineseDigits[r]; ok {
+			digit = d
+			continue
+		}
+		if r == '万' {
+			if digit > 0 {
+				section += digit
+			}
+			total += section * 10000
+			section, digit = 0, -1
+			continue
+		}
+		unit, ok := chineseUnits[r]
+		if !ok {
+			return 0, false
+		}
+		// "十二" 省略了前面的 "一"
+		if digit < 0 {
+			digit = 1
+		}
+		section += digit * unit
+		digit = -1
+	}
+	if digit > 0 {
+		section += digit
+	}
+	return total + section, true
+}
+
+// toHalfWidthDigits 将全角数字转换为半角
+func toHalfWidthDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, s)
+}
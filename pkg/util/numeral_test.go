@@ -0,0 +1,38 @@
+package util
+
+import "testing"
+
+func TestParseChineseNumeral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		ok       bool
+	}{
+		{"1", 1, true},
+		{"12", 12, true},
+		{"１２", 12, true},
+		{"一", 1, true},
+		{"十", 10, true},
+		{"十二", 12, true},
+		{"二十", 20, true},
+		{"二十三", 23, true},
+		{"两百", 200, true},
+		{"一百零五", 105, true},
+		{"一千二百三十四", 1234, true},
+		{"一万零五", 10005, true},
+		{"二十万", 200000, true},
+		{"二〇二四", 2024, true},
+		{"零", 0, true},
+		{"", 0, false},
+		{"第二", 0, false},
+		{"abc", 0, false},
+		{"-1", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseChineseNumeral(tt.input)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("ParseChineseNumeral(%q) = %d, %v，期望 %d, %v", tt.input, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
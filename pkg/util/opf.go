@@ -0,0 +1,293 @@
+package util
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// opfElement 描述 OPF metadata 下的一个子元素及其在原始字节中的位置
+type opfElement struct {
+	Prefix string     // 原始命名空间前缀，如 "dc"
+	Local  string     // 本地名称，如 "title"
+	Attr   []xml.Attr // 原始属性（Name.Space 为前缀）
+	Text   string     // 元素文本内容
+	Start  int        // 开始标签起始偏移
+	End    int        // 结束标签结束偏移
+}
+
+// Attribute 返回指定本地名称的属性值，忽略前缀
+func (el *opfElement) Attribute(local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// opfEditor 基于字节偏移修改 OPF，未涉及的部分保持原样
+// encoding/xml 的编码器会改写命名空间，因此不做整体序列化
+type opfEditor struct {
+	data []byte
+}
+
+func newOpfEditor(data []byte) *opfEditor {
+	return &opfEditor{data: data}
+}
+
+// Bytes 返回修改后的 OPF 内容
+func (e *opfEditor) Bytes() []byte {
+	return e.data
+}
+
+// opfNamespace 是 OPF 命名空间，EPUB2 的 opf:role 等属性使用它
+const opfNamespace = "http://www.idpf.org/2007/opf"
+
+// metadataScan 扫描结果
+type metadataScan struct {
+	elements  []opfElement
+	dcPrefix  string // dc 元素使用的前缀
+	opfPrefix string // 已声明的 OPF 命名空间前缀，未声明时为空
+	version   string // package 的 version 属性
+	openEnd   int    // <metadata ...> 开始标签的结束偏移
+	closeAt   int    // </metadata> 的起始偏移
+	indent    string // 子元素缩进
+}
+
+// epub3 判断是否为 EPUB3，EPUB3 使用 meta refines 描述作者角色
+func (s *metadataScan) epub3() bool {
+	return strings.HasPrefix(s.version, "3")
+}
+
+// scan 扫描 metadata 的直接子元素
+func (e *opfEditor) scan() (*metadataScan, error) {
+	d := xml.NewDecoder(bytes.NewReader(e.data))
+	d.Strict = false
+
+	result := &metadataScan{dcPrefix: "dc", closeAt: -1}
+	depth := 0
+	metaDepth := -1
+	var current *opfElement
+	var text strings.Builder
+
+	for {
+		offset := int(d.InputOffset())
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("无法解析 OPF: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if metaDepth < 0 && (t.Name.Local == "package" || t.Name.Local == "metadata") {
+				for _, a := range t.Attr {
+					if a.Name.Space == "xmlns" && a.Value == opfNamespace {
+						result.opfPrefix = a.Name.Local
+					}
+					if t.Name.Local == "package" && a.Name.Local == "version" {
+						result.version = a.Value
+					}
+				}
+			}
+			if metaDepth < 0 && t.Name.Local == "metadata" {
+				metaDepth = depth
+				result.openEnd = int(d.InputOffset())
+				continue
+			}
+			if metaDepth > 0 && depth == metaDepth+1 {
+				current = &opfElement{
+					Prefix: t.Name.Space,
+					Local:  t.Name.Local,
+					Attr:   append([]xml.Attr(nil), t.Attr...),
+					Start:  offset,
+				}
+				text.Reset()
+				if result.indent == "" {
+					result.indent = leadingIndent(e.data, offset)
+				}
+			}
+		case xml.EndElement:
+			if current != nil && depth == metaDepth+1 {
+				current.End = int(d.InputOffset())
+				current.Text = text.String()
+				if current.Prefix != "" && isDCElement(current.Local) {
+					result.dcPrefix = current.Prefix
+				}
+				result.elements = append(result.elements, *current)
+				current = nil
+			} else if depth == metaDepth {
+				result.closeAt = offset
+				return result, nil
+			}
+			depth--
+		case xml.CharData:
+			if current != nil {
+				text.Write(t)
+			}
+		}
+	}
+
+	if metaDepth < 0 {
+		return nil, fmt.Errorf("OPF 中缺少 metadata 元素")
+	}
+	return nil, fmt.Errorf("OPF 中 metadata 元素未闭合")
+}
+
+// SetText 修改第一个指定名称元素的文本，保留其属性（如 EPUB3 的 id）
+// 若不存在该元素则在 metadata 末尾新增
+func (e *opfEditor) SetText(local, text string) error {
+	s, err := e.scan()
+	if err != nil {
+		return err
+	}
+
+	for _, el := range s.elements {
+		if el.Local != local {
+			continue
+		}
+		el.Text = text
+		e.splice(el.Start, el.End, renderElement(&el))
+		return nil
+	}
+
+	e.insert(s, renderElement(&opfElement{Prefix: s.dcPrefix, Local: local, Text: text}))
+	return nil
+}
+
+// replaceElements 删除 old 中的元素，并在第一个元素的位置写入 rendered
+// old 为空时在 metadata 末尾新增；rendered 为空时只删除
+func (e *opfEditor) replaceElements(s *metadataScan, old []opfElement, rendered []string) {
+	group := strings.Join(rendered, "\n"+s.indent)
+	if len(old) == 0 {
+		if group != "" {
+			e.insert(s, group)
+		}
+		return
+	}
+
+	sorted := append([]opfElement(nil), old...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	// 从后往前删除，保证前面元素的偏移不变
+	for i := len(sorted) - 1; i > 0; i-- {
+		e.splice(e.lineStart(sorted[i].Start), sorted[i].End, "")
+	}
+	if group == "" {
+		e.splice(e.lineStart(sorted[0].Start), sorted[0].End, "")
+		return
+	}
+	e.splice(sorted[0].Start, sorted[0].End, group)
+}
+
+// lineStart 元素独占一行时返回包含前导空白和上一个换行符的起始偏移，否则返回 offset
+func (e *opfEditor) lineStart(offset int) int {
+	start := offset
+	for start > 0 && (e.data[start-1] == ' ' || e.data[start-1] == '\t') {
+		start--
+	}
+	if start > 0 && e.data[start-1] == '\n' {
+		start--
+		if start > 0 && e.data[start-1] == '\r' {
+			start--
+		}
+		return start
+	}
+	return offset
+}
+
+// declareOpfPrefix 在 metadata 开始标签上声明 OPF 命名空间前缀，返回使用的前缀
+func (e *opfEditor) declareOpfPrefix(s *metadataScan) string {
+	if s.opfPrefix != "" {
+		return s.opfPrefix
+	}
+	// 开始标签以 ">" 结尾
+	at := s.openEnd - 1
+	e.splice(at, at, ` xmlns:opf="`+opfNamespace+`"`)
+	return "opf"
+}
+
+// splice 用 repl 替换 [start, end) 区间
+func (e *opfEditor) splice(start, end int, repl string) {
+	var buf bytes.Buffer
+	buf.Grow(len(e.data) - (end - start) + len(repl))
+	buf.Write(e.data[:start])
+	buf.WriteString(repl)
+	buf.Write(e.data[end:])
+	e.data = buf.Bytes()
+}
+
+// insert 在 </metadata> 之前插入一个元素，沿用已有子元素的缩进
+func (e *opfEditor) insert(s *metadataScan, rendered string) {
+	lineStart := s.closeAt
+	for lineStart > 0 && (e.data[lineStart-1] == ' ' || e.data[lineStart-1] == '\t') {
+		lineStart--
+	}
+	if lineStart > 0 && e.data[lineStart-1] == '\n' {
+		// </metadata> 独占一行，新元素插入到它的上一行
+		e.splice(lineStart, lineStart, s.indent+rendered+"\n")
+		return
+	}
+	e.splice(s.closeAt, s.closeAt, rendered)
+}
+
+// renderElement 将元素渲染为 XML 文本
+func renderElement(el *opfElement) string {
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(qualifiedName(el.Prefix, el.Local))
+	for _, a := range el.Attr {
+		b.WriteString(" ")
+		b.WriteString(qualifiedName(a.Name.Space, a.Name.Local))
+		b.WriteString(`="`)
+		xml.EscapeText(&b, []byte(a.Value))
+		b.WriteString(`"`)
+	}
+	if el.Text == "" && !isDCElement(el.Local) {
+		b.WriteString("/>")
+		return b.String()
+	}
+	b.WriteString(">")
+	xml.EscapeText(&b, []byte(el.Text))
+	b.WriteString("</")
+	b.WriteString(qualifiedName(el.Prefix, el.Local))
+	b.WriteString(">")
+	return b.String()
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// isDCElement 判断是否为 Dublin Core 元素名
+func isDCElement(local string) bool {
+	switch local {
+	case "title", "creator", "subject", "description", "publisher", "contributor",
+		"date", "type", "format", "identifier", "source", "language", "relation",
+		"coverage", "rights":
+		return true
+	}
+	return false
+}
+
+// leadingIndent 返回 offset 所在行起始处的空白
+func leadingIndent(data []byte, offset int) string {
+	start := offset
+	for start > 0 && (data[start-1] == ' ' || data[start-1] == '\t') {
+		start--
+	}
+	if start > 0 && data[start-1] != '\n' {
+		return ""
+	}
+	return string(data[start:offset])
+}
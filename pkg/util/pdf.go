@@ -0,0 +1,518 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// PDF 对象类型
+// 参考 ISO 32000-1 第 7.3 节；数字为 int64 或 float64，布尔值为 bool，null 为 nil
+type (
+	pdfName    string          // 名称，不含开头的 "/"
+	pdfString  []byte          // 字符串的原始字节
+	pdfArray   []any           // 数组
+	pdfDict    map[pdfName]any // 字典
+	pdfKeyword string          // 关键字和分隔符，如 "obj"、"R"、"<<"，只在解析时出现
+)
+
+// pdfRef 间接引用 "num gen R"
+type pdfRef struct {
+	Num, Gen int
+}
+
+// pdfStream 流对象，Offset 为流数据在文件中的起始位置
+type pdfStream struct {
+	Dict   pdfDict
+	Offset int64
+}
+
+// errPdfShort 解析到缓冲区末尾时返回，调用方应读取更多数据后重试
+var errPdfShort = errors.New("数据不完整")
+
+// pdfLexer PDF 词法和语法分析器，在一段已读取的数据上工作
+type pdfLexer struct {
+	buf []byte
+	pos int
+	eof bool // buf 已到文件末尾，末尾的单词是完整的
+}
+
+func isPdfSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isPdfDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// skipSpace 跳过空白和注释
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		if c == '%' {
+			for l.pos < len(l.buf) && l.buf[l.pos] != '\n' && l.buf[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPdfSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// token 读取下一个词法单元：pdfName、pdfString、int64、float64 或 pdfKeyword
+func (l *pdfLexer) token() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.buf) {
+		return nil, errPdfShort
+	}
+	c := l.buf[l.pos]
+	switch {
+	case c == '/':
+		return l.name()
+	case c == '(':
+		return l.literalString()
+	case c == '<':
+		if l.pos+1 >= len(l.buf) {
+			return nil, errPdfShort
+		}
+		if l.buf[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfKeyword("<<"), nil
+		}
+		return l.hexString()
+	case c == '>':
+		if l.pos+1 >= len(l.buf) {
+			return nil, errPdfShort
+		}
+		if l.buf[l.pos+1] != '>' {
+			return nil, fmt.Errorf("偏移 %d 处多余的 '>'", l.pos)
+		}
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	case c == ')':
+		return nil, fmt.Errorf("偏移 %d 处多余的 ')'", l.pos)
+	}
+
+	start := l.pos
+	for l.pos < len(l.buf) && !isPdfSpace(l.buf[l.pos]) && !isPdfDelim(l.buf[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.buf) && !l.eof {
+		// 单词可能在缓冲区边界被截断
+		return nil, errPdfShort
+	}
+	word := string(l.buf[start:l.pos])
+	if n, err := strconv.ParseInt(word, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil && (word[0] == '.' || word[0] == '-' || word[0] == '+' || word[0] >= '0' && word[0] <= '9') {
+		return f, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+func (l *pdfLexer) name() (any, error) {
+	l.pos++
+	var sb []byte
+	for {
+		if l.pos >= len(l.buf) {
+			return nil, errPdfShort
+		}
+		c := l.buf[l.pos]
+		if isPdfSpace(c) || isPdfDelim(c) {
+			return pdfName(sb), nil
+		}
+		if c == '#' && l.pos+2 < len(l.buf) {
+			if v, err := strconv.ParseUint(string(l.buf[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				sb = append(sb, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		sb = append(sb, c)
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) literalString() (any, error) {
+	l.pos++
+	var sb []byte
+	depth := 1
+	for {
+		if l.pos >= len(l.buf) {
+			return nil, errPdfShort
+		}
+		c := l.buf[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return pdfString(sb), nil
+			}
+		case '\\':
+			if l.pos >= len(l.buf) {
+				return nil, errPdfShort
+			}
+			e := l.buf[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// 反斜杠加换行表示续行
+				if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.buf) && l.buf[l.pos] >= '0' && l.buf[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.buf[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		sb = append(sb, c)
+	}
+}
+
+func (l *pdfLexer) hexString() (any, error) {
+	l.pos++
+	var digits []byte
+	for {
+		if l.pos >= len(l.buf) {
+			return nil, errPdfShort
+		}
+		c := l.buf[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		if isPdfSpace(c) {
+			continue
+		}
+		if _, err := strconv.ParseUint(string(c), 16, 8); err != nil {
+			return nil, fmt.Errorf("十六进制字符串中的无效字符 %q", c)
+		}
+		digits = append(digits, c)
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return pdfString(out), nil
+}
+
+// object 读取一个完整的对象，"num gen R" 解析为 pdfRef
+func (l *pdfLexer) object() (any, error) {
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	return l.objectFrom(tok)
+}
+
+func (l *pdfLexer) objectFrom(tok any) (any, error) {
+	switch t := tok.(type) {
+	case int64:
+		// 向前查看是否为间接引用
+		save := l.pos
+		if gen, err := l.token(); err == nil {
+			if g, ok := gen.(int64); ok {
+				if kw, err := l.token(); err == nil && kw == pdfKeyword("R") {
+					return pdfRef{Num: int(t), Gen: int(g)}, nil
+				} else if errors.Is(err, errPdfShort) && !l.eof {
+					return nil, err
+				}
+			}
+		} else if errors.Is(err, errPdfShort) && !l.eof {
+			return nil, err
+		}
+		l.pos = save
+		return t, nil
+	case pdfKeyword:
+		switch t {
+		case "<<":
+			return l.dict()
+		case "[":
+			return l.array()
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("意外的关键字 %q", string(t))
+	}
+	return tok, nil
+}
+
+func (l *pdfLexer) dict() (pdfDict, error) {
+	d := pdfDict{}
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == pdfKeyword(">>") {
+			return d, nil
+		}
+		key, ok := tok.(pdfName)
+		if !ok {
+			return nil, fmt.Errorf("字典的键不是名称: %v", tok)
+		}
+		value, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		d[key] = value
+	}
+}
+
+func (l *pdfLexer) array() (pdfArray, error) {
+	a := pdfArray{}
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == pdfKeyword("]") {
+			return a, nil
+		}
+		value, err := l.objectFrom(tok)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, value)
+	}
+}
+
+// indirectObject 读取 "num gen obj ... endobj"，流对象返回 pdfStream（Offset 为相对 buf 的位置）
+func (l *pdfLexer) indirectObject() (pdfRef, any, error) {
+	var ref pdfRef
+	num, err := l.token()
+	if err != nil {
+		return ref, nil, err
+	}
+	gen, err := l.token()
+	if err != nil {
+		return ref, nil, err
+	}
+	kw, err := l.token()
+	if err != nil {
+		return ref, nil, err
+	}
+	n, ok1 := num.(int64)
+	g, ok2 := gen.(int64)
+	if !ok1 || !ok2 || kw != pdfKeyword("obj") {
+		return ref, nil, fmt.Errorf("不是间接对象的开头")
+	}
+	ref = pdfRef{Num: int(n), Gen: int(g)}
+
+	value, err := l.object()
+	if err != nil {
+		return ref, nil, err
+	}
+	dict, isDict := value.(pdfDict)
+	save := l.pos
+	tok, err := l.token()
+	if err != nil {
+		if errors.Is(err, errPdfShort) && (!isDict || l.eof) {
+			// 非字典对象之后的内容不影响结果
+			return ref, value, nil
+		}
+		return ref, nil, err
+	}
+	if isDict && tok == pdfKeyword("stream") {
+		// stream 关键字之后是 CRLF 或 LF
+		if l.pos < len(l.buf) && l.buf[l.pos] == '\r' {
+			l.pos++
+		}
+		if l.pos >= len(l.buf) {
+			return ref, nil, errPdfShort
+		}
+		if l.buf[l.pos] == '\n' {
+			l.pos++
+		}
+		return ref, pdfStream{Dict: dict, Offset: int64(l.pos)}, nil
+	}
+	l.pos = save
+	return ref, value, nil
+}
+
+// pdfInt 返回整数值，浮点数取整
+func pdfInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// writePdfObject 序列化对象，字典的键按名称排序
+func writePdfObject(buf *bytes.Buffer, v any) {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case int64:
+		buf.WriteString(strconv.FormatInt(t, 10))
+	case int:
+		buf.WriteString(strconv.Itoa(t))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(t, 'f', -1, 64))
+	case pdfName:
+		buf.WriteByte('/')
+		for i := 0; i < len(t); i++ {
+			c := t[i]
+			if c < 0x21 || c > 0x7E || c == '#' || isPdfDelim(c) {
+				fmt.Fprintf(buf, "#%02X", c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	case pdfString:
+		writePdfString(buf, t)
+	case pdfRef:
+		fmt.Fprintf(buf, "%d %d R", t.Num, t.Gen)
+	case pdfArray:
+		buf.WriteByte('[')
+		for i, item := range t {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writePdfObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writePdfObject(buf, pdfName(k))
+			buf.WriteByte(' ')
+			writePdfObject(buf, t[pdfName(k)])
+		}
+		buf.WriteString(">>")
+	default:
+		panic(fmt.Sprintf("无法序列化的 PDF 对象 %T", v))
+	}
+}
+
+// writePdfString 可打印 ASCII 写为字面字符串，其他写为十六进制字符串
+func writePdfString(buf *bytes.Buffer, s []byte) {
+	printable := true
+	for _, c := range s {
+		if c < 0x20 || c > 0x7E {
+			printable = false
+			break
+		}
+	}
+	if !printable {
+		fmt.Fprintf(buf, "<%X>", s)
+		return
+	}
+	buf.WriteByte('(')
+	for _, c := range s {
+		if c == '(' || c == ')' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	buf.WriteByte(')')
+}
+
+// pdfDocEncodingHigh PDFDocEncoding 中 0x80-0xA0 对应的字符，0xA1 以上与 Latin-1 相同
+var pdfDocEncodingHigh = [33]rune{
+	'•', '†', '‡', '…', '—', '–', 'ƒ', '⁄', '‹', '›', '−', '‰', '„', '“', '”', '‘',
+	'’', '‚', '™', 'ﬁ', 'ﬂ', 'Ł', 'Œ', 'Š', 'Ÿ', 'Ž', 'ı', 'ł', 'œ', 'š', 'ž', utf8.RuneError,
+	'€',
+}
+
+// decodePdfText 解码文本字符串：UTF-16BE（FE FF 开头）、UTF-8（EF BB BF 开头）或 PDFDocEncoding
+func decodePdfText(s []byte) string {
+	switch {
+	case len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF:
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF:
+		return string(s[3:])
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		if c >= 0x80 && c <= 0xA0 {
+			runes[i] = pdfDocEncodingHigh[c-0x80]
+		} else {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
+
+// encodePdfText 编码文本字符串，非 ASCII 文本使用带 BOM 的 UTF-16BE
+func encodePdfText(s string) pdfString {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return pdfString(s)
+	}
+	out := []byte{0xFE, 0xFF}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return pdfString(out)
+}
@@ -0,0 +1,355 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testPdf 构造测试 PDF 文件的参数
+type testPdf struct {
+	infoTitle  string // /Info 中的 /Title，为空时不写
+	xmpTitle   string // XMP 中的 dc:title，为空时不写 XMP
+	xrefStream bool   // 使用交叉引用流，页面树和 /Info 放在对象流中
+	hybrid     bool   // 混合引用：传统交叉引用表中对象流内的对象标记为空闲，由 /XRefStm 给出
+	linearized int64  // 不为 0 时在开头写入 /L 为该值的线性化参数字典
+	badInfo    bool   // /Info 在交叉引用表中的偏移错位
+	noInfo     bool   // 不写 /Info，修改书名时新建的 /Info 编号大于 XMP
+}
+
+// build 生成对象 1 目录、2 页面树、3 /Info、4 XMP
+func (p testPdf) build() []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+	if p.linearized != 0 {
+		fmt.Fprintf(&out, "9 0 obj\n<< /Linearized 1 /L %d /N 1 >>\nendobj\n", p.linearized)
+	}
+
+	info := "<< /Producer (test) >>"
+	if p.infoTitle != "" {
+		var b bytes.Buffer
+		writePdfString(&b, encodePdfText(p.infoTitle))
+		info = "<< /Producer (test) /Title " + b.String() + " >>"
+	}
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	if p.xmpTitle != "" {
+		catalog = "<< /Type /Catalog /Pages 2 0 R /Metadata 4 0 R >>"
+	}
+	pages := "<< /Type /Pages /Kids [] /Count 0 >>"
+	xmp := `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?><x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+		`<dc:title><rdf:Alt><rdf:li xml:lang="x-default">` + p.xmpTitle + `</rdf:li></rdf:Alt></dc:title>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta><?xpacket end="w"?>`
+
+	trailerInfo := " /Info 3 0 R"
+	if p.noInfo {
+		trailerInfo = ""
+	}
+
+	offsets := map[int]int{}
+	writeObj := func(num int, body string) {
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+	writeObj(1, catalog)
+	if p.xmpTitle != "" {
+		writeObj(4, fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(xmp), xmp))
+	}
+
+	if !p.xrefStream && !p.hybrid {
+		writeObj(2, pages)
+		if !p.noInfo {
+			writeObj(3, info)
+		}
+		if p.badInfo {
+			offsets[3]++
+		}
+		xref := out.Len()
+		out.WriteString("xref\n0 5\n0000000000 65535 f\r\n")
+		for num := 1; num <= 4; num++ {
+			if off, ok := offsets[num]; ok {
+				fmt.Fprintf(&out, "%010d 00000 n\r\n", off)
+			} else {
+				out.WriteString("0000000000 00000 f\r\n")
+			}
+		}
+		fmt.Fprintf(&out, "trailer\n<< /Size 5 /Root 1 0 R%s >>\nstartxref\n%d\n%%%%EOF\n", trailerInfo, xref)
+		return out.Bytes()
+	}
+
+	// 对象 5 为包含对象 2、3 的对象流，对象 6 为交叉引用流
+	header, content, count := fmt.Sprintf("2 0 3 %d ", len(pages)+1), pages+" "+info, 2
+	if p.noInfo {
+		header, content, count = "2 0 ", pages, 1
+	}
+	var objstm bytes.Buffer
+	zw := zlib.NewWriter(&objstm)
+	zw.Write([]byte(header + content))
+	zw.Close()
+	writeObj(5, fmt.Sprintf("<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+		count, len(header), objstm.Len(), objstm.Bytes()))
+
+	xref := out.Len()
+	offsets[6] = xref
+	row := func(typ byte, field2 int, field3 int) []byte {
+		return []byte{typ, byte(field2 >> 24), byte(field2 >> 16), byte(field2 >> 8), byte(field2), byte(field3 >> 8), byte(field3)}
+	}
+	rows := [][]byte{row(0, 0, 0xFFFF), row(1, offsets[1], 0), row(2, 5, 0), row(2, 5, 1), row(0, 0, 0), row(1, offsets[5], 0), row(1, offsets[6], 0)}
+	if p.xmpTitle != "" {
+		rows[4] = row(1, offsets[4], 0)
+	}
+	if p.noInfo {
+		rows[3] = row(0, 0, 0)
+	}
+	// PNG Up 预测器
+	var raw bytes.Buffer
+	prev := make([]byte, 7)
+	for _, r := range rows {
+		raw.WriteByte(2)
+		for i := range r {
+			raw.WriteByte(r[i] - prev[i])
+		}
+		prev = r
+	}
+	var data bytes.Buffer
+	zw = zlib.NewWriter(&data)
+	zw.Write(raw.Bytes())
+	zw.Close()
+	fmt.Fprintf(&out, "6 0 obj\n<< /Type /XRef /Size 7 /W [1 4 2] /Root 1 0 R%s /Filter /FlateDecode "+
+		"/DecodeParms << /Predictor 12 /Columns 7 >> /Length %d >>\nstream\n", trailerInfo, data.Len())
+	out.Write(data.Bytes())
+	out.WriteString("\nendstream\nendobj\n")
+	if !p.hybrid {
+		fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xref)
+		return out.Bytes()
+	}
+
+	// 与 Word 导出的文件相同，传统交叉引用表只列出顶层对象
+	table := out.Len()
+	out.WriteString("xref\n0 7\n0000000000 65535 f\r\n")
+	for num := 1; num <= 6; num++ {
+		if off, ok := offsets[num]; ok {
+			fmt.Fprintf(&out, "%010d 00000 n\r\n", off)
+		} else {
+			out.WriteString("0000000000 65535 f\r\n")
+		}
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size 7 /Root 1 0 R%s /XRefStm %d >>\nstartxref\n%d\n%%%%EOF\n", trailerInfo, xref, table)
+	return out.Bytes()
+}
+
+func writeTestPdf(t *testing.T, path string, p testPdf) {
+	t.Helper()
+	if err := os.WriteFile(path, p.build(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPdfValidator(t *testing.T) {
+	dir := t.TempDir()
+	good := testPdf{infoTitle: "三体", xmpTitle: "三体"}.build()
+	tests := []struct {
+		name     string
+		data     []byte
+		deep     bool
+		wantErr  bool
+		wantType ErrorType
+	}{
+		{name: "交叉引用表", data: good},
+		{name: "交叉引用表深度检测", data: good, deep: true},
+		{name: "交叉引用流和对象流", data: testPdf{infoTitle: "三体", xrefStream: true}.build(), deep: true},
+		{name: "混合引用", data: testPdf{infoTitle: "三体", hybrid: true}.build(), deep: true},
+		{name: "线性化文件", data: testPdf{linearized: 100}.build()},
+		{name: "缺少 %%EOF", data: good[:len(good)-10], wantErr: true, wantType: ErrorTypeCorrupted},
+		{name: "截断到一半", data: good[:len(good)/2], wantErr: true, wantType: ErrorTypeCorrupted},
+		{name: "不是 PDF", data: []byte("hello world %%EOF"), wantErr: true, wantType: ErrorTypeFormat},
+		{
+			name:     "startxref 指向错误的位置",
+			data:     bytes.Replace(good, []byte("startxref\n"), []byte("startxref\n1"), 1),
+			wantErr:  true,
+			wantType: ErrorTypeCorrupted,
+		},
+		{name: "线性化长度超出文件", data: testPdf{linearized: 1 << 20}.build(), wantErr: true, wantType: ErrorTypeCorrupted},
+		// /Info 的偏移错位只有深度检测能发现
+		{name: "对象偏移错位", data: testPdf{badInfo: true}.build()},
+		{name: "对象偏移错位深度检测", data: testPdf{badInfo: true}.build(), deep: true, wantErr: true, wantType: ErrorTypeCorrupted},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("book%d.pdf", i))
+			os.WriteFile(path, tt.data, 0644)
+
+			err := (&PdfValidator{Deep: tt.deep}).Validate(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v，期望出错 %v", err, tt.wantErr)
+			}
+			var epubErr *EpubError
+			if tt.wantErr && (!errors.As(err, &epubErr) || epubErr.Type != tt.wantType) {
+				t.Errorf("错误 = %v，期望类型 %v", err, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestReadPdfMetadata(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		pdf  testPdf
+		want string
+	}{
+		{testPdf{infoTitle: "三体（高清扫描版）", xmpTitle: "其他"}, "三体（高清扫描版）"},
+		{testPdf{infoTitle: "Plain (ASCII) title"}, "Plain (ASCII) title"},
+		{testPdf{xmpTitle: "球状闪电 &amp; 超新星纪元"}, "球状闪电 & 超新星纪元"},
+		{testPdf{infoTitle: "三体", xrefStream: true}, "三体"},
+		{testPdf{infoTitle: "三体", hybrid: true}, "三体"},
+		{testPdf{}, ""},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("book%d.pdf", i))
+		writeTestPdf(t, path, tt.pdf)
+		meta, err := ReadBookMetadata(path)
+		if err != nil {
+			t.Errorf("%+v: ReadBookMetadata 失败: %v", tt.pdf, err)
+			continue
+		}
+		if meta.Title != tt.want {
+			t.Errorf("%+v: 书名 = %q，期望 %q", tt.pdf, meta.Title, tt.want)
+		}
+	}
+}
+
+func TestUpdatePdfMetadata(t *testing.T) {
+	for _, layout := range []testPdf{{}, {xrefStream: true}, {hybrid: true}} {
+		t.Run(fmt.Sprintf("xrefStream=%v,hybrid=%v", layout.xrefStream, layout.hybrid), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "book.pdf")
+			layout.infoTitle, layout.xmpTitle = "三体（高清扫描版）（www.xxx.com）", "三体（高清扫描版）"
+			orig := layout.build()
+			os.WriteFile(path, orig, 0644)
+
+			for _, title := range []string{"三体", "Three Body (2)"} {
+				if err := UpdatePdfMetadata(path, &MetadataUpdate{Title: &title}); err != nil {
+					t.Fatalf("UpdatePdfMetadata 失败: %v", err)
+				}
+				meta, err := ReadPdfMetadata(path)
+				if err != nil || meta.Title != title {
+					t.Fatalf("写入后书名 = %+v, %v，期望 %q", meta, err, title)
+				}
+			}
+
+			data, _ := os.ReadFile(path)
+			if !bytes.HasPrefix(data, orig) {
+				t.Error("增量更新不应修改原有内容")
+			}
+			if err := (&PdfValidator{Deep: true}).Validate(path); err != nil {
+				t.Errorf("更新后的文件未通过检测: %v", err)
+			}
+
+			doc, f, err := OpenPdf(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			xmp, err := doc.XMP()
+			if err != nil || xmpTitle(xmp) != "Three Body (2)" {
+				t.Errorf("XMP 书名 = %q, %v", xmpTitle(xmp), err)
+			}
+			info, _ := doc.Info()
+			if decodePdfText(info["Producer"].(pdfString)) != "test" {
+				t.Errorf("/Info 的其他条目应保留: %v", info)
+			}
+		})
+	}
+}
+
+func TestUpdatePdfMetadata_Unsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	writeTestPdf(t, path, testPdf{infoTitle: "三体"})
+	orig, _ := os.ReadFile(path)
+
+	publisher := "重庆出版社"
+	err := UpdatePdfMetadata(path, &MetadataUpdate{Publisher: &publisher})
+	if err == nil || !strings.Contains(err.Error(), "只支持修改书名") {
+		t.Errorf("修改出版社应返回错误，得到 %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, orig) {
+		t.Error("失败时不应修改文件")
+	}
+}
+
+func TestPdfTextString(t *testing.T) {
+	for _, s := range []string{"", "ASCII (title)", "三体：地球往事", "emoji 📚"} {
+		if got := decodePdfText(encodePdfText(s)); got != s {
+			t.Errorf("编码后解码 %q 得到 %q", s, got)
+		}
+	}
+	// PDFDocEncoding 的 0x80-0xA0 与 Latin-1 不同
+	if got := decodePdfText([]byte{'a', 0x84, 0xA0, 0xE9}); got != "a—€é" {
+		t.Errorf("PDFDocEncoding 解码 = %q", got)
+	}
+
+	l := &pdfLexer{buf: []byte(`<< /A (x\(y\)\101\
+z) /B <48 65 6C6C 6F> /C [1 0 R 2.5 /N#20a] >>`), eof: true}
+	obj, err := l.object()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := obj.(pdfDict)
+	if string(d["A"].(pdfString)) != "x(y)Az" || string(d["B"].(pdfString)) != "Hello" {
+		t.Errorf("字符串解析结果 = %q, %q", d["A"], d["B"])
+	}
+	arr := d["C"].(pdfArray)
+	if arr[0] != (pdfRef{Num: 1}) || arr[1] != 2.5 || arr[2] != pdfName("N a") {
+		t.Errorf("数组解析结果 = %#v", arr)
+	}
+}
+
+func TestUpdatePdfMetadata_XrefOrder(t *testing.T) {
+	// 没有 /Info 时新建的 /Info 编号大于 XMP，交叉引用的子段仍应按编号升序排列
+	for _, layout := range []testPdf{{}, {xrefStream: true}, {hybrid: true}} {
+		t.Run(fmt.Sprintf("xrefStream=%v,hybrid=%v", layout.xrefStream, layout.hybrid), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "book.pdf")
+			layout.xmpTitle, layout.noInfo = "三体", true
+			orig := layout.build()
+			os.WriteFile(path, orig, 0644)
+
+			title := "三体：黑暗森林"
+			if err := UpdatePdfMetadata(path, &MetadataUpdate{Title: &title}); err != nil {
+				t.Fatalf("UpdatePdfMetadata 失败: %v", err)
+			}
+			if meta, err := ReadPdfMetadata(path); err != nil || meta.Title != title {
+				t.Fatalf("写入后书名 = %+v, %v", meta, err)
+			}
+
+			var nums []int64
+			if layout.xrefStream {
+				doc, f, err := OpenPdf(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				index, _ := doc.Trailer["Index"].(pdfArray)
+				for i := 0; i < len(index); i += 2 {
+					n, _ := pdfInt(index[i])
+					nums = append(nums, n)
+				}
+			} else {
+				data, _ := os.ReadFile(path)
+				for _, m := range regexp.MustCompile(`(?m)^(\d+) 1\n\d{10} `).FindAllSubmatch(data[len(orig):], -1) {
+					n, _ := strconv.ParseInt(string(m[1]), 10, 64)
+					nums = append(nums, n)
+				}
+			}
+			if len(nums) < 2 || !slices.IsSorted(nums) {
+				t.Errorf("子段的对象编号 = %v，期望升序", nums)
+			}
+		})
+	}
+}
@@ -0,0 +1,724 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pdfTailSize 在文件末尾的这段范围内查找 startxref 和 %%EOF
+	pdfTailSize = 2048
+	// pdfMaxObjectSize 单个对象（不含流数据）的最大长度
+	pdfMaxObjectSize = 16 << 20
+)
+
+// pdfXrefEntry 交叉引用表中的一项
+// Type 为 1 时 Offset 是对象在文件中的位置；为 2 时 Offset 是对象流的编号，Index 是对象在流中的序号
+type pdfXrefEntry struct {
+	Type   byte
+	Offset int64
+	Gen    int
+	Index  int
+}
+
+// PdfDocument 解析后的 PDF 文件结构：文件头、交叉引用表和 trailer
+// 对象按需读取，不会把整个文件读入内存
+type PdfDocument struct {
+	Version    string  // 文件头中的版本，如 "1.7"
+	Trailer    pdfDict // 最新的 trailer（或交叉引用流的字典）
+	StartXref  int64   // 最后一个 startxref 指向的位置
+	XrefStream bool    // 最新的交叉引用段是否为交叉引用流
+	Linearized pdfDict // 线性化参数字典，不是线性化文件时为 nil
+
+	r       io.ReaderAt
+	size    int64
+	xref    map[int]pdfXrefEntry
+	objStms map[int]*pdfObjStm
+	// tableFree 最近读取的交叉引用表中新增的空闲条目，同一段的 /XRefStm 可以覆盖
+	tableFree []int
+}
+
+// pdfObjStm 解压后的对象流
+type pdfObjStm struct {
+	data    []byte
+	offsets []int // 第 i 个对象相对 data 的位置
+	nums    []int
+}
+
+// OpenPdf 打开并解析 PDF 文件，调用方需关闭返回的文件
+func OpenPdf(filePath string) (*PdfDocument, *os.File, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法打开文件", Detail: err.Error()}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法打开文件", Detail: err.Error()}
+	}
+	doc, err := ParsePdf(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return doc, f, nil
+}
+
+// ParsePdf 检查文件头和 %%EOF，从 startxref 开始沿 /Prev 读取所有交叉引用段，并检查线性化参数
+// 结构问题返回 EpubError：缺少文件头为 ErrorTypeFormat，截断或交叉引用损坏为 ErrorTypeCorrupted
+func ParsePdf(r io.ReaderAt, size int64) (*PdfDocument, error) {
+	doc := &PdfDocument{r: r, size: size, xref: make(map[int]pdfXrefEntry), objStms: make(map[int]*pdfObjStm)}
+
+	head := make([]byte, min(size, 1024))
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法读取文件", Detail: err.Error()}
+	}
+	i := bytes.Index(head, []byte("%PDF-"))
+	if i < 0 {
+		return nil, &EpubError{Type: ErrorTypeFormat, Message: "不是 PDF 文件", Detail: "文件开头缺少 %PDF- 标识"}
+	}
+	version := head[i+5:]
+	if j := bytes.IndexAny(version, "\r\n \t%"); j >= 0 {
+		version = version[:j]
+	}
+	doc.Version = string(version)
+
+	tailStart := max(0, size-pdfTailSize)
+	tail := make([]byte, size-tailStart)
+	if _, err := r.ReadAt(tail, tailStart); err != nil && err != io.EOF {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "无法读取文件", Detail: err.Error()}
+	}
+	eof := bytes.LastIndex(tail, []byte("%%EOF"))
+	if eof < 0 {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "文件被截断", Detail: "文件末尾缺少 %%EOF"}
+	}
+	sx := bytes.LastIndex(tail[:eof], []byte("startxref"))
+	if sx < 0 {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: "文件末尾缺少 startxref"}
+	}
+	l := &pdfLexer{buf: tail[sx+len("startxref") : eof], eof: true}
+	tok, err := l.token()
+	offset, ok := tok.(int64)
+	if err != nil || !ok || offset <= 0 || offset >= size {
+		return nil, &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: fmt.Sprintf("startxref 指向无效的位置 %v（文件 %d 字节）", tok, size)}
+	}
+	doc.StartXref = offset
+
+	if err := doc.readXrefChain(offset); err != nil {
+		return nil, err
+	}
+	if err := doc.checkLinearized(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// readXrefChain 从最新的交叉引用段开始沿 /Prev 读取，较新段中的条目优先
+func (d *PdfDocument) readXrefChain(offset int64) error {
+	visited := make(map[int64]bool)
+	for first := true; ; first = false {
+		if visited[offset] {
+			return &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: fmt.Sprintf("/Prev 在偏移 %d 处形成循环", offset)}
+		}
+		visited[offset] = true
+
+		trailer, isStream, err := d.readXrefSection(offset)
+		if err != nil {
+			return &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: fmt.Sprintf("偏移 %d: %v", offset, err)}
+		}
+		if first {
+			d.Trailer, d.XrefStream = trailer, isStream
+		}
+		// 混合引用文件：传统交叉引用表之外的对象在 /XRefStm 指向的交叉引用流中
+		// 对象流中的对象在传统表中列为空闲，先移除这些条目，让交叉引用流中的条目生效
+		if stm, ok := pdfInt(trailer["XRefStm"]); ok && !isStream {
+			free := make(map[int]pdfXrefEntry, len(d.tableFree))
+			for _, num := range d.tableFree {
+				free[num] = d.xref[num]
+				delete(d.xref, num)
+			}
+			if _, _, err := d.readXrefSection(stm); err != nil {
+				return &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: fmt.Sprintf("/XRefStm 偏移 %d: %v", stm, err)}
+			}
+			for num, entry := range free {
+				if _, ok := d.xref[num]; !ok {
+					d.xref[num] = entry
+				}
+			}
+		}
+
+		prev, ok := pdfInt(trailer["Prev"])
+		if !ok {
+			break
+		}
+		if prev <= 0 || prev >= d.size {
+			return &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: fmt.Sprintf("/Prev 指向无效的位置 %d", prev)}
+		}
+		offset = prev
+	}
+	if _, ok := d.Trailer["Root"].(pdfRef); !ok {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "交叉引用表损坏", Detail: "trailer 中缺少 /Root"}
+	}
+	return nil
+}
+
+// readXrefSection 读取一个交叉引用段，返回其 trailer 字典
+func (d *PdfDocument) readXrefSection(offset int64) (pdfDict, bool, error) {
+	var trailer pdfDict
+	var isStream bool
+	err := d.parseAt(offset, func(l *pdfLexer) error {
+		save := l.pos
+		tok, err := l.token()
+		if err != nil {
+			return err
+		}
+		if tok == pdfKeyword("xref") {
+			trailer, err = d.parseXrefTable(l)
+			return err
+		}
+		l.pos = save
+		_, obj, err := l.indirectObject()
+		if err != nil {
+			return err
+		}
+		stream, ok := obj.(pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("XRef") {
+			return errors.New("既不是交叉引用表也不是交叉引用流")
+		}
+		stream.Offset += offset
+		trailer, isStream = stream.Dict, true
+		return d.parseXrefStream(stream)
+	})
+	return trailer, isStream, err
+}
+
+// parseXrefTable 解析 "xref" 之后的子段和 trailer
+func (d *PdfDocument) parseXrefTable(l *pdfLexer) (pdfDict, error) {
+	d.tableFree = d.tableFree[:0]
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == pdfKeyword("trailer") {
+			obj, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(pdfDict)
+			if !ok {
+				return nil, errors.New("trailer 不是字典")
+			}
+			return trailer, nil
+		}
+		start, ok := tok.(int64)
+		if !ok {
+			return nil, fmt.Errorf("意外的内容 %v", tok)
+		}
+		countTok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		count, ok := countTok.(int64)
+		if !ok || count < 0 {
+			return nil, errors.New("子段的条目数无效")
+		}
+		for i := int64(0); i < count; i++ {
+			var fields [3]any
+			for j := range fields {
+				if fields[j], err = l.token(); err != nil {
+					return nil, err
+				}
+			}
+			off, ok1 := fields[0].(int64)
+			gen, ok2 := fields[1].(int64)
+			if !ok1 || !ok2 || (fields[2] != pdfKeyword("n") && fields[2] != pdfKeyword("f")) {
+				return nil, fmt.Errorf("对象 %d 的条目格式错误", start+i)
+			}
+			num := int(start + i)
+			if _, seen := d.xref[num]; seen {
+				continue
+			}
+			if fields[2] == pdfKeyword("f") {
+				d.xref[num] = pdfXrefEntry{Type: 0, Gen: int(gen)}
+				d.tableFree = append(d.tableFree, num)
+			} else {
+				d.xref[num] = pdfXrefEntry{Type: 1, Offset: off, Gen: int(gen)}
+			}
+		}
+	}
+}
+
+// parseXrefStream 解析交叉引用流中的条目
+func (d *PdfDocument) parseXrefStream(stream pdfStream) error {
+	data, err := d.streamData(stream)
+	if err != nil {
+		return err
+	}
+	w, ok := stream.Dict["W"].(pdfArray)
+	if !ok || len(w) != 3 {
+		return errors.New("交叉引用流缺少 /W")
+	}
+	var widths [3]int
+	rowLen := 0
+	for i := range widths {
+		n, ok := pdfInt(w[i])
+		if !ok || n < 0 || n > 8 {
+			return errors.New("交叉引用流的 /W 无效")
+		}
+		widths[i] = int(n)
+		rowLen += int(n)
+	}
+	size, _ := pdfInt(stream.Dict["Size"])
+	index := pdfArray{int64(0), size}
+	if idx, ok := stream.Dict["Index"].(pdfArray); ok {
+		index = idx
+	}
+	if rowLen == 0 || len(index)%2 != 0 {
+		return errors.New("交叉引用流的 /Index 无效")
+	}
+
+	field := func(row []byte, i, def int) int64 {
+		if widths[i] == 0 {
+			return int64(def)
+		}
+		start := 0
+		for j := 0; j < i; j++ {
+			start += widths[j]
+		}
+		var v int64
+		for _, b := range row[start : start+widths[i]] {
+			v = v<<8 | int64(b)
+		}
+		return v
+	}
+
+	pos := 0
+	for i := 0; i < len(index); i += 2 {
+		start, ok1 := pdfInt(index[i])
+		count, ok2 := pdfInt(index[i+1])
+		if !ok1 || !ok2 || count < 0 {
+			return errors.New("交叉引用流的 /Index 无效")
+		}
+		for j := int64(0); j < count; j++ {
+			if pos+rowLen > len(data) {
+				return errors.New("交叉引用流的数据不完整")
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			num := int(start + j)
+			if _, seen := d.xref[num]; seen {
+				continue
+			}
+			switch typ := field(row, 0, 1); typ {
+			case 0:
+				d.xref[num] = pdfXrefEntry{Type: 0, Gen: int(field(row, 2, 0))}
+			case 1:
+				d.xref[num] = pdfXrefEntry{Type: 1, Offset: field(row, 1, 0), Gen: int(field(row, 2, 0))}
+			case 2:
+				d.xref[num] = pdfXrefEntry{Type: 2, Offset: field(row, 1, 0), Index: int(field(row, 2, 0))}
+			}
+		}
+	}
+	return nil
+}
+
+// checkLinearized 检查线性化参数是否与文件一致
+// 增量更新后 /L 小于文件大小是正常的；/L 或提示表超出文件末尾说明文件被截断
+func (d *PdfDocument) checkLinearized() error {
+	var lin pdfDict
+	d.parseAt(0, func(l *pdfLexer) error {
+		_, obj, err := l.indirectObject()
+		if err != nil {
+			return err
+		}
+		if dict, ok := obj.(pdfDict); ok && dict["Linearized"] != nil {
+			lin = dict
+		} else if stream, ok := obj.(pdfStream); ok && stream.Dict["Linearized"] != nil {
+			lin = stream.Dict
+		}
+		return nil
+	})
+	if lin == nil {
+		return nil
+	}
+	d.Linearized = lin
+
+	truncated := func(detail string) error {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "线性化文件被截断", Detail: detail}
+	}
+	if length, ok := pdfInt(lin["L"]); !ok {
+		return &EpubError{Type: ErrorTypeFormat, Message: "线性化参数损坏", Detail: "缺少 /L"}
+	} else if length > d.size {
+		return truncated(fmt.Sprintf("/L 声明 %d 字节，文件只有 %d 字节", length, d.size))
+	}
+	for _, key := range []pdfName{"E", "T"} {
+		if v, ok := pdfInt(lin[key]); ok && v > d.size {
+			return truncated(fmt.Sprintf("/%s 指向 %d，超出文件末尾", key, v))
+		}
+	}
+	if h, ok := lin["H"].(pdfArray); ok {
+		for i := 0; i+1 < len(h); i += 2 {
+			off, _ := pdfInt(h[i])
+			n, _ := pdfInt(h[i+1])
+			if off+n > d.size {
+				return truncated(fmt.Sprintf("提示表 %d+%d 超出文件末尾", off, n))
+			}
+		}
+	}
+	return nil
+}
+
+// parseAt 从 offset 开始读取数据并调用 parse，数据不够时加倍读取后重试
+func (d *PdfDocument) parseAt(offset int64, parse func(l *pdfLexer) error) error {
+	if offset < 0 || offset >= d.size {
+		return fmt.Errorf("偏移 %d 超出文件范围", offset)
+	}
+	for n := int64(4096); ; n *= 2 {
+		n = min(n, d.size-offset)
+		buf := make([]byte, n)
+		if _, err := d.r.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return err
+		}
+		l := &pdfLexer{buf: buf, eof: offset+n == d.size}
+		err := parse(l)
+		if !errors.Is(err, errPdfShort) || l.eof {
+			return err
+		}
+		if n >= pdfMaxObjectSize {
+			return fmt.Errorf("对象超过 %d 字节", pdfMaxObjectSize)
+		}
+	}
+}
+
+// Object 读取编号为 num 的对象，不存在或已删除的对象返回 nil
+func (d *PdfDocument) Object(num int) (any, error) {
+	entry, ok := d.xref[num]
+	if !ok {
+		return nil, nil
+	}
+	switch entry.Type {
+	case 1:
+		var obj any
+		err := d.parseAt(entry.Offset, func(l *pdfLexer) error {
+			ref, o, err := l.indirectObject()
+			if err != nil {
+				return err
+			}
+			if ref.Num != num {
+				return fmt.Errorf("偏移处是对象 %d", ref.Num)
+			}
+			if s, ok := o.(pdfStream); ok {
+				s.Offset += entry.Offset
+				o = s
+			}
+			obj = o
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("无法读取对象 %d（偏移 %d）: %w", num, entry.Offset, err)
+		}
+		return obj, nil
+	case 2:
+		stm, err := d.objStm(int(entry.Offset))
+		if err != nil {
+			return nil, fmt.Errorf("无法读取对象 %d: %w", num, err)
+		}
+		if entry.Index >= len(stm.offsets) || stm.nums[entry.Index] != num {
+			return nil, fmt.Errorf("对象流 %d 中没有对象 %d", entry.Offset, num)
+		}
+		l := &pdfLexer{buf: stm.data, pos: stm.offsets[entry.Index], eof: true}
+		return l.object()
+	}
+	return nil, nil
+}
+
+// Resolve 解析间接引用，其他对象原样返回
+func (d *PdfDocument) Resolve(v any) (any, error) {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v, nil
+		}
+		var err error
+		if v, err = d.Object(ref.Num); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("间接引用嵌套过深")
+}
+
+// objStm 读取并缓存对象流
+func (d *PdfDocument) objStm(num int) (*pdfObjStm, error) {
+	if stm, ok := d.objStms[num]; ok {
+		return stm, nil
+	}
+	if entry := d.xref[num]; entry.Type != 1 {
+		return nil, fmt.Errorf("对象流 %d 不存在", num)
+	}
+	obj, err := d.Object(num)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok {
+		return nil, fmt.Errorf("对象 %d 不是对象流", num)
+	}
+	data, err := d.streamData(stream)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := pdfInt(stream.Dict["N"])
+	first, _ := pdfInt(stream.Dict["First"])
+	if n < 0 || first < 0 || first > int64(len(data)) {
+		return nil, fmt.Errorf("对象流 %d 的 /N 或 /First 无效", num)
+	}
+
+	stm := &pdfObjStm{data: data}
+	l := &pdfLexer{buf: data[:first], eof: true}
+	for i := int64(0); i < n; i++ {
+		numTok, err1 := l.token()
+		offTok, err2 := l.token()
+		objNum, ok1 := numTok.(int64)
+		off, ok2 := offTok.(int64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 || first+off > int64(len(data)) {
+			return nil, fmt.Errorf("对象流 %d 的索引损坏", num)
+		}
+		stm.nums = append(stm.nums, int(objNum))
+		stm.offsets = append(stm.offsets, int(first+off))
+	}
+	d.objStms[num] = stm
+	return stm, nil
+}
+
+// streamData 读取并解码流数据，支持不压缩和 FlateDecode（含 PNG 预测器）
+func (d *PdfDocument) streamData(s pdfStream) ([]byte, error) {
+	lengthObj, err := d.Resolve(s.Dict["Length"])
+	if err != nil {
+		return nil, err
+	}
+	length, ok := pdfInt(lengthObj)
+	if !ok || length < 0 {
+		return nil, errors.New("流的 /Length 无效")
+	}
+	if s.Offset+length > d.size {
+		return nil, fmt.Errorf("流数据超出文件末尾（%d+%d > %d）", s.Offset, length, d.size)
+	}
+	data := make([]byte, length)
+	if _, err := d.r.ReadAt(data, s.Offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	filters := pdfArray{}
+	switch f := s.Dict["Filter"].(type) {
+	case pdfName:
+		filters = pdfArray{f}
+	case pdfArray:
+		filters = f
+	}
+	params := pdfArray{}
+	switch p := s.Dict["DecodeParms"].(type) {
+	case pdfDict:
+		params = pdfArray{p}
+	case pdfArray:
+		params = p
+	}
+	for i, f := range filters {
+		if f != pdfName("FlateDecode") {
+			return nil, fmt.Errorf("不支持的流过滤器 %v", f)
+		}
+		if data, err = inflatePdf(data); err != nil {
+			return nil, err
+		}
+		if i < len(params) {
+			if p, ok := params[i].(pdfDict); ok {
+				if data, err = pdfPredictor(data, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return data, nil
+}
+
+func inflatePdf(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("FlateDecode 失败: %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("FlateDecode 失败: %w", err)
+	}
+	return out, nil
+}
+
+// pdfPredictor 还原 PNG 预测器（/Predictor 10-15）处理过的数据
+func pdfPredictor(data []byte, params pdfDict) ([]byte, error) {
+	predictor, _ := pdfInt(params["Predictor"])
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("不支持的预测器 %d", predictor)
+		}
+		return data, nil
+	}
+	columns, ok := pdfInt(params["Columns"])
+	if !ok {
+		columns = 1
+	}
+	colors, ok := pdfInt(params["Colors"])
+	if !ok {
+		colors = 1
+	}
+	bpc, ok := pdfInt(params["BitsPerComponent"])
+	if !ok {
+		bpc = 8
+	}
+	bpp := int(max(1, colors*bpc/8))
+	rowLen := int((columns*colors*bpc + 7) / 8)
+	if rowLen <= 0 {
+		return nil, errors.New("预测器参数无效")
+	}
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+rowLen+1 <= len(data); pos += rowLen + 1 {
+		filter, row := data[pos], append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("未知的 PNG 过滤类型 %d", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Catalog 返回文档目录（/Root）
+func (d *PdfDocument) Catalog() (pdfDict, error) {
+	obj, err := d.Resolve(d.Trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := obj.(pdfDict)
+	if !ok {
+		return nil, errors.New("/Root 不是字典")
+	}
+	return catalog, nil
+}
+
+// Encrypted 判断文档是否加密
+func (d *PdfDocument) Encrypted() bool {
+	return d.Trailer["Encrypt"] != nil
+}
+
+// PdfValidator PDF 检测器
+// 默认检查文件头、%%EOF、startxref、交叉引用表和 /Prev 链、线性化参数、文档目录和页面树；
+// Deep 为 true 时逐个检查交叉引用表中的对象能否读取
+type PdfValidator struct {
+	Deep bool
+}
+
+// Profile 返回检测配置的标识
+func (v *PdfValidator) Profile() string {
+	if v.Deep {
+		return "pdf-deep"
+	}
+	return "pdf"
+}
+
+// Validate 检测 PDF 文件，返回 nil 表示文件正常，返回 EpubError 表示检测到问题
+func (v *PdfValidator) Validate(filePath string) error {
+	doc, f, err := OpenPdf(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	catalog, err := doc.Catalog()
+	if err != nil {
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "无法读取文档目录", Detail: err.Error()}
+	}
+	pages, err := doc.Resolve(catalog["Pages"])
+	if _, ok := pages.(pdfDict); err != nil || !ok {
+		detail := "/Pages 不是字典"
+		if err != nil {
+			detail = err.Error()
+		}
+		return &EpubError{Type: ErrorTypeCorrupted, Message: "无法读取页面树", Detail: detail}
+	}
+
+	if v.Deep {
+		return doc.checkObjects()
+	}
+	return nil
+}
+
+// checkObjects 读取交叉引用表中的每个对象，列出无法读取的对象
+func (d *PdfDocument) checkObjects() error {
+	var bad []int
+	for num, entry := range d.xref {
+		if entry.Type == 0 {
+			continue
+		}
+		if _, err := d.Object(num); err != nil {
+			bad = append(bad, num)
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	slices.Sort(bad)
+	var names []string
+	for _, num := range bad[:min(len(bad), 20)] {
+		names = append(names, strconv.Itoa(num))
+	}
+	detail := "无法读取对象 " + strings.Join(names, ", ")
+	if len(bad) > 20 {
+		detail += fmt.Sprintf(" 等 %d 个", len(bad))
+	}
+	return &EpubError{Type: ErrorTypeCorrupted, Message: "对象损坏", Detail: detail}
+}
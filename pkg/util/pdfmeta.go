@@ -0,0 +1,326 @@
+package util
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	xmpTitleRe = regexp.MustCompile(`(?s)<dc:title\b[^>]*>.*?</dc:title>`)
+	xmpLiRe    = regexp.MustCompile(`(?s)(<rdf:li\b[^>]*>)(.*?)(</rdf:li>)`)
+)
+
+// Info 返回文档信息字典（trailer 中的 /Info），没有时返回 nil
+func (d *PdfDocument) Info() (pdfDict, error) {
+	if d.Trailer["Info"] == nil {
+		return nil, nil
+	}
+	obj, err := d.Resolve(d.Trailer["Info"])
+	if err != nil {
+		return nil, err
+	}
+	info, _ := obj.(pdfDict)
+	return info, nil
+}
+
+// XMP 返回文档目录中 /Metadata 指向的 XMP 数据，没有时返回 nil
+func (d *PdfDocument) XMP() ([]byte, error) {
+	catalog, err := d.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := d.Resolve(catalog["Metadata"])
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok {
+		return nil, errors.New("/Metadata 不是流")
+	}
+	return d.streamData(stream)
+}
+
+// Title 返回书名，依次取 /Info 的 /Title 和 XMP 的 dc:title
+func (d *PdfDocument) Title() (string, error) {
+	info, err := d.Info()
+	if err != nil {
+		return "", err
+	}
+	if s, ok := info["Title"].(pdfString); ok {
+		if title := strings.TrimSpace(decodePdfText(s)); title != "" {
+			return title, nil
+		}
+	}
+	xmp, err := d.XMP()
+	if err != nil || xmp == nil {
+		return "", nil
+	}
+	return xmpTitle(xmp), nil
+}
+
+// xmpTitle 返回 XMP 中 dc:title 的第一个 rdf:li
+func xmpTitle(xmp []byte) string {
+	block := xmpTitleRe.Find(xmp)
+	if block == nil {
+		return ""
+	}
+	m := xmpLiRe.FindSubmatch(block)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSpace(html.UnescapeString(string(m[2])))
+}
+
+// setXmpTitle 将 XMP 中 dc:title 的所有 rdf:li 替换为 title，没有 dc:title 时返回 false
+func setXmpTitle(xmp []byte, title string) ([]byte, bool) {
+	loc := xmpTitleRe.FindIndex(xmp)
+	if loc == nil {
+		return nil, false
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(title))
+	block := xmpLiRe.ReplaceAllFunc(xmp[loc[0]:loc[1]], func(li []byte) []byte {
+		m := xmpLiRe.FindSubmatch(li)
+		return bytes.Join([][]byte{m[1], escaped.Bytes(), m[3]}, nil)
+	})
+
+	out := make([]byte, 0, len(xmp)+len(block))
+	out = append(out, xmp[:loc[0]]...)
+	out = append(out, block...)
+	out = append(out, xmp[loc[1]:]...)
+	return out, true
+}
+
+// ReadPdfMetadata 读取 PDF 的书名
+// 加密的文件无法读取文档信息，返回错误
+func ReadPdfMetadata(filePath string) (*EpubMetadata, error) {
+	doc, f, err := OpenPdf(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 PDF 元数据: %w", err)
+	}
+	defer f.Close()
+	if doc.Encrypted() {
+		return nil, errors.New("无法读取 PDF 元数据: 文件已加密")
+	}
+	title, err := doc.Title()
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 PDF 元数据: %w", err)
+	}
+	return &EpubMetadata{Title: title}, nil
+}
+
+// UpdatePdfMetadata 通过增量更新修改 PDF 的书名
+// 在文件末尾追加新的 /Info 字典、XMP 元数据流（有 dc:title 时）和交叉引用段，原有内容保持不变；
+// 最新的交叉引用段为交叉引用流时同样写入交叉引用流。只支持修改书名，加密的文件不支持修改
+func UpdatePdfMetadata(filePath string, update *MetadataUpdate) error {
+	if update.Creators != nil || update.Publisher != nil || update.Subjects != nil || update.Series != nil {
+		return errors.New("PDF 只支持修改书名")
+	}
+	if update.Title == nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	doc, err := ParsePdf(f, size)
+	if err != nil {
+		return err
+	}
+	if doc.Encrypted() {
+		return errors.New("加密的 PDF 不支持修改元数据")
+	}
+
+	data, err := doc.titleUpdate(*update.Title, size)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(data, size); err != nil {
+		f.Truncate(size)
+		return fmt.Errorf("写入增量更新失败: %w", err)
+	}
+
+	// 重新解析，确认书名已更新；失败时删除追加的内容
+	check, err := ParsePdf(f, size+int64(len(data)))
+	if err == nil {
+		var title string
+		if title, err = check.Title(); err == nil && title != strings.TrimSpace(*update.Title) {
+			err = fmt.Errorf("写入后读取的书名为 %q", title)
+		}
+	}
+	if err != nil {
+		f.Truncate(size)
+		return fmt.Errorf("增量更新校验失败: %w", err)
+	}
+	return f.Sync()
+}
+
+// titleUpdate 生成修改书名的增量更新数据，base 为原文件大小
+func (d *PdfDocument) titleUpdate(title string, base int64) ([]byte, error) {
+	nextNum, _ := pdfInt(d.Trailer["Size"])
+	newObject := func() pdfRef {
+		ref := pdfRef{Num: int(nextNum)}
+		nextNum++
+		return ref
+	}
+
+	// 新的 /Info 字典，保留原有的其他条目
+	infoRef, ok := d.Trailer["Info"].(pdfRef)
+	if ok {
+		infoRef.Gen = d.xref[infoRef.Num].Gen
+	} else {
+		infoRef = newObject()
+	}
+	oldInfo, err := d.Info()
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文档信息: %w", err)
+	}
+	info := pdfDict{}
+	for k, v := range oldInfo {
+		info[k] = v
+	}
+	info["Title"] = encodePdfText(title)
+
+	type object struct {
+		ref  pdfRef
+		body []byte
+	}
+	objects := []object{{ref: infoRef}}
+	var body bytes.Buffer
+	writePdfObject(&body, info)
+	objects[0].body = append([]byte(nil), body.Bytes()...)
+
+	// XMP 中有 dc:title 时一并修改，写为不压缩的流
+	if xmpRef, xmpStream, xmp := d.xmpObject(); xmp != nil {
+		if updated, ok := setXmpTitle(xmp, title); ok {
+			dict := pdfDict{}
+			for k, v := range xmpStream.Dict {
+				dict[k] = v
+			}
+			delete(dict, "Filter")
+			delete(dict, "DecodeParms")
+			dict["Length"] = int64(len(updated))
+			body.Reset()
+			writePdfObject(&body, dict)
+			body.WriteString("\nstream\n")
+			body.Write(updated)
+			body.WriteString("\nendstream")
+			objects = append(objects, object{ref: xmpRef, body: append([]byte(nil), body.Bytes()...)})
+		}
+	}
+
+	var out bytes.Buffer
+	offset := func() int64 { return base + int64(out.Len()) }
+	out.WriteString("\n")
+	offsets := make([]int64, len(objects))
+	for i, obj := range objects {
+		offsets[i] = offset()
+		fmt.Fprintf(&out, "%d %d obj\n", obj.ref.Num, obj.ref.Gen)
+		out.Write(obj.body)
+		out.WriteString("\nendobj\n")
+	}
+
+	trailer := pdfDict{
+		"Root": d.Trailer["Root"],
+		"Info": pdfRef{Num: infoRef.Num, Gen: infoRef.Gen},
+		"Prev": d.StartXref,
+	}
+	if id, ok := d.Trailer["ID"]; ok {
+		trailer["ID"] = id
+	}
+
+	// 交叉引用的子段必须按对象编号升序排列
+	type xrefRow struct {
+		ref    pdfRef
+		offset int64
+	}
+	rows := make([]xrefRow, len(objects))
+	for i, obj := range objects {
+		rows[i] = xrefRow{obj.ref, offsets[i]}
+	}
+	sortRows := func() {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].ref.Num < rows[j].ref.Num })
+	}
+
+	xrefOffset := offset()
+	if d.XrefStream {
+		// 交叉引用流本身也是一个新对象
+		xrefRef := newObject()
+		trailer["Type"] = pdfName("XRef")
+		trailer["Size"] = nextNum
+		trailer["W"] = pdfArray{int64(1), int64(8), int64(2)}
+		rows = append(rows, xrefRow{xrefRef, xrefOffset})
+		sortRows()
+		index := pdfArray{}
+		var data bytes.Buffer
+		for _, row := range rows {
+			index = append(index, int64(row.ref.Num), int64(1))
+			data.WriteByte(1)
+			for shift := 56; shift >= 0; shift -= 8 {
+				data.WriteByte(byte(row.offset >> shift))
+			}
+			data.WriteByte(byte(row.ref.Gen >> 8))
+			data.WriteByte(byte(row.ref.Gen))
+		}
+		trailer["Index"] = index
+		trailer["Length"] = int64(data.Len())
+
+		fmt.Fprintf(&out, "%d 0 obj\n", xrefRef.Num)
+		writePdfObject(&out, trailer)
+		out.WriteString("\nstream\n")
+		out.Write(data.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	} else {
+		trailer["Size"] = nextNum
+		sortRows()
+		out.WriteString("xref\n")
+		for _, row := range rows {
+			fmt.Fprintf(&out, "%d 1\n%010d %05d n\r\n", row.ref.Num, row.offset, row.ref.Gen)
+		}
+		out.WriteString("trailer\n")
+		writePdfObject(&out, trailer)
+		out.WriteString("\n")
+	}
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes(), nil
+}
+
+// xmpObject 返回 XMP 元数据流的引用、流和解码后的数据，没有或无法解码时 data 为 nil
+func (d *PdfDocument) xmpObject() (pdfRef, pdfStream, []byte) {
+	catalog, err := d.Catalog()
+	if err != nil {
+		return pdfRef{}, pdfStream{}, nil
+	}
+	ref, ok := catalog["Metadata"].(pdfRef)
+	if !ok {
+		return pdfRef{}, pdfStream{}, nil
+	}
+	obj, err := d.Object(ref.Num)
+	if err != nil {
+		return pdfRef{}, pdfStream{}, nil
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok {
+		return pdfRef{}, pdfStream{}, nil
+	}
+	data, err := d.streamData(stream)
+	if err != nil {
+		return pdfRef{}, pdfStream{}, nil
+	}
+	ref.Gen = d.xref[ref.Num].Gen
+	return ref, stream, data
+}
@@ -0,0 +1,48 @@
+package util
+
+// RunOrdered 使用 jobs 个 goroutine 并发执行 work(0..count-1)，
+// 并在调用方 goroutine 中按输入顺序依次回调 emit。
+// 输出、用户确认等需要串行的操作应放在 emit 中完成。
+// 同时处于执行或等待输出状态的任务数不超过 jobs*2，避免结果堆积占用内存。
+func RunOrdered[T any](count, jobs int, work func(i int) T, emit func(i int, result T)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs == 1 || count <= 1 {
+		for i := 0; i < count; i++ {
+			emit(i, work(i))
+		}
+		return
+	}
+
+	type task struct {
+		index int
+		ready chan T
+	}
+
+	// pending 按顺序保存已派发的任务，容量即并发窗口大小
+	pending := make(chan task, jobs*2)
+	tasks := make(chan task)
+
+	go func() {
+		for i := 0; i < count; i++ {
+			t := task{index: i, ready: make(chan T, 1)}
+			pending <- t
+			tasks <- t
+		}
+		close(tasks)
+		close(pending)
+	}()
+
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for t := range tasks {
+				t.ready <- work(t.index)
+			}
+		}()
+	}
+
+	for t := range pending {
+		emit(t.index, <-t.ready)
+	}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunOrdered(t *testing.T) {
+	for _, jobs := range []int{0, 1, 4, 16} {
+		count := 50
+		var running, maxRunning int32
+
+		var got []int
+		RunOrdered(count, jobs, func(i int) int {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			// 让靠前的任务更晚完成，验证输出仍然有序
+			time.Sleep(time.Duration(count-i) * 50 * time.Microsecond)
+			atomic.AddInt32(&running, -1)
+			return i * i
+		}, func(i int, result int) {
+			if result != i*i {
+				t.Errorf("jobs=%d: 第 %d 个结果 = %d，期望 %d", jobs, i, result, i*i)
+			}
+			got = append(got, i)
+		})
+
+		if len(got) != count {
+			t.Fatalf("jobs=%d: 回调次数 = %d，期望 %d", jobs, len(got), count)
+		}
+		for i, v := range got {
+			if v != i {
+				t.Fatalf("jobs=%d: 输出顺序错误，第 %d 个为 %d", jobs, i, v)
+			}
+		}
+
+		limit := int32(jobs)
+		if limit < 1 {
+			limit = 1
+		}
+		if maxRunning > limit {
+			t.Errorf("jobs=%d: 最大并发数 = %d，超过限制", jobs, maxRunning)
+		}
+	}
+}
+
+func TestRunOrdered_Empty(t *testing.T) {
+	called := false
+	RunOrdered(0, 4, func(i int) int {
+		called = true
+		return i
+	}, func(i int, result int) {
+		called = true
+	})
+	if called {
+		t.Error("count 为 0 时不应调用 work 或 emit")
+	}
+}
@@ -0,0 +1,264 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy 重命名目标冲突时的处理方式
+type ConflictPolicy string
+
+const (
+	ConflictSkip   ConflictPolicy = "skip"   // 跳过冲突的文件
+	ConflictSuffix ConflictPolicy = "suffix" // 添加 (1)、(2) 等序号后缀
+	ConflictFail   ConflictPolicy = "fail"   // 存在冲突时不执行任何重命名
+)
+
+// ErrRenameConflict 冲突处理方式为 ConflictFail 且存在冲突时返回
+var ErrRenameConflict = errors.New("重命名目标存在冲突")
+
+// RenameMove 一个文件的重命名，From → To
+type RenameMove struct {
+	From string
+	To   string
+}
+
+// RenameConflict 计划中的一处冲突
+type RenameConflict struct {
+	RenameMove
+	Reason   string // 冲突原因
+	Resolved string // 添加序号后的目标路径，跳过时为空
+}
+
+// RenameStep 执行时的一步。环形重命名（如 a→b、b→a）中的一个文件会先移动到临时文件名，
+// 因此一个 RenameMove 可能对应两步；Final 为 true 表示这一步完成了 Moves[Move]
+type RenameStep struct {
+	From  string
+	To    string
+	Move  int
+	Final bool
+}
+
+// RenamePlan 一批重命名的执行计划
+// 执行前检查所有目标：与磁盘上不会被移走的文件重名、或与计划中其他文件的目标重名时按 ConflictPolicy 处理，
+// 并安排执行顺序，使每个文件都在目标位置腾空后才移动，环形重命名通过临时文件名完成，不会覆盖任何文件
+type RenamePlan struct {
+	Moves     []RenameMove     // 将要执行的重命名，已去除冲突跳过和无需改名的项
+	Conflicts []RenameConflict // 检测到的冲突
+	Unchanged int              // 目标与原路径相同的文件数
+	steps     []RenameStep
+}
+
+// PlanRenames 为 moves 生成执行计划
+// policy 为 ConflictFail 且存在冲突时返回的计划只包含 Conflicts，错误为 ErrRenameConflict
+func PlanRenames(moves []RenameMove, policy ConflictPolicy) (*RenamePlan, error) {
+	plan := &RenamePlan{}
+
+	type item struct {
+		RenameMove
+		src, dst string // 绝对路径，用于比较
+	}
+	var items []*item
+	for _, m := range moves {
+		src, err := filepath.Abs(m.From)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := filepath.Abs(m.To)
+		if err != nil {
+			return nil, err
+		}
+		if src == dst {
+			plan.Unchanged++
+			continue
+		}
+		items = append(items, &item{RenameMove: m, src: src, dst: dst})
+	}
+
+	// occupiedBy 判断 dst 被占用的原因：计划中较早的文件已使用该目标，或磁盘上已有不会被移走的文件
+	occupiedBy := func(it *item, claimed map[string]bool, sources map[string]bool) string {
+		if claimed[it.dst] {
+			return "与其他文件的目标路径相同"
+		}
+		if sources[it.dst] {
+			return ""
+		}
+		if info, err := os.Stat(it.dst); err == nil {
+			// 大小写不敏感的文件系统上只改变大小写时，目标就是文件本身
+			if srcInfo, err := os.Stat(it.src); err == nil && os.SameFile(info, srcInfo) {
+				return ""
+			}
+			return "目标文件已存在"
+		}
+		return ""
+	}
+
+	// 跳过一个文件后它的原路径不再腾空，可能使其他文件产生新的冲突，因此反复检查直到没有新的跳过
+	skipped := make(map[*item]string)
+	for {
+		sources := make(map[string]bool)
+		for _, it := range items {
+			if skipped[it] == "" {
+				sources[it.src] = true
+			}
+		}
+		claimed := make(map[string]bool)
+		plan.Conflicts = nil
+		newSkip := false
+		for _, it := range items {
+			if reason := skipped[it]; reason != "" {
+				plan.Conflicts = append(plan.Conflicts, RenameConflict{RenameMove: it.RenameMove, Reason: reason})
+				continue
+			}
+			reason := occupiedBy(it, claimed, sources)
+			if reason == "" {
+				claimed[it.dst] = true
+				continue
+			}
+
+			conflict := RenameConflict{RenameMove: it.RenameMove, Reason: reason}
+			if policy == ConflictSuffix {
+				to, err := suffixedPath(it.To, func(p string) bool {
+					abs, err := filepath.Abs(p)
+					return err != nil || claimed[abs] || sources[abs] || Exists(p)
+				})
+				if err != nil {
+					return nil, err
+				}
+				it.To = to
+				it.dst = filepath.Join(filepath.Dir(it.dst), filepath.Base(to))
+				claimed[it.dst] = true
+				conflict.Resolved = to
+			} else {
+				skipped[it] = reason
+				newSkip = true
+			}
+			plan.Conflicts = append(plan.Conflicts, conflict)
+		}
+		if !newSkip || policy != ConflictSkip {
+			break
+		}
+	}
+
+	if policy == ConflictFail && len(plan.Conflicts) > 0 {
+		return plan, fmt.Errorf("%w: %d 个文件", ErrRenameConflict, len(plan.Conflicts))
+	}
+
+	// 按目标位置腾空的顺序安排执行：目标仍是其他待移动文件的原路径时等待，
+	// 剩下的都在环中，把环中一个文件先移到临时文件名以打开环
+	var active []*item
+	pending := make(map[string]int) // 尚未移走的原路径 → 计划序号
+	targets := make(map[string]bool)
+	for _, it := range items {
+		if skipped[it] == "" {
+			pending[it.src] = len(active)
+			targets[it.dst] = true
+			active = append(active, it)
+			plan.Moves = append(plan.Moves, it.RenameMove)
+		}
+	}
+	from := make([]string, len(active)) // 每个文件当前所在的路径
+	done := make([]bool, len(active))
+	for i, it := range active {
+		from[i] = it.From
+	}
+	for remaining := len(active); remaining > 0; {
+		progressed := false
+		for i, it := range active {
+			if done[i] {
+				continue
+			}
+			if j, ok := pending[it.dst]; ok && j != i {
+				continue
+			}
+			plan.steps = append(plan.steps, RenameStep{From: from[i], To: it.To, Move: i, Final: true})
+			delete(pending, it.src)
+			done[i] = true
+			remaining--
+			progressed = true
+		}
+		if progressed {
+			continue
+		}
+
+		for i, it := range active {
+			if done[i] || from[i] != it.From {
+				continue
+			}
+			tmp, err := tempRenamePath(it.From, func(p string) bool {
+				abs, err := filepath.Abs(p)
+				if err != nil {
+					return true
+				}
+				_, isSource := pending[abs]
+				return isSource || targets[abs] || Exists(p)
+			})
+			if err != nil {
+				return nil, err
+			}
+			plan.steps = append(plan.steps, RenameStep{From: it.From, To: tmp, Move: i})
+			delete(pending, it.src)
+			from[i] = tmp
+			break
+		}
+	}
+	return plan, nil
+}
+
+// Steps 返回按执行顺序排列的每一步
+func (p *RenamePlan) Steps() []RenameStep {
+	return p.steps
+}
+
+// Execute 按顺序执行计划，每完成一步调用一次 onStep（可为 nil）
+// 某一步失败时立即停止并返回错误，已完成的步骤不回滚，可根据 onStep 的记录恢复
+func (p *RenamePlan) Execute(onStep func(step RenameStep)) error {
+	for _, step := range p.steps {
+		// 执行前再次确认目标未被占用，避免计划生成后出现的文件被覆盖
+		if Exists(step.To) && !sameFile(step.From, step.To) {
+			return fmt.Errorf("目标文件已存在: %s", step.To)
+		}
+		if err := MoveFile(step.From, step.To); err != nil {
+			return fmt.Errorf("重命名失败: %w", err)
+		}
+		if onStep != nil {
+			onStep(step)
+		}
+	}
+	return nil
+}
+
+// sameFile 判断两个路径是否指向同一文件
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// suffixedPath 为 path 添加 (1)、(2) 等序号后缀，返回第一个 taken 为 false 的路径
+func suffixedPath(path string, taken func(string) bool) (string, error) {
+	ext := FileExt(path)
+	stem := strings.TrimSuffix(path, ext)
+	for i := 1; i < 10000; i++ {
+		candidate := fmt.Sprintf("%s(%d)%s", stem, i, ext)
+		if !taken(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("无法为 %s 找到可用的文件名（尝试了 10000 次）", path)
+}
+
+// tempRenamePath 返回与 path 同目录的临时文件名，用于打开环形重命名
+func tempRenamePath(path string, taken func(string) bool) (string, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; i < 10000; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf(".%s.renaming-%d", base, i))
+		if !taken(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("无法为 %s 找到临时文件名", path)
+}
@@ -0,0 +1,142 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRenameFiles 在 dir 中创建以文件名为内容的文件
+func writeRenameFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkRenameFiles 检查 dir 中的文件内容与 want（文件名 → 内容）完全一致
+func checkRenameFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("期望 %d 个文件，得到 %v", len(want), names)
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s 的内容期望 %q，得到 %q (%v)", name, content, data, err)
+		}
+	}
+}
+
+func renameMoves(dir string, pairs ...string) []RenameMove {
+	var moves []RenameMove
+	for i := 0; i < len(pairs); i += 2 {
+		moves = append(moves, RenameMove{From: filepath.Join(dir, pairs[i]), To: filepath.Join(dir, pairs[i+1])})
+	}
+	return moves
+}
+
+func TestPlanRenames_Cycles(t *testing.T) {
+	dir := t.TempDir()
+	writeRenameFiles(t, dir, "a", "b", "c", "d", "e")
+
+	// a↔b 交换，c→d→e→c 三元环
+	moves := renameMoves(dir, "a", "b", "b", "a", "c", "d", "d", "e", "e", "c")
+	plan, err := PlanRenames(moves, ConflictSkip)
+	if err != nil {
+		t.Fatalf("PlanRenames 失败: %v", err)
+	}
+	if len(plan.Conflicts) != 0 || len(plan.Moves) != 5 {
+		t.Fatalf("期望 5 项且没有冲突，得到 %d 项，冲突 %v", len(plan.Moves), plan.Conflicts)
+	}
+	if len(plan.Steps()) != 7 {
+		t.Errorf("两个环各需要一次临时改名，期望 7 步，得到 %d", len(plan.Steps()))
+	}
+
+	finals := 0
+	if err := plan.Execute(func(step RenameStep) {
+		if step.Final {
+			finals++
+		}
+	}); err != nil {
+		t.Fatalf("Execute 失败: %v", err)
+	}
+	if finals != 5 {
+		t.Errorf("期望 5 次完成回调，得到 %d", finals)
+	}
+	checkRenameFiles(t, dir, map[string]string{"b": "a", "a": "b", "d": "c", "e": "d", "c": "e"})
+}
+
+func TestPlanRenames_Chain(t *testing.T) {
+	dir := t.TempDir()
+	writeRenameFiles(t, dir, "1", "2", "3")
+
+	// 顺序执行会让 1 覆盖 2，计划应先移动 3、再移动 2、最后移动 1
+	plan, err := PlanRenames(renameMoves(dir, "1", "2", "2", "3", "3", "4"), ConflictFail)
+	if err != nil {
+		t.Fatalf("PlanRenames 失败: %v", err)
+	}
+	if len(plan.Steps()) != 3 {
+		t.Errorf("链式重命名不需要临时文件名，期望 3 步，得到 %d", len(plan.Steps()))
+	}
+	if err := plan.Execute(nil); err != nil {
+		t.Fatalf("Execute 失败: %v", err)
+	}
+	checkRenameFiles(t, dir, map[string]string{"2": "1", "3": "2", "4": "3"})
+}
+
+func TestPlanRenames_Conflicts(t *testing.T) {
+	setup := func() (string, []RenameMove) {
+		dir := t.TempDir()
+		writeRenameFiles(t, dir, "a", "b", "c", "x")
+		// a→x 与已有文件冲突；a 被跳过后不再腾空，b→a 随之冲突；c→y 没有冲突；x→x 无需改名
+		return dir, renameMoves(dir, "a", "x", "b", "a", "c", "y", "x", "x")
+	}
+
+	dir, moves := setup()
+	plan, err := PlanRenames(moves, ConflictSkip)
+	if err != nil {
+		t.Fatalf("PlanRenames 失败: %v", err)
+	}
+	if len(plan.Conflicts) != 2 || len(plan.Moves) != 1 || plan.Unchanged != 1 {
+		t.Fatalf("期望 2 个冲突、1 项重命名，得到 %+v", plan)
+	}
+	if plan.Conflicts[1].From != moves[1].From || plan.Conflicts[1].Resolved != "" {
+		t.Errorf("冲突应按输入顺序排列，得到 %+v", plan.Conflicts)
+	}
+	if err := plan.Execute(nil); err != nil {
+		t.Fatalf("Execute 失败: %v", err)
+	}
+	checkRenameFiles(t, dir, map[string]string{"a": "a", "b": "b", "y": "c", "x": "x"})
+
+	// 存在冲突时不执行任何重命名
+	_, moves = setup()
+	if _, err := PlanRenames(moves, ConflictFail); !errors.Is(err, ErrRenameConflict) {
+		t.Errorf("期望 ErrRenameConflict，得到 %v", err)
+	}
+
+	// 添加序号后缀，计划内的重复目标也分别添加后缀
+	dir, _ = setup()
+	plan, err = PlanRenames(renameMoves(dir, "a", "x", "b", "a", "c", "x"), ConflictSuffix)
+	if err != nil {
+		t.Fatalf("PlanRenames 失败: %v", err)
+	}
+	if len(plan.Conflicts) != 2 || plan.Conflicts[1].Resolved != filepath.Join(dir, "x(2)") {
+		t.Fatalf("期望 a→x(1)、c→x(2)，得到 %+v", plan.Conflicts)
+	}
+	if err := plan.Execute(nil); err != nil {
+		t.Fatalf("Execute 失败: %v", err)
+	}
+	checkRenameFiles(t, dir, map[string]string{"x(1)": "a", "a": "b", "x(2)": "c", "x": "x"})
+}
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errNothingToRepair 表示文件无需修复，用于跳过重新打包
+var errNothingToRepair = errors.New("无需修复")
+
+// RepairEpub 修复打包不规范的 EPUB，返回已执行的修复项
+// 可修复的问题：mimetype 不是第一个条目、被压缩或内容错误，带 "./" 前缀的条目名，
+// 缺失或指向错误的 container.xml（归档中只有一个 OPF 时重新生成），
+// 编码与声明不一致的内容文档（转换为 UTF-8 并修改 XML 声明和 <meta charset>）。
+// 无需修复时返回空列表；无法修复时返回错误，原文件保持不变
+func RepairEpub(filePath string) ([]string, error) {
+	var fixes []string
+	err := rewriteEpub(filePath, func(entries []*zipEntry) ([]*zipEntry, error) {
+		if fix := checkMimetypeEntry(entries); fix != "" {
+			fixes = append(fixes, fix)
+		}
+
+		entries, renamed := normalizeEntryNames(entries)
+		if renamed > 0 {
+			fixes = append(fixes, fmt.Sprintf("去除 %d 个条目名的 \"./\" 前缀", renamed))
+		}
+
+		entries, fix, err := repairContainer(entries)
+		if err != nil {
+			return nil, err
+		}
+		if fix != "" {
+			fixes = append(fixes, fix)
+		}
+
+		encodingFixes, err := repairContentEncoding(entries)
+		if err != nil {
+			return nil, err
+		}
+		fixes = append(fixes, encodingFixes...)
+
+		if len(fixes) == 0 {
+			return nil, errNothingToRepair
+		}
+		return entries, nil
+	})
+	if errors.Is(err, errNothingToRepair) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fixes, nil
+}
+
+// checkMimetypeEntry 检查 mimetype 条目，返回需要执行的修复说明
+// 重新打包时总会写出规范的 mimetype，这里只负责判断是否需要修复
+func checkMimetypeEntry(entries []*zipEntry) string {
+	if len(entries) == 0 || entries[0].Name != "mimetype" {
+		if findEntry(entries, "mimetype") == nil {
+			return "补充 mimetype"
+		}
+		return "将 mimetype 移到第一个条目"
+	}
+	if entries[0].file != nil && entries[0].file.Method != zip.Store {
+		return "以不压缩方式存储 mimetype"
+	}
+	data, err := entries[0].Read()
+	if err != nil || string(data) != EpubMimetype {
+		return "修正 mimetype 内容"
+	}
+	return ""
+}
+
+// normalizeEntryNames 去除条目名的 "./" 前缀，重名时保留第一个条目
+func normalizeEntryNames(entries []*zipEntry) ([]*zipEntry, int) {
+	renamed := 0
+	seen := make(map[string]bool, len(entries))
+	result := entries[:0]
+	for _, e := range entries {
+		name := e.Name
+		for strings.HasPrefix(name, "./") {
+			name = strings.TrimPrefix(name, "./")
+		}
+		if name != e.Name {
+			renamed++
+			e.Name = name
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, e)
+	}
+	return result, renamed
+}
+
+// repairContainer 在 container.xml 缺失或指向不存在的 OPF 时，根据归档中唯一的 OPF 重新生成
+func repairContainer(entries []*zipEntry) ([]*zipEntry, string, error) {
+	if opfPath, err := readRootfilePath(entries); err == nil && findEntry(entries, opfPath) != nil {
+		return entries, "", nil
+	}
+
+	var opfs []string
+	for _, e := range entries {
+		if strings.HasSuffix(strings.ToLower(e.Name), ".opf") {
+			opfs = append(opfs, e.Name)
+		}
+	}
+	if len(opfs) != 1 {
+		return nil, "", fmt.Errorf("无法修复 container.xml：归档中有 %d 个 OPF 文件", len(opfs))
+	}
+
+	data := renderContainerXML(opfs[0])
+	if entry := findEntry(entries, "META-INF/container.xml"); entry != nil {
+		entry.SetData(data)
+		return entries, fmt.Sprintf("重写 container.xml，指向 %s", opfs[0]), nil
+	}
+
+	// container.xml 紧跟在 mimetype 之后
+	entries = append([]*zipEntry{{Name: "META-INF/container.xml", data: data}}, entries...)
+	return entries, fmt.Sprintf("重新生成 container.xml，指向 %s", opfs[0]), nil
+}
+
+// renderContainerXML 生成指向 opfPath 的 container.xml
+func renderContainerXML(opfPath string) []byte {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(opfPath))
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="%s" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`, escaped.String()))
+}
@@ -0,0 +1,204 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepairEpub_MimetypeAndEntryNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+
+	// mimetype 被压缩且不是第一个条目，其余条目带 "./" 前缀
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("无法创建测试文件: %v", err)
+	}
+	w := zip.NewWriter(f)
+	entries := testEpubEntries("三体")
+	for _, e := range append(entries[1:], entries[0]) {
+		name := e[0]
+		if name != "mimetype" {
+			name = "./" + name
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatalf("无法创建 ZIP 条目: %v", err)
+		}
+		fw.Write([]byte(e[1]))
+	}
+	w.Close()
+	f.Close()
+
+	if GetErrorType(strictValidator().Validate(path)) != ErrorTypeMimetype {
+		t.Fatal("修复前期望 ErrorTypeMimetype")
+	}
+
+	fixes, err := RepairEpub(path)
+	if err != nil {
+		t.Fatalf("RepairEpub 失败: %v", err)
+	}
+	if len(fixes) != 2 {
+		t.Errorf("期望 2 项修复，得到 %v", fixes)
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("修复后期望检测通过，得到 %v", err)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("无法打开修复后的文件: %v", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if strings.HasPrefix(f.Name, "./") {
+			t.Errorf("条目名仍带 \"./\" 前缀: %s", f.Name)
+		}
+	}
+}
+
+func TestRepairEpub_RegenerateContainer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	writeTestEpub(t, path, append(entries[:1], entries[2:]...))
+
+	fixes, err := RepairEpub(path)
+	if err != nil {
+		t.Fatalf("RepairEpub 失败: %v", err)
+	}
+	if len(fixes) != 1 || !strings.Contains(fixes[0], "OEBPS/content.opf") {
+		t.Errorf("修复项 = %v", fixes)
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("修复后期望检测通过，得到 %v", err)
+	}
+}
+
+func TestRepairEpub_WrongRootfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[1][1] = strings.Replace(entries[1][1], "OEBPS/content.opf", "content.opf", 1)
+	writeTestEpub(t, path, entries)
+
+	if _, err := RepairEpub(path); err != nil {
+		t.Fatalf("RepairEpub 失败: %v", err)
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("修复后期望检测通过，得到 %v", err)
+	}
+}
+
+func TestRepairEpub_AmbiguousOpf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries = append(entries[:1], entries[2:]...)
+	entries = append(entries, [2]string{"OEBPS/other.opf", entries[1][1]})
+	writeTestEpub(t, path, entries)
+
+	before, _ := os.ReadFile(path)
+	if _, err := RepairEpub(path); err == nil {
+		t.Fatal("存在多个 OPF 时期望返回错误")
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(before, after) {
+		t.Error("无法修复时不应修改原文件")
+	}
+}
+
+func TestRepairEpub_NothingToRepair(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, path, testEpubEntries("三体"))
+
+	before, _ := os.ReadFile(path)
+	fixes, err := RepairEpub(path)
+	if err != nil || len(fixes) != 0 {
+		t.Errorf("期望无需修复，得到 %v, %v", fixes, err)
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(before, after) {
+		t.Error("无需修复时不应修改原文件")
+	}
+}
+
+func TestRepairEpub_ContentEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = strings.Replace(entries[2][1], `</manifest>`,
+		`  <item id="chapter2" href="chapter2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>`, 1)
+	entries[3][1] = mustEncodeGBK(testXhtml)
+	entries = append(entries, [2]string{"OEBPS/chapter2.xhtml", `<html><head><meta charset="gb2312"/></head><body>正文</body></html>`})
+	writeTestEpub(t, path, entries)
+
+	if GetErrorType(strictValidator().Validate(path)) != ErrorTypeEncoding {
+		t.Fatal("修复前期望 ErrorTypeEncoding")
+	}
+
+	fixes, err := RepairEpub(path)
+	if err != nil {
+		t.Fatalf("RepairEpub 失败: %v", err)
+	}
+	want := []string{"将 1 个内容文档从 GB18030 转换为 UTF-8", "将 1 个内容文档的编码声明改为 UTF-8"}
+	if strings.Join(fixes, "|") != strings.Join(want, "|") {
+		t.Errorf("修复项 = %v，期望 %v", fixes, want)
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("修复后期望检测通过，得到 %v", err)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("无法打开修复后的文件: %v", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		rc, _ := f.Open()
+		var buf bytes.Buffer
+		buf.ReadFrom(rc)
+		rc.Close()
+		switch f.Name {
+		case "OEBPS/chapter1.xhtml":
+			if buf.String() != testXhtml {
+				t.Errorf("chapter1 = %s", buf.String())
+			}
+		case "OEBPS/chapter2.xhtml":
+			if !strings.Contains(buf.String(), `<meta charset="utf-8"/>`) {
+				t.Errorf("chapter2 = %s", buf.String())
+			}
+		}
+	}
+}
+
+func TestRepairEpub_DamagedEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[3][1] = "<html><body>" + strings.Repeat("正文", 20) + "\xff</body></html>"
+	writeTestEpub(t, path, entries)
+	before, _ := os.ReadFile(path)
+
+	if _, err := RepairEpub(path); err == nil {
+		t.Error("无法判断实际编码时期望返回错误")
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Error("修复失败时不应修改原文件")
+	}
+}
+
+func TestRepairEpub_Latin1Content(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("Café")
+	entries[3][1] = testLatin1Xhtml
+	writeTestEpub(t, path, entries)
+	before, _ := os.ReadFile(path)
+
+	// 不像中文的内容不能按 GB18030 转换，否则会被改成乱码
+	if _, err := RepairEpub(path); err == nil {
+		t.Error("无法判断实际编码时期望返回错误")
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Error("修复失败时不应修改原文件")
+	}
+}
@@ -0,0 +1,326 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 缓存格式变化时修改桶名，旧数据自动失效
+var (
+	scanFilesBucket  = []byte("files.v2")  // 绝对路径 → scanRecord
+	scanHashesBucket = []byte("hashes.v2") // SHA-256 → 绝对路径，用于识别被移动或重命名的文件
+)
+
+// scanFlushSize 累积多少条记录后写入数据库
+const scanFlushSize = 200
+
+// ScanCache 记录文件的检测结果和元数据，文件未变化时跳过重新读取
+// 以绝对路径为键，大小和修改时间一致即视为未变化；路径不在缓存中时按 SHA-256 查找，
+// 因此移动或重命名过的文件也能命中。可以在多个 goroutine 中并发调用；
+// nil 表示不使用缓存，Validate 和 Metadata 直接读取文件
+type ScanCache struct {
+	db      *bolt.DB
+	refresh bool // 忽略已有记录，重新读取并更新缓存
+
+	mu      sync.Mutex
+	pending map[string]*scanRecord // 尚未写入数据库的记录
+
+	hits, misses atomic.Int64
+}
+
+// scanRecord 一个文件的缓存记录
+type scanRecord struct {
+	Size     int64                  `json:"size"`
+	ModTime  int64                  `json:"mtime"` // UnixNano
+	Hash     string                 `json:"hash"`
+	Results  map[string]*scanResult `json:"results,omitempty"` // 检测器配置 → 检测结果
+	Metadata *EpubMetadata          `json:"metadata,omitempty"`
+	MetaErr  string                 `json:"meta_error,omitempty"`
+}
+
+// scanResult 一次检测的结果，Error 为 nil 表示通过
+type scanResult struct {
+	Error *EpubError `json:"error,omitempty"`
+}
+
+// DefaultScanCachePath 返回默认的缓存文件路径，Linux 上为 $XDG_CACHE_HOME/bookimporter/scan.db
+func DefaultScanCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("无法确定缓存目录: %w", err)
+	}
+	return filepath.Join(dir, "bookimporter", "scan.db"), nil
+}
+
+// OpenScanCache 打开或创建缓存文件，另一个进程正在使用时等待 1 秒后返回错误
+func OpenScanCache(path string) (*ScanCache, error) {
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("无法创建缓存目录: %w", err)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("无法打开扫描缓存 %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{scanFilesBucket, scanHashesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法初始化扫描缓存: %w", err)
+	}
+	return &ScanCache{db: db, pending: make(map[string]*scanRecord)}, nil
+}
+
+// SetRefresh 设置为 true 时忽略已有记录，所有文件重新读取，结果仍写入缓存
+func (c *ScanCache) SetRefresh(refresh bool) {
+	if c != nil {
+		c.refresh = refresh
+	}
+}
+
+// Hits 返回使用缓存结果的次数
+func (c *ScanCache) Hits() int {
+	if c == nil {
+		return 0
+	}
+	return int(c.hits.Load())
+}
+
+// Misses 返回重新读取文件的次数
+func (c *ScanCache) Misses() int {
+	if c == nil {
+		return 0
+	}
+	return int(c.misses.Load())
+}
+
+// Close 写入尚未保存的记录并关闭缓存
+func (c *ScanCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	err := c.flush()
+	if closeErr := c.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Validate 使用 v 检测文件，文件未变化且缓存中有同一检测器配置的结果时直接返回
+// 只缓存通过和 EpubError，其他错误（如无法读取文件）每次重新检测
+func (c *ScanCache) Validate(path string, v FileValidator) error {
+	if c == nil {
+		return v.Validate(path)
+	}
+	profile := v.Profile()
+	abs, rec, err := c.lookup(path)
+	if err != nil {
+		return v.Validate(path)
+	}
+	if res, ok := rec.Results[profile]; ok && !c.refresh {
+		c.hits.Add(1)
+		if res.Error != nil {
+			return res.Error
+		}
+		return nil
+	}
+
+	c.misses.Add(1)
+	validateErr := v.Validate(path)
+	var epubErr *EpubError
+	if validateErr != nil && !errors.As(validateErr, &epubErr) {
+		return validateErr
+	}
+	c.update(abs, rec, func(r *scanRecord) {
+		if r.Results == nil {
+			r.Results = make(map[string]*scanResult)
+		}
+		r.Results[profile] = &scanResult{Error: epubErr}
+	})
+	return validateErr
+}
+
+// Metadata 读取文件的元数据，文件未变化时返回缓存的结果
+func (c *ScanCache) Metadata(path string) (*EpubMetadata, error) {
+	if c == nil {
+		return ReadBookMetadata(path)
+	}
+	abs, rec, err := c.lookup(path)
+	if err != nil {
+		return ReadBookMetadata(path)
+	}
+	if (rec.Metadata != nil || rec.MetaErr != "") && !c.refresh {
+		c.hits.Add(1)
+		if rec.MetaErr != "" {
+			return nil, errors.New(rec.MetaErr)
+		}
+		return rec.Metadata, nil
+	}
+
+	c.misses.Add(1)
+	meta, metaErr := ReadBookMetadata(path)
+	c.update(abs, rec, func(r *scanRecord) {
+		r.Metadata = meta
+		if metaErr != nil {
+			r.MetaErr = metaErr.Error()
+		}
+	})
+	return meta, metaErr
+}
+
+// lookup 返回与文件当前状态一致的记录
+// 路径对应的记录已过期时按内容摘要查找其他路径的记录，都没有时返回只有大小、修改时间和摘要的新记录
+func (c *ScanCache) lookup(path string) (string, *scanRecord, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", nil, err
+	}
+	size, mtime := info.Size(), info.ModTime().UnixNano()
+
+	if rec := c.get(abs); rec != nil && rec.Size == size && rec.ModTime == mtime {
+		return abs, rec, nil
+	}
+
+	sum, err := hashFile(abs, sha256.New())
+	if err != nil {
+		return "", nil, err
+	}
+	hash := hex.EncodeToString(sum)
+	rec := &scanRecord{Size: size, ModTime: mtime, Hash: hash}
+	if other := c.getByHash(hash); other != nil && other.Size == size {
+		rec.Results, rec.Metadata, rec.MetaErr = other.Results, other.Metadata, other.MetaErr
+	}
+	return abs, rec, nil
+}
+
+// get 返回路径的记录，优先使用尚未写入的记录
+func (c *ScanCache) get(abs string) *scanRecord {
+	c.mu.Lock()
+	rec, ok := c.pending[abs]
+	c.mu.Unlock()
+	if ok {
+		return rec.clone()
+	}
+
+	var data []byte
+	c.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(scanFilesBucket).Get([]byte(abs)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if data == nil {
+		return nil
+	}
+	rec = &scanRecord{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil
+	}
+	return rec
+}
+
+// getByHash 返回内容摘要为 hash 的记录
+func (c *ScanCache) getByHash(hash string) *scanRecord {
+	c.mu.Lock()
+	for _, rec := range c.pending {
+		if rec.Hash == hash {
+			c.mu.Unlock()
+			return rec.clone()
+		}
+	}
+	c.mu.Unlock()
+
+	var path string
+	c.db.View(func(tx *bolt.Tx) error {
+		path = string(tx.Bucket(scanHashesBucket).Get([]byte(hash)))
+		return nil
+	})
+	if path == "" {
+		return nil
+	}
+	// 索引可能已过期，以路径记录中的摘要为准
+	if rec := c.get(path); rec != nil && rec.Hash == hash {
+		return rec
+	}
+	return nil
+}
+
+// update 修改记录并加入待写入队列，队列满时写入数据库
+func (c *ScanCache) update(abs string, rec *scanRecord, change func(r *scanRecord)) {
+	c.mu.Lock()
+	// 同一文件的另一项结果可能已在队列中，合并到最新的记录上
+	if pending, ok := c.pending[abs]; ok && pending.Size == rec.Size && pending.ModTime == rec.ModTime {
+		rec = pending
+	}
+	change(rec)
+	c.pending[abs] = rec
+	full := len(c.pending) >= scanFlushSize
+	c.mu.Unlock()
+
+	if full {
+		c.flush()
+	}
+}
+
+// flush 在一个事务中写入所有待写入的记录
+func (c *ScanCache) flush() error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]*scanRecord)
+	c.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		files, hashes := tx.Bucket(scanFilesBucket), tx.Bucket(scanHashesBucket)
+		for abs, rec := range pending {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := files.Put([]byte(abs), data); err != nil {
+				return err
+			}
+			if err := hashes.Put([]byte(rec.Hash), []byte(abs)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("写入扫描缓存失败: %w", err)
+	}
+	return nil
+}
+
+// clone 复制记录，避免修改队列中共享的 Results
+func (r *scanRecord) clone() *scanRecord {
+	cp := *r
+	if r.Results != nil {
+		cp.Results = make(map[string]*scanResult, len(r.Results))
+		for k, v := range r.Results {
+			cp.Results[k] = v
+		}
+	}
+	return &cp
+}
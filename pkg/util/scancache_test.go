@@ -0,0 +1,136 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// countingValidator 返回记录检测次数的检测器
+func countingValidator(calls *atomic.Int32) *Validator {
+	return NewEpubValidator(ValidateOptions{}).AddStage(ValidationStage{
+		Name: "count",
+		Check: func(a *EpubArchive) error {
+			calls.Add(1)
+			return nil
+		},
+	})
+}
+
+func TestScanCache_Validate(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "cache", "scan.db")
+	book := filepath.Join(dir, "book.epub")
+	writeTestEpub(t, book, testEpubEntries("三体"))
+
+	cache, err := OpenScanCache(dbPath)
+	if err != nil {
+		t.Fatalf("OpenScanCache 失败: %v", err)
+	}
+	var calls atomic.Int32
+	v := countingValidator(&calls)
+
+	for i := 0; i < 2; i++ {
+		if err := cache.Validate(book, v); err != nil {
+			t.Fatalf("Validate 失败: %v", err)
+		}
+	}
+	if calls.Load() != 1 || cache.Hits() != 1 || cache.Misses() != 1 {
+		t.Fatalf("检测 %d 次，命中 %d 次，未命中 %d 次；期望 1、1、1", calls.Load(), cache.Hits(), cache.Misses())
+	}
+
+	// 其他配置的检测器不使用该结果
+	if err := cache.Validate(book, DefaultValidator()); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Misses() != 2 {
+		t.Errorf("不同检测器配置应重新检测，未命中 %d 次", cache.Misses())
+	}
+
+	// 重新打开后结果仍在；重命名的文件按内容摘要命中
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	moved := filepath.Join(dir, "moved.epub")
+	os.Rename(book, moved)
+	cache, err = OpenScanCache(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if err := cache.Validate(moved, v); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("重命名后应使用缓存，检测了 %d 次", calls.Load())
+	}
+
+	// 内容变化后重新检测
+	writeTestEpub(t, moved, testEpubEntries("球状闪电"))
+	cache.Validate(moved, v)
+	if calls.Load() != 2 {
+		t.Errorf("内容变化后应重新检测，检测了 %d 次", calls.Load())
+	}
+
+	// SetRefresh 忽略已有结果
+	cache.SetRefresh(true)
+	cache.Validate(moved, v)
+	if calls.Load() != 3 {
+		t.Errorf("refresh 时应重新检测，检测了 %d 次", calls.Load())
+	}
+}
+
+func TestScanCache_EpubErrorAndMetadata(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenScanCache(filepath.Join(dir, "scan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.epub")
+	os.WriteFile(broken, []byte("not a zip"), 0644)
+	book := filepath.Join(dir, "book.epub")
+	writeTestEpub(t, book, testEpubEntries("三体"))
+
+	cache.Validate(broken, DefaultValidator())
+	cache.Metadata(book)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = OpenScanCache(filepath.Join(dir, "scan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	err = cache.Validate(broken, DefaultValidator())
+	var epubErr *EpubError
+	if !errors.As(err, &epubErr) || epubErr.Type != ErrorTypeCorrupted {
+		t.Errorf("缓存的检测结果 = %v，期望 ErrorTypeCorrupted", err)
+	}
+	meta, err := cache.Metadata(book)
+	if err != nil || meta.Title != "三体" || len(meta.Creators) != 1 {
+		t.Errorf("缓存的元数据 = %+v, %v", meta, err)
+	}
+	if cache.Hits() != 2 {
+		t.Errorf("命中 %d 次，期望 2", cache.Hits())
+	}
+}
+
+func TestScanCache_Nil(t *testing.T) {
+	var cache *ScanCache
+	book := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, book, testEpubEntries("三体"))
+
+	if err := cache.Validate(book, DefaultValidator()); err != nil {
+		t.Errorf("nil 缓存 Validate 失败: %v", err)
+	}
+	if meta, err := cache.Metadata(book); err != nil || meta.Title != "三体" {
+		t.Errorf("nil 缓存 Metadata = %+v, %v", meta, err)
+	}
+	if err := cache.Close(); err != nil || cache.Hits() != 0 {
+		t.Error("nil 缓存的方法应为空操作")
+	}
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SeriesInfo 系列信息
+type SeriesInfo struct {
+	Name  string  `json:"name"`
+	Index float64 `json:"index,omitempty"` // 在系列中的序号，为 0 表示未知
+}
+
+// String 返回便于显示的文本，如 "三体 #2"
+func (s *SeriesInfo) String() string {
+	if s == nil {
+		return ""
+	}
+	if s.Index == 0 {
+		return s.Name
+	}
+	return fmt.Sprintf("%s #%s", s.Name, formatSeriesIndex(s.Index))
+}
+
+// Equal 判断两个系列信息是否相同
+func (s *SeriesInfo) Equal(other *SeriesInfo) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.Name == other.Name && s.Index == other.Index
+}
+
+// formatSeriesIndex 格式化序号，整数不带小数点
+func formatSeriesIndex(index float64) string {
+	return strconv.FormatFloat(index, 'f', -1, 64)
+}
+
+const numeralChars = `0-9０-９零〇一二两三四五六七八九十百千万`
+
+var (
+	// reVolumeOrdinal 第二部、第3卷、第十二册
+	reVolumeOrdinal = regexp.MustCompile(`^第([` + numeralChars + `]+)[部卷册辑集季本]$`)
+	// reVolumePrefix 卷二、卷3
+	reVolumePrefix = regexp.MustCompile(`^卷([` + numeralChars + `]+)$`)
+	// reVolumePosition 上、中、下，可带 部卷册篇
+	reVolumePosition = regexp.MustCompile(`^([上中下])[部卷册篇]?$`)
+	// reVolumeLatin Vol. 2、Volume 3、Book 1
+	reVolumeLatin = regexp.MustCompile(`(?i)^(?:vol(?:ume)?|book)\.?\s*(\d+)$`)
+)
+
+// volumePositions 上中下对应的序号，只用于排序
+var volumePositions = map[string]float64{"上": 1, "中": 2, "下": 3}
+
+// parseVolume 解析括号内表示分卷的内容，返回序号
+func parseVolume(content string) (float64, bool) {
+	content = strings.TrimSpace(content)
+	for _, re := range []*regexp.Regexp{reVolumeOrdinal, reVolumePrefix} {
+		if m := re.FindStringSubmatch(content); m != nil {
+			if n, ok := ParseChineseNumeral(m[1]); ok && n > 0 {
+				return float64(n), true
+			}
+		}
+	}
+	if m := reVolumePosition.FindStringSubmatch(content); m != nil {
+		return volumePositions[m[1]], true
+	}
+	if m := reVolumeLatin.FindStringSubmatch(content); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			return float64(n), true
+		}
+	}
+	return 0, false
+}
+
+// ExtractSeries 使用内置规则从书名中提取系列信息
+func ExtractSeries(title string) (string, *SeriesInfo) {
+	return defaultTitleCleaner.ExtractSeries(title)
+}
+
+// ExtractSeries 从书名的括号片段中提取系列信息
+// 如 "三体（第二部）：黑暗森林" 返回书名 "三体：黑暗森林"，系列 "三体"、序号 2。
+// 系列名为分卷片段之前的文本；"全4册" 这类表示整套的片段不视为分卷。
+// 未找到分卷片段时原样返回书名和 nil
+func (c *TitleCleaner) ExtractSeries(title string) (string, *SeriesInfo) {
+	segments := c.split(title)
+	for i := 1; i < len(segments); i++ {
+		content, ok := c.unwrap(segments[i])
+		if !ok {
+			continue
+		}
+		index, ok := parseVolume(content)
+		if !ok {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.Join(segments[:i], ""))
+		if name == "" {
+			return title, nil
+		}
+		rest := append(append([]string(nil), segments[:i]...), segments[i+1:]...)
+		newTitle := strings.TrimSpace(strings.Join(rest, ""))
+		return newTitle, &SeriesInfo{Name: name, Index: index}
+	}
+	return title, nil
+}
+
+// unwrap 去掉片段首尾的括号，片段不是括号片段时返回 false
+// 左右括号不要求配对，如 "（第二部)"
+func (c *TitleCleaner) unwrap(segment string) (string, bool) {
+	for _, open := range c.rules.Brackets {
+		if !strings.HasPrefix(segment, open[0]) {
+			continue
+		}
+		for _, close := range c.rules.Brackets {
+			if strings.HasSuffix(segment, close[1]) && len(segment) >= len(open[0])+len(close[1]) {
+				return segment[len(open[0]) : len(segment)-len(close[1])], true
+			}
+		}
+	}
+	return "", false
+}
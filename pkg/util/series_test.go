@@ -0,0 +1,116 @@
+package util
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractSeries(t *testing.T) {
+	tests := []struct {
+		input     string
+		title     string
+		series    string
+		index     float64
+		hasSeries bool
+	}{
+		{"三体（第二部）", "三体", "三体", 2, true},
+		{"三体（第二部）：黑暗森林", "三体：黑暗森林", "三体", 2, true},
+		{"明朝那些事儿(第3卷)", "明朝那些事儿", "明朝那些事儿", 3, true},
+		{"平凡的世界（第十二册)", "平凡的世界", "平凡的世界", 12, true},
+		{"资治通鉴【卷二】", "资治通鉴", "资治通鉴", 2, true},
+		{"红楼梦（下）", "红楼梦", "红楼梦", 3, true},
+		{"红楼梦（上册）", "红楼梦", "红楼梦", 1, true},
+		{"The Expanse (Book 2)", "The Expanse", "The Expanse", 2, true},
+		// 表示整套的片段不是分卷
+		{"大江大河（全4册）", "大江大河（全4册）", "", 0, false},
+		{"深入理解Java虚拟机（第3版）", "深入理解Java虚拟机（第3版）", "", 0, false},
+		{"三体", "三体", "", 0, false},
+		// 没有系列名时不提取
+		{"（第二部）", "（第二部）", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		title, series := ExtractSeries(tt.input)
+		if title != tt.title {
+			t.Errorf("ExtractSeries(%q) 书名 = %q，期望 %q", tt.input, title, tt.title)
+		}
+		if (series != nil) != tt.hasSeries {
+			t.Errorf("ExtractSeries(%q) 系列 = %v，期望存在: %v", tt.input, series, tt.hasSeries)
+			continue
+		}
+		if series != nil && (series.Name != tt.series || series.Index != tt.index) {
+			t.Errorf("ExtractSeries(%q) 系列 = %s，期望 %s #%v", tt.input, series, tt.series, tt.index)
+		}
+	}
+}
+
+func TestUpdateEpubMetadata_SeriesEpub2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, path, testEpubEntries("三体（第二部）"))
+
+	meta, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	if meta.Series != nil {
+		t.Fatalf("期望没有系列信息，得到 %v", meta.Series)
+	}
+
+	title, series := ExtractSeries(meta.Title)
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Title: &title, Series: series}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	opf := readTestOpf(t, path)
+	for _, want := range []string{
+		`<dc:title>三体</dc:title>`,
+		`<meta name="calibre:series" content="三体"/>`,
+		`<meta name="calibre:series_index" content="2"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("OPF 中缺少 %q:\n%s", want, opf)
+		}
+	}
+	if strings.Contains(opf, "belongs-to-collection") {
+		t.Errorf("EPUB2 不应写入 belongs-to-collection:\n%s", opf)
+	}
+
+	// 再次写入时替换而不是追加
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Series: &SeriesInfo{Name: "地球往事", Index: 2}}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+	meta, _ = ReadEpubMetadata(path)
+	if meta.Series.String() != "地球往事 #2" {
+		t.Errorf("系列 = %s", meta.Series)
+	}
+	if opf := readTestOpf(t, path); strings.Count(opf, "calibre:series\"") != 1 {
+		t.Errorf("calibre:series 应只有一个:\n%s", opf)
+	}
+}
+
+func TestUpdateEpubMetadata_SeriesEpub3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[2][1] = testOpf3
+	writeTestEpub(t, path, entries)
+
+	if err := UpdateEpubMetadata(path, &MetadataUpdate{Series: &SeriesInfo{Name: "三体", Index: 2}}); err != nil {
+		t.Fatalf("UpdateEpubMetadata 失败: %v", err)
+	}
+
+	opf := readTestOpf(t, path)
+	for _, want := range []string{
+		`<meta name="calibre:series" content="三体"/>`,
+		`<meta property="belongs-to-collection" id="collection1">三体</meta>`,
+		`<meta refines="#collection1" property="collection-type">series</meta>`,
+		`<meta refines="#collection1" property="group-position">2</meta>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("OPF 中缺少 %q:\n%s", want, opf)
+		}
+	}
+	if err := strictValidator().Validate(path); err != nil {
+		t.Errorf("写入后期望检测通过，得到 %v", err)
+	}
+}
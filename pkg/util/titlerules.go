@@ -0,0 +1,255 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TitleRules 书名清理规则
+// 书名按括号切分为片段，第一个片段为主标题，其后的括号片段满足保留条件时保留，其余删除
+type TitleRules struct {
+	// Strip 切分前从书名中删除的正则
+	Strip []string `yaml:"strip"`
+	// Preserve 括号内容匹配任一正则时保留
+	Preserve []string `yaml:"preserve"`
+	// Brackets 括号对，每项为 [左括号, 右括号]，均为单个字符
+	Brackets [][2]string `yaml:"brackets"`
+	// KeepSuffixes 括号内容以这些后缀结尾时保留，如 "版"
+	KeepSuffixes []string `yaml:"keep_suffixes"`
+
+	// MaxSegments 只检查主标题之后的前 N 个括号片段，之后的片段一律删除
+	MaxSegments int `yaml:"max_segments"`
+	// ShortLength 括号内容不超过该字数时直接保留
+	ShortLength int `yaml:"short_length"`
+	// PreserveMaxLength 匹配 Preserve 的括号内容需少于该字数
+	PreserveMaxLength int `yaml:"preserve_max_length"`
+	// SuffixMaxLength 匹配 KeepSuffixes 的括号内容需少于该字数
+	SuffixMaxLength int `yaml:"suffix_max_length"`
+}
+
+// titleRulesFile 规则文件格式
+// 默认将文件中的列表追加到内置规则之后，数值覆盖内置规则；Replace 为 true 时不使用内置规则
+type titleRulesFile struct {
+	Replace    bool `yaml:"replace"`
+	TitleRules `yaml:",inline"`
+}
+
+// DefaultTitleRules 返回内置的书名清理规则
+func DefaultTitleRules() *TitleRules {
+	return &TitleRules{
+		Preserve: []string{
+			`.{2,6}篇`,
+			`[上中下+]`,
+			`[上中下、]+[册本卷部辑]`,
+			`套装.*?[册本卷部辑]`,
+			`[全共].*?[册本卷部辑]`,
+			`\d+[册本卷部辑]`,
+			`第.*?[版卷部辑]`,
+			`[\d一二三四五六七八九十百千]+[-~—～][\d一二三四五六七八九十百千]+`,
+			`\d{4}[-~—～]\d{4}`,
+		},
+		Brackets: [][2]string{
+			{"【", "】"},
+			{"[", "]"},
+			{"（", "）"},
+			{"(", ")"},
+		},
+		KeepSuffixes:      []string{"版"},
+		MaxSegments:       2,
+		ShortLength:       3,
+		PreserveMaxLength: 20,
+		SuffixMaxLength:   10,
+	}
+}
+
+// LoadTitleRules 从 YAML 文件加载书名清理规则，只支持 YAML，.toml 文件直接返回错误
+func LoadTitleRules(path string) (*TitleRules, error) {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return nil, fmt.Errorf("不支持 TOML 格式的规则文件 %s，请改用 YAML", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取规则文件: %w", err)
+	}
+
+	var file titleRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("无法解析规则文件 %s: %w", path, err)
+	}
+	if file.Replace {
+		return &file.TitleRules, nil
+	}
+	return DefaultTitleRules().merge(&file.TitleRules), nil
+}
+
+// merge 将 other 中的列表追加到 r 之后，非零数值覆盖 r
+func (r *TitleRules) merge(other *TitleRules) *TitleRules {
+	r.Strip = append(r.Strip, other.Strip...)
+	r.Preserve = append(r.Preserve, other.Preserve...)
+	r.Brackets = append(r.Brackets, other.Brackets...)
+	r.KeepSuffixes = append(r.KeepSuffixes, other.KeepSuffixes...)
+	if other.MaxSegments != 0 {
+		r.MaxSegments = other.MaxSegments
+	}
+	if other.ShortLength != 0 {
+		r.ShortLength = other.ShortLength
+	}
+	if other.PreserveMaxLength != 0 {
+		r.PreserveMaxLength = other.PreserveMaxLength
+	}
+	if other.SuffixMaxLength != 0 {
+		r.SuffixMaxLength = other.SuffixMaxLength
+	}
+	return r
+}
+
+// TitleCleaner 编译后的书名清理规则，可以在多个 goroutine 中并发使用
+type TitleCleaner struct {
+	rules    *TitleRules
+	strip    []*regexp.Regexp
+	preserve *regexp.Regexp
+	open     map[string]bool   // 左括号
+	pair     map[string]string // 右括号 -> 左括号
+}
+
+// Compile 校验并编译规则
+func (r *TitleRules) Compile() (*TitleCleaner, error) {
+	c := &TitleCleaner{
+		rules: r,
+		open:  make(map[string]bool, len(r.Brackets)),
+		pair:  make(map[string]string, len(r.Brackets)),
+	}
+
+	for _, p := range r.Strip {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("strip 规则 %q 无效: %w", p, err)
+		}
+		c.strip = append(c.strip, re)
+	}
+
+	for _, p := range r.Preserve {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("preserve 规则 %q 无效: %w", p, err)
+		}
+	}
+	if len(r.Preserve) > 0 {
+		c.preserve = regexp.MustCompile(strings.Join(r.Preserve, "|"))
+	}
+
+	for _, b := range r.Brackets {
+		if utf8.RuneCountInString(b[0]) != 1 || utf8.RuneCountInString(b[1]) != 1 {
+			return nil, fmt.Errorf("括号对 %q 无效: 左右括号必须各为一个字符", b)
+		}
+		c.open[b[0]] = true
+		c.pair[b[1]] = b[0]
+	}
+
+	if r.MaxSegments < 0 || r.ShortLength < 0 || r.PreserveMaxLength < 0 || r.SuffixMaxLength < 0 {
+		return nil, fmt.Errorf("长度阈值不能为负数")
+	}
+	return c, nil
+}
+
+// defaultTitleCleaner 内置规则编译结果，供 TryCleanTitle 使用
+var defaultTitleCleaner = mustCompileTitleRules(DefaultTitleRules())
+
+// mustCompileTitleRules 编译规则，失败时 panic，仅用于内置规则
+func mustCompileTitleRules(r *TitleRules) *TitleCleaner {
+	c, err := r.Compile()
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Clean 清理书名，清理后为空时返回原书名
+func (c *TitleCleaner) Clean(title string) string {
+	cleaned := title
+	for _, re := range c.strip {
+		cleaned = re.ReplaceAllString(cleaned, "")
+	}
+
+	outTitle := ""
+	for i, v := range c.split(cleaned) {
+		if i == 0 {
+			outTitle = v
+		} else if i <= c.rules.MaxSegments && c.keep(v) {
+			outTitle += v
+		}
+	}
+
+	// 去除首尾空格
+	outTitle = strings.ReplaceAll(outTitle, "\"", " ")
+	outTitle = strings.TrimSpace(outTitle)
+	if utf8.RuneCountInString(outTitle) == 0 {
+		return title
+	}
+	return outTitle
+}
+
+// split 按括号将书名切分为片段，嵌套的括号属于最外层片段
+func (c *TitleCleaner) split(title string) []string {
+	stack := &Stack{}
+	symbol := &Stack{}
+	word := ""
+
+	for _, r := range title {
+		char := string(r)
+		switch {
+		case c.open[char]:
+			if symbol.IsEmpty() && utf8.RuneCountInString(word) > 0 {
+				stack.Push(word)
+				word = char
+			} else {
+				word += char
+			}
+			symbol.Push(char)
+		case c.pair[char] != "":
+			word += char
+			if symbol.Peek() == c.pair[char] {
+				symbol.Pop()
+				stack.Push(word)
+				word = ""
+			}
+		default:
+			word += char
+		}
+	}
+
+	if symbol.IsEmpty() || len(word) != 0 {
+		stack.Push(word)
+	}
+	return stack.GetItems()
+}
+
+// keep 判断括号片段是否保留
+func (c *TitleCleaner) keep(content string) bool {
+	s := content
+	for _, b := range c.rules.Brackets {
+		s = strings.TrimPrefix(s, b[0])
+	}
+	for _, b := range c.rules.Brackets {
+		s = strings.TrimSuffix(s, b[1])
+	}
+
+	length := utf8.RuneCountInString(s)
+	if length <= c.rules.ShortLength {
+		return true
+	}
+	if c.preserve != nil && c.preserve.MatchString(s) && length < c.rules.PreserveMaxLength {
+		return true
+	}
+	for _, suffix := range c.rules.KeepSuffixes {
+		if strings.HasSuffix(s, suffix) && length < c.rules.SuffixMaxLength {
+			return true
+		}
+	}
+	return false
+}
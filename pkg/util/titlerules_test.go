@@ -0,0 +1,145 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRulesFile 写入规则文件并返回路径
+func writeRulesFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("无法写入规则文件: %v", err)
+	}
+	return path
+}
+
+// compileRulesFile 加载并编译规则文件
+func compileRulesFile(t *testing.T, content string) *TitleCleaner {
+	t.Helper()
+	rules, err := LoadTitleRules(writeRulesFile(t, content))
+	if err != nil {
+		t.Fatalf("LoadTitleRules 失败: %v", err)
+	}
+	cleaner, err := rules.Compile()
+	if err != nil {
+		t.Fatalf("Compile 失败: %v", err)
+	}
+	return cleaner
+}
+
+func TestTitleCleaner_DefaultRules(t *testing.T) {
+	cleaner, err := DefaultTitleRules().Compile()
+	if err != nil {
+		t.Fatalf("内置规则编译失败: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"具象之力（世界科幻大师丛书）", "具象之力"},
+		{"深入理解Java虚拟机（第3版）", "深入理解Java虚拟机（第3版）"},
+		{"成功企业这样管理（套装12册）", "成功企业这样管理（套装12册）"},
+		{"三体（修订版）", "三体（修订版）"},
+		{"他的秘密【澳洲小说天后莫里亚蒂成名作】", "他的秘密"},
+		{"【独家】", "【独家】"},
+	}
+	for _, tt := range tests {
+		if got := cleaner.Clean(tt.input); got != tt.expected {
+			t.Errorf("Clean(%q) = %q，期望 %q", tt.input, got, tt.expected)
+		}
+		if got := TryCleanTitle(tt.input); got != tt.expected {
+			t.Errorf("TryCleanTitle(%q) = %q，期望 %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadTitleRules_ExtendDefaults(t *testing.T) {
+	cleaner := compileRulesFile(t, `
+strip:
+  - '^\[[^\]]*\.com\]\s*'
+preserve:
+  - '典藏'
+brackets:
+  - ["〔", "〕"]
+`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 新增的 strip 规则
+		{"[sanqiu.com] 三体", "三体"},
+		// 新增的 preserve 规则
+		{"红楼梦（脂评汇校本典藏）", "红楼梦（脂评汇校本典藏）"},
+		// 新增的括号对
+		{"围城〔钱锺书代表作，现代文学经典之作〕", "围城"},
+		// 内置规则仍然生效
+		{"深入理解Java虚拟机（第3版）", "深入理解Java虚拟机（第3版）"},
+		{"具象之力（世界科幻大师丛书）", "具象之力"},
+	}
+	for _, tt := range tests {
+		if got := cleaner.Clean(tt.input); got != tt.expected {
+			t.Errorf("Clean(%q) = %q，期望 %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadTitleRules_Thresholds(t *testing.T) {
+	cleaner := compileRulesFile(t, "short_length: 8\n")
+
+	// 内容为 7 个字，内置规则会删除，阈值调整后保留
+	if got := cleaner.Clean("具象之力（世界科幻大师丛）"); got != "具象之力（世界科幻大师丛）" {
+		t.Errorf("Clean 结果 = %q", got)
+	}
+}
+
+func TestLoadTitleRules_Replace(t *testing.T) {
+	cleaner := compileRulesFile(t, `
+replace: true
+brackets:
+  - ["【", "】"]
+`)
+
+	// 只处理【】，圆括号不再视为括号
+	if got := cleaner.Clean("三体（第一部）【刘慈欣代表作，雨果奖获奖作品】"); got != "三体（第一部）" {
+		t.Errorf("Clean 结果 = %q", got)
+	}
+}
+
+func TestLoadTitleRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"无效的 YAML", "preserve: [", "无法解析规则文件"},
+		{"无效的正则", "preserve:\n  - '('\n", "preserve 规则"},
+		{"无效的括号对", "brackets:\n  - ['<<', '>>']\n", "括号对"},
+		{"负数阈值", "short_length: -1\n", "负数"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadTitleRules(writeRulesFile(t, tt.content))
+			if err == nil {
+				_, err = rules.Compile()
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("期望包含 %q 的错误，得到 %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	toml := filepath.Join(t.TempDir(), "rules.toml")
+	os.WriteFile(toml, []byte("max_segments = 3\n"), 0644)
+	if _, err := LoadTitleRules(toml); err == nil || !strings.Contains(err.Error(), "TOML") {
+		t.Errorf("TOML 规则文件期望返回错误，得到 %v", err)
+	}
+	if _, err := LoadTitleRules(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("规则文件不存在时期望返回错误")
+	}
+}
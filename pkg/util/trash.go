@@ -0,0 +1,298 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 回收站采用 freedesktop.org Trash 规范的目录结构：
+// files/ 中存放删除的文件，info/<名称>.trashinfo 记录原路径和删除时间，另外记录删除原因
+const (
+	trashFilesDir = "files"
+	trashInfoDir  = "info"
+	trashInfoExt  = ".trashinfo"
+	trashTimeFmt  = "2006-01-02T15:04:05"
+)
+
+// TrashItem 回收站中的一个文件
+type TrashItem struct {
+	Name         string    // 在回收站中的名称，同名文件添加 (1)、(2) 等后缀
+	Path         string    // 在回收站中的完整路径
+	OriginalPath string    // 删除前的绝对路径
+	DeletedAt    time.Time // 删除时间
+	Reason       string    // 删除原因，如检测到的错误
+	Size         int64
+}
+
+// Trash bookimporter 管理的回收站
+type Trash struct {
+	dir string
+}
+
+// DefaultTrashDir 返回默认的回收站目录 ~/.bookimporter/trash
+func DefaultTrashDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("无法确定用户目录: %w", err)
+	}
+	return filepath.Join(home, ".bookimporter", "trash"), nil
+}
+
+// OpenTrash 打开 dir 处的回收站，目录在第一次放入文件时创建
+func OpenTrash(dir string) *Trash {
+	return &Trash{dir: dir}
+}
+
+// OpenDefaultTrash 打开默认的回收站
+func OpenDefaultTrash() (*Trash, error) {
+	dir, err := DefaultTrashDir()
+	if err != nil {
+		return nil, err
+	}
+	return OpenTrash(dir), nil
+}
+
+// Dir 返回回收站目录
+func (t *Trash) Dir() string {
+	return t.dir
+}
+
+// Put 将文件移入回收站并记录原路径和删除原因
+// 先以独占方式创建 .trashinfo 占用名称，再移动文件；移动失败时删除 .trashinfo，文件保持不变
+func (t *Trash) Put(path, reason string) (*TrashItem, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("不能将目录移入回收站: %s", path)
+	}
+	for _, dir := range []string{trashFilesDir, trashInfoDir} {
+		if err := EnsureDir(filepath.Join(t.dir, dir)); err != nil {
+			return nil, fmt.Errorf("无法创建回收站目录: %w", err)
+		}
+	}
+
+	item := &TrashItem{
+		OriginalPath: abs,
+		DeletedAt:    time.Now().Truncate(time.Second),
+		Reason:       reason,
+		Size:         info.Size(),
+	}
+	infoFile, err := t.reserveName(item, filepath.Base(abs))
+	if err != nil {
+		return nil, err
+	}
+	infoPath := infoFile.Name()
+
+	_, err = fmt.Fprintf(infoFile, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), item.DeletedAt.Format(trashTimeFmt))
+	if err == nil && reason != "" {
+		_, err = fmt.Fprintf(infoFile, "Reason=%s\n", strings.Join(strings.Fields(reason), " "))
+	}
+	if err == nil {
+		err = infoFile.Sync()
+	}
+	if closeErr := infoFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(infoPath)
+		return nil, fmt.Errorf("无法写入回收站信息: %w", err)
+	}
+
+	if err := MoveFile(abs, item.Path); err != nil {
+		os.Remove(infoPath)
+		return nil, err
+	}
+	return item, nil
+}
+
+// reserveName 为文件选择回收站中未使用的名称，返回已创建的 .trashinfo 文件
+func (t *Trash) reserveName(item *TrashItem, base string) (*os.File, error) {
+	ext := FileExt(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 0; i < 10000; i++ {
+		name := base
+		if i > 0 {
+			name = fmt.Sprintf("%s(%d)%s", stem, i, ext)
+		}
+		path := filepath.Join(t.dir, trashFilesDir, name)
+		if Exists(path) {
+			continue
+		}
+		f, err := os.OpenFile(t.infoPath(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("无法写入回收站信息: %w", err)
+		}
+		item.Name, item.Path = name, path
+		return f, nil
+	}
+	return nil, fmt.Errorf("无法为 %s 找到可用的回收站名称（尝试了 10000 次）", base)
+}
+
+func (t *Trash) infoPath(name string) string {
+	return filepath.Join(t.dir, trashInfoDir, name+trashInfoExt)
+}
+
+// List 返回回收站中的所有文件，按删除时间排序，最早的在前
+// 缺少对应文件或无法解析的 .trashinfo 被忽略
+func (t *Trash) List() ([]TrashItem, error) {
+	matches, err := filepath.Glob(filepath.Join(t.dir, trashInfoDir, "*"+trashInfoExt))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []TrashItem
+	for _, infoPath := range matches {
+		name := strings.TrimSuffix(filepath.Base(infoPath), trashInfoExt)
+		item, err := t.readItem(name)
+		if err != nil {
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.Before(items[j].DeletedAt)
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+// readItem 读取名称为 name 的文件的信息
+func (t *Trash) readItem(name string) (*TrashItem, error) {
+	item := &TrashItem{Name: name, Path: filepath.Join(t.dir, trashFilesDir, name)}
+	info, err := os.Stat(item.Path)
+	if err != nil {
+		return nil, fmt.Errorf("回收站中没有 %s", name)
+	}
+	item.Size = info.Size()
+
+	f, err := os.Open(t.infoPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("回收站中没有 %s 的信息: %w", name, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			if item.OriginalPath, err = url.PathUnescape(value); err != nil {
+				return nil, fmt.Errorf("无法解析 %s 的原路径: %w", name, err)
+			}
+		case "DeletionDate":
+			item.DeletedAt, _ = time.ParseInLocation(trashTimeFmt, value, time.Local)
+		case "Reason":
+			item.Reason = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if item.OriginalPath == "" {
+		return nil, fmt.Errorf("%s 的信息中缺少原路径", name)
+	}
+	return item, nil
+}
+
+// Find 按回收站中的名称或原路径查找文件，原路径对应多个文件时返回最近删除的
+func (t *Trash) Find(nameOrPath string) (*TrashItem, error) {
+	if !strings.ContainsRune(nameOrPath, filepath.Separator) {
+		if item, err := t.readItem(nameOrPath); err == nil {
+			return item, nil
+		}
+	}
+
+	abs, err := filepath.Abs(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	items, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].OriginalPath == abs {
+			return &items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("回收站中没有 %s", nameOrPath)
+}
+
+// Restore 将回收站中名称为 name 的文件移回原路径，原路径已被占用时返回错误且不做改动
+func (t *Trash) Restore(name string) (*TrashItem, error) {
+	item, err := t.readItem(name)
+	if err != nil {
+		return nil, err
+	}
+	if Exists(item.OriginalPath) {
+		return nil, fmt.Errorf("原路径已存在文件: %s", item.OriginalPath)
+	}
+	if err := EnsureDir(filepath.Dir(item.OriginalPath)); err != nil {
+		return nil, err
+	}
+	if err := MoveFile(item.Path, item.OriginalPath); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(t.infoPath(name)); err != nil {
+		return item, fmt.Errorf("已恢复，但无法删除回收站信息: %w", err)
+	}
+	return item, nil
+}
+
+// Remove 永久删除回收站中名称为 name 的文件
+func (t *Trash) Remove(name string) error {
+	if err := os.Remove(filepath.Join(t.dir, trashFilesDir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(t.infoPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Purge 永久删除 before 之前删除的文件，before 为零值时清空回收站，返回已删除的文件
+func (t *Trash) Purge(before time.Time) ([]TrashItem, error) {
+	items, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	var purged []TrashItem
+	for _, item := range items {
+		if !before.IsZero() && !item.DeletedAt.Before(before) {
+			continue
+		}
+		if err := t.Remove(item.Name); err != nil {
+			return purged, fmt.Errorf("无法删除 %s: %w", item.Name, err)
+		}
+		purged = append(purged, item)
+	}
+	return purged, nil
+}
+
+// RestoreTrashedFile 将回收站中的文件移回原路径，trashedPath 为 TrashItem.Path
+func RestoreTrashedFile(trashedPath string) (*TrashItem, error) {
+	trash := OpenTrash(filepath.Dir(filepath.Dir(trashedPath)))
+	return trash.Restore(filepath.Base(trashedPath))
+}
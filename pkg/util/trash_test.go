@@ -0,0 +1,142 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrash_PutListRestore(t *testing.T) {
+	dir := t.TempDir()
+	trash := OpenTrash(filepath.Join(dir, "trash"))
+	book := filepath.Join(dir, "books", "三体 第一部.epub")
+	os.MkdirAll(filepath.Dir(book), 0755)
+	if err := os.WriteFile(book, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := trash.Put(book, "mimetype 缺失\n无法解析")
+	if err != nil {
+		t.Fatalf("Put 失败: %v", err)
+	}
+	if Exists(book) || !Exists(item.Path) {
+		t.Fatalf("文件未移入回收站: %s", item.Path)
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("List 返回 %d 个文件，期望 1", len(items))
+	}
+	got := items[0]
+	if got.Name != "三体 第一部.epub" || got.OriginalPath != book || got.Size != 7 {
+		t.Errorf("List 返回 %+v", got)
+	}
+	if got.Reason != "mimetype 缺失 无法解析" {
+		t.Errorf("Reason = %q", got.Reason)
+	}
+	if time.Since(got.DeletedAt) > time.Minute {
+		t.Errorf("DeletedAt = %v", got.DeletedAt)
+	}
+
+	// 按原路径查找
+	found, err := trash.Find(book)
+	if err != nil || found.Name != got.Name {
+		t.Fatalf("Find(%q) = %+v, %v", book, found, err)
+	}
+
+	// 原路径被占用时不恢复
+	os.WriteFile(book, []byte("new"), 0644)
+	if _, err := trash.Restore(got.Name); err == nil {
+		t.Fatal("原路径已存在时应返回错误")
+	}
+	os.Remove(book)
+	os.Remove(filepath.Dir(book))
+
+	if _, err := trash.Restore(got.Name); err != nil {
+		t.Fatalf("Restore 失败: %v", err)
+	}
+	if data, _ := os.ReadFile(book); string(data) != "content" {
+		t.Errorf("恢复后的内容 = %q", data)
+	}
+	if items, _ := trash.List(); len(items) != 0 {
+		t.Errorf("恢复后回收站中还有 %d 个文件", len(items))
+	}
+}
+
+func TestTrash_NameConflictAndPurge(t *testing.T) {
+	dir := t.TempDir()
+	trash := OpenTrash(filepath.Join(dir, "trash"))
+	for _, sub := range []string{"a", "b"} {
+		path := filepath.Join(dir, sub, "book.epub")
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(sub), 0644)
+		if _, err := trash.Put(path, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, _ := trash.List()
+	if len(items) != 2 || !Exists(filepath.Join(trash.Dir(), "files", "book(1).epub")) {
+		t.Fatalf("同名文件应添加后缀，List 返回 %+v", items)
+	}
+
+	// 同一原路径只对应自己的文件
+	found, err := trash.Find(filepath.Join(dir, "b", "book.epub"))
+	if err != nil || found.Name != "book(1).epub" {
+		t.Fatalf("Find 返回 %+v, %v", found, err)
+	}
+
+	// 修改其中一个的删除时间，只清理较早的
+	old := time.Now().Add(-48 * time.Hour).Format(trashTimeFmt)
+	os.WriteFile(trash.infoPath("book.epub"),
+		[]byte("[Trash Info]\nPath="+filepath.Join(dir, "a", "book.epub")+"\nDeletionDate="+old+"\n"), 0600)
+
+	purged, err := trash.Purge(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Name != "book.epub" {
+		t.Fatalf("Purge 返回 %+v", purged)
+	}
+	if Exists(purged[0].Path) {
+		t.Error("清理后文件仍存在")
+	}
+
+	if purged, _ := trash.Purge(time.Time{}); len(purged) != 1 {
+		t.Errorf("清空回收站返回 %d 个文件，期望 1", len(purged))
+	}
+}
+
+func TestSafeDeleteFile_Undo(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	book := filepath.Join(t.TempDir(), "broken.epub")
+	os.WriteFile(book, []byte("broken"), 0644)
+
+	item, err := SafeDeleteFile(book, "zip: not a valid zip file", false)
+	if err != nil {
+		t.Fatalf("SafeDeleteFile 失败: %v", err)
+	}
+	if Exists(book) {
+		t.Fatal("文件未被删除")
+	}
+	if dir, _ := DefaultTrashDir(); filepath.Dir(filepath.Dir(item.Path)) != dir {
+		t.Errorf("文件未移入默认回收站: %s", item.Path)
+	}
+
+	entry := &JournalEntry{Op: JournalDelete, Path: book, NewPath: item.Path}
+	if err := UndoJournalEntry(entry); err != nil {
+		t.Fatalf("撤销删除失败: %v", err)
+	}
+	if !Exists(book) || Exists(item.Path) {
+		t.Error("撤销后文件未回到原路径")
+	}
+
+	if err := UndoJournalEntry(&JournalEntry{Op: JournalDelete, Path: book}); err == nil {
+		t.Error("没有回收站路径的删除记录应无法撤销")
+	}
+}
@@ -0,0 +1,312 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// 文本编码名称
+const (
+	EncodingUTF8    = "UTF-8"
+	EncodingUTF16LE = "UTF-16LE"
+	EncodingUTF16BE = "UTF-16BE"
+	EncodingGB18030 = "GB18030"
+	EncodingBig5    = "Big5"
+)
+
+// encodingSampleSize 判断编码时解码的字节数
+const encodingSampleSize = 64 << 10
+
+// commonHanChars 常用汉字的简体和繁体字形，用于比较 GB18030 和 Big5 两种解码哪个更合理
+const commonHanChars = "的一是了我不人在他有这个上们来到时大地为子中你说生国年着就那和要她出也得里后自以会家可下而过天去能对小多然于心学么之都好看起发当没成只如事把还用第样道想作种开美总从无情己面最女但现前些所同日手又行意动方期它头经长儿回位分爱老因很给名法间知世什两次使身者被高已亲其进此话常与活正感" +
+	"這個們來時為說國著裡後會過對於學麼發當沒還樣種開從無現進話與動頭經長兒愛給間兩親"
+
+var commonHan = func() map[rune]bool {
+	m := make(map[rune]bool)
+	for _, r := range commonHanChars {
+		m[r] = true
+	}
+	return m
+}()
+
+// textEncodings 可自动识别的编码
+var textEncodings = map[string]encoding.Encoding{
+	EncodingUTF16LE: xunicode.UTF16(xunicode.LittleEndian, xunicode.ExpectBOM),
+	EncodingUTF16BE: xunicode.UTF16(xunicode.BigEndian, xunicode.ExpectBOM),
+	EncodingGB18030: simplifiedchinese.GB18030,
+	EncodingBig5:    traditionalchinese.Big5,
+}
+
+// DetectTextEncoding 判断文本的编码，返回 EncodingUTF8、EncodingGB18030 等
+// 有 BOM 时按 BOM 判断，合法的 UTF-8 视为 UTF-8；否则分别按 GB18030（兼容 GBK、GB2312）和 Big5
+// 解码开头的一段文本，取常用汉字较多、无法解码的字符较少的一种
+func DetectTextEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	sample := data
+	if len(sample) > encodingSampleSize {
+		sample = sample[:encodingSampleSize]
+	}
+	// 截取的样本末尾可能是不完整的字符
+	for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		if len(sample) == len(data) {
+			break
+		}
+		sample = sample[:len(sample)-1]
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+
+	if hanScore(sample, traditionalchinese.Big5) > hanScore(sample, simplifiedchinese.GB18030) {
+		return EncodingBig5
+	}
+	return EncodingGB18030
+}
+
+// hanScore 按 enc 解码 sample，常用汉字加分，无法解码的字符减分
+func hanScore(sample []byte, enc encoding.Encoding) int {
+	decoded, err := enc.NewDecoder().Bytes(sample)
+	if err != nil {
+		return -len(sample)
+	}
+	score := 0
+	for _, r := range string(decoded) {
+		switch {
+		case r == utf8.RuneError:
+			score -= 10
+		case commonHan[r]:
+			score++
+		}
+	}
+	return score
+}
+
+// DecodeText 将文本转换为 UTF-8，去除 BOM 并将换行统一为 "\n"，返回文本和实际使用的编码
+// encodingName 为空时自动判断，否则按名称指定编码，如 "gbk"、"big5"、"utf-8"
+func DecodeText(data []byte, encodingName string) (string, string, error) {
+	name := encodingName
+	if name == "" {
+		name = DetectTextEncoding(data)
+	}
+
+	var enc encoding.Encoding
+	if e, ok := textEncodings[name]; ok {
+		enc = e
+	} else if !strings.EqualFold(name, EncodingUTF8) && !strings.EqualFold(name, "utf8") {
+		e, err := htmlindex.Get(name)
+		if err != nil {
+			return "", "", fmt.Errorf("不支持的编码 %q", encodingName)
+		}
+		enc = e
+		if n, err := htmlindex.Name(e); err == nil && n != "utf-8" {
+			name = n
+		}
+	}
+
+	if enc != nil {
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", "", fmt.Errorf("按 %s 解码失败: %w", name, err)
+		}
+		data = decoded
+	} else {
+		name = EncodingUTF8
+	}
+
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return text, name, nil
+}
+
+// 默认的章节标题规则
+const (
+	// DefaultChapterPattern 章标题：第X章/回/节、Chapter N，以及序章、楔子、番外等
+	DefaultChapterPattern = `^(?:第[` + numeralChars + `]+[章回节]|(?i:chapter\s*(?:[0-9]+|[ivxlc]+)\b)|(?:序章|序言|楔子|引子|终章|尾声|后记|番外|完本感言)(?:\s|$|[:：\p{Han}]))`
+	// DefaultVolumePattern 卷标题：第X卷/部/集、卷X
+	DefaultVolumePattern = `^(?:第[` + numeralChars + `]+[卷部集]|卷[` + numeralChars + `]+)(?:\s|$|[:：\p{Han}])`
+	// DefaultMaxHeadingLength 标题的最大字数，超过时视为正文
+	DefaultMaxHeadingLength = 40
+)
+
+// txtPartSize 没有章节标题时每部分的字数
+const txtPartSize = 20000
+
+// ChapterRules 切分章节的标题规则，按去除首尾空白后的整行匹配
+type ChapterRules struct {
+	Chapter    *regexp.Regexp // 章标题
+	Volume     *regexp.Regexp // 卷标题，nil 表示不分卷
+	MaxHeading int            // 标题的最大字数，避免把以 "第一章" 开头的正文当作标题
+}
+
+// NewChapterRules 编译章和卷的标题正则，chapter 为空时使用默认规则，volume 为空时不分卷
+func NewChapterRules(chapter, volume string) (*ChapterRules, error) {
+	if chapter == "" {
+		chapter = DefaultChapterPattern
+	}
+	r := &ChapterRules{MaxHeading: DefaultMaxHeadingLength}
+	var err error
+	if r.Chapter, err = regexp.Compile(chapter); err != nil {
+		return nil, fmt.Errorf("无效的章标题正则 %q: %w", chapter, err)
+	}
+	if volume != "" {
+		if r.Volume, err = regexp.Compile(volume); err != nil {
+			return nil, fmt.Errorf("无效的卷标题正则 %q: %w", volume, err)
+		}
+	}
+	return r, nil
+}
+
+// DefaultChapterRules 返回默认的章和卷标题规则
+func DefaultChapterRules() *ChapterRules {
+	r, err := NewChapterRules(DefaultChapterPattern, DefaultVolumePattern)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// TxtChapter 从文本中切分出的一章
+type TxtChapter struct {
+	Title      string
+	Volume     bool     // 卷标题，之后的章节在目录中位于该卷之下
+	Paragraphs []string // 去除首尾空白后的非空行
+}
+
+// Split 按标题将文本切分为章节
+// 第一个标题之前的内容作为 "前言"；没有正文的章节（如开头的目录）和其后没有章节的卷被丢弃；
+// 没有匹配到任何标题时按字数切分
+func (r *ChapterRules) Split(text string) []TxtChapter {
+	var chapters []TxtChapter
+	current := TxtChapter{Title: "前言"}
+	found := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if volume, ok := r.heading(line); ok {
+			chapters = append(chapters, current)
+			current = TxtChapter{Title: normalizeSpaces(line), Volume: volume}
+			found = true
+			continue
+		}
+		current.Paragraphs = append(current.Paragraphs, line)
+	}
+	chapters = append(chapters, current)
+
+	if !found {
+		return splitTxtParts(current.Paragraphs)
+	}
+
+	var result []TxtChapter
+	for _, c := range chapters {
+		if c.Volume || len(c.Paragraphs) > 0 {
+			result = append(result, c)
+		}
+	}
+	// 去除空章节后，卷后紧跟另一卷或已到末尾时，该卷没有章节
+	chapters, result = result, nil
+	for i, c := range chapters {
+		if !c.Volume || len(c.Paragraphs) > 0 || i+1 < len(chapters) && !chapters[i+1].Volume {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// heading 判断一行是否为标题，返回是否为卷标题
+func (r *ChapterRules) heading(line string) (volume bool, ok bool) {
+	if r.MaxHeading > 0 && utf8.RuneCountInString(line) > r.MaxHeading {
+		return false, false
+	}
+	if r.Volume != nil && r.Volume.MatchString(line) {
+		return true, true
+	}
+	return false, r.Chapter.MatchString(line)
+}
+
+// splitTxtParts 将没有标题的文本按字数切分为若干部分，只有一部分时标题为 "正文"
+func splitTxtParts(paragraphs []string) []TxtChapter {
+	var parts []TxtChapter
+	var current []string
+	size := 0
+	for _, p := range paragraphs {
+		current = append(current, p)
+		size += utf8.RuneCountInString(p)
+		if size >= txtPartSize {
+			parts = append(parts, TxtChapter{Paragraphs: current})
+			current, size = nil, 0
+		}
+	}
+	if len(current) > 0 || len(parts) == 0 {
+		parts = append(parts, TxtChapter{Paragraphs: current})
+	}
+	if len(parts) == 1 {
+		parts[0].Title = "正文"
+		return parts
+	}
+	for i := range parts {
+		parts[i].Title = fmt.Sprintf("第 %d 部分", i+1)
+	}
+	return parts
+}
+
+var (
+	// reBookTitleMarks 书名号中的书名
+	reBookTitleMarks = regexp.MustCompile(`《([^》]+)》`)
+	// reAuthorMarker 文件名中作者前的标记，如 "作者："、" by "
+	reAuthorMarker = regexp.MustCompile(`(?i)(?:作者|著者)\s*[:：]\s*|\s+by\s+`)
+	// reAuthorSuffix 作者后的括号说明和多余的 "著"、"作品"，如 "【完结】"
+	reAuthorSuffix = regexp.MustCompile(`(?:\s*(?:[\[［【(（〔][^\]］】)）〕]*[\]］】)）〕]|著|作品))+$`)
+)
+
+// ParseBookFileName 使用默认规则从文件名中解析书名和作者，见 TitleCleaner.ParseFileName
+func ParseBookFileName(fileName string) (string, []string) {
+	return defaultTitleCleaner.ParseFileName(fileName)
+}
+
+// ParseFileName 从文件名中解析书名和作者，如 "《斗破苍穹》（校对版全本）作者：天蚕土豆.txt"
+// 书名取书名号中的内容，没有书名号时取作者标记之前的部分，并按规则清理；
+// 作者取 "作者："、" by " 等标记之后的部分，去除末尾的括号说明并拆分多个作者，没有标记时为空
+func (c *TitleCleaner) ParseFileName(fileName string) (string, []string) {
+	name := filepath.Base(fileName)
+	name = normalizeSpaces(strings.TrimSuffix(name, FileExt(name)))
+
+	title := name
+	var authors []string
+	if loc := reAuthorMarker.FindStringIndex(name); loc != nil && loc[0] > 0 {
+		title = name[:loc[0]]
+		author := reAuthorSuffix.ReplaceAllString(strings.TrimSpace(name[loc[1]:]), "")
+		for _, creator := range NormalizeCreators([]Creator{{Name: author}}) {
+			authors = append(authors, creator.Name)
+		}
+	}
+
+	if m := reBookTitleMarks.FindStringSubmatch(title); m != nil {
+		title = m[1]
+	}
+	title = strings.TrimSpace(c.Clean(strings.TrimSpace(title)))
+	if title == "" {
+		title = name
+	}
+	return title, authors
+}
@@ -0,0 +1,255 @@
+package util
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const testTxtNovel = `内容简介：少年离家的故事。
+目录
+第一卷 风起
+第一章 开始
+
+第一卷 风起
+　　第一章 开始
+　　少年站在山顶，望着远方的云海。
+　　他说："我们一定会回来的。"
+
+第二章　出发
+　　他们离开了家乡。
+第二卷 云涌
+第三章 重逢
+　　多年以后，他们在城中重逢。
+第四章 这一行很长很长很长很长很长很长很长很长很长很长很长很长很长很长很长很长很长很长，不是标题
+番外 后来的事
+　　这是番外。
+`
+
+func TestDecodeText(t *testing.T) {
+	simplified := "第一章 开始\n　　这是一个关于我们的故事，他们来到这里。\r\n"
+	traditional := "第一章 開始\n　　這是一個關於我們的故事，他們來到這裡。\r\n"
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(simplified)
+	big5, _ := traditionalchinese.Big5.NewEncoder().String(traditional)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		wantEnc  string
+		want     string
+	}{
+		{"UTF-8", []byte(simplified), "", EncodingUTF8, simplified},
+		{"UTF-8 BOM", []byte("\ufeff" + simplified), "", EncodingUTF8, simplified},
+		{"GBK", []byte(gbk), "", EncodingGB18030, simplified},
+		{"Big5", []byte(big5), "", EncodingBig5, traditional},
+		{"UTF-16LE", []byte{0xFF, 0xFE, 'a', 0, 0x2D, 0x4E}, "", EncodingUTF16LE, "a中"},
+		{"指定编码", []byte(gbk), "gbk", "gbk", simplified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, enc, err := DecodeText(tt.data, tt.encoding)
+			if err != nil {
+				t.Fatalf("DecodeText 失败: %v", err)
+			}
+			if enc != tt.wantEnc {
+				t.Errorf("编码 = %q, want %q", enc, tt.wantEnc)
+			}
+			if want := strings.ReplaceAll(tt.want, "\r\n", "\n"); got != want {
+				t.Errorf("文本 = %q, want %q", got, want)
+			}
+		})
+	}
+
+	if _, _, err := DecodeText([]byte("x"), "no-such-encoding"); err == nil {
+		t.Error("未知编码期望返回错误")
+	}
+}
+
+func TestChapterRules_Split(t *testing.T) {
+	chapters := DefaultChapterRules().Split(testTxtNovel)
+
+	var got []string
+	for _, c := range chapters {
+		title := c.Title
+		if c.Volume {
+			title = "[卷]" + title
+		}
+		got = append(got, title)
+	}
+	want := []string{"前言", "[卷]第一卷 风起", "第一章 开始", "第二章 出发", "[卷]第二卷 云涌", "第三章 重逢", "番外 后来的事"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("章节 = %v, want %v", got, want)
+	}
+	if p := chapters[2].Paragraphs; len(p) != 2 || p[0] != "少年站在山顶，望着远方的云海。" {
+		t.Errorf("第一章段落 = %q", p)
+	}
+	// 过长的行不是标题
+	if p := chapters[5].Paragraphs; len(p) != 2 {
+		t.Errorf("第三章段落 = %q", p)
+	}
+
+	// 不分卷
+	rules, err := NewChapterRules("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rules.Split(testTxtNovel) {
+		if c.Volume || strings.Contains(c.Title, "卷") {
+			t.Errorf("不分卷时不应有卷标题: %q", c.Title)
+		}
+	}
+
+	// 英文章节
+	english := DefaultChapterRules().Split("Chapter 1 Beginning\nIt was night.\nCHAPTER II\nThe end.\n")
+	if len(english) != 2 || english[1].Title != "CHAPTER II" {
+		t.Errorf("英文章节 = %+v", english)
+	}
+
+	// 没有标题时按字数切分
+	parts := DefaultChapterRules().Split("只有一段正文。\n第二段。")
+	if len(parts) != 1 || parts[0].Title != "正文" || len(parts[0].Paragraphs) != 2 {
+		t.Errorf("无标题 = %+v", parts)
+	}
+	long := strings.Repeat(strings.Repeat("字", 1000)+"\n", 45)
+	if parts := DefaultChapterRules().Split(long); len(parts) != 3 || parts[2].Title != "第 3 部分" {
+		t.Errorf("按字数切分得到 %d 部分", len(parts))
+	}
+
+	if _, err := NewChapterRules("第(", ""); err == nil {
+		t.Error("无效的正则期望返回错误")
+	}
+}
+
+func TestParseBookFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		authors []string
+	}{
+		{"《斗破苍穹》（校对版全本）作者：天蚕土豆.txt", "斗破苍穹", []string{"天蚕土豆"}},
+		{"/novels/诡秘之主（精校无错排版TXT） 作者: 爱潜水的乌贼【完结】.txt", "诡秘之主", []string{"爱潜水的乌贼"}},
+		{"笑傲江湖 by 金庸.txt", "笑傲江湖", []string{"金庸"}},
+		{"三体（网络收集整理TXT）.txt", "三体", nil},
+		{"某书 作者：[美] 张三、李四 著.txt", "某书", []string{"张三", "李四"}},
+		{"《雪中悍刀行》.txt", "雪中悍刀行", nil},
+		{"作者：佚名.txt", "作者：佚名", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, authors := ParseBookFileName(tt.name)
+			if title != tt.title {
+				t.Errorf("书名 = %q, want %q", title, tt.title)
+			}
+			if strings.Join(authors, "、") != strings.Join(tt.authors, "、") {
+				t.Errorf("作者 = %q, want %q", authors, tt.authors)
+			}
+		})
+	}
+}
+
+func TestTxtBook_WriteEpub(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "novel.epub")
+	book := &TxtBook{
+		Title:    "风起 & 云涌",
+		Authors:  []string{"天蚕土豆", "某人"},
+		Chapters: DefaultChapterRules().Split(testTxtNovel),
+	}
+	if err := book.WriteEpub(path); err != nil {
+		t.Fatalf("WriteEpub 失败: %v", err)
+	}
+
+	if err := NewEpubValidator(ValidateOptions{Deep: true, Conformance: true}).Validate(path); err != nil {
+		t.Errorf("生成的 EPUB 未通过检测: %v", err)
+	}
+	meta, err := ReadEpubMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadEpubMetadata 失败: %v", err)
+	}
+	if meta.Title != book.Title || len(meta.Creators) != 2 || meta.Creators[0].Name != "天蚕土豆" || meta.Creators[0].Role != "aut" {
+		t.Errorf("元数据 = %+v", meta)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("新文件权限 = %v", info.Mode().Perm())
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		var b strings.Builder
+		buf := make([]byte, 4096)
+		for {
+			n, err := rc.Read(buf)
+			b.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		rc.Close()
+		files[f.Name] = b.String()
+	}
+	if zr.File[0].Name != "mimetype" {
+		t.Errorf("第一个条目 = %s", zr.File[0].Name)
+	}
+	if nav := files["OEBPS/nav.xhtml"]; !strings.Contains(nav, `epub:type="toc"`) ||
+		!strings.Contains(nav, `<li><a href="text/0002.xhtml">第一卷 风起</a>`+"\n        <ol>") {
+		t.Errorf("nav 目录不正确:\n%s", nav)
+	}
+	if ncx := files["OEBPS/toc.ncx"]; !strings.Contains(ncx, `<meta name="dtb:depth" content="2"/>`) ||
+		strings.Count(ncx, "<navPoint ") != len(book.Chapters) {
+		t.Errorf("NCX 目录不正确:\n%s", ncx)
+	}
+	if opf := files["OEBPS/content.opf"]; !strings.Contains(opf, `<spine toc="ncx">`) || !strings.Contains(opf, `properties="nav"`) {
+		t.Errorf("OPF 缺少 nav 或 NCX:\n%s", opf)
+	}
+	if c := files["OEBPS/text/0003.xhtml"]; !strings.Contains(c, "<h2>第一章 开始</h2>") || !strings.Contains(c, "<p>他说：&#34;我们一定会回来的。&#34;</p>") {
+		t.Errorf("章节内容不正确:\n%s", c)
+	}
+
+	if err := (&TxtBook{Title: "空"}).WriteEpub(path); err == nil {
+		t.Error("没有章节时期望返回错误")
+	}
+}
+
+func TestTxtBook_ReplaceEpub(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "novel.epub")
+	os.WriteFile(path, []byte("old"), 0644)
+	book := &TxtBook{Title: "风起", Chapters: DefaultChapterRules().Split(testTxtNovel)}
+
+	// prepare 失败时放弃写入，原文件和目录保持不变
+	if err := book.ReplaceEpub(path, func() error { return errors.New("busy") }); err == nil {
+		t.Error("prepare 失败时期望返回错误")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("原文件被修改: %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("期望清理临时文件，得到 %d 个文件", len(entries))
+	}
+
+	// prepare 在新文件写入之后调用，可以移走原文件
+	err := book.ReplaceEpub(path, func() error {
+		if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+			t.Errorf("调用 prepare 时新文件应已写入临时文件")
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		t.Fatalf("ReplaceEpub 失败: %v", err)
+	}
+	if meta, err := ReadEpubMetadata(path); err != nil || meta.Title != book.Title {
+		t.Errorf("ReadEpubMetadata = %+v, %v", meta, err)
+	}
+}
@@ -0,0 +1,272 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// TxtBook 由纯文本转换而来的书籍
+type TxtBook struct {
+	Title    string
+	Authors  []string
+	Language string // 语言代码，如 "zh"，为空时使用 "zh"
+	Chapters []TxtChapter
+}
+
+// txtEpubStyle 生成的 EPUB 使用的样式
+const txtEpubStyle = `body { margin: 0 5%; line-height: 1.6; }
+h1, h2 { text-align: center; margin: 2em 0 1em; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; }
+p { text-indent: 2em; margin: 0.3em 0; }
+`
+
+// tocNode 目录中的一项，Children 为卷下的章节序号
+type tocNode struct {
+	Index    int
+	Children []int
+}
+
+// WriteEpub 生成 EPUB 3 文件，同时包含 nav 和 NCX 目录
+// 先写入同目录的临时文件，完成后重命名为 filePath
+func (b *TxtBook) WriteEpub(filePath string) error {
+	return b.ReplaceEpub(filePath, nil)
+}
+
+// ReplaceEpub 与 WriteEpub 相同，但在临时文件写入成功之后、重命名之前调用 prepare，
+// 用于移走已存在的文件。prepare 返回错误时放弃写入，filePath 保持不变
+func (b *TxtBook) ReplaceEpub(filePath string, prepare func() error) error {
+	entries, err := b.epubEntries()
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(filePath)
+	tmpPath, err := writeTempFile(filePath, func(w io.Writer) error {
+		return writeEpubArchive(w, entries)
+	})
+	if err != nil {
+		return err
+	}
+	if prepare != nil {
+		if err := prepare(); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	// 新文件使用常规权限，而不是临时文件的 0600
+	if os.IsNotExist(statErr) {
+		os.Chmod(tmpPath, 0o644)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("无法写入文件: %w", err)
+	}
+	return nil
+}
+
+// epubEntries 生成 EPUB 中除 mimetype 外的所有文件
+func (b *TxtBook) epubEntries() ([]*zipEntry, error) {
+	if len(b.Chapters) == 0 {
+		return nil, fmt.Errorf("没有可转换的内容")
+	}
+	uid, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	lang := b.Language
+	if lang == "" {
+		lang = "zh"
+	}
+
+	entries := []*zipEntry{
+		{Name: "META-INF/container.xml", data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)},
+		{Name: "OEBPS/content.opf", data: []byte(b.renderOpf(uid, lang))},
+		{Name: "OEBPS/nav.xhtml", data: []byte(b.renderNav(lang))},
+		{Name: "OEBPS/toc.ncx", data: []byte(b.renderNcx(uid))},
+		{Name: "OEBPS/style.css", data: []byte(txtEpubStyle)},
+	}
+	for i, c := range b.Chapters {
+		entries = append(entries, &zipEntry{
+			Name: "OEBPS/" + chapterHref(i),
+			data: []byte(renderChapter(c, lang)),
+		})
+	}
+	return entries, nil
+}
+
+// toc 按卷组织目录：卷之后的章节属于该卷，第一个卷之前的章节位于顶层
+func (b *TxtBook) toc() []tocNode {
+	var nodes []tocNode
+	volume := -1
+	for i, c := range b.Chapters {
+		switch {
+		case c.Volume:
+			nodes = append(nodes, tocNode{Index: i})
+			volume = len(nodes) - 1
+		case volume >= 0:
+			nodes[volume].Children = append(nodes[volume].Children, i)
+		default:
+			nodes = append(nodes, tocNode{Index: i})
+		}
+	}
+	return nodes
+}
+
+func (b *TxtBook) renderOpf(uid, lang string) string {
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&s, "    <dc:identifier id=\"bookid\">urn:uuid:%s</dc:identifier>\n", uid)
+	fmt.Fprintf(&s, "    <dc:title>%s</dc:title>\n", xmlText(b.Title))
+	for i, author := range b.Authors {
+		fmt.Fprintf(&s, "    <dc:creator id=\"creator%d\">%s</dc:creator>\n", i+1, xmlText(author))
+		fmt.Fprintf(&s, "    <meta refines=\"#creator%d\" property=\"role\" scheme=\"marc:relators\">aut</meta>\n", i+1)
+	}
+	fmt.Fprintf(&s, "    <dc:language>%s</dc:language>\n", xmlText(lang))
+	fmt.Fprintf(&s, "    <meta property=\"dcterms:modified\">%s</meta>\n", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	s.WriteString(`  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+`)
+	for i := range b.Chapters {
+		fmt.Fprintf(&s, "    <item id=\"c%04d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, chapterHref(i))
+	}
+	s.WriteString("  </manifest>\n  <spine toc=\"ncx\">\n")
+	for i := range b.Chapters {
+		fmt.Fprintf(&s, "    <itemref idref=\"c%04d\"/>\n", i+1)
+	}
+	s.WriteString("  </spine>\n</package>\n")
+	return s.String()
+}
+
+func (b *TxtBook) renderNav(lang string) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head>
+  <title>目录</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>目录</h1>
+    <ol>
+`, xmlText(lang), xmlText(lang))
+	link := func(i int) string {
+		return fmt.Sprintf(`<a href="%s">%s</a>`, chapterHref(i), xmlText(b.Chapters[i].Title))
+	}
+	for _, node := range b.toc() {
+		if len(node.Children) == 0 {
+			fmt.Fprintf(&s, "      <li>%s</li>\n", link(node.Index))
+			continue
+		}
+		fmt.Fprintf(&s, "      <li>%s\n        <ol>\n", link(node.Index))
+		for _, child := range node.Children {
+			fmt.Fprintf(&s, "          <li>%s</li>\n", link(child))
+		}
+		s.WriteString("        </ol>\n      </li>\n")
+	}
+	s.WriteString("    </ol>\n  </nav>\n</body>\n</html>\n")
+	return s.String()
+}
+
+func (b *TxtBook) renderNcx(uid string) string {
+	nodes := b.toc()
+	depth := 1
+	for _, node := range nodes {
+		if len(node.Children) > 0 {
+			depth = 2
+		}
+	}
+
+	var s strings.Builder
+	fmt.Fprintf(&s, `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="urn:uuid:%s"/>
+    <meta name="dtb:depth" content="%d"/>
+    <meta name="dtb:totalPageCount" content="0"/>
+    <meta name="dtb:maxPageNumber" content="0"/>
+  </head>
+  <docTitle><text>%s</text></docTitle>
+  <navMap>
+`, uid, depth, xmlText(b.Title))
+	point := func(i int, indent string) {
+		fmt.Fprintf(&s, "%s<navPoint id=\"np%d\" playOrder=\"%d\">\n", indent, i+1, i+1)
+		fmt.Fprintf(&s, "%s  <navLabel><text>%s</text></navLabel>\n", indent, xmlText(b.Chapters[i].Title))
+		fmt.Fprintf(&s, "%s  <content src=\"%s\"/>\n", indent, chapterHref(i))
+	}
+	for _, node := range nodes {
+		point(node.Index, "    ")
+		for _, child := range node.Children {
+			point(child, "      ")
+			s.WriteString("      </navPoint>\n")
+		}
+		s.WriteString("    </navPoint>\n")
+	}
+	s.WriteString("  </navMap>\n</ncx>\n")
+	return s.String()
+}
+
+// renderChapter 生成一章的 XHTML，卷标题使用 h1，章标题使用 h2
+func renderChapter(c TxtChapter, lang string) string {
+	tag := "h2"
+	if c.Volume {
+		tag = "h1"
+	}
+	var s strings.Builder
+	fmt.Fprintf(&s, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="%s" lang="%s">
+<head>
+  <title>%s</title>
+  <link rel="stylesheet" type="text/css" href="../style.css"/>
+</head>
+<body>
+  <%s>%s</%s>
+`, xmlText(lang), xmlText(lang), xmlText(c.Title), tag, xmlText(c.Title), tag)
+	for _, p := range c.Paragraphs {
+		fmt.Fprintf(&s, "  <p>%s</p>\n", xmlText(p))
+	}
+	s.WriteString("</body>\n</html>\n")
+	return s.String()
+}
+
+// chapterHref 第 i 章在 OEBPS 中的路径
+func chapterHref(i int) string {
+	return fmt.Sprintf("text/%04d.xhtml", i+1)
+}
+
+// xmlText 转义文本，无效的 XML 字符替换为 U+FFFD
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// newUUID 生成随机的 UUID（版本 4）
+func newUUID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", fmt.Errorf("无法生成标识符: %w", err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
@@ -0,0 +1,257 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kapmahc/epub"
+)
+
+// EpubArchive 已打开的 EPUB 归档，供各检测阶段共享
+// container.xml 和 OPF 在首次访问时解析并缓存
+type EpubArchive struct {
+	Path   string
+	Reader *zip.Reader
+
+	files     map[string]*zip.File
+	container *epub.Container
+	opf       *epub.Opf
+}
+
+// newEpubArchive 基于 zip.Reader 创建归档
+func newEpubArchive(filePath string, r *zip.Reader) *EpubArchive {
+	a := &EpubArchive{
+		Path:   filePath,
+		Reader: r,
+		files:  make(map[string]*zip.File, len(r.File)),
+	}
+	for _, f := range r.File {
+		name := strings.TrimPrefix(f.Name, "./")
+		if _, exists := a.files[name]; !exists {
+			a.files[name] = f
+		}
+	}
+	return a
+}
+
+// File 按名称查找条目，兼容 "./" 前缀，不存在时返回 nil
+func (a *EpubArchive) File(name string) *zip.File {
+	return a.files[strings.TrimPrefix(name, "./")]
+}
+
+// ReadFile 读取条目的完整内容
+func (a *EpubArchive) ReadFile(name string) ([]byte, error) {
+	f := a.File(name)
+	if f == nil {
+		return nil, fmt.Errorf("文件 %s 不存在", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Container 返回解析后的 META-INF/container.xml
+func (a *EpubArchive) Container() (*epub.Container, error) {
+	if a.container != nil {
+		return a.container, nil
+	}
+	data, err := a.ReadFile("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	var c epub.Container
+	if err := xml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("container.xml 解析失败: %w", err)
+	}
+	a.container = &c
+	return a.container, nil
+}
+
+// OpfPath 返回 container.xml 中声明的 OPF 路径
+func (a *EpubArchive) OpfPath() (string, error) {
+	c, err := a.Container()
+	if err != nil {
+		return "", err
+	}
+	if c.Rootfile.Path == "" {
+		return "", fmt.Errorf("container.xml 未指定 OPF 路径")
+	}
+	return c.Rootfile.Path, nil
+}
+
+// Opf 返回解析后的 OPF
+func (a *EpubArchive) Opf() (*epub.Opf, error) {
+	if a.opf != nil {
+		return a.opf, nil
+	}
+	opfPath, err := a.OpfPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.ReadFile(opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var opf epub.Opf
+	if err := xml.Unmarshal(data, &opf); err != nil {
+		return nil, fmt.Errorf("OPF 解析失败: %w", err)
+	}
+	a.opf = &opf
+	return a.opf, nil
+}
+
+// ResolveHref 将 OPF 中的相对路径转换为归档内的条目名
+// href 是 URL，会去掉片段标识并解码百分号转义
+func (a *EpubArchive) ResolveHref(href string) string {
+	opfPath, _ := a.OpfPath()
+	return resolveOpfHref(opfPath, href)
+}
+
+// resolveOpfHref 将 opfPath 中的相对路径 href 转换为归档内的条目名
+func resolveOpfHref(opfPath, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(opfPath), href)
+}
+
+// ValidationStage 检测阶段，Check 返回 nil 表示通过
+type ValidationStage struct {
+	Name  string
+	Check func(a *EpubArchive) error
+}
+
+// Validator EPUB 检测器
+// 文件只打开一次，所有阶段在同一个 zip.Reader 上依次执行，遇到第一个错误即返回
+type Validator struct {
+	stages []ValidationStage
+}
+
+// NewValidator 使用指定的检测阶段创建检测器
+func NewValidator(stages ...ValidationStage) *Validator {
+	return &Validator{stages: stages}
+}
+
+// ValidateOptions 内置检测器的选项
+type ValidateOptions struct {
+	// Deep 完整读取每个条目，校验 CRC32 和解压后大小
+	// 默认只读取每个条目开头的 1KB，无法发现条目尾部的截断或位损坏
+	Deep bool
+
+	// Conformance 检查 OCF/OPF 结构规范：mimetype 条目、rootfile、manifest、spine 和内容文档的编码
+	// 不规范但内容完好的文件仍然可以阅读，因此默认不检查
+	Conformance bool
+}
+
+// NewEpubValidator 根据选项创建包含内置检测阶段的检测器
+func NewEpubValidator(opts ValidateOptions) *Validator {
+	zipStage := ValidationStage{Name: "zip", Check: checkZipIntegrity}
+	if opts.Deep {
+		zipStage = ValidationStage{Name: "zip-deep", Check: checkZipIntegrityDeep}
+	}
+
+	v := NewValidator(
+		zipStage,
+		ValidationStage{Name: "required-files", Check: checkRequiredFiles},
+	)
+	if opts.Conformance {
+		v.AddStage(ValidationStage{Name: "mimetype", Check: checkMimetype})
+		v.AddStage(ValidationStage{Name: "rootfile", Check: checkRootfile})
+	}
+	v.AddStage(ValidationStage{Name: "metadata", Check: checkMetadata})
+	if opts.Conformance {
+		v.AddStage(ValidationStage{Name: "manifest", Check: checkManifest})
+		v.AddStage(ValidationStage{Name: "spine", Check: checkSpine})
+		v.AddStage(ValidationStage{Name: "encoding", Check: checkContentEncoding})
+	}
+	return v
+}
+
+// DefaultValidator 创建包含默认检测阶段的检测器：ZIP 完整性、必需文件、元数据
+func DefaultValidator() *Validator {
+	return NewEpubValidator(ValidateOptions{})
+}
+
+// AddStage 在末尾追加检测阶段
+func (v *Validator) AddStage(stage ValidationStage) *Validator {
+	v.stages = append(v.stages, stage)
+	return v
+}
+
+// Stages 返回所有检测阶段
+func (v *Validator) Stages() []ValidationStage {
+	return v.stages
+}
+
+// Profile 返回由各阶段名称组成的标识，如 "zip,required-files,metadata"，用于区分不同配置的检测结果
+func (v *Validator) Profile() string {
+	names := make([]string, len(v.stages))
+	for i, stage := range v.stages {
+		names[i] = stage.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// Validate 检测 EPUB 文件
+// 返回 nil 表示文件正常，返回 EpubError 表示检测到问题
+func (v *Validator) Validate(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &EpubError{
+				Type:    ErrorTypeCorrupted,
+				Message: "文件不存在",
+				Detail:  filePath,
+			}
+		}
+		return &EpubError{
+			Type:    ErrorTypeCorrupted,
+			Message: "无法打开文件",
+			Detail:  err.Error(),
+		}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return &EpubError{
+			Type:    ErrorTypeCorrupted,
+			Message: "无法打开文件",
+			Detail:  err.Error(),
+		}
+	}
+
+	return v.ValidateReader(filePath, f, info.Size())
+}
+
+// ValidateReader 检测已打开的 EPUB 数据
+func (v *Validator) ValidateReader(filePath string, r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return &EpubError{
+			Type:    ErrorTypeCorrupted,
+			Message: "ZIP 文件损坏",
+			Detail:  "无法打开或读取文件",
+		}
+	}
+
+	archive := newEpubArchive(filePath, zr)
+	for _, stage := range v.stages {
+		if err := stage.Check(archive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
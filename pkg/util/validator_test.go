@@ -0,0 +1,127 @@
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultValidator_ValidEpub(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, path, testEpubEntries("三体"))
+
+	if err := DefaultValidator().Validate(path); err != nil {
+		t.Errorf("期望检测通过，得到 %v", err)
+	}
+}
+
+func TestDefaultValidator_MissingTitle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("")
+	entries[2][1] = "<package><metadata></metadata></package>"
+	writeTestEpub(t, path, entries)
+
+	err := DefaultValidator().Validate(path)
+	if GetErrorType(err) != ErrorTypeMetadata {
+		t.Errorf("期望 ErrorTypeMetadata，得到 %v", err)
+	}
+}
+
+func TestDefaultValidator_MissingOpf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries = append(entries[:2], entries[3:]...)
+	writeTestEpub(t, path, entries)
+
+	err := DefaultValidator().Validate(path)
+	if GetErrorType(err) != ErrorTypeFormat {
+		t.Errorf("期望 ErrorTypeFormat，得到 %v", err)
+	}
+}
+
+func TestValidator_CustomStages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, path, testEpubEntries("三体"))
+
+	var order []string
+	var archives []*EpubArchive
+	stage := func(name string) ValidationStage {
+		return ValidationStage{Name: name, Check: func(a *EpubArchive) error {
+			order = append(order, name)
+			archives = append(archives, a)
+			return nil
+		}}
+	}
+
+	v := NewValidator(stage("first"), stage("second"))
+	v.AddStage(ValidationStage{Name: "title", Check: func(a *EpubArchive) error {
+		opf, err := a.Opf()
+		if err != nil {
+			return err
+		}
+		if opf.Metadata.Title[0] != "三体" {
+			return errors.New("标题不匹配")
+		}
+		return nil
+	}})
+
+	if len(v.Stages()) != 3 {
+		t.Fatalf("阶段数量 = %d，期望 3", len(v.Stages()))
+	}
+	if err := v.Validate(path); err != nil {
+		t.Fatalf("期望检测通过，得到 %v", err)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("阶段执行顺序 = %v", order)
+	}
+	if archives[0] != archives[1] {
+		t.Error("各阶段应共享同一个已打开的归档")
+	}
+}
+
+func TestValidator_StopsAtFirstError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeTestEpub(t, path, testEpubEntries("三体"))
+
+	failure := &EpubError{Type: ErrorTypeFormat, Message: "自定义错误"}
+	called := false
+	v := NewValidator(
+		ValidationStage{Name: "fail", Check: func(a *EpubArchive) error { return failure }},
+		ValidationStage{Name: "never", Check: func(a *EpubArchive) error {
+			called = true
+			return nil
+		}},
+	)
+
+	if err := v.Validate(path); err != failure {
+		t.Errorf("期望返回第一个阶段的错误，得到 %v", err)
+	}
+	if called {
+		t.Error("出错后不应继续执行后续阶段")
+	}
+}
+
+func TestEpubArchive_FileWithDotSlashPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	entries := testEpubEntries("三体")
+	entries[1][0] = "./META-INF/container.xml"
+	writeTestEpub(t, path, entries)
+
+	v := NewValidator(ValidationStage{Name: "container", Check: func(a *EpubArchive) error {
+		if a.File("META-INF/container.xml") == nil {
+			return errors.New("未找到 container.xml")
+		}
+		opfPath, err := a.OpfPath()
+		if err != nil {
+			return err
+		}
+		if opfPath != "OEBPS/content.opf" {
+			return errors.New("OPF 路径不正确: " + opfPath)
+		}
+		return nil
+	}})
+
+	if err := v.Validate(path); err != nil {
+		t.Error(err)
+	}
+}